@@ -0,0 +1,80 @@
+package wikicrawl
+
+import (
+	"bytes"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+
+	"golang.org/x/net/html"
+)
+
+// Problems of a page a screen reader user runs into: images without alt
+// text and links without any text to announce. Images with an empty alt
+// are decorative and accepted.
+func AccessibilityFindings(reader io.Reader, page string) []Finding {
+	var findings []Finding
+	var anchor *html.Token
+	var text string
+
+	z := html.NewTokenizer(reader)
+	for {
+		tokenType := z.Next()
+		if tokenType == html.ErrorToken {
+			return findings
+		}
+
+		token := z.Token()
+		switch {
+		case (tokenType == html.StartTagToken || tokenType == html.SelfClosingTagToken) && token.Data == "img":
+			alt, found := attrFound(token, "alt")
+			if !found {
+				findings = append(findings, Finding{Page: page, Check: "img-alt", Problem: "missing alt text: " + attr(token, "src")})
+			}
+			text += alt
+		case tokenType == html.StartTagToken && token.Data == "a":
+			if _, found := attrFound(token, "href"); found {
+				anchor, text = &token, attr(token, "aria-label")+attr(token, "title")
+			}
+		case tokenType == html.TextToken:
+			text += token.Data
+		case tokenType == html.EndTagToken && token.Data == "a" && anchor != nil:
+			if len(strings.TrimSpace(text)) == 0 {
+				findings = append(findings, Finding{Page: page, Check: "link-text", Problem: "empty link text: " + attr(*anchor, "href")})
+			}
+			anchor = nil
+		}
+	}
+}
+
+// Processor collecting the accessibility findings of every crawled page,
+// flagging the pages having any.
+type AccessibilityProcessor struct {
+	sync.Mutex
+
+	findings []Finding
+}
+
+func (ap *AccessibilityProcessor) Process(page Link, body []byte) bool {
+	findings := AccessibilityFindings(bytes.NewReader(body), page.String())
+
+	ap.Lock()
+	defer ap.Unlock()
+	ap.findings = append(ap.findings, findings...)
+
+	return len(findings) > 0
+}
+
+// Findings collected so far, ordered by page.
+func (ap *AccessibilityProcessor) Findings() []Finding {
+	ap.Lock()
+	defer ap.Unlock()
+
+	findings := append([]Finding(nil), ap.findings...)
+	sort.SliceStable(findings, func(i, j int) bool {
+		return findings[i].Page < findings[j].Page
+	})
+
+	return findings
+}
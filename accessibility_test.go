@@ -0,0 +1,60 @@
+package wikicrawl
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestAccessibilityFindings(t *testing.T) {
+	t.Run("Images without alt and empty links", func(t *testing.T) {
+		t.Parallel()
+		page := `<html><body>
+<img src="/photo.jpg"><img src="/spacer.gif" alt="">
+<a href="/wiki/A">A</a><a href="/wiki/B"> </a><a href="/wiki/C"><img src="/c.png" alt="C"></a>
+<a href="/wiki/D" aria-label="D"></a><a href="/wiki/E"><img src="/e.png"></a><a name="anchor"></a>
+</body></html>`
+		expected := []Finding{
+			{Page: "p", Check: "img-alt", Problem: "missing alt text: /photo.jpg"},
+			{Page: "p", Check: "link-text", Problem: "empty link text: /wiki/B"},
+			{Page: "p", Check: "img-alt", Problem: "missing alt text: /e.png"},
+			{Page: "p", Check: "link-text", Problem: "empty link text: /wiki/E"},
+		}
+		if found := AccessibilityFindings(strings.NewReader(page), "p"); !reflect.DeepEqual(found, expected) {
+			t.Errorf("Findings mismatch, got: %v, want: %v.", found, expected)
+		}
+	})
+}
+
+func TestAccessibilityProcessor(t *testing.T) {
+	t.Run("Collect findings across the crawl", func(t *testing.T) {
+		t.Parallel()
+		server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			switch req.URL.Path {
+			case "/":
+				fmt.Fprint(rw, `<html><a href="/a">a</a><a href="/b">b</a></html>`)
+			case "/a":
+				fmt.Fprint(rw, `<html><img src="/logo.png"></html>`)
+			default:
+				fmt.Fprint(rw, `<html><img src="/logo.png" alt="Logo"></html>`)
+			}
+		}))
+		defer server.Close()
+
+		processor := new(AccessibilityProcessor)
+		c := NewCrawler(server.URL)
+		c.Processors = append(c.Processors, processor)
+		result := c.Crawl(server.URL + "/")
+
+		expected := []Finding{{Page: server.URL + "/a", Check: "img-alt", Problem: "missing alt text: /logo.png"}}
+		if found := processor.Findings(); !reflect.DeepEqual(found, expected) {
+			t.Errorf("Findings mismatch, got: %v, want: %v.", found, expected)
+		}
+		if found, want := result.Flagged.Keys(), []string{server.URL + "/a"}; !reflect.DeepEqual(found, want) {
+			t.Errorf("Flagged mismatch, got: %v, want: %v.", found, want)
+		}
+	})
+}
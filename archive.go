@@ -0,0 +1,155 @@
+package wikicrawl
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// Default Internet Archive availability API.
+const waybackAvailability = "https://archive.org/wayback/available"
+
+// Finds archived copies of dead external links with the Wayback Machine
+// availability API. Endpoint defaults to the public archive.org API.
+type ArchiveLookup struct {
+	Client   *http.Client
+	Endpoint string
+}
+
+// Simple constructor for ArchiveLookup type.
+func NewArchiveLookup() *ArchiveLookup {
+	return &ArchiveLookup{
+		Client:   &http.Client{Timeout: 30 * time.Second},
+		Endpoint: waybackAvailability,
+	}
+}
+
+// Snapshot of a page kept by the archive.
+type Snapshot struct {
+	URL       string `json:"url"`
+	Timestamp string `json:"timestamp"`
+	Status    string `json:"status"`
+	Available bool   `json:"available"`
+}
+
+// Nearest archived snapshot of link, nil when the archive has none.
+func (al *ArchiveLookup) Closest(link string) (*Snapshot, error) {
+	var availability struct {
+		Snapshots struct {
+			Closest *Snapshot `json:"closest"`
+		} `json:"archived_snapshots"`
+	}
+
+	query := url.Values{"url": {link}}
+	if err := getJSON(al.Client, al.Endpoint+"?"+query.Encode(), &availability); err != nil {
+		return nil, err
+	}
+
+	closest := availability.Snapshots.Closest
+	if closest == nil || !closest.Available {
+		return nil, nil
+	}
+
+	return closest, nil
+}
+
+// Records the nearest archived snapshot of every broken external link in
+// the page metadata of the result.
+func (al *ArchiveLookup) Annotate(result *CrawlResult) {
+	for _, link := range result.Broken.Values() {
+		if link.Class != ExternalLink {
+			continue
+		}
+
+		snapshot, err := al.Closest(link.String())
+		if err != nil {
+			log.WithFields(result.Metadata.logFields()).WithFields(log.Fields{
+				"link": link,
+				"err":  err,
+			}).Warn("Archive lookup failed")
+			continue
+		}
+
+		if snapshot != nil {
+			result.record(link, func(info *PageInfo) {
+				info.Archive = snapshot.URL
+			})
+		}
+	}
+}
+
+// Replacement of a dead link by its archived snapshot.
+//
+//  1. Broken: The dead link.
+//  2. Replacement: Archived snapshot to link to instead.
+//  3. Pages: Wiki pages containing the dead link.
+type SuggestedFix struct {
+	Broken      string      `json:"broken"`
+	Replacement string      `json:"replacement"`
+	Pages       []FixedPage `json:"pages"`
+}
+
+// Wiki page needing an edit, Title is empty for pages without one.
+type FixedPage struct {
+	URL   string `json:"url"`
+	Title string `json:"title,omitempty"`
+}
+
+// Lists broken links with a known archived snapshot, together with the
+// pages to edit. Run ArchiveLookup.Annotate first.
+func (cr *CrawlResult) SuggestedFixes() []SuggestedFix {
+	var fixes []SuggestedFix
+	for _, key := range cr.Broken.Keys() {
+		info, found := cr.Pages.Get(key)
+		if !found || len(info.Archive) == 0 {
+			continue
+		}
+
+		fix := SuggestedFix{Broken: key, Replacement: info.Archive}
+		for _, referrer := range info.Referrers {
+			page := FixedPage{URL: referrer}
+			if source, found := cr.Pages.Get(referrer); found {
+				page.Title = source.Link.Title
+			}
+			fix.Pages = append(fix.Pages, page)
+		}
+		fixes = append(fixes, fix)
+	}
+
+	return fixes
+}
+
+// Citations of broken links found in wikitext, crawled with Wikitext or
+// from a dump.
+func (cr *CrawlResult) DeadCitations() []Citation {
+	var citations []Citation
+	for _, key := range cr.Broken.Keys() {
+		if info, found := cr.Pages.Get(key); found {
+			citations = append(citations, info.Citations...)
+		}
+	}
+
+	return citations
+}
+
+// Suggested fixes file, for bots applying the edits.
+type SuggestedFixes struct {
+	Metadata Metadata       `json:"metadata"`
+	Fixes    []SuggestedFix `json:"fixes"`
+}
+
+// Writes the suggested fixes as JSON.
+func (cr *CrawlResult) WriteSuggestedFixes(writer io.Writer) error {
+	file := SuggestedFixes{Metadata: cr.Metadata, Fixes: cr.SuggestedFixes()}
+	if file.Fixes == nil {
+		file.Fixes = []SuggestedFix{}
+	}
+
+	encoder := json.NewEncoder(writer)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(file)
+}
@@ -0,0 +1,89 @@
+package wikicrawl
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestArchiveLookup(t *testing.T) {
+	t.Run("Wayback Machine availability", func(t *testing.T) {
+		archive := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			if req.URL.Query().Get("url") != "http://dead.com/page" {
+				fmt.Fprint(rw, `{"archived_snapshots": {}}`)
+				return
+			}
+			fmt.Fprint(rw, `{"archived_snapshots": {"closest": {"available": true, "status": "200",
+				"url": "http://web.archive.org/web/2013/http://dead.com/page", "timestamp": "20130919044612"}}}`)
+		}))
+		defer archive.Close()
+
+		lookup := NewArchiveLookup()
+		lookup.Endpoint = archive.URL
+
+		t.Run("Closest snapshot", func(t *testing.T) {
+			snapshot, err := lookup.Closest("http://dead.com/page")
+			if err != nil || snapshot == nil || snapshot.Timestamp != "20130919044612" {
+				t.Errorf("Snapshot mismatch, got: %+v, %v.", snapshot, err)
+			}
+
+			if snapshot, _ := lookup.Closest("http://never.com"); snapshot != nil {
+				t.Errorf("Snapshot mismatch, got: %+v, want: nil.", snapshot)
+			}
+		})
+
+		t.Run("Annotate broken external links", func(t *testing.T) {
+			result := NewCrawlResult()
+			dead := NewLink("http://dead.com/page")
+			dead.Class = ExternalLink
+			result.Broken.Add(dead)
+			result.Broken.Add(NewLink("http://testing.com/internal"))
+			lookup.Annotate(result)
+
+			info, _ := result.Pages.Get("http://dead.com/page")
+			expected := "http://web.archive.org/web/2013/http://dead.com/page"
+			if info.Archive != expected {
+				t.Errorf("Archive mismatch, got: %s, want: %s.", info.Archive, expected)
+			}
+
+			if result.Pages.Contains("http://testing.com/internal") {
+				t.Errorf("Internal links should not be looked up.")
+			}
+		})
+
+		t.Run("Suggested fixes", func(t *testing.T) {
+			result := NewCrawlResult()
+			source := NewLink("http://testing.com/index.php?title=Sources")
+			result.record(source, func(info *PageInfo) {})
+
+			dead := NewLink("http://dead.com/page")
+			dead.Class = ExternalLink
+			result.Broken.Add(dead)
+			result.record(dead, func(info *PageInfo) {
+				info.AddReferrer(source.String())
+			})
+			lookup.Annotate(result)
+
+			var buffer bytes.Buffer
+			if err := result.WriteSuggestedFixes(&buffer); err != nil {
+				t.Fatalf("Writing fixes failed: %s.", err)
+			}
+
+			var file SuggestedFixes
+			json.Unmarshal(buffer.Bytes(), &file)
+			fixes := file.Fixes
+			expected := []SuggestedFix{{
+				Broken:      "http://dead.com/page",
+				Replacement: "http://web.archive.org/web/2013/http://dead.com/page",
+				Pages:       []FixedPage{{URL: source.String(), Title: "Sources"}},
+			}}
+			if !reflect.DeepEqual(fixes, expected) {
+				t.Errorf("Suggested fixes mismatch, got: %+v, want: %+v.", fixes, expected)
+			}
+		})
+	})
+}
@@ -0,0 +1,45 @@
+package wikicrawl
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Content every page matching a url pattern must contain.
+type Assertion struct {
+	URL     *regexp.Regexp
+	Content *regexp.Regexp
+}
+
+// Parses an assertion written as "<url regexp>::<content regexp>".
+// The last "::" separates the patterns so namespaced titles stay intact.
+func ParseAssertion(raw string) (Assertion, error) {
+	split := strings.LastIndex(raw, "::")
+	if split < 0 {
+		return Assertion{}, fmt.Errorf("assertion %q missing :: separator", raw)
+	}
+
+	link, err := regexp.Compile(raw[:split])
+	if err != nil {
+		return Assertion{}, err
+	}
+
+	content, err := regexp.Compile(raw[split+2:])
+	if err != nil {
+		return Assertion{}, err
+	}
+
+	return Assertion{URL: link, Content: content}, nil
+}
+
+// Reports if the assertion applies to the page.
+func (a Assertion) Applies(page Link) bool {
+	return a.URL.MatchString(page.String())
+}
+
+// Reports if the visible text of the page body satisfies the assertion.
+func (a Assertion) Holds(body []byte) bool {
+	return a.Content.MatchString(VisibleText(bytes.NewReader(body)))
+}
@@ -0,0 +1,57 @@
+package wikicrawl
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParseAssertion(t *testing.T) {
+	t.Run("Parse content assertions", func(t *testing.T) {
+		t.Run("Namespaced url pattern", func(t *testing.T) {
+			t.Parallel()
+			assertion, err := ParseAssertion("title=Policy:::Approved by")
+			if err != nil {
+				t.Fatalf("Parsing assertion failed: %s.", err)
+			}
+
+			if assertion.URL.String() != "title=Policy:" || assertion.Content.String() != "Approved by" {
+				t.Errorf("Assertion mismatch, got: %s, %s.", assertion.URL, assertion.Content)
+			}
+		})
+
+		t.Run("Missing separator", func(t *testing.T) {
+			t.Parallel()
+			if _, err := ParseAssertion("Approved by"); err == nil {
+				t.Errorf("Assertion without separator should fail.")
+			}
+		})
+	})
+}
+
+func TestCrawlAssertions(t *testing.T) {
+	t.Run("Report pages failing assertions", func(t *testing.T) {
+		t.Parallel()
+		server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			switch req.URL.Path {
+			case "/policy/good":
+				fmt.Fprintf(rw, `<html><body>Approved by the board.</body></html>`)
+			case "/policy/bad":
+				fmt.Fprintf(rw, `<html><body>Draft</body></html>`)
+			default:
+				fmt.Fprintf(rw, `<html><body><a href="/policy/good" /><a href="/policy/bad" /></body></html>`)
+			}
+		}))
+		defer server.Close()
+
+		assertion, _ := ParseAssertion("/policy/::Approved by")
+		c := NewCrawler(server.URL)
+		c.Assertions = append(c.Assertions, assertion)
+		result := c.Crawl(server.URL)
+
+		if len(result.AssertionFailures.Set) != 1 || !result.AssertionFailures.Contains(server.URL+"/policy/bad") {
+			t.Errorf("Assertion failures mismatch, got: %v.", result.AssertionFailures.Set)
+		}
+	})
+}
@@ -0,0 +1,128 @@
+package wikicrawl
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// Obtains a fresh wiki session for the crawler's http client.
+type Authenticator interface {
+	Login(client *http.Client, base *url.URL) error
+}
+
+// Logs in through the MediaWiki API with a bot or user password.
+// API defaults to api.php next to the wiki base url.
+type MediaWikiLogin struct {
+	API      string
+	Username string
+	Password string
+}
+
+func (ml *MediaWikiLogin) Login(client *http.Client, base *url.URL) error {
+	api := ml.API
+	if len(api) == 0 {
+		api = base.ResolveReference(&url.URL{Path: "api.php"}).String()
+	}
+
+	var tokens struct {
+		Query struct {
+			Tokens struct {
+				LoginToken string `json:"logintoken"`
+			} `json:"tokens"`
+		} `json:"query"`
+	}
+	query := url.Values{"action": {"query"}, "meta": {"tokens"}, "type": {"login"}, "format": {"json"}}
+	if err := getJSON(client, api+"?"+query.Encode(), &tokens); err != nil {
+		return err
+	}
+
+	var login struct {
+		Login struct {
+			Result string `json:"result"`
+			Reason string `json:"reason"`
+		} `json:"login"`
+	}
+	form := url.Values{
+		"action":     {"login"},
+		"lgname":     {ml.Username},
+		"lgpassword": {ml.Password},
+		"lgtoken":    {tokens.Query.Tokens.LoginToken},
+		"format":     {"json"},
+	}
+	resp, err := client.PostForm(api, form)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if err := json.NewDecoder(resp.Body).Decode(&login); err != nil {
+		return err
+	}
+
+	if login.Login.Result != "Success" {
+		return fmt.Errorf("login failed: %s %s", login.Login.Result, login.Login.Reason)
+	}
+
+	return nil
+}
+
+func getJSON(client *http.Client, link string, target interface{}) error {
+	resp, err := client.Get(link)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return fmt.Errorf("GET %s returned %s", link, resp.Status)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(target)
+}
+
+// Serializes logins so concurrent workers hitting an expired session only
+// trigger a single new login.
+type sessionGuard struct {
+	sync.Mutex
+
+	generation int
+}
+
+// Runs the configured Authenticator.
+func (c *Crawler) Login() error {
+	if c.Authenticator == nil {
+		return fmt.Errorf("no authenticator configured")
+	}
+
+	return c.Authenticator.Login(c.Client, c.base)
+}
+
+// Current session generation, captured before fetching a page.
+func (c *Crawler) sessionGeneration() int {
+	c.session.Lock()
+	defer c.session.Unlock()
+	return c.session.generation
+}
+
+// Logs in again unless another worker already did since generation.
+func (c *Crawler) refreshSession(generation int) error {
+	c.session.Lock()
+	defer c.session.Unlock()
+
+	if c.session.generation != generation {
+		return nil
+	}
+
+	c.logger().WithFields(log.Fields{"base": c.base.String()}).Warn("Session expired, logging in again")
+	if err := c.Login(); err != nil {
+		return err
+	}
+	c.session.generation++
+
+	return nil
+}
@@ -0,0 +1,107 @@
+package wikicrawl
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+// Minimal wiki requiring a login, sessions expire after a number of pages.
+type fakeWiki struct {
+	sync.Mutex
+
+	logins    int
+	session   string
+	pageViews int
+	expireAt  int
+}
+
+func (fw *fakeWiki) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	fw.Lock()
+	defer fw.Unlock()
+
+	switch {
+	case req.URL.Path == "/api.php" && req.Method == "GET":
+		fmt.Fprintf(rw, `{"query":{"tokens":{"logintoken":"token+\\"}}}`)
+	case req.URL.Path == "/api.php":
+		if req.FormValue("lgpassword") != "secret" || req.FormValue("lgtoken") != `token+\` {
+			fmt.Fprintf(rw, `{"login":{"result":"Failed","reason":"bad password"}}`)
+			return
+		}
+		fw.logins++
+		fw.session = fmt.Sprintf("session%d", fw.logins)
+		http.SetCookie(rw, &http.Cookie{Name: "wiki_session", Value: fw.session, Path: "/"})
+		fmt.Fprintf(rw, `{"login":{"result":"Success"}}`)
+	case req.URL.Path == "/index.php":
+		fmt.Fprintf(rw, `<html><body><form><input name="wpPassword"></form></body></html>`)
+	default:
+		cookie, err := req.Cookie("wiki_session")
+		if err != nil || cookie.Value != fw.session {
+			http.Redirect(rw, req, "/index.php?title=Special:UserLogin", http.StatusFound)
+			return
+		}
+
+		fw.pageViews++
+		if fw.pageViews == fw.expireAt {
+			fw.session = "expired"
+		}
+		fmt.Fprintf(rw, `<html><body><a href="/a" /><a href="/b" /><a href="/c" /></body></html>`)
+	}
+}
+
+func TestMediaWikiLogin(t *testing.T) {
+	t.Run("Log in through the MediaWiki API", func(t *testing.T) {
+		t.Run("Successful login", func(t *testing.T) {
+			t.Parallel()
+			wiki := &fakeWiki{}
+			server := httptest.NewServer(wiki)
+			defer server.Close()
+
+			c := NewCrawler(server.URL)
+			c.Authenticator = &MediaWikiLogin{Username: "Crawler", Password: "secret"}
+			if err := c.Login(); err != nil {
+				t.Errorf("Login failed: %s.", err)
+			}
+		})
+
+		t.Run("Wrong password", func(t *testing.T) {
+			t.Parallel()
+			server := httptest.NewServer(&fakeWiki{})
+			defer server.Close()
+
+			c := NewCrawler(server.URL)
+			c.Authenticator = &MediaWikiLogin{Username: "Crawler", Password: "wrong"}
+			if err := c.Login(); err == nil {
+				t.Errorf("Login with wrong password should fail.")
+			}
+		})
+	})
+}
+
+func TestSessionRefresh(t *testing.T) {
+	t.Run("Log in again when session expires", func(t *testing.T) {
+		t.Parallel()
+		wiki := &fakeWiki{expireAt: 2}
+		server := httptest.NewServer(wiki)
+		defer server.Close()
+
+		c := NewCrawler(server.URL)
+		c.Workers = 1
+		c.Authenticator = &MediaWikiLogin{Username: "Crawler", Password: "secret"}
+		result := c.Crawl(server.URL)
+
+		if len(result.AccessDenied.Set) != 0 {
+			t.Errorf("Pages should be retried after logging in, got: %v.", result.AccessDenied.Set)
+		}
+
+		if len(result.Visited.Set) != 4 {
+			t.Errorf("Visited links mismatch, got: %v.", result.Visited.Set)
+		}
+
+		if wiki.logins != 2 {
+			t.Errorf("Expected a login per expired session, got: %d.", wiki.logins)
+		}
+	})
+}
@@ -0,0 +1,154 @@
+package wikicrawl
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"sync"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// Wiki crawled as part of a batch, with the settings differing between
+// wikis. Other settings are shared by every crawl of the batch.
+//
+//  1. Name: Short name of the wiki, labelling its run and naming its
+//     report. Defaults to the host of the url.
+//  2. URL: Url of the wiki, also the seed of its crawl.
+//  3. Profile: Politeness preset of the crawl, defaults to the shared one.
+//  4. Namespaces and PathPrefix: Scope of the crawl, as for the crawler.
+//  5. Labels: Labels added to the run of the wiki.
+type BatchWiki struct {
+	Name       string            `json:"name"`
+	URL        string            `json:"url"`
+	Profile    string            `json:"profile,omitempty"`
+	Namespaces []string          `json:"namespaces,omitempty"`
+	PathPrefix string            `json:"pathPrefix,omitempty"`
+	Labels     map[string]string `json:"labels,omitempty"`
+}
+
+// Reads the wikis of a batch written as a JSON array, e.g.
+// [{"name": "docs", "url": "https://docs.example.com/wiki/Main_Page"}].
+func ReadBatch(reader io.Reader) ([]BatchWiki, error) {
+	var wikis []BatchWiki
+	if err := json.NewDecoder(reader).Decode(&wikis); err != nil {
+		return nil, err
+	}
+
+	names := make(map[string]bool)
+	for i, wiki := range wikis {
+		parsed, err := url.Parse(wiki.URL)
+		if err != nil || len(parsed.Host) == 0 {
+			return nil, fmt.Errorf("wiki %d has no valid url: %q", i, wiki.URL)
+		}
+		if len(wiki.Name) == 0 {
+			wikis[i].Name = parsed.Host
+		}
+		if names[wikis[i].Name] {
+			return nil, fmt.Errorf("wiki name %q is used twice", wikis[i].Name)
+		}
+		names[wikis[i].Name] = true
+
+		if _, found := Profiles[wiki.Profile]; len(wiki.Profile) > 0 && !found {
+			return nil, fmt.Errorf("unknown profile %q of wiki %s", wiki.Profile, wikis[i].Name)
+		}
+	}
+
+	return wikis, nil
+}
+
+// Crawls of several wikis running concurrently.
+//
+//  1. Parallel: Wikis crawled at the same time, one by one when unset.
+//  2. NewCrawler: Builds the crawler of a wiki with the shared settings,
+//     the settings of the wiki are applied on top.
+type Batch struct {
+	Wikis      []BatchWiki
+	Parallel   int
+	NewCrawler func(wiki BatchWiki) *Crawler
+}
+
+// Result of a wiki of a batch.
+type BatchResult struct {
+	Wiki   BatchWiki
+	Result *CrawlResult
+}
+
+// Crawls every wiki, returning the results in the order of the wikis.
+func (b Batch) Run() []BatchResult {
+	parallel := b.Parallel
+	if parallel < 1 {
+		parallel = 1
+	}
+
+	results := make([]BatchResult, len(b.Wikis))
+	slots := make(chan struct{}, parallel)
+	var wg sync.WaitGroup
+	for i, wiki := range b.Wikis {
+		wg.Add(1)
+		go func(i int, wiki BatchWiki) {
+			defer wg.Done()
+			slots <- struct{}{}
+			defer func() { <-slots }()
+
+			results[i] = BatchResult{Wiki: wiki, Result: b.crawler(wiki).Crawl(wiki.URL)}
+		}(i, wiki)
+	}
+	wg.Wait()
+
+	return results
+}
+
+// Crawler of a wiki, with the settings of the wiki applied.
+func (b Batch) crawler(wiki BatchWiki) *Crawler {
+	c := b.NewCrawler(wiki)
+	if profile, found := Profiles[wiki.Profile]; found {
+		c.ApplyProfile(profile)
+	}
+	if len(wiki.Namespaces) > 0 {
+		c.InNamespaces = wiki.Namespaces
+	}
+	if len(wiki.PathPrefix) > 0 {
+		c.PathPrefix = wiki.PathPrefix
+	}
+
+	WithLogFields(log.Fields{"wiki": wiki.Name})(c)
+	WithLabel("wiki", wiki.Name)(c)
+	for key, value := range wiki.Labels {
+		WithLabel(key, value)(c)
+	}
+
+	return c
+}
+
+// Renders the combined summary of a batch as a text table, one wiki per
+// line followed by the totals.
+func WriteBatchSummary(writer io.Writer, results []BatchResult) error {
+	if _, err := fmt.Fprintf(writer, "%-20s %8s %8s %8s %7s  %s\n", "Wiki", "Pages", "Visited", "Broken", "Rate", "Severity"); err != nil {
+		return err
+	}
+
+	var pages, visited, broken int
+	worst := SeverityOK
+	for _, batch := range results {
+		result := batch.Result
+		pages, visited, broken = pages+result.Pages.Len(), visited+result.Visited.Len(), broken+result.Broken.Len()
+		if result.Severity() > worst {
+			worst = result.Severity()
+		}
+
+		_, err := fmt.Fprintf(writer, "%-20s %8d %8d %8d %6.2f%%  %s\n", batch.Wiki.Name,
+			result.Pages.Len(), result.Visited.Len(), result.Broken.Len(), result.BrokenRate()*100, result.Severity())
+		if err != nil {
+			return err
+		}
+	}
+
+	rate := 0.0
+	if visited > 0 {
+		rate = float64(broken) / float64(visited)
+	}
+	_, err := fmt.Fprintf(writer, "%-20s %8d %8d %8d %6.2f%%  %s\n", "Total", pages, visited, broken, rate*100, worst)
+	return err
+}
@@ -0,0 +1,98 @@
+package wikicrawl
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestReadBatch(t *testing.T) {
+	t.Run("Read batch wikis", func(t *testing.T) {
+		t.Run("Default names", func(t *testing.T) {
+			t.Parallel()
+			wikis, err := ReadBatch(strings.NewReader(`[{"url": "http://docs.testing.com/wiki"}, {"name": "ops", "url": "http://ops.testing.com/wiki", "profile": "gentle"}]`))
+			if err != nil {
+				t.Fatalf("Reading batch failed: %s.", err)
+			}
+
+			if len(wikis) != 2 || wikis[0].Name != "docs.testing.com" || wikis[1].Name != "ops" || wikis[1].Profile != "gentle" {
+				t.Errorf("Wikis mismatch, got: %+v.", wikis)
+			}
+		})
+
+		t.Run("Invalid wikis", func(t *testing.T) {
+			t.Parallel()
+			for _, raw := range []string{
+				`[{"name": "docs"}]`,
+				`[{"url": "http://testing.com/a"}, {"url": "http://testing.com/b"}]`,
+				`[{"url": "http://testing.com", "profile": "reckless"}]`,
+			} {
+				if _, err := ReadBatch(strings.NewReader(raw)); err == nil {
+					t.Errorf("Reading %s should fail.", raw)
+				}
+			}
+		})
+	})
+}
+
+func TestBatch(t *testing.T) {
+	t.Run("Crawl several wikis", func(t *testing.T) {
+		t.Parallel()
+		var servers []*httptest.Server
+		for _, broken := range []bool{false, true} {
+			broken := broken
+			server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				switch req.URL.Path {
+				case "/wiki":
+					fmt.Fprint(rw, `<html><a href="/wiki/a">a</a><a href="/wiki/b">b</a></html>`)
+				case "/wiki/b":
+					if broken {
+						http.NotFound(rw, req)
+						return
+					}
+					fmt.Fprint(rw, `<html></html>`)
+				default:
+					fmt.Fprint(rw, `<html></html>`)
+				}
+			}))
+			defer server.Close()
+			servers = append(servers, server)
+		}
+
+		batch := Batch{
+			Wikis: []BatchWiki{
+				{Name: "healthy", URL: servers[0].URL + "/wiki"},
+				{Name: "broken", URL: servers[1].URL + "/wiki", Labels: map[string]string{"env": "prod"}},
+			},
+			Parallel: 2,
+			NewCrawler: func(wiki BatchWiki) *Crawler {
+				return NewCrawler(wiki.URL, WithLabel("team", "docs"))
+			},
+		}
+		results := batch.Run()
+
+		if len(results) != 2 || results[0].Wiki.Name != "healthy" || results[1].Wiki.Name != "broken" {
+			t.Fatalf("Results mismatch, got: %+v.", results)
+		}
+		if results[0].Result.Broken.Len() != 0 || results[1].Result.Broken.Len() != 1 {
+			t.Errorf("Broken links mismatch, got: %v and %v.", results[0].Result.Broken.Keys(), results[1].Result.Broken.Keys())
+		}
+
+		labels := results[1].Result.Metadata.Labels
+		if labels["wiki"] != "broken" || labels["env"] != "prod" || labels["team"] != "docs" {
+			t.Errorf("Labels mismatch, got: %v, want: map[env:prod team:docs wiki:broken].", labels)
+		}
+
+		var summary bytes.Buffer
+		if err := WriteBatchSummary(&summary, results); err != nil {
+			t.Fatalf("Writing summary failed: %s.", err)
+		}
+		lines := strings.Split(strings.TrimSpace(summary.String()), "\n")
+		if len(lines) != 4 || !strings.HasPrefix(lines[3], "Total") || !strings.HasSuffix(lines[3], "warning") {
+			t.Errorf("Summary mismatch, got: %s.", summary.String())
+		}
+	})
+}
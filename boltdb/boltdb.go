@@ -0,0 +1,282 @@
+// Package boltdb keeps crawl runs in a BoltDB file, a pure Go store for
+// single binary deployments.
+package boltdb
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+	"jalandis.com/wikicrawl"
+)
+
+var (
+	runsBucket  = []byte("runs")
+	pagesBucket = []byte("pages")
+	edgesBucket = []byte("edges")
+)
+
+// Store of crawl runs in a BoltDB file. Every run has its own bucket
+// holding the pages, edges and link sets of the result.
+type Store struct {
+	db *bolt.DB
+}
+
+var _ wikicrawl.Store = (*Store)(nil)
+
+// Opens or creates the store at path.
+func Open(path string) (*Store, error) {
+	db, err := bolt.Open(path, 0644, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(runsBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &Store{db: db}, nil
+}
+
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+func runBucket(run string) []byte {
+	return []byte("run:" + run)
+}
+
+func setBucket(set string) []byte {
+	return []byte("set:" + set)
+}
+
+// Saves the result under run, replacing a run saved with the same id.
+func (s *Store) SaveRun(run string, result *wikicrawl.CrawlResult) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		if tx.Bucket(runBucket(run)) != nil {
+			if err := tx.DeleteBucket(runBucket(run)); err != nil {
+				return err
+			}
+		}
+		bucket, err := tx.CreateBucket(runBucket(run))
+		if err != nil {
+			return err
+		}
+
+		record, err := json.Marshal(wikicrawl.Run{ID: run, Metadata: result.Metadata, Aborted: result.Aborted})
+		if err != nil {
+			return err
+		}
+		if err := tx.Bucket(runsBucket).Put([]byte(run), record); err != nil {
+			return err
+		}
+
+		pages, err := bucket.CreateBucket(pagesBucket)
+		if err != nil {
+			return err
+		}
+		for _, info := range result.Pages.Values() {
+			if err := putJSON(pages, info.Link.String(), info); err != nil {
+				return err
+			}
+		}
+
+		edges, err := bucket.CreateBucket(edgesBucket)
+		if err != nil {
+			return err
+		}
+		for page, targets := range result.Edges() {
+			if err := putJSON(edges, page, targets); err != nil {
+				return err
+			}
+		}
+
+		for name, set := range result.LinkSets() {
+			links, err := bucket.CreateBucket(setBucket(name))
+			if err != nil {
+				return err
+			}
+			for _, link := range set.Values() {
+				if err := putJSON(links, link.String(), link); err != nil {
+					return err
+				}
+			}
+		}
+
+		return nil
+	})
+}
+
+func putJSON(bucket *bolt.Bucket, key string, value interface{}) error {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	return bucket.Put([]byte(key), encoded)
+}
+
+// Loads a saved run in memory.
+func (s *Store) LoadRun(run string) (*wikicrawl.CrawlResult, error) {
+	result := wikicrawl.NewCrawlResult()
+	err := s.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(runBucket(run))
+		if bucket == nil {
+			return fmt.Errorf("unknown run %q", run)
+		}
+
+		var record wikicrawl.Run
+		if err := json.Unmarshal(tx.Bucket(runsBucket).Get([]byte(run)), &record); err != nil {
+			return err
+		}
+		result.Metadata = record.Metadata
+		result.Aborted = record.Aborted
+
+		err := bucket.Bucket(pagesBucket).ForEach(func(key, value []byte) error {
+			var info wikicrawl.PageInfo
+			if err := json.Unmarshal(value, &info); err != nil {
+				return err
+			}
+			result.Pages.Put(info)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		for name, set := range result.LinkSets() {
+			links := bucket.Bucket(setBucket(name))
+			if links == nil {
+				continue
+			}
+			err := links.ForEach(func(key, value []byte) error {
+				var link wikicrawl.Link
+				if err := json.Unmarshal(value, &link); err != nil {
+					return err
+				}
+				set.Add(link)
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+
+	return result, err
+}
+
+// Saved runs, oldest first.
+func (s *Store) Runs() ([]wikicrawl.Run, error) {
+	var runs []wikicrawl.Run
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(runsBucket).ForEach(func(key, value []byte) error {
+			var run wikicrawl.Run
+			if err := json.Unmarshal(value, &run); err != nil {
+				return err
+			}
+			runs = append(runs, run)
+			return nil
+		})
+	})
+
+	sort.SliceStable(runs, func(i, j int) bool {
+		return runs[i].Metadata.Started.Before(runs[j].Metadata.Started)
+	})
+
+	return runs, err
+}
+
+// Urls linked from page in run.
+func (s *Store) Edges(run, page string) ([]string, error) {
+	var targets []string
+	err := s.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(runBucket(run))
+		if bucket == nil {
+			return fmt.Errorf("unknown run %q", run)
+		}
+
+		encoded := bucket.Bucket(edgesBucket).Get([]byte(page))
+		if encoded == nil {
+			return nil
+		}
+		return json.Unmarshal(encoded, &targets)
+	})
+
+	return targets, err
+}
+
+// Thin result of a saved run answering paginated queries from the store
+// without loading the run in memory.
+func (s *Store) Result(run string) *wikicrawl.CrawlResult {
+	return wikicrawl.NewStoredResult(runStore{db: s.db, run: run})
+}
+
+type runStore struct {
+	db  *bolt.DB
+	run string
+}
+
+func (rs runStore) set(tx *bolt.Tx, set string) (*bolt.Bucket, error) {
+	bucket := tx.Bucket(runBucket(rs.run))
+	if bucket == nil {
+		return nil, fmt.Errorf("unknown run %q", rs.run)
+	}
+
+	links := bucket.Bucket(setBucket(set))
+	if links == nil {
+		return nil, fmt.Errorf("unknown result set %q", set)
+	}
+
+	return links, nil
+}
+
+func (rs runStore) CountLinks(set string) (int, error) {
+	count := 0
+	err := rs.db.View(func(tx *bolt.Tx) error {
+		links, err := rs.set(tx, set)
+		if err != nil {
+			return err
+		}
+		count = links.Stats().KeyN
+		return nil
+	})
+
+	return count, err
+}
+
+func (rs runStore) LinkPage(set string, offset, limit int) ([]string, error) {
+	page := []string{}
+	if offset < 0 {
+		return page, nil
+	}
+
+	err := rs.db.View(func(tx *bolt.Tx) error {
+		links, err := rs.set(tx, set)
+		if err != nil {
+			return err
+		}
+
+		cursor := links.Cursor()
+		skipped := 0
+		for key, _ := cursor.First(); key != nil && (limit < 0 || len(page) < limit); key, _ = cursor.Next() {
+			if skipped < offset {
+				skipped++
+				continue
+			}
+			page = append(page, string(key))
+		}
+		return nil
+	})
+
+	return page, err
+}
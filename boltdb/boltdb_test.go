@@ -0,0 +1,95 @@
+package boltdb
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"jalandis.com/wikicrawl"
+)
+
+func testResult(seed string, started time.Time) *wikicrawl.CrawlResult {
+	result := wikicrawl.NewCrawlResult()
+	result.Metadata = wikicrawl.Metadata{Tool: "wikicrawl", Seed: seed, Started: started}
+	for _, link := range []string{"http://testing.com/", "http://testing.com/a", "http://testing.com/gone"} {
+		result.Visited.Add(wikicrawl.NewLink(link))
+	}
+	result.Broken.Add(wikicrawl.NewLink("http://testing.com/gone"))
+	result.Pages.Put(wikicrawl.PageInfo{Link: wikicrawl.NewLink("http://testing.com/a"), Status: 200, Referrers: []string{"http://testing.com/"}})
+	result.Pages.Put(wikicrawl.PageInfo{Link: wikicrawl.NewLink("http://testing.com/gone"), Status: 404, Referrers: []string{"http://testing.com/", "http://testing.com/a"}})
+	return result
+}
+
+func TestStore(t *testing.T) {
+	t.Run("Save and load runs", func(t *testing.T) {
+		t.Parallel()
+		store, err := Open(filepath.Join(t.TempDir(), "runs.db"))
+		if err != nil {
+			t.Fatalf("Open failed: %s.", err)
+		}
+		defer store.Close()
+
+		started := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+		saved := testResult("http://testing.com/", started)
+		if err := store.SaveRun("second", saved); err != nil {
+			t.Fatalf("Save failed: %s.", err)
+		}
+		if err := store.SaveRun("first", testResult("http://testing.com/", started.Add(-time.Hour))); err != nil {
+			t.Fatalf("Save failed: %s.", err)
+		}
+
+		runs, err := store.Runs()
+		if err != nil || len(runs) != 2 || runs[0].ID != "first" || runs[1].ID != "second" {
+			t.Errorf("Runs mismatch, got: %v, err: %v.", runs, err)
+		}
+
+		loaded, err := store.LoadRun("second")
+		if err != nil {
+			t.Fatalf("Load failed: %s.", err)
+		}
+		if !reflect.DeepEqual(loaded.Visited.Keys(), saved.Visited.Keys()) || !reflect.DeepEqual(loaded.Broken.Keys(), saved.Broken.Keys()) {
+			t.Errorf("Loaded sets mismatch, got: %v %v.", loaded.Visited.Keys(), loaded.Broken.Keys())
+		}
+		if info, _ := loaded.Pages.Get("http://testing.com/gone"); info.Status != 404 || len(info.Referrers) != 2 {
+			t.Errorf("Loaded page mismatch, got: %+v.", info)
+		}
+		if !loaded.Metadata.Started.Equal(started) {
+			t.Errorf("Loaded metadata mismatch, got: %v, want: %v.", loaded.Metadata.Started, started)
+		}
+
+		if _, err := store.LoadRun("missing"); err == nil {
+			t.Errorf("Loading an unknown run should fail.")
+		}
+	})
+
+	t.Run("Query edges and stored sets", func(t *testing.T) {
+		t.Parallel()
+		store, err := Open(filepath.Join(t.TempDir(), "runs.db"))
+		if err != nil {
+			t.Fatalf("Open failed: %s.", err)
+		}
+		defer store.Close()
+
+		if err := store.SaveRun("run", testResult("http://testing.com/", time.Now())); err != nil {
+			t.Fatalf("Save failed: %s.", err)
+		}
+
+		edges, err := store.Edges("run", "http://testing.com/")
+		if expected := []string{"http://testing.com/a", "http://testing.com/gone"}; err != nil || !reflect.DeepEqual(edges, expected) {
+			t.Errorf("Edges mismatch, got: %v, want: %v, err: %v.", edges, expected, err)
+		}
+
+		result := store.Result("run")
+		if count, err := result.Count("visited"); err != nil || count != 3 {
+			t.Errorf("Count mismatch, got: %d, want: %d, err: %v.", count, 3, err)
+		}
+		page, err := result.VisitedPage(1, 1)
+		if expected := []string{"http://testing.com/a"}; err != nil || !reflect.DeepEqual(page, expected) {
+			t.Errorf("Page mismatch, got: %v, want: %v, err: %v.", page, expected, err)
+		}
+		if _, err := result.Count("missing"); err == nil {
+			t.Errorf("Unknown set should fail.")
+		}
+	})
+}
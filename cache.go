@@ -0,0 +1,97 @@
+package wikicrawl
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// Sets describing a page itself, carried over when the page is reused.
+var pageSets = []string{"flagged", "parseErrors", "assertionFailures", "errorPages", "mixedContent", "smallPages"}
+
+// End of the freshness of a response fetched at fetched, per its
+// Cache-Control max-age or else its Expires header. Zero when the response
+// must not be reused without fetching it again.
+func FreshUntil(header http.Header, fetched time.Time) time.Time {
+	maxAge := -1
+	for _, directive := range strings.Split(header.Get("Cache-Control"), ",") {
+		directive = strings.ToLower(strings.TrimSpace(directive))
+		switch {
+		case directive == "no-store" || directive == "no-cache":
+			return time.Time{}
+		case strings.HasPrefix(directive, "max-age="):
+			if seconds, err := strconv.Atoi(strings.Trim(directive[len("max-age="):], `"`)); err == nil {
+				maxAge = seconds
+			}
+		}
+	}
+
+	if maxAge >= 0 {
+		age, _ := strconv.Atoi(header.Get("Age"))
+		if maxAge <= age {
+			return time.Time{}
+		}
+		return fetched.Add(time.Duration(maxAge-age) * time.Second)
+	}
+
+	expires, err := http.ParseTime(header.Get("Expires"))
+	if err != nil {
+		return time.Time{}
+	}
+	// Expires is set by the server clock, compare it to the Date header.
+	if date, err := http.ParseTime(header.Get("Date")); err == nil {
+		expires = fetched.Add(expires.Sub(date))
+	}
+	if !expires.After(fetched) {
+		return time.Time{}
+	}
+
+	return expires
+}
+
+// Takes a page still fresh in the previous run from it instead of fetching
+// it again, following the links it had then.
+func (c *Crawler) reuseFresh(queue *WorkQueue, source Link) bool {
+	if c.Previous == nil {
+		return false
+	}
+
+	key := source.String()
+	previous, found := c.Previous.Pages.Get(key)
+	if !found || previous.Status != 200 || !previous.Parsed || len(previous.RedirectTo) > 0 || !time.Now().Before(previous.FreshUntil) {
+		return false
+	}
+
+	c.logger().WithFields(log.Fields{
+		"source":     source,
+		"freshUntil": previous.FreshUntil,
+	}).Debug("Reusing fresh page of the previous run")
+	queue.Result.Fresh.Add(source)
+	queue.Result.record(source, func(info *PageInfo) {
+		info.Status = previous.Status
+		info.Parsed = true
+		info.LinkCount = previous.LinkCount
+		info.InternalLinks = previous.InternalLinks
+		info.ExternalLinks = previous.ExternalLinks
+		info.Size = previous.Size
+		info.FreshUntil = previous.FreshUntil
+		info.Findings = previous.Findings
+		info.MixedContent = previous.MixedContent
+		for name, value := range previous.Headers {
+			info.captureHeader(name, value)
+		}
+	})
+
+	sets, previousSets := queue.Result.LinkSets(), c.Previous.LinkSets()
+	for _, name := range pageSets {
+		if previousSets[name].Contains(key) {
+			sets[name].Add(source)
+		}
+	}
+
+	c.queueLinks(queue, source, c.previousLinks[key])
+	return true
+}
@@ -0,0 +1,89 @@
+package wikicrawl
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestFreshUntil(t *testing.T) {
+	t.Run("Freshness of responses", func(t *testing.T) {
+		fetched := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+
+		t.Run("Max age", func(t *testing.T) {
+			t.Parallel()
+			header := http.Header{"Cache-Control": {"public, max-age=600"}, "Age": {"100"}}
+			if until := FreshUntil(header, fetched); !until.Equal(fetched.Add(500 * time.Second)) {
+				t.Errorf("Freshness mismatch, got: %s, want: %s.", until, fetched.Add(500*time.Second))
+			}
+		})
+
+		t.Run("Expires relative to the server date", func(t *testing.T) {
+			t.Parallel()
+			header := http.Header{"Expires": {"Sat, 01 Jun 2024 13:00:00 GMT"}, "Date": {"Sat, 01 Jun 2024 12:30:00 GMT"}}
+			if until := FreshUntil(header, fetched); !until.Equal(fetched.Add(30 * time.Minute)) {
+				t.Errorf("Freshness mismatch, got: %s, want: %s.", until, fetched.Add(30*time.Minute))
+			}
+		})
+
+		t.Run("Must revalidate", func(t *testing.T) {
+			t.Parallel()
+			for _, header := range []http.Header{
+				{},
+				{"Cache-Control": {"no-cache, max-age=600"}},
+				{"Cache-Control": {"s-maxage=18000, must-revalidate, max-age=0"}},
+				{"Cache-Control": {"max-age=60"}, "Age": {"120"}},
+				{"Expires": {"0"}},
+			} {
+				if until := FreshUntil(header, fetched); !until.IsZero() {
+					t.Errorf("%v should not be fresh, got: %s.", header, until)
+				}
+			}
+		})
+	})
+}
+
+func TestReuseFresh(t *testing.T) {
+	t.Run("Skip pages fresh in the previous run", func(t *testing.T) {
+		t.Parallel()
+		var requests int32
+		server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			atomic.AddInt32(&requests, 1)
+			switch req.URL.Path {
+			case "/cached":
+				rw.Header().Set("Cache-Control", "max-age=3600")
+				fmt.Fprint(rw, `<html><a href="/behind">behind</a><a href="/gone">gone</a></html>`)
+			case "/gone":
+				http.NotFound(rw, req)
+			default:
+				fmt.Fprint(rw, `<html><a href="/cached">cached</a></html>`)
+			}
+		}))
+		defer server.Close()
+
+		previous := NewCrawler(server.URL).Crawl(server.URL + "/start")
+		if info, _ := previous.Pages.Get(server.URL + "/cached"); info.FreshUntil.IsZero() {
+			t.Fatalf("Cached page should be fresh, got: %+v.", info)
+		}
+
+		atomic.StoreInt32(&requests, 0)
+		result := NewCrawler(server.URL, WithPrevious(previous)).Crawl(server.URL + "/start")
+
+		// Links of the fresh page are still followed and checked.
+		if got := atomic.LoadInt32(&requests); got != 3 {
+			t.Errorf("Requests mismatch, got: %d, want: 3.", got)
+		}
+		if result.Visited.Len() != previous.Visited.Len() || !result.Broken.Contains(server.URL+"/gone") {
+			t.Errorf("Results mismatch, got: %v and %v, want: %v.", result.Visited.Keys(), result.Broken.Keys(), previous.Visited.Keys())
+		}
+		if result.Fresh.Len() != 1 || !result.Fresh.Contains(server.URL+"/cached") {
+			t.Errorf("Fresh pages mismatch, got: %v, want: [%s/cached].", result.Fresh.Keys(), server.URL)
+		}
+		if info, _ := result.Pages.Get(server.URL + "/cached"); info.LinkCount != 2 || info.FreshUntil.IsZero() {
+			t.Errorf("Reused page info mismatch, got: %+v.", info)
+		}
+	})
+}
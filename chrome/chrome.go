@@ -0,0 +1,122 @@
+// Package chrome renders wiki pages in headless Chrome before link
+// extraction, for skins and extensions that build navigation client side.
+package chrome
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/chromedp"
+	"jalandis.com/wikicrawl"
+)
+
+// Fetcher rendering every page in a tab of a shared headless browser.
+//
+//  1. Timeout: Limit for loading and rendering a single page.
+//  2. Settle: Extra wait after load for scripts to inject content.
+//  3. Cookies: Sent with every page (e.g. the wiki session).
+type Fetcher struct {
+	browser context.Context
+	Timeout time.Duration
+	Settle  time.Duration
+	Cookies []*http.Cookie
+}
+
+// Starts a headless browser, the returned function shuts it down.
+func NewFetcher(opts ...chromedp.ExecAllocatorOption) (*Fetcher, context.CancelFunc) {
+	opts = append(chromedp.DefaultExecAllocatorOptions[:], opts...)
+	allocator, cancelAllocator := chromedp.NewExecAllocator(context.Background(), opts...)
+	browser, cancelBrowser := chromedp.NewContext(allocator)
+
+	f := &Fetcher{
+		browser: browser,
+		Timeout: 30 * time.Second,
+		Settle:  500 * time.Millisecond,
+	}
+
+	return f, func() {
+		cancelBrowser()
+		cancelAllocator()
+	}
+}
+
+func (f *Fetcher) Fetch(link wikicrawl.Link) (*wikicrawl.Page, error) {
+	tab, cancelTab := chromedp.NewContext(f.browser)
+	defer cancelTab()
+
+	tab, cancelTimeout := context.WithTimeout(tab, f.Timeout)
+	defer cancelTimeout()
+
+	var mu sync.Mutex
+	var document *network.Response
+	chromedp.ListenTarget(tab, func(ev interface{}) {
+		if ev, ok := ev.(*network.EventResponseReceived); ok && ev.Type == network.ResourceTypeDocument {
+			mu.Lock()
+			defer mu.Unlock()
+			document = ev.Response
+		}
+	})
+
+	var location, html string
+	err := chromedp.Run(tab,
+		network.Enable(),
+		f.setCookies(link),
+		chromedp.Navigate(link.String()),
+		chromedp.Sleep(f.Settle),
+		chromedp.Location(&location),
+		chromedp.OuterHTML("html", &html, chromedp.ByQuery),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if document == nil {
+		return nil, fmt.Errorf("no document response for %s", link)
+	}
+
+	final, err := url.Parse(location)
+	if err != nil {
+		return nil, err
+	}
+
+	header := make(http.Header)
+	for key, value := range document.Headers {
+		header.Set(key, fmt.Sprint(value))
+	}
+
+	page := &wikicrawl.Page{
+		URL:        final,
+		StatusCode: int(document.Status),
+		Status:     fmt.Sprintf("%d %s", document.Status, document.StatusText),
+		Header:     header,
+	}
+
+	if page.StatusCode == 200 {
+		page.Body = []byte(html)
+	}
+
+	return page, nil
+}
+
+func (f *Fetcher) setCookies(link wikicrawl.Link) chromedp.Action {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		for _, cookie := range f.Cookies {
+			err := network.SetCookie(cookie.Name, cookie.Value).
+				WithURL(link.String()).
+				WithPath(cookie.Path).
+				Do(ctx)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,53 @@
+package chrome
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os/exec"
+	"testing"
+
+	"jalandis.com/wikicrawl"
+)
+
+func requireBrowser(t *testing.T) {
+	for _, name := range []string{"google-chrome", "chromium", "chromium-browser", "headless-shell"} {
+		if _, err := exec.LookPath(name); err == nil {
+			return
+		}
+	}
+
+	t.Skip("No headless browser installed.")
+}
+
+func TestFetch(t *testing.T) {
+	t.Run("Render pages in headless browser", func(t *testing.T) {
+		t.Run("Links injected by scripts", func(t *testing.T) {
+			requireBrowser(t)
+			server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				fmt.Fprintf(rw, `<html><body><script>
+					document.body.innerHTML += '<a href="/injected">x</a>';
+					</script></body></html>`)
+			}))
+			defer server.Close()
+
+			f, cancel := NewFetcher()
+			defer cancel()
+
+			page, err := f.Fetch(wikicrawl.NewLink(server.URL))
+			if err != nil {
+				t.Fatalf("Fetch failed: %s.", err)
+			}
+
+			if page.StatusCode != 200 {
+				t.Errorf("Status mismatch, got: %d, want: %d.", page.StatusCode, 200)
+			}
+
+			links := wikicrawl.ParseLinks(bytes.NewReader(page.Body))
+			if !links.Contains("/injected") {
+				t.Errorf("Injected link missing from rendered page: %s.", page.Body)
+			}
+		})
+	})
+}
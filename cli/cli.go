@@ -1,25 +1,1092 @@
 package main
 
 import (
+	"compress/bzip2"
+	"context"
+	"encoding/json"
 	"flag"
 	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	_ "net/http/pprof"
+	"os"
+	"os/signal"
+	"regexp"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
 
 	"jalandis.com/wikicrawl"
+	"jalandis.com/wikicrawl/boltdb"
+	"jalandis.com/wikicrawl/chrome"
+	"jalandis.com/wikicrawl/postgres"
 )
 
+// Flag that can be repeated, collecting every value.
+type multiFlag []string
+
+func (mf *multiFlag) String() string {
+	return strings.Join(*mf, ",")
+}
+
+func (mf *multiFlag) Set(value string) error {
+	*mf = append(*mf, value)
+	return nil
+}
+
+func (mf *multiFlag) Get() interface{} {
+	return []string(*mf)
+}
+
+// Publishes a report to an output target (see wikicrawl.NewReportWriter).
+func writeReport(target string, report []byte) {
+	writer, err := wikicrawl.NewReportWriter(target)
+	if err != nil {
+		panic(err)
+	}
+	if err := writer.WriteReport(report); err != nil {
+		panic(err)
+	}
+}
+
+// Keys of a map of links per page, sorted.
+func sortedKeys(pages map[string][]string) []string {
+	keys := make([]string, 0, len(pages))
+	for key := range pages {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
 func main() {
+	config := flag.String("config", wikicrawl.DefaultConfigPath, "YAML file of flag values, used when present; flags also read WIKICRAWL_<FLAG> variables")
+	printConfig := flag.Bool("print-config", false, "print the effective configuration merged from flags, environment and -config, then exit")
 	wiki := flag.String("wiki", "wiki_url", "a string")
 	session := flag.String("session", "session", "a string")
-	flag.Parse()
+	wordlist := flag.String("wordlist", "", "file of words (one per line) that flag a page")
+	dump := flag.String("dump", "", "MediaWiki XML dump (.xml or .xml.bz2) to read pages from instead of crawling")
+	simulate := flag.String("simulate", "", "JSON report (-json) whose link graph is replayed with the current rules instead of crawling")
+	top := flag.Int("top", 0, "print the pages with the most links and the largest HTML, this many of each")
+	filter := flag.String("filter", "", `only report pages matching the expression, e.g. 'status>=500 && namespace=="Template"' (fields: url, title, namespace, class, redirect, status, depth, size, links, referrers, visited, broken)`)
+	groupBy := flag.String("group-by", "", "print broken links grouped by: page")
+	eventsOut := flag.String("events-out", "", "file to append crawl events to as they happen, one JSON object per line")
+	jsonOut := flag.String("json", "", "file to write the crawl result as JSON")
+	out := flag.String("out", "", "where to publish the JSON report: a path, - for stdout, file://, s3://bucket/key or gs://bucket/object")
+	signKey := flag.String("sign-key", "", "Ed25519 PEM private key signing the JSON report, written to <output>.sig")
+	hrefMap := flag.String("href-map", "", "CSV file mapping every discovered href to its canonical and final url")
+	graphOut := flag.String("graph", "", "file to write the link graph to with status, depth, namespace and PageRank of pages: .gexf (Gephi) or .graphml")
+	indexOut := flag.String("index", "", "file to write a full text index of crawled pages")
+	external := flag.Bool("external", false, "verify external links without crawling them")
+	skipHosts := flag.String("skip-hosts", "", "comma separated external hosts never verified")
+	checkHosts := flag.String("check-hosts", "", "comma separated external hosts always verified")
+	archive := flag.Bool("archive", false, "look up archived snapshots of broken external links")
+	fixes := flag.String("fixes", "", "file to write archive link replacements for broken external links (implies -archive)")
+	soft404 := flag.Bool("soft404", false, "report external links that look like error pages")
+	minLinks := flag.Int("min-links", 0, "report pages with fewer links as suspicious")
+	disambiguation := flag.Bool("disambiguation", false, "report article links to disambiguation pages, looked up through the wiki API")
+	minContent := flag.Int("min-content", 0, "report articles with fewer characters of text as blanked, 0 to disable")
+	wikitext := flag.Bool("wikitext", false, "also follow links found in the wikitext of each page (action=raw)")
+	captureHeaders := flag.String("capture-headers", "", "comma separated response headers recorded for every link in the -json report")
+	errorBody := flag.Int64("error-body", 0, "bytes of the body of broken responses recorded with their key headers in the -json report and stored runs")
+	accessibility := flag.Bool("accessibility", false, "report images missing alt text and links with empty text on every page")
+	crossCheck := flag.Bool("cross-check", false, "compare the WantedPages, BrokenRedirects and DoubleRedirects reports of the wiki with the crawl")
+	audit := flag.Bool("audit", false, "audit security headers and mixed content of every internal page")
+	frames := flag.Bool("frames", false, "follow frame and iframe sources")
+	pathPrefix := flag.String("path-prefix", "", "only crawl pages below this path, defaults to the wiki url path")
+	namespaces := flag.String("namespaces", "", "comma separated namespaces to crawl (e.g. Main,Category,Template)")
+	siteNamespaces := flag.Bool("site-namespaces", false, "load the localized namespace names and aliases of the wiki (Hilfe:, Benutzer:) from its siteinfo API")
+	namespaceMap := flag.String("namespace-map", "", `JSON file mapping localized namespace names to canonical ones, e.g. {"Hilfe": "Help"}`)
+	linkAttrs := flag.String("link-attrs", "", "comma separated attributes holding links on any tag (e.g. data-href)")
+	render := flag.Bool("render", false, "render pages in headless Chrome before extracting links")
+	profile := flag.String("profile", "default", "politeness preset: aggressive, default or gentle")
+	workers := flag.Int("workers", 0, "pages fetched concurrently, overrides profile")
+	queueSize := flag.Int("queue", 1000, "links queued before workers stall")
+	rate := flag.Float64("rate", 0, "maximum requests per second, overrides profile")
+	retries := flag.Int("retries", 0, "retries for failed requests, overrides profile")
+	delay := flag.Duration("delay", 0, "pause after each request, overrides profile")
+	warmUp := flag.Int("warm-up", 0, "pages per warm-up stage: start with one worker, doubling them each stage with few errors, 0 to start every worker at once")
+	maxLatency := flag.Duration("max-latency", 0, "average response time above which the crawler backs off, overrides profile")
+	jitter := flag.Duration("jitter", 0, "random extra pause up to this duration after each request")
+	shuffle := flag.Bool("shuffle", false, "queue the links of each page in random order")
+	acceptLanguage := flag.String("accept-language", "", "Accept-Language header sent with every request")
+	var resolve multiFlag
+	flag.Var(&resolve, "resolve", "connect a host to an address instead of resolving it, as <host>:[<port>:]<ip> with IPv6 in brackets, repeatable")
+	compareMobile := flag.Bool("compare-mobile", false, "also crawl the mobile variant and report links differing from desktop")
+	compareSkins := flag.String("compare-skins", "", "crawl the same pages under two skins given as <skin>,<skin> (useskin=) and report links differing between them, instead of a normal crawl")
+	mobileHost := flag.String("mobile-host", "", "host of the mobile variant (e.g. m.wiki-url), defaults to ?useskin=minerva")
+	bandwidth := flag.Float64("bandwidth", 0, "maximum download bytes per second, 0 for unlimited")
+	maxConnections := flag.Int("max-connections", 0, "connections open at once, 0 for unlimited; workers are also reduced to the open file limit")
+	user := flag.String("user", "", "wiki user to log in as, password read from WIKICRAWL_PASSWORD")
+	fixRedirects := flag.Bool("fix-redirects", false, "edit pages linking to redirects to link to the final page (requires -user)")
+	dryRun := flag.Bool("dry-run", false, "with -fix-redirects, only print the edits that would be made")
+	summary := flag.String("summary", "Bypass redirects: {redirects}", "edit summary for -fix-redirects")
+	abortOnLogin := flag.Bool("abort-on-login", false, "stop the crawl when the login form is served")
+	coordinate := flag.String("coordinate", "", "address to serve a shared frontier on for distributed workers")
+	coordinator := flag.String("coordinator", "", "coordinator url to crawl as a distributed worker")
+	serve := flag.String("serve", "", "address to serve the crawl control API on, with /healthz and /readyz probes")
+	shutdownTimeout := flag.Duration("shutdown-timeout", 30*time.Second, "time running crawls get to stop on SIGTERM in -serve mode")
+	progress := flag.Duration("progress", 0, "print progress with the estimated end of the crawl to stderr at this interval, 0 to stay quiet")
+	stallTimeout := flag.Duration("stall-timeout", 0, "abort with diagnostics when no page is done for this long, 0 to wait forever")
+	sample := flag.Float64("sample", 0, "fraction of discovered links followed, e.g. 0.1, 0 to follow every link")
+	pageKey := flag.String("page-key", "url", "what identifies a page when deduplicating: url, title or curid")
+	maxParseBytes := flag.Int64("max-parse-bytes", 0, "bytes of every page read and parsed at most, 0 for unlimited")
+	memoryLimit := flag.Uint64("memory-limit", 0, "approximate heap bytes after which no new links are queued, 0 for unlimited")
+	pprofAddr := flag.String("pprof-addr", "", "address to serve net/http/pprof profiles on during the crawl")
+	deterministic := flag.Bool("deterministic", false, "follow links one at a time in a reproducible order")
+	var assertions multiFlag
+	flag.Var(&assertions, "assert", "content assertion as <url regexp>::<content regexp>, repeatable")
+	var titlePrefixes multiFlag
+	flag.Var(&titlePrefixes, "title-prefix", "only follow pages whose title starts with the prefix, repeatable")
+	var categories multiFlag
+	flag.Var(&categories, "category", "only follow pages of the category, repeatable")
+	var verifyOnly multiFlag
+	flag.Var(&verifyOnly, "verify-only", "url regexp of pages checked for a response but never expanded, repeatable")
+	storePath := flag.String("store", "", "BoltDB file or postgres:// database every run is saved to")
+	runID := flag.String("run", time.Now().UTC().Format("20060102T150405Z"), "id of the run, logged with every message and used to save it in the -store")
+	resume := flag.Bool("resume", false, "continue the latest run of the -store when it was aborted")
+	fresh := flag.Bool("fresh", false, "reuse pages of the latest run of the -store still fresh per their Cache-Control or Expires headers")
+	diffRuns := flag.Bool("diff", false, "report changes since the latest run of the -store")
+	escalateAfter := flag.Duration("escalate-after", 0, "make the result critical when a link stays broken for longer across -store runs, e.g. 720h")
+	contentShrink := flag.Float64("content-shrink", 0.5, "share of its text a page must lose to be reported shrunk by -diff")
+	batch := flag.String("batch", "", "JSON file listing the wikis to crawl instead of -wiki, see wikicrawl.BatchWiki")
+	parallel := flag.Int("parallel", 4, "wikis of a -batch crawled concurrently")
+	history := flag.String("history", "", "JSON lines file the summary of every run is appended to")
+	trend := flag.Bool("trend", false, "print the trend report of the -history file instead of crawling")
+	var labels multiFlag
+	flag.Var(&labels, "label", "label of the run as <key>=<value>, kept in reports, stored runs and metrics, repeatable")
+	var notifications multiFlag
+	flag.Var(&notifications, "notify", "channel notified of the result as <slack|teams|webhook>[@<min severity>]=<webhook url>, repeatable")
+	reportURL := flag.String("report-url", "", "link to the full report included in notifications")
+	var metricsPush multiFlag
+	flag.Var(&metricsPush, "metrics-push", "endpoint run metrics are pushed to as <pushgateway|influxdb|graphite>=<url>, repeatable")
+	var queryRules multiFlag
+	flag.Var(&queryRules, "keep-query", "significant query parameters as <url regexp>::<param>,<param>, repeatable")
+	styles := flag.Bool("styles", false, "also verify linked style sheets and the url() references of styles hosted on the wiki")
+	scripts := flag.Bool("scripts", false, "also verify the <script src> resources of pages, reported apart from content links")
+	restricted := flag.Bool("restricted", false, "report links answered with 401 or 403 as restricted instead of broken, for partly private wikis")
+	discover := flag.Bool("discover", false, "also seed the crawl with the pages of the wiki host sitemaps and skip the pages robots.txt disallows")
+	forms := flag.Bool("forms", false, "also follow every target of simple GET forms (select boxes, radio buttons, hidden inputs)")
+	var methodRules multiFlag
+	var statusRules multiFlag
+	flag.Var(&statusRules, "ok-status", "statuses counted as success for matching urls as <url regexp>::<status>,<status>, a 3xx accepting redirects out of the wiki, repeatable")
+	flag.Var(&methodRules, "method", "request method of matching urls as <url regexp>::<GET|HEAD|POST>, repeatable")
+
+	// recheck [flags] <report.json> verifies again the broken links of a
+	// -json report instead of crawling.
+	recheck := len(os.Args) > 1 && os.Args[1] == "recheck"
+	if recheck {
+		flag.CommandLine.Parse(os.Args[2:])
+	} else {
+		flag.Parse()
+	}
+
+	// Command line flags win over the environment, which wins over the file.
+	if err := wikicrawl.ApplyEnvironment(flag.CommandLine, os.Environ()); err != nil {
+		panic(err)
+	}
+	configRequired := false
+	flag.Visit(func(f *flag.Flag) {
+		configRequired = configRequired || f.Name == "config"
+	})
+	if err := wikicrawl.ApplyConfigFile(flag.CommandLine, *config, configRequired); err != nil {
+		panic(err)
+	}
+	if *printConfig {
+		flag.Set("print-config", "false")
+		if err := wikicrawl.WriteConfig(os.Stdout, flag.CommandLine, "session", "store", "notify", "metrics-push"); err != nil {
+			panic(err)
+		}
+		return
+	}
+
+	if len(*pprofAddr) > 0 {
+		go func() {
+			panic(http.ListenAndServe(*pprofAddr, nil))
+		}()
+	}
+
+	options := []wikicrawl.Option{wikicrawl.WithSession(*session), wikicrawl.WithBandwidth(*bandwidth),
+		wikicrawl.WithMaxConnections(*maxConnections)}
+	if len(*acceptLanguage) > 0 {
+		options = append(options, wikicrawl.WithAcceptLanguage(*acceptLanguage))
+	}
+	for _, raw := range resolve {
+		override, err := wikicrawl.ParseHostOverride(raw)
+		if err != nil {
+			panic(err)
+		}
+		options = append(options, wikicrawl.WithHostOverrides(override))
+	}
+	if len(*eventsOut) > 0 {
+		events, err := os.OpenFile(*eventsOut, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			panic(err)
+		}
+		defer events.Close()
+		options = append(options, wikicrawl.WithEventLog(events))
+	}
+
+	// Crawler of a wiki configured from the flags, one per wiki of a -batch.
+	newCrawler := func(base string) *wikicrawl.Crawler {
+		c := wikicrawl.NewCrawler(base, options...)
+
+		preset, found := wikicrawl.Profiles[*profile]
+		if !found {
+			panic("Unknown profile: " + *profile)
+		}
+		c.ApplyProfile(preset)
+
+		key, found := wikicrawl.PageKeys[*pageKey]
+		if !found {
+			panic("Unknown page key: " + *pageKey)
+		}
+		c.PageKey = key
+
+		flag.Visit(func(f *flag.Flag) {
+			switch f.Name {
+			case "workers":
+				c.Workers = *workers
+			case "rate":
+				c.RateLimit = *rate
+			case "retries":
+				c.Retries = *retries
+			case "delay":
+				c.Delay = *delay
+			case "max-latency":
+				c.MaxLatency = *maxLatency
+			}
+		})
+		c.CheckExternal = *external
+		c.FollowFrames = *frames
+		c.Wikitext = *wikitext
+		c.Forms = *forms
+		c.Styles = *styles
+		c.Scripts = *scripts
+		c.Discover = *discover
+		c.Restricted = *restricted
+		c.MinContent = *minContent
+		c.WarmUp = *warmUp
+		// Stored runs keep content hashes for -diff to compare.
+		c.HashContent = len(*storePath) > 0
+		c.Audit = *audit
+		c.ErrorBody = *errorBody
+		c.StallTimeout = *stallTimeout
+		c.MemoryLimit = *memoryLimit
+		c.MaxParseBytes = *maxParseBytes
+		c.Sample = *sample
+		c.QueueSize = *queueSize
+		c.AbortOnLogin = *abortOnLogin
+		c.Deterministic = *deterministic
+		c.Jitter = *jitter
+		c.Shuffle = *shuffle
+		if len(*user) > 0 {
+			c.Authenticator = &wikicrawl.MediaWikiLogin{
+				Username: *user,
+				Password: os.Getenv("WIKICRAWL_PASSWORD"),
+			}
+			if err := c.Login(); err != nil {
+				panic(err)
+			}
+		}
+		if len(*pathPrefix) > 0 {
+			wikicrawl.WithScope(c.SameHost, *pathPrefix)(c)
+		}
+		c.Localize = *siteNamespaces
+		if len(*namespaceMap) > 0 {
+			file, err := os.Open(*namespaceMap)
+			if err != nil {
+				panic(err)
+			}
+			names, err := wikicrawl.ReadNamespaceMap(file)
+			file.Close()
+			if err != nil {
+				panic(err)
+			}
+			c.NamespaceMap = names
+		}
+		if len(*namespaces) > 0 {
+			c.InNamespaces = strings.Split(*namespaces, ",")
+		}
+		if len(*captureHeaders) > 0 {
+			c.CaptureHeaders = strings.Split(*captureHeaders, ",")
+		}
+		if len(*linkAttrs) > 0 {
+			c.ExtraAttrs = strings.Split(*linkAttrs, ",")
+		}
+		if *soft404 {
+			c.Soft404 = wikicrawl.NewSoft404Detector()
+		}
+		if len(*skipHosts) > 0 {
+			c.SkipHosts = strings.Split(*skipHosts, ",")
+		}
+		if len(*checkHosts) > 0 {
+			c.CheckHosts = strings.Split(*checkHosts, ",")
+		}
+
+		if len(*wordlist) > 0 {
+			data, err := ioutil.ReadFile(*wordlist)
+			if err != nil {
+				panic(err)
+			}
+
+			checker := wikicrawl.NewWordListChecker(strings.Fields(string(data)))
+			c.Processors = append(c.Processors, wikicrawl.NewContentProcessor(checker))
+		}
+
+		c.TrackHrefs = len(*hrefMap) > 0
+		c.TitlePrefixes = titlePrefixes
+		c.Categories = categories
+
+		for _, raw := range verifyOnly {
+			c.VerifyOnly = append(c.VerifyOnly, regexp.MustCompile(raw))
+		}
+
+		for _, raw := range queryRules {
+			rule, err := wikicrawl.ParseQueryRule(raw)
+			if err != nil {
+				panic(err)
+			}
+			c.QueryRules = append(c.QueryRules, rule)
+		}
+
+		for _, raw := range methodRules {
+			rule, err := wikicrawl.ParseMethodRule(raw)
+			if err != nil {
+				panic(err)
+			}
+			c.Methods = append(c.Methods, rule)
+		}
+
+		for _, raw := range statusRules {
+			rule, err := wikicrawl.ParseStatusRule(raw)
+			if err != nil {
+				panic(err)
+			}
+			c.StatusRules = append(c.StatusRules, rule)
+		}
+
+		for _, raw := range labels {
+			key, value, err := wikicrawl.ParseLabel(raw)
+			if err != nil {
+				panic(err)
+			}
+			wikicrawl.WithLabel(key, value)(c)
+		}
+
+		for _, raw := range assertions {
+			assertion, err := wikicrawl.ParseAssertion(raw)
+			if err != nil {
+				panic(err)
+			}
+			c.Assertions = append(c.Assertions, assertion)
+		}
+
+		return c
+	}
+
+	var rechecked *wikicrawl.CrawlResult
+	if recheck {
+		if flag.NArg() != 1 {
+			panic("Usage: recheck [flags] <report.json>")
+		}
+		file, err := os.Open(flag.Arg(0))
+		if err != nil {
+			panic(err)
+		}
+		rechecked, err = wikicrawl.ReadReport(file)
+		file.Close()
+		if err != nil {
+			panic(err)
+		}
+		// The wiki of the report is rechecked unless given.
+		if *wiki == flag.Lookup("wiki").DefValue {
+			*wiki = rechecked.Metadata.Seed
+		}
+	}
+	c := newCrawler(*wiki)
 
-	c := wikicrawl.NewCrawler(*wiki, *session)
-	result := c.Crawl(*wiki)
+	if *render {
+		fetcher, cancel := chrome.NewFetcher()
+		defer cancel()
+		fetcher.Cookies = c.Client.Jar.Cookies(c.Base())
+		c.Fetcher = fetcher
+	}
+
+	var resultFilter *wikicrawl.Filter
+	if len(*filter) > 0 {
+		var err error
+		if resultFilter, err = wikicrawl.ParseFilter(*filter); err != nil {
+			panic(err)
+		}
+	}
+
+	var channels []wikicrawl.Notification
+	for _, raw := range notifications {
+		channel, err := wikicrawl.ParseNotification(raw)
+		if err != nil {
+			panic(err)
+		}
+		channel.ReportURL = *reportURL
+		channels = append(channels, channel)
+	}
+
+	var pushes []wikicrawl.MetricsPush
+	for _, raw := range metricsPush {
+		push, err := wikicrawl.ParseMetricsPush(raw)
+		if err != nil {
+			panic(err)
+		}
+		pushes = append(pushes, push)
+	}
+
+	var a11y *wikicrawl.AccessibilityProcessor
+	if *accessibility {
+		a11y = new(wikicrawl.AccessibilityProcessor)
+		c.Processors = append(c.Processors, a11y)
+	}
+
+	var index *wikicrawl.TextIndex
+	if len(*indexOut) > 0 {
+		index = wikicrawl.NewTextIndex()
+		c.Processors = append(c.Processors, index)
+	}
+
+	var store wikicrawl.Store
+	var latest *wikicrawl.Run
+	if len(*storePath) > 0 {
+		var err error
+		if strings.HasPrefix(*storePath, "postgres://") || strings.HasPrefix(*storePath, "postgresql://") {
+			store, err = postgres.Open(*storePath)
+		} else {
+			store, err = boltdb.Open(*storePath)
+		}
+		if err != nil {
+			panic(err)
+		}
+		defer store.Close()
+
+		if latest, err = wikicrawl.LatestRun(store); err != nil {
+			panic(err)
+		}
+
+		if *fresh && latest != nil {
+			if c.Previous, err = store.LoadRun(latest.ID); err != nil {
+				panic(err)
+			}
+		}
+	}
+
+	if *trend {
+		entries, err := wikicrawl.HistoryFile{Path: *history}.Entries()
+		if err != nil {
+			panic(err)
+		}
+		if err := wikicrawl.WriteTrend(os.Stdout, entries); err != nil {
+			panic(err)
+		}
+		return
+	}
+
+	if len(*simulate) > 0 {
+		file, err := os.Open(*simulate)
+		if err != nil {
+			panic(err)
+		}
+		saved, err := wikicrawl.ReadReport(file)
+		file.Close()
+		if err != nil {
+			panic(err)
+		}
+
+		simulation := c.Simulate(saved, *wiki)
+		fmt.Printf("Simulated crawl visits %d pages (%d saved), %d broken\n",
+			simulation.Result.Visited.Len(), saved.Visited.Len(), simulation.Result.Broken.Len())
+		for _, added := range simulation.Added {
+			fmt.Printf("+ %s\n", added)
+		}
+		for _, dropped := range simulation.Dropped {
+			fmt.Printf("- %s\n", dropped)
+		}
+		return
+	}
+
+	if len(*compareSkins) > 0 {
+		skins := strings.Split(*compareSkins, ",")
+		if len(skins) != 2 {
+			panic("Expected two skins to compare: " + *compareSkins)
+		}
+
+		diff := c.CompareSkins(*wiki, skins[0], skins[1])
+		for _, page := range sortedKeys(diff.Missing) {
+			for _, link := range diff.Missing[page] {
+				fmt.Println("Missing with " + skins[1] + ": " + link + " (on " + page + ")")
+			}
+		}
+		for _, page := range sortedKeys(diff.Extra) {
+			for _, link := range diff.Extra[page] {
+				fmt.Println(skins[1] + " only: " + link + " (on " + page + ")")
+			}
+		}
+		for _, link := range diff.Broken {
+			fmt.Println("Broken with " + skins[1] + ": " + link)
+		}
+		return
+	}
+
+	// Publishes the reports, history and metrics of a result and notifies
+	// the channels. {wiki} in report targets is replaced by name.
+	publish := func(result *wikicrawl.CrawlResult, name string) {
+		// Reports show the filtered slice, history, metrics and
+		// notifications the whole run.
+		reported := result
+		if resultFilter != nil {
+			reported = result.Filter(resultFilter)
+		}
+
+		for _, target := range []string{*jsonOut, *out} {
+			if len(target) == 0 {
+				continue
+			}
+			target = strings.Replace(target, "{wiki}", name, -1)
+
+			report, err := json.MarshalIndent(reported, "", "  ")
+			if err != nil {
+				panic(err)
+			}
+			writeReport(target, report)
+
+			// Signatures are published next to named reports only.
+			if len(*signKey) > 0 && target != "-" && target != "stdout:" {
+				pemKey, err := ioutil.ReadFile(*signKey)
+				if err != nil {
+					panic(err)
+				}
+				key, err := wikicrawl.LoadSigningKey(pemKey)
+				if err != nil {
+					panic(err)
+				}
+				signature, _ := json.MarshalIndent(wikicrawl.SignReport(report, key), "", "  ")
+				writeReport(target+".sig", signature)
+			}
+		}
+
+		if len(*history) > 0 {
+			if err := (wikicrawl.HistoryFile{Path: *history}).Append(result.HistoryEntry()); err != nil {
+				panic(err)
+			}
+		}
+
+		for _, push := range pushes {
+			if err := push.Push(result); err != nil {
+				fmt.Fprintf(os.Stderr, "Pushing %s metrics failed: %s\n", push.Format, err)
+			}
+		}
+
+		for _, channel := range channels {
+			if err := channel.Notify(result); err != nil {
+				fmt.Fprintf(os.Stderr, "Sending %s notification failed: %s\n", channel.Format, err)
+			}
+		}
+	}
+
+	if len(*batch) > 0 {
+		for _, target := range []string{*jsonOut, *out} {
+			if len(target) > 0 && !strings.Contains(target, "{wiki}") {
+				panic("Report targets of a -batch must contain {wiki}: " + target)
+			}
+		}
+
+		file, err := os.Open(*batch)
+		if err != nil {
+			panic(err)
+		}
+		wikis, err := wikicrawl.ReadBatch(file)
+		file.Close()
+		if err != nil {
+			panic(err)
+		}
 
-	for key, _ := range result.Visited.Set {
+		results := wikicrawl.Batch{
+			Wikis:    wikis,
+			Parallel: *parallel,
+			NewCrawler: func(batchWiki wikicrawl.BatchWiki) *wikicrawl.Crawler {
+				crawler := newCrawler(batchWiki.URL)
+				crawler.RunID = *runID + "-" + batchWiki.Name
+				return crawler
+			},
+		}.Run()
+		for _, batchResult := range results {
+			if store != nil {
+				if err := store.SaveRun(*runID+"-"+batchResult.Wiki.Name, batchResult.Result); err != nil {
+					panic(err)
+				}
+			}
+			publish(batchResult.Result, batchResult.Wiki.Name)
+		}
+
+		if err := wikicrawl.WriteBatchSummary(os.Stdout, results); err != nil {
+			panic(err)
+		}
+		return
+	}
+
+	if len(*serve) > 0 {
+		configured := *c
+		server := wikicrawl.NewServer(*wiki, func() *wikicrawl.Crawler {
+			crawler := configured
+			return &crawler
+		})
+		httpServer := &http.Server{Addr: *serve, Handler: server}
+		// SIGTERM stops the server cleanly, e.g. when a pod is evicted.
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, syscall.SIGTERM, os.Interrupt)
+		go func() {
+			<-signals
+			ctx, cancel := context.WithTimeout(context.Background(), *shutdownTimeout)
+			defer cancel()
+			if err := server.Drain(ctx); err != nil {
+				fmt.Fprintf(os.Stderr, "Draining crawls failed: %s\n", err)
+			}
+			httpServer.Shutdown(ctx)
+		}()
+		if err := httpServer.ListenAndServe(); err != http.ErrServerClosed {
+			panic(err)
+		}
+		return
+	}
+
+	if len(*coordinator) > 0 {
+		if err := c.CrawlRemote(*coordinator); err != nil {
+			panic(err)
+		}
+		return
+	}
+
+	var result *wikicrawl.CrawlResult
+	if len(*coordinate) > 0 {
+		co := wikicrawl.NewCoordinator(c.Seed(*wiki))
+		co.Result.Metadata.ConfigHash = c.ConfigHash()
+		go func() {
+			panic(http.ListenAndServe(*coordinate, co))
+		}()
+		result = co.Wait()
+
+		// Give polling workers a chance to learn the crawl is done.
+		time.Sleep(2 * time.Second)
+	} else if len(*dump) > 0 {
+		file, err := os.Open(*dump)
+		if err != nil {
+			panic(err)
+		}
+		defer file.Close()
+
+		var reader io.Reader = file
+		if strings.HasSuffix(*dump, ".bz2") {
+			reader = bzip2.NewReader(file)
+		}
+
+		if result, err = c.CrawlDump(reader); err != nil {
+			panic(err)
+		}
+	} else if recheck {
+		result = c.Recheck(rechecked)
+		*runID = result.Metadata.Run
+		for _, key := range rechecked.Broken.Keys() {
+			if !result.Broken.Contains(key) {
+				fmt.Println("Fixed link: " + key)
+			}
+		}
+	} else {
+		var queue *wikicrawl.WorkQueue
+		if *resume && latest != nil && latest.Aborted {
+			c.RunID = latest.ID
+			previous, err := store.LoadRun(latest.ID)
+			if err != nil {
+				panic(err)
+			}
+			queue = c.StartResume(previous)
+			*runID = latest.ID
+		} else {
+			c.RunID = *runID
+			queue = c.Start(*wiki)
+		}
+		abortOnSignal(queue)
+		finished := make(chan struct{})
+		if *progress > 0 {
+			go printProgress(queue, *progress, finished)
+		}
+		queue.Wait()
+		close(finished)
+		result = queue.Result
+	}
+
+	if store != nil {
+		var previous *wikicrawl.CrawlResult
+		if latest != nil && latest.ID != *runID {
+			var err error
+			if previous, err = store.LoadRun(latest.ID); err != nil {
+				panic(err)
+			}
+		}
+		result.TrackBrokenSince(previous, *escalateAfter)
+
+		if *diffRuns && previous != nil {
+			diff := wikicrawl.DiffRuns(previous, result)
+			for _, link := range diff.NewlyBroken {
+				fmt.Println("Newly broken since " + latest.ID + ": " + link)
+			}
+			for _, link := range diff.Fixed {
+				fmt.Println("Fixed since " + latest.ID + ": " + link)
+			}
+			fmt.Printf("Pages since %s: %d added, %d removed\n", latest.ID, len(diff.Added), len(diff.Removed))
+			for _, change := range wikicrawl.DiffContent(previous, result, *contentShrink) {
+				fmt.Printf("Content %s since %s: %s (%d -> %d characters)\n", change.Kind, latest.ID, change.URL, change.OldSize, change.NewSize)
+			}
+		}
+
+		if err := store.SaveRun(*runID, result); err != nil {
+			panic(err)
+		}
+	}
+
+	if *compareMobile {
+		mobile := *c
+		mobile.Use(wikicrawl.MobileVariant(c.Base().Host, *mobileHost))
+		diff := wikicrawl.CompareResults(result, mobile.Crawl(*wiki))
+		for _, page := range sortedKeys(diff.Missing) {
+			for _, link := range diff.Missing[page] {
+				fmt.Println("Missing on mobile: " + link + " (on " + page + ")")
+			}
+		}
+		for _, page := range sortedKeys(diff.Extra) {
+			for _, link := range diff.Extra[page] {
+				fmt.Println("Mobile only: " + link + " (on " + page + ")")
+			}
+		}
+		for _, link := range diff.Broken {
+			fmt.Println("Broken on mobile: " + link)
+		}
+	}
+
+	if index != nil {
+		file, err := os.Create(*indexOut)
+		if err != nil {
+			panic(err)
+		}
+		defer file.Close()
+
+		if err := index.Save(file); err != nil {
+			panic(err)
+		}
+	}
+
+	if *fixRedirects {
+		fixer := wikicrawl.NewRedirectFixer(c.Client, c.Base())
+		fixer.Summary = *summary
+		fixer.DryRun = *dryRun
+		edits, err := fixer.Fix(result)
+		label := "Edited page"
+		if *dryRun {
+			label = "Planned edit"
+		}
+		for _, edit := range edits {
+			fmt.Printf("%s: %s (%d links, %s)\n", label, edit.Page, edit.Replacements, edit.Summary)
+		}
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	if *archive || len(*fixes) > 0 {
+		wikicrawl.NewArchiveLookup().Annotate(result)
+	}
+
+	if a11y != nil {
+		result.Accessibility = a11y.Findings()
+	}
+	if *crossCheck {
+		entries, err := c.CrossCheck(result)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Cross-checking wiki reports failed: %s\n", err)
+		}
+		result.WikiReports = entries
+	}
+
+	// Runs are stored, trended and alerted on whole, every output below
+	// shows the filtered slice.
+	whole := result
+	if resultFilter != nil {
+		result = result.Filter(resultFilter)
+	}
+
+	if len(*hrefMap) > 0 {
+		file, err := os.Create(*hrefMap)
+		if err != nil {
+			panic(err)
+		}
+		defer file.Close()
+
+		if err := result.WriteHrefMap(file); err != nil {
+			panic(err)
+		}
+	}
+
+	if len(*graphOut) > 0 {
+		file, err := os.Create(*graphOut)
+		if err != nil {
+			panic(err)
+		}
+		defer file.Close()
+
+		switch {
+		case strings.HasSuffix(*graphOut, ".gexf"):
+			err = result.WriteGEXF(file)
+		case strings.HasSuffix(*graphOut, ".graphml"):
+			err = result.WriteGraphML(file)
+		default:
+			err = fmt.Errorf("unknown graph format of %s, expected .gexf or .graphml", *graphOut)
+		}
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	if len(*fixes) > 0 {
+		file, err := os.Create(*fixes)
+		if err != nil {
+			panic(err)
+		}
+		defer file.Close()
+
+		if err := result.WriteSuggestedFixes(file); err != nil {
+			panic(err)
+		}
+	}
+
+	publish(whole, "")
+
+	fmt.Printf("Crawl metadata: %s %s, seed %s, config %s, user %s, %s - %s\n",
+		result.Metadata.Tool, result.Metadata.Version, result.Metadata.Seed, result.Metadata.ConfigHash,
+		result.Metadata.User, result.Metadata.Started.Format(time.RFC3339), result.Metadata.Finished.Format(time.RFC3339))
+
+	fmt.Printf("Queue stats: peak depth %d of %d, producer stall %s, worker idle %s\n",
+		result.Queue.PeakDepth, result.Queue.Capacity, result.Queue.ProducerStall, result.Queue.WorkerIdle)
+
+	if result.Truncated {
+		fmt.Printf("Memory limit reached, %d links were not crawled, results are incomplete.\n", result.Queue.Dropped)
+	}
+
+	if result.Stall != nil {
+		fmt.Printf("Crawl stalled since %s, %d links pending\n", result.Stall.LastProgress.Format(time.RFC3339), result.Stall.Pending)
+		for _, link := range result.Stall.InFlight {
+			fmt.Println("Stuck on: " + link)
+		}
+		fmt.Println(result.Stall.Stacks)
+	}
+
+	for _, level := range result.DepthLevels() {
+		fmt.Printf("Depth %d: %d pages, %d cumulative (%.1f%%)\n", level.Depth, level.Pages, level.Cumulative, level.Coverage*100)
+	}
+
+	if result.Metadata.Sample > 0 {
+		fmt.Printf("Sampled %.0f%% of links, estimated broken rate: %.2f%% (%d of %d)\n", result.Metadata.Sample*100,
+			result.BrokenRate()*100, result.Broken.Len(), result.Visited.Len())
+	}
+
+	if result.Fresh.Len() > 0 {
+		fmt.Printf("Reused %d pages still fresh since run %s\n", result.Fresh.Len(), latest.ID)
+	}
+
+	if result.Aborted {
+		fmt.Println("Crawl aborted, results are incomplete.")
+	}
+
+	for _, key := range result.Visited.Keys() {
 		fmt.Println("Visited link: " + key)
 	}
 
-	for key, _ := range result.Broken.Set {
-		fmt.Println("Broken link :" + key)
+	brokenLink := func(key string) string {
+		info, _ := result.Pages.Get(key)
+		if !info.BrokenSince.IsZero() && info.BrokenSince.Before(result.Metadata.Started) {
+			key += " (broken since " + info.BrokenSince.Format("2006-01-02") + ")"
+		}
+		if len(info.Archive) > 0 {
+			return key + " (archived: " + info.Archive + ")"
+		}
+		return key
+	}
+	switch *groupBy {
+	case "page":
+		grouped := result.BrokenByReferrer()
+		pages := sortedKeys(grouped)
+		sort.SliceStable(pages, func(i, j int) bool {
+			return len(grouped[pages[i]]) > len(grouped[pages[j]])
+		})
+		for _, page := range pages {
+			label := page
+			if len(page) == 0 {
+				label = "(no referrer)"
+			}
+			fmt.Printf("Page with broken links: %s (%d)\n", label, len(grouped[page]))
+			for _, key := range grouped[page] {
+				fmt.Println("    " + brokenLink(key))
+			}
+		}
+	default:
+		for _, key := range result.Broken.Keys() {
+			if !result.Resources.Contains(key) {
+				fmt.Println("Broken link :" + brokenLink(key))
+			}
+		}
+		// Style sheets, images and scripts rot with the skin and
+		// extensions, not the content.
+		for _, key := range result.Broken.Keys() {
+			if result.Resources.Contains(key) && !result.Scripts.Contains(key) {
+				fmt.Println("Broken resource: " + brokenLink(key))
+			}
+		}
+		for _, key := range result.Broken.Keys() {
+			if result.Scripts.Contains(key) {
+				fmt.Println("Broken script: " + brokenLink(key))
+			}
+		}
+	}
+
+	for _, citation := range result.DeadCitations() {
+		fmt.Printf("Dead citation: %s ({{%s}} %s in section %q of %s)\n",
+			citation.URL, citation.Template, citation.Parameter, citation.Section, citation.Page)
+	}
+
+	for _, key := range result.ExternalRedirects.Keys() {
+		info, _ := result.Pages.Get(key)
+		fmt.Println("External redirect: " + key + " -> " + info.RedirectTo)
+	}
+
+	for _, info := range result.PagesByLinks(*top) {
+		fmt.Printf("Most links: %s (%d internal, %d external)\n", info.Link.String(), info.InternalLinks, info.ExternalLinks)
+	}
+	for _, info := range result.PagesBySize(*top) {
+		fmt.Printf("Largest page: %s (%d bytes)\n", info.Link.String(), info.Size)
+	}
+
+	maintenance := result.Maintenance()
+	for _, page := range sortedKeys(maintenance.SelfLinks) {
+		for _, link := range maintenance.SelfLinks[page] {
+			fmt.Println("Self-link: " + page + " links to itself via " + link)
+		}
+	}
+	for _, cycle := range maintenance.RedirectCycles {
+		fmt.Println("Redirect cycle: " + strings.Join(append(cycle, cycle[0]), " -> "))
+	}
+
+	for _, key := range result.MixedContent.Keys() {
+		info, _ := result.Pages.Get(key)
+		fmt.Println("Mixed content: " + key + " (" + strings.Join(info.MixedContent, ", ") + ")")
+	}
+
+	for _, finding := range result.SecurityFindings() {
+		fmt.Println("Security finding: " + finding.Page + " " + finding.Check + ": " + finding.Problem)
+	}
+
+	for _, finding := range result.Accessibility {
+		fmt.Println("Accessibility finding: " + finding.Page + " " + finding.Check + ": " + finding.Problem)
+	}
+
+	for _, key := range result.Restricted.Keys() {
+		fmt.Println("Restricted: " + key)
+	}
+
+	for _, entry := range result.WikiReports {
+		switch {
+		case !entry.Discrepancy():
+			continue
+		case entry.Wiki:
+			fmt.Println("Only in wiki " + entry.Report + ": " + entry.Title)
+		default:
+			fmt.Println("Only in crawl " + entry.Report + ": " + entry.Title)
+		}
+	}
+
+	for _, key := range result.AccessDenied.Keys() {
+		fmt.Println("Access denied: " + key)
+	}
+
+	for _, key := range result.ErrorPages.Keys() {
+		fmt.Println("Error page: " + key)
+	}
+
+	for _, key := range result.SuspectBroken.Keys() {
+		fmt.Println("Suspected broken link: " + key)
+	}
+
+	for _, key := range result.Flagged.Keys() {
+		fmt.Println("Flagged page: " + key)
+	}
+
+	for _, key := range result.AssertionFailures.Keys() {
+		fmt.Println("Assertion failed: " + key)
+	}
+
+	for _, key := range result.ParseErrors.Keys() {
+		fmt.Println("Parse error: " + key)
+	}
+
+	for _, key := range result.SmallPages.Keys() {
+		info, _ := result.Pages.Get(key)
+		fmt.Printf("Blanked page: %s (%d characters)\n", key, info.ContentSize)
+	}
+
+	if *disambiguation {
+		links, err := c.DisambiguationLinks(result)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Looking up disambiguation pages failed: %s\n", err)
+		}
+		for _, link := range links {
+			fmt.Printf("Disambiguation link: %s -> %s\n", link.Page, link.Target)
+		}
+	}
+
+	for _, key := range result.SparsePages(*minLinks) {
+		info, _ := result.Pages.Get(key)
+		fmt.Printf("Sparse page: %s (%d links)\n", key, info.LinkCount)
+	}
+}
+
+// Aborts the crawl of the queue on SIGTERM (e.g. a pod evicted) or Ctrl-C,
+// its partial result is still published. A second signal kills the process.
+func abortOnSignal(queue *wikicrawl.WorkQueue) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, os.Interrupt)
+	go func() {
+		<-signals
+		signal.Stop(signals)
+		fmt.Fprintln(os.Stderr, "Stopping, publishing partial results.")
+		queue.Abort()
+	}()
+}
+
+// Prints the progress and estimated end of the crawl to stderr every
+// interval until finished is closed.
+func printProgress(queue *wikicrawl.WorkQueue, interval time.Duration, finished chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			estimate := queue.Estimate()
+			if estimate.Total == 0 {
+				fmt.Fprintf(os.Stderr, "Progress: %d of %d+ pages, %.1f pages/s, still discovering\n",
+					estimate.Done, estimate.Discovered, estimate.PagesPerSecond)
+				continue
+			}
+			fmt.Fprintf(os.Stderr, "Progress: %d of ~%d pages, %.1f pages/s, about %s left (done around %s)\n",
+				estimate.Done, estimate.Total, estimate.PagesPerSecond, estimate.Remaining, estimate.Finish.Format("15:04"))
+		case <-finished:
+			return
+		}
 	}
 }
@@ -0,0 +1,174 @@
+package wikicrawl
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Configuration file read when present, e.g. mounted into a container.
+const DefaultConfigPath = "/etc/wikicrawl/config.yaml"
+
+// Prefix of the environment variables setting flags.
+const envPrefix = "WIKICRAWL_"
+
+// Printed instead of the values of sensitive flags.
+const redacted = "<redacted>"
+
+// Environment variable setting a flag, e.g. WIKICRAWL_MAX_PARSE_BYTES for
+// -max-parse-bytes.
+func EnvName(flagName string) string {
+	return envPrefix + strings.ToUpper(strings.Replace(flagName, "-", "_", -1))
+}
+
+// Sets the flags not given on the command line from the environment.
+// Repeatable flags get a single value.
+func ApplyEnvironment(flags *flag.FlagSet, environ []string) error {
+	env := make(map[string]string)
+	for _, variable := range environ {
+		if split := strings.Index(variable, "="); split > 0 {
+			env[variable[:split]] = variable[split+1:]
+		}
+	}
+
+	set := setFlags(flags)
+	var err error
+	flags.VisitAll(func(f *flag.Flag) {
+		value, found := env[EnvName(f.Name)]
+		if err != nil || !found || set[f.Name] {
+			return
+		}
+		if setErr := flags.Set(f.Name, value); setErr != nil {
+			err = fmt.Errorf("%s: %s", EnvName(f.Name), setErr)
+		}
+	})
+
+	return err
+}
+
+// Sets the flags given neither on the command line nor in the environment
+// from a YAML file mapping flag names to values, lists for repeatable
+// flags:
+//
+//	wiki: https://wiki.example.com/wiki/Main_Page
+//	profile: gentle
+//	label: [env=prod, team=docs]
+//
+// A missing file is only an error when required.
+func ApplyConfigFile(flags *flag.FlagSet, path string, required bool) error {
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) && !required {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var config map[string]interface{}
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return fmt.Errorf("%s: %s", path, err)
+	}
+
+	set := setFlags(flags)
+	for _, name := range sortedNames(config) {
+		if flags.Lookup(name) == nil {
+			return fmt.Errorf("%s: unknown flag %q", path, name)
+		}
+		if set[name] {
+			continue
+		}
+
+		values, isList := config[name].([]interface{})
+		if !isList {
+			values = []interface{}{config[name]}
+		}
+		for _, value := range values {
+			if err := flags.Set(name, fmt.Sprint(value)); err != nil {
+				return fmt.Errorf("%s: %s: %s", path, name, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Writes the effective value of every flag as a YAML configuration file.
+// Values of the sensitive flags, e.g. credentials or webhook urls, are
+// redacted since the output usually ends up in logs.
+func WriteConfig(writer io.Writer, flags *flag.FlagSet, sensitive ...string) error {
+	config := make(map[string]interface{})
+	flags.VisitAll(func(f *flag.Flag) {
+		config[f.Name] = f.Value.String()
+		getter, ok := f.Value.(flag.Getter)
+		if !ok {
+			return
+		}
+		switch value := getter.Get().(type) {
+		case time.Duration:
+			// Durations are kept in their flag syntax, e.g. 30s.
+		case []string:
+			if len(value) > 0 {
+				config[f.Name] = value
+			} else {
+				delete(config, f.Name)
+			}
+		default:
+			config[f.Name] = value
+		}
+	})
+	for _, name := range sensitive {
+		if value, found := config[name]; found {
+			config[name] = redact(value)
+		}
+	}
+
+	encoder := yaml.NewEncoder(writer)
+	defer encoder.Close()
+	return encoder.Encode(config)
+}
+
+// Value of a sensitive flag, unset values and the number of repeated
+// values are kept.
+func redact(value interface{}) interface{} {
+	switch value := value.(type) {
+	case []string:
+		hidden := make([]string, len(value))
+		for i := range hidden {
+			hidden[i] = redacted
+		}
+		return hidden
+	case string:
+		if len(value) == 0 {
+			return value
+		}
+	}
+
+	return redacted
+}
+
+// Names of the flags set so far.
+func setFlags(flags *flag.FlagSet) map[string]bool {
+	set := make(map[string]bool)
+	flags.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+
+	return set
+}
+
+func sortedNames(config map[string]interface{}) []string {
+	names := make([]string, 0, len(config))
+	for name := range config {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
@@ -0,0 +1,113 @@
+package wikicrawl
+
+import (
+	"bytes"
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Repeatable flag collecting every value.
+type listFlag []string
+
+func (lf *listFlag) String() string {
+	return strings.Join(*lf, ",")
+}
+
+func (lf *listFlag) Set(value string) error {
+	*lf = append(*lf, value)
+	return nil
+}
+
+func (lf *listFlag) Get() interface{} {
+	return []string(*lf)
+}
+
+func configFlags() (*flag.FlagSet, *string, *string, *time.Duration, *listFlag) {
+	flags := flag.NewFlagSet("wikicrawl", flag.ContinueOnError)
+	profile := flags.String("profile", "default", "")
+	wiki := flags.String("wiki", "", "")
+	delay := flags.Duration("delay", 0, "")
+	labels := new(listFlag)
+	flags.Var(labels, "label", "")
+	flags.Int64("max-parse-bytes", 0, "")
+	return flags, wiki, profile, delay, labels
+}
+
+func TestConfig(t *testing.T) {
+	t.Run("Configuration discovery", func(t *testing.T) {
+		t.Run("Precedence", func(t *testing.T) {
+			t.Parallel()
+			path := filepath.Join(t.TempDir(), "config.yaml")
+			ioutil.WriteFile(path, []byte("wiki: http://file.com\nprofile: gentle\ndelay: 2s\nlabel: [env=prod, team=docs]\n"), 0644)
+
+			flags, wiki, profile, delay, labels := configFlags()
+			flags.Parse([]string{"-wiki", "http://flag.com"})
+			if err := ApplyEnvironment(flags, []string{"WIKICRAWL_PROFILE=aggressive", "WIKICRAWL_WIKI=http://env.com", "HOME=/root"}); err != nil {
+				t.Fatalf("Applying environment failed: %s.", err)
+			}
+			if err := ApplyConfigFile(flags, path, true); err != nil {
+				t.Fatalf("Applying config failed: %s.", err)
+			}
+
+			if *wiki != "http://flag.com" || *profile != "aggressive" || *delay != 2*time.Second {
+				t.Errorf("Flags mismatch, got: %s %s %s, want: http://flag.com aggressive 2s.", *wiki, *profile, *delay)
+			}
+			if labels.String() != "env=prod,team=docs" {
+				t.Errorf("Labels mismatch, got: %s, want: env=prod,team=docs.", labels.String())
+			}
+		})
+
+		t.Run("Missing and invalid files", func(t *testing.T) {
+			t.Parallel()
+			flags, _, _, _, _ := configFlags()
+			missing := filepath.Join(t.TempDir(), "missing.yaml")
+			if err := ApplyConfigFile(flags, missing, false); err != nil {
+				t.Errorf("Optional missing config should be ignored, got: %s.", err)
+			}
+			if err := ApplyConfigFile(flags, missing, true); err == nil {
+				t.Errorf("Required missing config should fail.")
+			}
+
+			path := filepath.Join(t.TempDir(), "config.yaml")
+			ioutil.WriteFile(path, []byte("colour: blue\n"), 0644)
+			if err := ApplyConfigFile(flags, path, false); err == nil || !strings.Contains(err.Error(), "colour") {
+				t.Errorf("Unknown flag should fail, got: %v.", err)
+			}
+		})
+
+		t.Run("Print effective configuration", func(t *testing.T) {
+			t.Parallel()
+			flags, _, _, _, _ := configFlags()
+			flags.Parse([]string{"-wiki", "http://flag.com", "-delay", "1m", "-label", "env=prod", "-max-parse-bytes", "1024"})
+
+			var written bytes.Buffer
+			if err := WriteConfig(&written, flags); err != nil {
+				t.Fatalf("Writing config failed: %s.", err)
+			}
+			expected := "delay: 1m0s\nlabel:\n    - env=prod\nmax-parse-bytes: 1024\nprofile: default\nwiki: http://flag.com\n"
+			if written.String() != expected {
+				t.Errorf("Config mismatch, got: %s, want: %s.", written.String(), expected)
+			}
+
+			reread, wiki, _, delay, labels := configFlags()
+			path := filepath.Join(t.TempDir(), "config.yaml")
+			ioutil.WriteFile(path, written.Bytes(), 0644)
+			if err := ApplyConfigFile(reread, path, true); err != nil || *wiki != "http://flag.com" || *delay != time.Minute || labels.String() != "env=prod" {
+				t.Errorf("Printed config should read back, got: %s %s %s, err: %v.", *wiki, *delay, labels.String(), err)
+			}
+
+			written.Reset()
+			if err := WriteConfig(&written, flags, "wiki", "label", "profile"); err != nil {
+				t.Fatalf("Writing config failed: %s.", err)
+			}
+			expected = "delay: 1m0s\nlabel:\n    - <redacted>\nmax-parse-bytes: 1024\nprofile: <redacted>\nwiki: <redacted>\n"
+			if written.String() != expected {
+				t.Errorf("Redacted config mismatch, got: %s, want: %s.", written.String(), expected)
+			}
+		})
+	})
+}
@@ -0,0 +1,144 @@
+package wikicrawl
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	log "github.com/Sirupsen/logrus"
+	"golang.org/x/net/html"
+)
+
+// Id of the element holding the parsed wikitext of MediaWiki pages.
+const contentElementID = "mw-content-text"
+
+// Extracts the visible text of the article, the content element of
+// MediaWiki pages or the whole page when it has none, without the skin
+// navigation that changes on every page.
+func ContentText(reader io.Reader) string {
+	var all, content []string
+	skip := 0
+	// Depth of the content element while inside it, counting nested tags
+	// of the same name.
+	var contentTag string
+	depth, found := 0, false
+
+	z := html.NewTokenizer(reader)
+	for {
+		tokenType := z.Next()
+
+		switch tokenType {
+		case html.ErrorToken:
+			if found {
+				return strings.Join(content, " ")
+			}
+			return strings.Join(all, " ")
+		case html.StartTagToken, html.EndTagToken:
+			token := z.Token()
+			if token.Data == "script" || token.Data == "style" {
+				if tokenType == html.StartTagToken {
+					skip++
+				} else if skip > 0 {
+					skip--
+				}
+			}
+
+			switch {
+			case depth > 0 && token.Data == contentTag && tokenType == html.StartTagToken:
+				depth++
+			case depth > 0 && token.Data == contentTag:
+				depth--
+			case !found && tokenType == html.StartTagToken && attr(token, "id") == contentElementID:
+				contentTag, depth, found = token.Data, 1, true
+			}
+		case html.TextToken:
+			if skip > 0 {
+				continue
+			}
+			if chunk := strings.Join(strings.Fields(string(z.Text())), " "); len(chunk) > 0 {
+				all = append(all, chunk)
+				if depth > 0 {
+					content = append(content, chunk)
+				}
+			}
+		}
+	}
+}
+
+// Hash of the content text of a page, equal for pages whose text did not
+// change.
+func ContentHash(text string) string {
+	sum := sha256.Sum256([]byte(text))
+	return hex.EncodeToString(sum[:16])
+}
+
+// Records the content hash and size of a fetched page and reports articles
+// with less text than MinContent, e.g. blanked by accident. Redirects and
+// disambiguation pages are short on purpose and never reported.
+func (c *Crawler) checkContent(result *CrawlResult, source Link, page *Page) {
+	text := ContentText(bytes.NewReader(page.Body))
+	size := utf8.RuneCountInString(text)
+	result.record(source, func(info *PageInfo) {
+		info.ContentSize = size
+		if c.HashContent {
+			info.ContentHash = ContentHash(text)
+		}
+	})
+
+	if size >= c.MinContent || len(source.Namespace) > 0 || source.String() != page.URL.String() ||
+		IsRedirectPage(page.Body) || IsDisambiguationPage(page.Body) {
+		return
+	}
+
+	c.logger().WithFields(log.Fields{
+		"source": source,
+		"size":   size,
+	}).Warn("Article has almost no content")
+	result.SmallPages.Add(source)
+}
+
+// Kinds of content changes between runs.
+const (
+	ContentChanged = "changed"
+	ContentShrunk  = "shrunk"
+	ContentBlanked = "blanked"
+)
+
+// Page whose content differs from the previous run.
+//
+//  1. Kind: Blanked when no text is left, shrunk when it lost more than
+//     the shrink ratio of its text, changed otherwise.
+//  2. OldSize and NewSize: Characters of content text in each run.
+type ContentChange struct {
+	URL     string `json:"url"`
+	Kind    string `json:"kind"`
+	OldSize int    `json:"oldSize"`
+	NewSize int    `json:"newSize"`
+}
+
+// Pages whose content hash differs between the runs, among those hashed
+// in both. Shrink is the share of text a page must lose to be reported
+// shrunk, e.g. 0.5 for half.
+func DiffContent(old, new *CrawlResult, shrink float64) []ContentChange {
+	changes := []ContentChange{}
+	for _, info := range new.Pages.Values() {
+		before, found := old.Pages.Get(info.Link.String())
+		if !found || len(before.ContentHash) == 0 || len(info.ContentHash) == 0 || before.ContentHash == info.ContentHash {
+			continue
+		}
+
+		change := ContentChange{URL: info.Link.String(), Kind: ContentChanged, OldSize: before.ContentSize, NewSize: info.ContentSize}
+		switch {
+		case info.ContentSize == 0:
+			change.Kind = ContentBlanked
+		case float64(info.ContentSize) < float64(before.ContentSize)*(1-shrink):
+			change.Kind = ContentShrunk
+		}
+		changes = append(changes, change)
+	}
+
+	return changes
+}
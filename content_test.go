@@ -0,0 +1,105 @@
+package wikicrawl
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestContentText(t *testing.T) {
+	t.Run("Extract article text", func(t *testing.T) {
+		t.Run("MediaWiki content element", func(t *testing.T) {
+			t.Parallel()
+			body := `<html><body><div id="p-navigation">Main page Recent changes</div>
+				<div id="mw-content-text"><div class="mw-parser-output"><p>First   paragraph.</p>
+				<script>var x;</script><div>Nested</div></div></div>
+				<div id="footer">Privacy policy</div></body></html>`
+			if text := ContentText(strings.NewReader(body)); text != "First paragraph. Nested" {
+				t.Errorf("Text mismatch, got: %q, want: %q.", text, "First paragraph. Nested")
+			}
+		})
+
+		t.Run("Whole page without content element", func(t *testing.T) {
+			t.Parallel()
+			body := `<html><body><h1>Title</h1><p>Text</p></body></html>`
+			if text := ContentText(strings.NewReader(body)); text != "Title Text" {
+				t.Errorf("Text mismatch, got: %q, want: %q.", text, "Title Text")
+			}
+		})
+	})
+}
+
+func TestDiffContent(t *testing.T) {
+	t.Run("Compare content between runs", func(t *testing.T) {
+		t.Parallel()
+		run := func(texts map[string]string) *CrawlResult {
+			result := NewCrawlResult()
+			for page, text := range texts {
+				result.record(NewLink("http://testing.com/"+page), func(info *PageInfo) {
+					info.ContentHash, info.ContentSize = ContentHash(text), len(text)
+				})
+			}
+			return result
+		}
+
+		long := strings.Repeat("lorem ipsum ", 20)
+		old := run(map[string]string{"same": long, "edited": long, "cut": long, "blank": long})
+		new := run(map[string]string{"same": long, "edited": long + "more", "cut": "lorem", "blank": "", "added": long})
+
+		changes := DiffContent(old, new, 0.5)
+		kinds := make(map[string]string)
+		for _, change := range changes {
+			kinds[strings.TrimPrefix(change.URL, "http://testing.com/")] = change.Kind
+		}
+		want := map[string]string{"edited": ContentChanged, "cut": ContentShrunk, "blank": ContentBlanked}
+		if fmt.Sprint(kinds) != fmt.Sprint(want) {
+			t.Errorf("Changes mismatch, got: %v, want: %v.", kinds, want)
+		}
+	})
+}
+
+func TestHashContent(t *testing.T) {
+	t.Run("Record content hashes while crawling", func(t *testing.T) {
+		t.Parallel()
+		server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			fmt.Fprint(rw, `<html><div id="mw-content-text">Article</div></html>`)
+		}))
+		defer server.Close()
+
+		result := NewCrawler(server.URL, WithContentHashes()).Crawl(server.URL + "/start")
+		info, _ := result.Pages.Get(server.URL + "/start")
+		if info.ContentHash != ContentHash("Article") || info.ContentSize != 7 {
+			t.Errorf("Content mismatch, got: %s (%d), want: %s (7).", info.ContentHash, info.ContentSize, ContentHash("Article"))
+		}
+	})
+}
+
+func TestMinContent(t *testing.T) {
+	t.Run("Report articles with almost no text", func(t *testing.T) {
+		t.Parallel()
+		server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			switch req.URL.Path {
+			case "/start":
+				fmt.Fprintf(rw, `<html><div id="mw-content-text">%s<a href="/blank">b</a><a href="/redirect">r</a><a href="/disambiguation">d</a></div></html>`,
+					strings.Repeat("Long article text. ", 10))
+			case "/redirect":
+				fmt.Fprint(rw, `<html><div id="mw-content-text"><div class="redirectMsg">Target</div></div></html>`)
+			case "/disambiguation":
+				fmt.Fprint(rw, `<html><div id="mw-content-text"><table id="disambigbox">May refer to</table></div></html>`)
+			default:
+				fmt.Fprint(rw, `<html><div id="mw-content-text"></div><div id="footer">Privacy policy</div></html>`)
+			}
+		}))
+		defer server.Close()
+
+		result := NewCrawler(server.URL, WithMinContent(50)).Crawl(server.URL + "/start")
+		if keys := result.SmallPages.Keys(); len(keys) != 1 || keys[0] != server.URL+"/blank" {
+			t.Errorf("Small pages mismatch, got: %v, want: %s.", keys, server.URL+"/blank")
+		}
+		if info, _ := result.Pages.Get(server.URL + "/start"); len(info.ContentHash) > 0 || info.ContentSize < 50 {
+			t.Errorf("Content mismatch, got: %+v, want: size only.", info)
+		}
+	})
+}
@@ -1,15 +1,33 @@
 package wikicrawl
 
 import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
+	"io/ioutil"
+	"math"
+	"math/rand"
 	"net/http"
 	"net/http/cookiejar"
 	"net/url"
+	"regexp"
+	"runtime/debug"
+	"sort"
 	"strings"
 	"time"
 
 	log "github.com/Sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 	"golang.org/x/net/html"
+	"golang.org/x/net/html/charset"
+	"golang.org/x/text/unicode/norm"
 )
 
 // Wikimedia namespaces to ignore.
@@ -20,121 +38,1084 @@ var ignore = []string{
 	"Create_Article", "Special:",
 }
 
+// Tag attributes holding links, keyed by tag name.
+// Attributes under the "*" key are extracted from any tag.
+type LinkAttrs map[string][]string
+
+// Link attributes followed by default, anchors and image map areas.
+var AnchorAttrs = LinkAttrs{"a": {"href"}, "area": {"href"}}
+
+// Link attributes of embedded frames.
+var FrameAttrs = LinkAttrs{"frame": {"src"}, "iframe": {"src"}}
+
+// Combines several attribute tables into one.
+func MergeLinkAttrs(tables ...LinkAttrs) LinkAttrs {
+	merged := make(LinkAttrs)
+	for _, table := range tables {
+		for tag, keys := range table {
+			merged[tag] = append(merged[tag], keys...)
+		}
+	}
+
+	return merged
+}
+
 // Results of crawling wiki.
 //  1. Visited: List of visited links.
 //  2. Broken: List of Broken links.
+//  3. Flagged: List of pages flagged by a PageProcessor.
+//  4. SuspectBroken: List of links that look like soft 404 pages.
+//  5. ParseErrors: List of pages the HTML tokenizer failed on before EOF.
+//  6. Pages: Status, link count and referrers of every link seen.
+//  7. AssertionFailures: List of pages failing a content Assertion.
+//  8. ErrorPages: List of MediaWiki error pages served with a 200 status.
+//  9. AccessDenied: List of pages answered with the login form.
+//  10. Aborted: Crawl stopped before exploring every link.
+//  11. Store: Storage backend answering paginated queries when configured.
+//  12. Metadata: Provenance of the result.
+//  13. ExternalRedirects: List of links redirecting outside the crawl scope.
+//  14. Queue: Work queue usage of the crawl.
+//  15. MixedContent: List of https pages embedding plain http resources.
+//  16. Errors: Typed failure of broken, out of scope and unparsable links.
+//  17. Stall: Diagnostics when the watchdog aborted a stalled crawl.
+//  18. Fresh: List of pages taken from the previous run while fresh.
+//  19. Hrefs: How every discovered href was normalized, nil unless tracked.
+//  20. SmallPages: List of articles with less text than MinContent.
+//  21. LongBroken: List of links broken for longer than escalation allows.
+//  22. Resources: List of style sheets, style images and scripts embedded
+//     by pages, verified without being crawled.
+//  23. Scripts: List of the scripts among Resources, e.g. gadgets.
+//  24. Accessibility: Findings of an AccessibilityProcessor, set by the
+//     caller once the crawl is done.
+//  25. Restricted: List of links answered with 401 or 403, not broken.
+//  26. WikiReports: Pages flagged by the maintenance reports of the wiki
+//     or the crawl, set by the caller from CrossCheck.
+//  27. Truncated: Links were left out to stay below the memory limit, the
+//     result is incomplete, see Queue.Dropped.
 type CrawlResult struct {
-	Visited LinkSet
-	Broken  LinkSet
+	Visited           LinkSet
+	Broken            LinkSet
+	Flagged           LinkSet
+	SuspectBroken     LinkSet
+	ParseErrors       LinkSet
+	Pages             LinkMap
+	AssertionFailures LinkSet
+	ErrorPages        LinkSet
+	AccessDenied      LinkSet
+	Aborted           bool
+	Store             ResultStore
+	Metadata          Metadata
+	ExternalRedirects LinkSet
+	Queue             QueueStats
+	MixedContent      LinkSet
+	Errors            ErrorSet
+	Stall             *StallReport
+	Fresh             LinkSet
+	Hrefs             *HrefMap
+	SmallPages        LinkSet
+	LongBroken        LinkSet
+	Resources         LinkSet
+	Scripts           LinkSet
+	Accessibility     []Finding
+	Restricted        LinkSet
+	WikiReports       []ReportEntry
+	Truncated         bool
+}
+
+// Simple constructor for an empty CrawlResult.
+func NewCrawlResult() *CrawlResult {
+	return &CrawlResult{
+		Visited:           NewLinkSet(),
+		Broken:            NewLinkSet(),
+		Flagged:           NewLinkSet(),
+		SuspectBroken:     NewLinkSet(),
+		ParseErrors:       NewLinkSet(),
+		Pages:             NewLinkMap(),
+		AssertionFailures: NewLinkSet(),
+		ErrorPages:        NewLinkSet(),
+		AccessDenied:      NewLinkSet(),
+		ExternalRedirects: NewLinkSet(),
+		MixedContent:      NewLinkSet(),
+		Errors:            NewErrorSet(),
+		Fresh:             NewLinkSet(),
+		SmallPages:        NewLinkSet(),
+		LongBroken:        NewLinkSet(),
+		Resources:         NewLinkSet(),
+		Scripts:           NewLinkSet(),
+		Restricted:        NewLinkSet(),
+	}
+}
+
+// Named link sets of the result, used when exchanging or merging results.
+func (cr *CrawlResult) LinkSets() map[string]*LinkSet {
+	return map[string]*LinkSet{
+		"visited":           &cr.Visited,
+		"broken":            &cr.Broken,
+		"flagged":           &cr.Flagged,
+		"suspectBroken":     &cr.SuspectBroken,
+		"parseErrors":       &cr.ParseErrors,
+		"assertionFailures": &cr.AssertionFailures,
+		"errorPages":        &cr.ErrorPages,
+		"accessDenied":      &cr.AccessDenied,
+		"externalRedirects": &cr.ExternalRedirects,
+		"mixedContent":      &cr.MixedContent,
+		"fresh":             &cr.Fresh,
+		"smallPages":        &cr.SmallPages,
+		"longBroken":        &cr.LongBroken,
+		"resources":         &cr.Resources,
+		"scripts":           &cr.Scripts,
+		"restricted":        &cr.Restricted,
+	}
+}
+
+// Serializes the result with every link set as a sorted list.
+func (cr *CrawlResult) MarshalJSON() ([]byte, error) {
+	out := make(map[string]interface{})
+	for name, set := range cr.LinkSets() {
+		out[name] = set.Keys()
+	}
+
+	out["pages"] = cr.Pages.Values()
+	out["aborted"] = cr.Aborted
+	out["truncated"] = cr.Truncated
+	out["metadata"] = cr.Metadata
+	out["queue"] = cr.Queue
+	out["maintenance"] = cr.Maintenance()
+	out["securityFindings"] = cr.SecurityFindings()
+	if len(cr.Accessibility) > 0 {
+		out["accessibility"] = cr.Accessibility
+	}
+	if len(cr.WikiReports) > 0 {
+		out["wikiReports"] = cr.WikiReports
+	}
+	out["depths"] = cr.DepthLevels()
+	if cr.Hrefs != nil {
+		out["hrefs"] = cr.HrefMappings()
+	}
+
+	failures := make(map[string]string)
+	for _, err := range cr.Errors.Values() {
+		failures[err.URL] = err.Err.Error()
+	}
+	out["errors"] = failures
+	if cr.Stall != nil {
+		out["stall"] = cr.Stall
+	}
+
+	return json.Marshal(out)
+}
+
+// Adds everything found in other to the result.
+func (cr *CrawlResult) merge(other *CrawlResult) {
+	sets := cr.LinkSets()
+	for name, set := range other.LinkSets() {
+		set.Range(func(_ string, link Link) bool {
+			sets[name].Add(link)
+			return true
+		})
+	}
+
+	for _, info := range other.Pages.Values() {
+		cr.Pages.Update(info.Link.String(), func(current PageInfo, found bool) PageInfo {
+			if !found {
+				return info
+			}
+			return current.merge(info)
+		})
+	}
+
+	for _, err := range other.Errors.Values() {
+		cr.Errors.Put(err)
+	}
+
+	cr.Aborted = cr.Aborted || other.Aborted
+	cr.Truncated = cr.Truncated || other.Truncated
+}
+
+// Combines the results of parallel or sharded crawls (e.g. one crawl per
+// namespace) into one report: link sets are joined, page metadata and
+// referrers merged and queue stats added up.
+func MergeResults(a, b *CrawlResult) *CrawlResult {
+	merged := NewCrawlResult()
+	merged.merge(a)
+	merged.merge(b)
+
+	merged.Metadata = mergeMetadata(a.Metadata, b.Metadata)
+	merged.Queue = a.Queue.add(b.Queue)
+	merged.Stall = a.Stall
+	if merged.Stall == nil {
+		merged.Stall = b.Stall
+	}
+	merged.Store = a.Store
+	if merged.Store == nil {
+		merged.Store = b.Store
+	}
+
+	return merged
+}
+
+// Lists crawled pages with fewer than min links, a hint of silent parse
+// failures since wiki pages always carry navigation links.
+func (cr *CrawlResult) SparsePages(min int) []string {
+	var sparse []string
+	for _, info := range cr.Pages.Values() {
+		if info.Parsed && info.LinkCount < min {
+			sparse = append(sparse, info.Link.String())
+		}
+	}
+
+	return sparse
+}
+
+// Copies the configured response headers into the metadata of a link.
+func (c *Crawler) captureHeaders(info *PageInfo, header http.Header) {
+	for _, name := range c.CaptureHeaders {
+		if values := header.Values(name); len(values) > 0 {
+			info.captureHeader(http.CanonicalHeaderKey(name), strings.Join(values, ", "))
+		}
+	}
+}
+
+// Response headers kept with the body of a broken response, telling
+// firewalls, caches and application errors apart.
+var errorHeaders = []string{"Content-Type", "Server", "Via", "X-Cache", "Retry-After", "Cf-Ray", "X-Powered-By"}
+
+// Keeps the start of a broken response and its key headers when enabled.
+func (c *Crawler) captureError(info *PageInfo, header http.Header, body []byte) {
+	if c.ErrorBody <= 0 {
+		return
+	}
+	if int64(len(body)) > c.ErrorBody {
+		body = body[:c.ErrorBody]
+	}
+	info.ErrorBody = strings.ToValidUTF8(string(body), "")
+	for _, name := range errorHeaders {
+		if values := header.Values(name); len(values) > 0 {
+			info.captureHeader(name, strings.Join(values, ", "))
+		}
+	}
+}
+
+// Numbers of internal and external web links among the links of a page.
+func (c *Crawler) countLinks(links *LinkSet) (int, int) {
+	internal, external := 0, 0
+	for _, raw := range links.Keys() {
+		parsed, err := url.Parse(raw)
+		if err != nil {
+			continue
+		}
+
+		switch resolved := c.base.ResolveReference(parsed); {
+		case c.IsExternal(resolved):
+			external++
+		case resolved.Scheme == "http" || resolved.Scheme == "https":
+			internal++
+		}
+	}
+
+	return internal, external
+}
+
+// Parsed pages with the most links, at most n.
+func (cr *CrawlResult) PagesByLinks(n int) []PageInfo {
+	return cr.topPages(n, func(info PageInfo) int {
+		return info.LinkCount
+	})
+}
+
+// Parsed pages with the largest HTML, at most n.
+func (cr *CrawlResult) PagesBySize(n int) []PageInfo {
+	return cr.topPages(n, func(info PageInfo) int {
+		return info.Size
+	})
+}
+
+func (cr *CrawlResult) topPages(n int, measure func(PageInfo) int) []PageInfo {
+	var pages []PageInfo
+	for _, info := range cr.Pages.Values() {
+		if info.Parsed {
+			pages = append(pages, info)
+		}
+	}
+
+	sort.SliceStable(pages, func(i, j int) bool {
+		return measure(pages[i]) > measure(pages[j])
+	})
+	if len(pages) > n {
+		pages = pages[:n]
+	}
+
+	return pages
+}
+
+// Share of visited links found broken. On sampled crawls, an estimate of
+// the broken link rate of the whole wiki.
+func (cr *CrawlResult) BrokenRate() float64 {
+	visited := cr.Visited.Len()
+	if visited == 0 {
+		return 0
+	}
+
+	return float64(cr.Broken.Len()) / float64(visited)
+}
+
+// Pages visited at a depth from the seed.
+//
+//  1. Depth: Links followed from the seed.
+//  2. Pages: Pages first visited at the depth.
+//  3. Cumulative: Pages visited at the depth or less.
+//  4. Coverage: Share of all visited pages reached at the depth or less.
+type DepthLevel struct {
+	Depth      int     `json:"depth"`
+	Pages      int     `json:"pages"`
+	Cumulative int     `json:"cumulative"`
+	Coverage   float64 `json:"coverage"`
+}
+
+// Breadth of the crawl per depth level and the cumulative coverage curve,
+// showing how far from the seed the pages of the wiki lie.
+func (cr *CrawlResult) DepthLevels() []DepthLevel {
+	visited := cr.Visited.Values()
+	if len(visited) == 0 {
+		return nil
+	}
+
+	counts := make(map[int]int)
+	deepest := 0
+	for _, link := range visited {
+		counts[link.Depth]++
+		if link.Depth > deepest {
+			deepest = link.Depth
+		}
+	}
+
+	levels := make([]DepthLevel, 0, deepest+1)
+	cumulative := 0
+	for depth := 0; depth <= deepest; depth++ {
+		cumulative += counts[depth]
+		levels = append(levels, DepthLevel{
+			Depth:      depth,
+			Pages:      counts[depth],
+			Cumulative: cumulative,
+			Coverage:   float64(cumulative) / float64(len(visited)),
+		})
+	}
+
+	return levels
+}
+
+// Groups broken links by the pages linking to them, the pages to edit.
+// Broken links without referrer (seeds) are grouped under "".
+func (cr *CrawlResult) BrokenByReferrer() map[string][]string {
+	grouped := make(map[string][]string)
+	for _, key := range cr.Broken.Keys() {
+		info, _ := cr.Pages.Get(key)
+		if len(info.Referrers) == 0 {
+			grouped[""] = append(grouped[""], key)
+		}
+		for _, referrer := range info.Referrers {
+			grouped[referrer] = append(grouped[referrer], key)
+		}
+	}
+
+	return grouped
+}
+
+// Updates the metadata of link, creating it on first sight.
+func (cr *CrawlResult) record(link Link, update func(info *PageInfo)) {
+	cr.Pages.Update(link.String(), func(info PageInfo, found bool) PageInfo {
+		if !found {
+			info.Link = link
+		}
+		update(&info)
+		return info
+	})
 }
 
 // Crawler type holds state and methods for exploring a wiki.
 type Crawler struct {
-	base   *url.URL
-	Client *http.Client
+	base           *url.URL
+	hosts          *HostCache
+	pageIDs        *pageIDCache
+	members        map[string]bool
+	limiter        *rateLimiter
+	throttle       *adaptiveThrottle
+	ramp           *rampUp
+	previousLinks  map[string][]string
+	session        *sessionGuard
+	sessionID      string
+	middleware     []Middleware
+	overrides      []HostOverride
+	pageSet        map[string]bool
+	robots         *Robots
+	Client         *http.Client
+	Processors     []PageProcessor
+	CheckExternal  bool
+	SkipHosts      HostList
+	CheckHosts     HostList
+	Soft404        *Soft404Detector
+	FollowFrames   bool
+	ExtraAttrs     []string
+	Fetcher        Fetcher
+	Workers        int
+	RateLimit      float64
+	Retries        int
+	RetryBackoff   time.Duration
+	Delay          time.Duration
+	MaxLatency     time.Duration
+	Assertions     []Assertion
+	AbortOnLogin   bool
+	Authenticator  Authenticator
+	Validator      func(link *url.URL) bool
+	Logger         *log.Logger
+	LogFields      log.Fields
+	RunID          string
+	Deterministic  bool
+	Tracer         trace.Tracer
+	InNamespaces   []string
+	SameHost       bool
+	PathPrefix     string
+	Jitter         time.Duration
+	Shuffle        bool
+	Wikitext       bool
+	Events         EventSink
+	CaptureHeaders []string
+	Audit          bool
+	QueryRules     []QueryRule
+	VerifyOnly     []*regexp.Regexp
+	StallTimeout   time.Duration
+	MemoryLimit    uint64
+	QueueSize      int
+	MaxParseBytes  int64
+	PageKey        KeyFunc
+	Sample         float64
+	TitlePrefixes  []string
+	Categories     []string
+	Labels         map[string]string
+	Previous       *CrawlResult
+	TrackHrefs     bool
+	Forms          bool
+	Methods        []MethodRule
+	HashContent    bool
+	MinContent     int
+	WarmUp         int
+	ErrorBody      int64
+	Styles         bool
+	Scripts        bool
+	StatusRules    []StatusRule
+	Discover       bool
+	NamespaceMap   map[string]string
+	Localize       bool
+	Restricted     bool
 }
 
-// Simple constructor for Crawler type.
-func NewCrawler(base Link, session string) *Crawler {
+// Simple constructor for Crawler type, configured through functional options.
+func NewCrawler(base string, opts ...Option) *Crawler {
 	c := new(Crawler)
 	result, err := url.Parse(base)
 	if err != nil {
 		panic(err)
 	}
+	result.Host = asciiHost(result.Host)
 	c.base = result
+	c.SameHost = true
+	c.PathPrefix = norm.NFC.String(result.Path)
+	c.hosts = NewHostCache()
+	c.pageIDs = &pageIDCache{titles: make(map[string]string)}
+	c.session = new(sessionGuard)
+	c.ApplyProfile(Profiles["default"])
 
 	jar, _ := cookiejar.New(nil)
-	cookie := &http.Cookie{
-		Name:   "wikidb2_is__session",
-		Value:  session,
-		Path:   "/",
-		Domain: c.base.Host,
+	c.Client = &http.Client{
+		Timeout:       time.Second * 10,
+		Jar:           jar,
+		CheckRedirect: checkRedirect,
 	}
-	cookies := []*http.Cookie{cookie}
-	jar.SetCookies(c.base, cookies)
 
-	c.Client = &http.Client{
-		Timeout: time.Second * 10,
-		Jar:     jar,
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	if len(c.overrides) > 0 {
+		c.pinHosts()
+	}
+
+	if len(c.middleware) > 0 {
+		c.Use(c.middleware...)
+	}
+
+	if len(c.sessionID) > 0 {
+		if c.Client.Jar == nil {
+			c.Client.Jar, _ = cookiejar.New(nil)
+		}
+
+		cookie := &http.Cookie{
+			Name:   "wikidb2_is__session",
+			Value:  c.sessionID,
+			Path:   "/",
+			Domain: c.base.Hostname(),
+		}
+		cookies := []*http.Cookie{cookie}
+		c.Client.Jar.SetCookies(c.base, cookies)
 	}
 
 	return c
 }
 
+// Root url of the crawled wiki.
+func (c *Crawler) Base() *url.URL {
+	return c.base
+}
+
 // Crawls all valid links that can be found from the initial url.
-func (c *Crawler) Crawl(source Link) *CrawlResult {
-	queue := NewWorkQueue(*c, 1000)
-	queue.Start(10)
-	queue.AddWork(source)
+func (c *Crawler) Crawl(source string) *CrawlResult {
+	queue := c.Start(source)
 	queue.Wait()
 	return queue.Result
 }
 
+// Starts crawling in the background, the returned queue allows waiting
+// for, inspecting and aborting the crawl.
+func (c *Crawler) Start(source string) *WorkQueue {
+	result := NewCrawlResult()
+	result.Metadata = c.metadata(source)
+	queue := c.start(result)
+	queue.AddWork(c.Seed(source))
+	if !c.Discover {
+		return queue
+	}
+
+	// Sitemaps list pages however the wiki writes them, they are queued
+	// normalized like the links of pages. Full queues hold the overflow,
+	// queueing does not block.
+	for _, seed := range c.discover() {
+		if link, err := url.Parse(seed); err == nil {
+			if link = c.normalize(link); c.ValidateLink(link) {
+				queue.AddWork(c.classify(linkFromURL(link)))
+			}
+		}
+	}
+	return queue
+}
+
+// Starts the workers of a queue adding to result.
+func (c *Crawler) start(result *CrawlResult) *WorkQueue {
+	// Resumed runs keep their id.
+	if len(result.Metadata.Run) == 0 {
+		result.Metadata.Run = c.RunID
+	}
+	if len(result.Metadata.Run) == 0 {
+		result.Metadata.Run = newRunID()
+	}
+	c.RunID = result.Metadata.Run
+	c.limitWorkers()
+	c.limiter = newRateLimiter(c.RateLimit)
+	c.throttle = newAdaptiveThrottle(c.MaxLatency, c.workers())
+	c.ramp = newRampUp(c.WarmUp, c.workers())
+	if c.Previous != nil {
+		c.previousLinks = c.Previous.Edges()
+	}
+	if c.TrackHrefs && result.Hrefs == nil {
+		hrefs := NewHrefMap()
+		result.Hrefs = &hrefs
+	}
+	if c.Discover {
+		c.loadRobots()
+	}
+	if c.Localize {
+		c.loadNamespaces()
+	}
+	if len(c.Categories) > 0 {
+		c.loadCategories()
+	}
+	queue := NewWorkQueue(*c, c.queueSize())
+	queue.Result = result
+	queue.ctx, queue.span = c.tracer().Start(context.Background(), "crawl",
+		trace.WithAttributes(attribute.String("wikicrawl.seed", result.Metadata.Seed)))
+	queue.Start(c.workers())
+	if c.StallTimeout > 0 {
+		queue.Watch(c.StallTimeout)
+	}
+	if c.MemoryLimit > 0 {
+		queue.LimitMemory(c.MemoryLimit)
+	}
+	return queue
+}
+
+// Capacity of the work queue, 1000 links unless configured.
+func (c *Crawler) queueSize() int {
+	if c.QueueSize > 0 {
+		return c.QueueSize
+	}
+
+	return 1000
+}
+
+// Number of concurrent workers, a single one in deterministic mode so
+// links are followed in FIFO order.
+func (c *Crawler) workers() int {
+	if c.Deterministic {
+		return 1
+	}
+
+	return c.Workers
+}
+
+// Link for a crawl starting point.
+func (c *Crawler) Seed(source string) Link {
+	link := NewLink(source)
+	// Seeds keep their query, only their host matches the links of pages.
+	if link.URL != nil && len(link.URL.Host) > 0 {
+		link.URL.Host = asciiHost(link.URL.Host)
+	}
+	return c.classify(link)
+}
+
+// Link found on the parent page.
+func (c *Crawler) child(found *url.URL, parent Link) Link {
+	link := c.classify(linkFromURL(found))
+	link.Depth = parent.Depth + 1
+	return link
+}
+
+// Sets the Class of a link relative to the crawled wiki.
+func (c *Crawler) classify(link Link) Link {
+	switch {
+	case link.URL == nil:
+		link.Class = InvalidLink
+	case strings.EqualFold(link.URL.Host, c.base.Host):
+		link.Class = InternalLink
+	default:
+		link.Class = ExternalLink
+	}
+	if link.Class == InternalLink {
+		link.Namespace = TitleNamespace(c.canonicalTitle(link.Title))
+	}
+
+	return link
+}
+
+// Follows a link, recording a panic as a broken link instead of taking
+// the whole crawl down. Failures of the queue itself are not recovered.
+func (c *Crawler) followIsolated(source Link, queue *WorkQueue) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			if failure, ok := recovered.(queueFailure); ok {
+				panic(failure)
+			}
+			c.logger().WithFields(log.Fields{
+				"source": source,
+				"panic":  recovered,
+				"stack":  string(debug.Stack()),
+			}).Error("Recovered from panic following link")
+			c.broken(queue.Result, source, linkError(source, ErrPanic, fmt.Errorf("%v", recovered)))
+		}
+	}()
+
+	c.FollowLink(source, queue)
+}
+
 func (c *Crawler) FollowLink(source Link, queue *WorkQueue) {
 
 	// Avoid duplicate visits.
+	key := source.String()
+	if queue.Result.AccessDenied.Contains(key) {
+		return
+	}
 	if ok := queue.Result.Visited.Add(source); !ok {
 		return
 	}
 
-	log.WithFields(log.Fields{"source": source}).Debug("Crawling new url")
+	ctx, span := c.tracer().Start(queue.ctx, "page", trace.WithAttributes(
+		attribute.String("url.full", key),
+		attribute.Int("wikicrawl.depth", source.Depth),
+		attribute.Int("wikicrawl.queue.pending", queue.Pending()),
+	))
+	defer span.End()
+
+	if source.URL != nil && c.ShouldVerify(source.URL) {
+		c.VerifyExternal(ctx, source, queue)
+		return
+	}
+
+	if queue.Result.Resources.Contains(key) {
+		c.checkResource(ctx, source, queue)
+		return
+	}
+
+	if c.reuseFresh(queue, source) {
+		return
+	}
+
+	c.logger().WithFields(log.Fields{"source": source}).Debug("Crawling new url")
 
-	resp, err := c.Client.Get(source)
+	generation := c.sessionGeneration()
+	page, err := c.politeFetch(ctx, source)
+	if err == nil && c.Authenticator != nil && IsLoginPage(page) {
+		if err := c.refreshSession(generation); err != nil {
+			c.logger().WithFields(log.Fields{"err": err}).Error("Failed logging in again")
+		} else {
+			page, err = c.politeFetch(ctx, source)
+		}
+	}
 	if err != nil {
-		log.WithFields(log.Fields{
+		c.logger().WithFields(log.Fields{
 			"err": err,
 		}).Warn("GET returned with error")
-		queue.Result.Broken.Add(source)
+		var cycle *RedirectCycleError
+		if errors.As(err, &cycle) {
+			c.recordRedirects(queue.Result, cycle.Chain)
+		}
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		c.broken(queue.Result, source, linkError(source, ErrFetch, err))
 		return
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != 200 {
-		log.WithFields(log.Fields{
+	span.SetAttributes(attribute.Int("http.response.status_code", page.StatusCode))
+	queue.Result.record(source, func(info *PageInfo) {
+		info.Status = page.StatusCode
+		c.captureHeaders(info, page.Header)
+		if page.StatusCode == 200 {
+			info.FreshUntil = FreshUntil(page.Header, time.Now())
+		}
+	})
+	c.emit(Event{Type: EventFetch, URL: key, Status: page.StatusCode})
+
+	redirected := key != page.URL.String() && !c.InScope(page.URL)
+	if page.StatusCode != 200 && c.acceptStatus(source, page.StatusCode, redirected) {
+		if redirected {
+			queue.Result.record(source, func(info *PageInfo) {
+				info.RedirectTo = page.URL.String()
+			})
+			queue.Result.ExternalRedirects.Add(source)
+		}
+		return
+	}
+	if page.StatusCode != 200 {
+		c.logger().WithFields(log.Fields{
 			"source": source,
-			"status": resp.Status,
+			"status": page.Status,
 		}).Warn("GET returned with non 200 response")
-		queue.Result.Broken.Add(source)
+		queue.Result.record(source, func(info *PageInfo) {
+			c.captureError(info, page.Header, page.Body)
+			// Redirects to missing pages are broken redirects.
+			if key != page.URL.String() {
+				info.RedirectTo, info.RedirectHops = page.URL.String(), page.Redirects
+			}
+		})
+		if c.restricted(queue.Result, source, page.StatusCode) {
+			return
+		}
+		c.broken(queue.Result, source, statusError(source, page.StatusCode, page.Status))
 		return
 	}
 
-	if source != resp.Request.URL.String() {
-		log.WithFields(log.Fields{
+	if IsLoginPage(page) {
+		c.logger().WithFields(log.Fields{
+			"source": source,
+			"final":  page.URL,
+		}).Warn("Login form served instead of content")
+		queue.Result.Visited.Remove(key)
+		queue.Result.AccessDenied.Add(source)
+
+		if c.AbortOnLogin {
+			c.logger().Error("Aborting crawl, session is not logged in")
+			queue.Abort()
+		}
+		return
+	}
+
+	if key != page.URL.String() {
+		c.logger().WithFields(log.Fields{
 			"requested": source,
-			"redirect":  resp.Request.URL,
+			"redirect":  page.URL,
 		}).Warn("Redirect detected.")
 
-		if ok := queue.Result.Visited.Add(resp.Request.URL.String()); !ok {
+		queue.Result.record(source, func(info *PageInfo) {
+			info.RedirectTo, info.RedirectHops = page.URL.String(), page.Redirects
+		})
+		c.emit(Event{Type: EventRedirect, URL: key, Status: page.StatusCode, Target: page.URL.String()})
+
+		// Out of scope targets (SSO, link shorteners) are neither visited nor parsed.
+		if !c.InScope(page.URL) {
+			c.logger().WithFields(log.Fields{
+				"source":   source,
+				"redirect": page.URL,
+			}).Warn("Redirect leaves the crawled wiki")
+			queue.Result.ExternalRedirects.Add(source)
+			queue.Result.Errors.Put(linkError(source, ErrScope, fmt.Errorf("redirects to %s", page.URL)))
+			return
+		}
+
+		redirect := c.classify(linkFromURL(page.URL))
+		redirect.Depth = source.Depth
+		if ok := queue.Result.Visited.Add(redirect); !ok {
 			return
 		}
 	}
 
-	for raw := range ParseLinks(resp.Body).Set {
+	if page.URL.Scheme == "https" {
+		if resources := MixedContent(page.Body, page.URL); len(resources) > 0 {
+			c.logger().WithFields(log.Fields{
+				"source":    source,
+				"resources": resources,
+			}).Warn("Page embeds plain http resources")
+			queue.Result.MixedContent.Add(source)
+			queue.Result.record(source, func(info *PageInfo) {
+				info.MixedContent = resources
+			})
+		}
+	}
+
+	if c.Audit {
+		findings := AuditPage(page)
+		queue.Result.record(source, func(info *PageInfo) {
+			info.Findings = findings
+		})
+	}
+
+	if c.HashContent || c.MinContent > 0 {
+		c.checkContent(queue.Result, source, page)
+	}
+
+	if IsMediaWikiError(page.Body) {
+		c.logger().WithFields(log.Fields{"source": source}).Warn("MediaWiki error page served with 200 response")
+		queue.Result.ErrorPages.Add(source)
+	}
+
+	for _, processor := range c.Processors {
+		if processor.Process(source, page.Body) {
+			queue.Result.Flagged.Add(source)
+		}
+	}
+
+	for _, assertion := range c.Assertions {
+		if assertion.Applies(source) && !assertion.Holds(page.Body) {
+			c.logger().WithFields(log.Fields{
+				"source":  source,
+				"content": assertion.Content.String(),
+			}).Warn("Page failed content assertion")
+			queue.Result.AssertionFailures.Add(source)
+		}
+	}
+
+	// Verify-only pages are confirmed alive but not expanded.
+	if c.IsVerifyOnly(source) {
+		return
+	}
+
+	// Fetchers may have parsed the links while downloading the body.
+	links, err := page.Links, page.ParseErr
+	if links == nil {
+		links, err = ParsePageAttrs(bytes.NewReader(page.Body), c.LinkAttrs())
+	}
+	if err != nil {
+		c.logger().WithFields(log.Fields{
+			"source": source,
+			"err":    err,
+		}).Warn("HTML tokenizer failed before end of page")
+		queue.Result.ParseErrors.Add(source)
+		queue.Result.Errors.Put(linkError(source, ErrParse, err))
+	}
+	if title := WikiPageTitle(page.URL); c.Wikitext && len(title) > 0 {
+		text := c.pageWikitext(ctx, title)
+		for _, href := range c.wikitextLinks(text) {
+			links.Add(NewLink(href))
+		}
+		c.recordCitations(queue.Result, source, text)
+	}
+	if c.Forms {
+		for _, target := range FormTargets(bytes.NewReader(page.Body), page.URL) {
+			links.Add(NewLink(target))
+		}
+	}
+	if c.Styles {
+		c.queueResources(queue, source, page.URL, StyleURLs(bytes.NewReader(page.Body)))
+	}
+	if c.Scripts {
+		for _, script := range c.queueResources(queue, source, page.URL, ScriptURLs(bytes.NewReader(page.Body))) {
+			queue.Result.Scripts.Add(script)
+		}
+	}
+	internal, external := c.countLinks(links)
+	queue.Result.record(source, func(info *PageInfo) {
+		info.Parsed = true
+		info.LinkCount = links.Len()
+		info.InternalLinks = internal
+		info.ExternalLinks = external
+		info.Size = len(page.Body)
+	})
+	span.SetAttributes(attribute.Int("wikicrawl.links", links.Len()))
+	c.queueLinks(queue, source, c.order(links))
+}
+
+// Records the links found on source and queues those to follow.
+func (c *Crawler) queueLinks(queue *WorkQueue, source Link, raws []string) {
+	key := source.String()
+	referrer := func(info *PageInfo) {
+		info.AddReferrer(key)
+	}
+
+	for _, raw := range raws {
 		result, err := url.Parse(raw)
 		if err != nil {
-			queue.Result.Broken.Add(raw)
+			invalid := NewLink(raw)
+			invalid.Depth = source.Depth + 1
+			c.broken(queue.Result, invalid, linkError(invalid, ErrParse, err))
+			queue.Result.record(invalid, referrer)
+			queue.Result.mapHref(key, raw, "", SkippedInvalid)
 			continue
 		}
 
-		href := NormalizeUrl(result, c.base)
-		if c.ValidateLink(href) && !queue.Result.Visited.Contains(href.String()) {
-			queue.AddWork(href.String())
-		} else {
-			log.WithFields(log.Fields{"href": href}).Debug("Skipping link.")
+		if external := c.base.ResolveReference(result); c.ShouldVerify(external) {
+			external.Fragment = ""
+			link := c.child(external, source)
+			queue.Result.record(link, referrer)
+			queue.Result.mapHref(key, raw, link.String(), "")
+			if !queue.Result.Visited.Contains(link.String()) && c.sampled(link) {
+				c.hosts.Prefetch(external.Hostname())
+				queue.AddWork(link)
+			}
+			continue
+		}
+
+		href := c.resolvePageID(c.normalize(result))
+		if !c.ValidateLink(href) {
+			c.logger().WithFields(log.Fields{"href": href}).Debug("Skipping link.")
+			c.emit(Event{Type: EventSkip, URL: href.String()})
+			queue.Result.mapHref(key, raw, href.String(), SkippedExcluded)
+			continue
+		}
+
+		link := c.child(href, source)
+		queue.Result.record(link, referrer)
+		queue.Result.mapHref(key, raw, link.String(), "")
+		if !queue.Result.Visited.Contains(link.String()) && c.sampled(link) {
+			queue.AddWork(link)
+		}
+	}
+}
+
+// Reports if a discovered link is part of the sample, every link unless
+// sampling a fraction of them. Links are picked by a hash of their key so
+// a link is sampled no matter how many pages link to it, and sampled
+// crawls of the same wiki stay comparable.
+func (c *Crawler) sampled(link Link) bool {
+	if c.Sample <= 0 || c.Sample >= 1 {
+		return true
+	}
+
+	sum := sha256.Sum256([]byte(c.pageKey()(link)))
+	return float64(binary.BigEndian.Uint64(sum[:8]))/math.MaxUint64 < c.Sample
+}
+
+// Links found on a page, sorted in deterministic mode and shuffled when
+// requested.
+func (c *Crawler) order(links *LinkSet) []string {
+	if c.Deterministic {
+		return links.Keys()
+	}
+
+	keys := make([]string, 0, links.Len())
+	links.Range(func(key string, _ Link) bool {
+		keys = append(keys, key)
+		return true
+	})
+
+	if c.Shuffle {
+		rand.Shuffle(len(keys), func(i, j int) {
+			keys[i], keys[j] = keys[j], keys[i]
+		})
+	}
+
+	return keys
+}
+
+// Logger for crawl messages, the logrus standard logger unless configured.
+// Messages carry the run id and wiki host, so logs of concurrent crawls in
+// one process can be told apart.
+func (c *Crawler) logger() *log.Entry {
+	logger := c.Logger
+	if logger == nil {
+		logger = log.StandardLogger()
+	}
+
+	fields := log.Fields{}
+	for key, value := range c.LogFields {
+		fields[key] = value
+	}
+	if c.base != nil {
+		fields["host"] = c.base.Host
+	}
+	if len(c.RunID) > 0 {
+		fields["run"] = c.RunID
+	}
+
+	return logger.WithFields(fields)
+}
+
+// Fetcher used for crawled pages, plain GET requests unless configured.
+func (c *Crawler) fetcher() Fetcher {
+	if c.Fetcher != nil {
+		return c.Fetcher
+	}
+
+	return &HTTPFetcher{Client: c.Client, Attrs: c.LinkAttrs(), MaxBytes: c.MaxParseBytes, Methods: c.Methods, ErrorBytes: c.ErrorBody}
+}
+
+// Tag attributes the crawler extracts links from.
+//
+//  1. Anchors and image map areas.
+//  2. Frame sources when FollowFrames is on.
+//  3. ExtraAttrs (e.g. data-href) on any tag.
+func (c *Crawler) LinkAttrs() LinkAttrs {
+	tables := []LinkAttrs{AnchorAttrs}
+	if c.FollowFrames {
+		tables = append(tables, FrameAttrs)
+	}
+	if len(c.ExtraAttrs) > 0 {
+		tables = append(tables, LinkAttrs{"*": c.ExtraAttrs})
+	}
+
+	return MergeLinkAttrs(tables...)
+}
+
+// Reports if a link matches a VerifyOnly pattern or, in focused crawls,
+// lies outside the focused area or the compared page set.
+func (c *Crawler) IsVerifyOnly(link Link) bool {
+	if !c.InFocus(link) {
+		return true
+	}
+	if c.pageSet != nil && !c.pageSet[link.String()] {
+		return true
+	}
+
+	for _, pattern := range c.VerifyOnly {
+		if pattern.MatchString(link.String()) {
+			return true
 		}
 	}
+
+	return false
 }
 
 // Validates if link should be followed.
 //
 //  1. Only crawls internal links.
 //  2. Skips trivial Wikimedia namespaces.
+//  3. Skips pages robots.txt disallows, when discovering seeds.
+//  4. Applies the custom Validator when configured.
 func (c *Crawler) ValidateLink(link *url.URL) bool {
-	if !strings.Contains(link.String(), c.base.String()) {
+	if !c.InScope(link) {
+		return false
+	}
+
+	if strings.EqualFold(link.Host, c.base.Host) && !c.robots.Allowed(link) {
 		return false
 	}
 
-	if title := WikiPageTitle(link); len(title) > 0 {
+	if title := c.canonicalTitle(WikiPageTitle(link)); len(title) > 0 {
 		for _, trivial := range ignore {
 			if strings.HasPrefix(title, trivial) {
 				return false
@@ -142,9 +1123,59 @@ func (c *Crawler) ValidateLink(link *url.URL) bool {
 		}
 	}
 
+	if len(c.InNamespaces) > 0 && !c.inNamespace(link) {
+		return false
+	}
+
+	if c.Validator != nil {
+		return c.Validator(link)
+	}
+
 	return true
 }
 
+// Reports if link is a web page within the crawled part of the wiki.
+//
+//  1. SameHost: The host (and port) must match the base url.
+//  2. PathPrefix: The path must start with the prefix, on a segment boundary.
+func (c *Crawler) InScope(link *url.URL) bool {
+	if link.Scheme != "http" && link.Scheme != "https" {
+		return false
+	}
+
+	if c.SameHost && !strings.EqualFold(link.Host, c.base.Host) {
+		return false
+	}
+
+	return hasPathPrefix(link.Path, c.PathPrefix)
+}
+
+func hasPathPrefix(path, prefix string) bool {
+	prefix = strings.TrimSuffix(prefix, "/")
+	if !strings.HasPrefix(path, prefix) {
+		return false
+	}
+
+	return len(path) == len(prefix) || path[len(prefix)] == '/'
+}
+
+// Reports if the page title of link belongs to one of InNamespaces, pages
+// without a namespace prefix belong to Main.
+func (c *Crawler) inNamespace(link *url.URL) bool {
+	namespace := TitleNamespace(c.canonicalTitle(WikiPageTitle(link)))
+	if len(namespace) == 0 {
+		namespace = "Main"
+	}
+
+	for _, allowed := range c.InNamespaces {
+		if strings.EqualFold(strings.Replace(allowed, " ", "_", -1), namespace) {
+			return true
+		}
+	}
+
+	return false
+}
+
 // Parse WikiMedia page title with namespace.
 // WikiMedia short url's not supported.
 func WikiPageTitle(link *url.URL) string {
@@ -163,21 +1194,25 @@ func WikiPageTitle(link *url.URL) string {
 // Normalize a url to facilitate comparison.
 //
 //  1. Resolve url from known base (/relative => http://base/relative)
-//  2. Cleanup query by filtering unnecessary parameters
+//  2. Cleanup query by filtering unnecessary parameters (DefaultQueryRules)
 //  3. Remove any URL fragment (#junk)
 //  4. Force protocol to match base
-//  5. Unify case of host and protocol
+//  5. Unify case of host and protocol, hosts in their ASCII (punycode) form
+//  6. Unify the Unicode form and percent encoding of the path and title
 func NormalizeUrl(link *url.URL, base *url.URL) *url.URL {
-	clean := base.ResolveReference(link)
-
-	if title := WikiPageTitle(clean); len(title) != 0 {
-		clean.RawQuery = url.Values{"title": []string{title}}.Encode()
-	}
+	return NormalizeUrlRules(link, base, DefaultQueryRules)
+}
 
+// Normalize a url like NormalizeUrl, keeping the query parameters of the
+// first matching rule.
+func NormalizeUrlRules(link *url.URL, base *url.URL, rules []QueryRule) *url.URL {
+	clean := base.ResolveReference(link)
 	clean.Fragment = ""
+	applyQueryRules(clean, rules)
 
 	clean.Scheme = strings.ToLower(base.Scheme)
-	clean.Host = strings.ToLower(clean.Host)
+	clean.Host = asciiHost(clean.Host)
+	normalizeUnicode(clean)
 
 	log.WithFields(log.Fields{
 		"base":     base.String(),
@@ -188,27 +1223,91 @@ func NormalizeUrl(link *url.URL, base *url.URL) *url.URL {
 	return clean
 }
 
+// Transcodes a page body to UTF-8.
+// The charset is sniffed from BOMs, the Content-Type header and meta tags.
+func DecodeBody(body []byte, contentType string) []byte {
+	reader, err := charset.NewReader(bytes.NewReader(body), contentType)
+	if err != nil {
+		return body
+	}
+
+	decoded, err := ioutil.ReadAll(reader)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"contentType": contentType,
+			"err":         err,
+		}).Warn("Failed transcoding page body")
+		return body
+	}
+
+	return bytes.TrimPrefix(decoded, []byte("\xef\xbb\xbf"))
+}
+
 // Parses HTML and returns a list of all href values found.
-func ParseLinks(reader io.Reader) LinkSet {
+func ParseLinks(reader io.Reader) *LinkSet {
+	links, _ := ParsePage(reader)
+	return links
+}
+
+// Parses HTML and returns all href values found along with any tokenizer
+// error other than reaching the end of the page.
+func ParsePage(reader io.Reader) (*LinkSet, error) {
+	return ParsePageAttrs(reader, AnchorAttrs)
+}
+
+// Parses HTML and returns the values of all link attributes found along
+// with any tokenizer error other than reaching the end of the page.
+//
+// Tags are read with TagName and TagAttr instead of Token so tags without
+// link attributes cost no allocation.
+func ParsePageAttrs(reader io.Reader, attrs LinkAttrs) (*LinkSet, error) {
 	links := NewLinkSet()
+	wildcard := attrs["*"]
 	z := html.NewTokenizer(reader)
 	for {
 		tokenType := z.Next()
 
 		switch {
 		case tokenType == html.ErrorToken:
-			return links
+			if err := z.Err(); err != io.EOF {
+				return &links, err
+			}
+			return &links, nil
 		case tokenType == html.StartTagToken || tokenType == html.SelfClosingTagToken:
-			token := z.Token()
-
-			if token.Data == "a" {
-				for _, attr := range token.Attr {
-					if attr.Key == "href" {
-						links.Add(attr.Val)
-						break
-					}
+			name, hasAttr := z.TagName()
+			keys := attrs[string(name)]
+			if len(keys) == 0 && len(wildcard) == 0 {
+				continue
+			}
+
+			for hasAttr {
+				var key, val []byte
+				key, val, hasAttr = z.TagAttr()
+				if containsBytes(keys, key) || containsBytes(wildcard, key) {
+					links.Add(NewLink(string(val)))
 				}
 			}
 		}
 	}
 }
+
+// Same as contains without converting value to a string.
+func containsBytes(list []string, value []byte) bool {
+	for _, item := range list {
+		if item == string(value) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+
+	return false
+}
@@ -1,13 +1,20 @@
 package wikicrawl
 
 import (
+	"bytes"
 	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
 	"reflect"
+	"regexp"
 	"strings"
 	"testing"
+	"testing/iotest"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
 )
 
 type expectedCounts struct {
@@ -24,7 +31,7 @@ func validateCrawl(t *testing.T, expected expectedCounts, handler func(http.Resp
 	}))
 	defer server.Close()
 
-	result := NewCrawler(server.URL, "").Crawl(server.URL)
+	result := NewCrawler(server.URL).Crawl(server.URL)
 	if len(result.Visited.Set) != expected.linkCount {
 		t.Errorf(`Visited links do not match expected.
 			Expected %d, found %d.`, expected.linkCount, len(result.Visited.Set))
@@ -72,6 +79,77 @@ func TestCrawl(t *testing.T) {
 				fmt.Fprintf(rw, `<html><body><a href="/path" /><a href="/error" /></body></html>`)
 			})
 		})
+
+		t.Run("Record link depth and class", func(t *testing.T) {
+			t.Parallel()
+			server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				if req.URL.Path == "/" {
+					fmt.Fprintf(rw, `<html><body><a href="/a" /></body></html>`)
+					return
+				}
+				fmt.Fprintf(rw, `<html><body><a href="/b" /></body></html>`)
+			}))
+			defer server.Close()
+
+			result := NewCrawler(server.URL).Crawl(server.URL + "/")
+			for path, depth := range map[string]int{"/": 0, "/a": 1, "/b": 2} {
+				link, found := result.Visited.Get(server.URL + path)
+				if !found || link.Depth != depth || link.Class != InternalLink {
+					t.Errorf("Link %s mismatch, got: %+v, want depth: %d.", path, link, depth)
+				}
+			}
+		})
+	})
+}
+
+func TestExternalRedirects(t *testing.T) {
+	t.Run("Redirects leaving the wiki", func(t *testing.T) {
+		t.Parallel()
+		external := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			fmt.Fprint(rw, `<html><body><a href="/sso-page" /></body></html>`)
+		}))
+		defer external.Close()
+
+		server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			if req.URL.Path == "/login" {
+				http.Redirect(rw, req, external.URL+"/sso", http.StatusFound)
+				return
+			}
+			fmt.Fprint(rw, `<html><body><a href="/login" /></body></html>`)
+		}))
+		defer server.Close()
+
+		result := NewCrawler(server.URL).Crawl(server.URL + "/")
+		if !result.ExternalRedirects.Contains(server.URL + "/login") {
+			t.Errorf("External redirects mismatch, got: %v.", result.ExternalRedirects.Keys())
+		}
+
+		if result.Visited.Contains(external.URL+"/sso") || result.Visited.Len() != 2 {
+			t.Errorf("Redirect target should not be visited, got: %v.", result.Visited.Keys())
+		}
+	})
+}
+
+func TestDeterministicCrawl(t *testing.T) {
+	t.Run("Reproducible crawl order", func(t *testing.T) {
+		t.Parallel()
+		var requests []string
+		server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			requests = append(requests, req.URL.Path)
+			switch req.URL.Path {
+			case "/":
+				fmt.Fprintf(rw, `<html><body><a href="/c" /><a href="/a" /><a href="/b" /></body></html>`)
+			case "/a":
+				fmt.Fprintf(rw, `<html><body><a href="/e" /><a href="/d" /></body></html>`)
+			}
+		}))
+		defer server.Close()
+
+		NewCrawler(server.URL, WithDeterministic()).Crawl(server.URL + "/")
+		expected := []string{"/", "/a", "/b", "/c", "/d", "/e"}
+		if !reflect.DeepEqual(requests, expected) {
+			t.Errorf("Crawl order mismatch, got: %v, want: %v.", requests, expected)
+		}
 	})
 }
 
@@ -93,7 +171,7 @@ func TestValidateLink(t *testing.T) {
 		t.Run("Validate successful link", func(t *testing.T) {
 			t.Parallel()
 			link, _ := url.Parse("http://testing.com?title=Accept")
-			c := NewCrawler("http://testing.com", "")
+			c := NewCrawler("http://testing.com")
 			if !c.ValidateLink(link) {
 				t.Errorf("Url incorrectly marked as invalid: %s.", link)
 			}
@@ -102,7 +180,7 @@ func TestValidateLink(t *testing.T) {
 		t.Run("Validate link with missing title", func(t *testing.T) {
 			t.Parallel()
 			link, _ := url.Parse("http://testing.com?notitle=1")
-			c := NewCrawler("http://testing.com", "")
+			c := NewCrawler("http://testing.com")
 			if !c.ValidateLink(link) {
 				t.Errorf("Url incorrectly marked as invalid: %s.", link)
 			}
@@ -111,16 +189,64 @@ func TestValidateLink(t *testing.T) {
 		t.Run("Skip outside link", func(t *testing.T) {
 			t.Parallel()
 			link, _ := url.Parse("http://otherdomain.com?title=Accept")
-			c := NewCrawler("http://testing.com", "")
+			c := NewCrawler("http://testing.com")
+			if c.ValidateLink(link) {
+				t.Errorf("Url incorrectly marked as valid: %s.", link)
+			}
+		})
+
+		t.Run("Skip base url in query of other host", func(t *testing.T) {
+			t.Parallel()
+			link, _ := url.Parse("http://evil.com/?u=http://testing.com")
+			c := NewCrawler("http://testing.com")
 			if c.ValidateLink(link) {
 				t.Errorf("Url incorrectly marked as valid: %s.", link)
 			}
 		})
 
+		t.Run("Path prefix scoping", func(t *testing.T) {
+			t.Parallel()
+			c := NewCrawler("http://testing.com/wiki")
+			for raw, expected := range map[string]bool{
+				"http://testing.com/wiki":           true,
+				"http://testing.com/wiki/Page":      true,
+				"http://testing.com/wikipedia/Page": false,
+				"http://testing.com/other/Page":     false,
+				"http://mirror.com/wiki/Page":       false,
+			} {
+				link, _ := url.Parse(raw)
+				if c.ValidateLink(link) != expected {
+					t.Errorf("Scope mismatch for %s, want: %t.", link, expected)
+				}
+			}
+
+			c = NewCrawler("http://testing.com/wiki", WithScope(false, "/"))
+			link, _ := url.Parse("http://mirror.com/other/Page")
+			if !c.ValidateLink(link) {
+				t.Errorf("Url incorrectly marked as invalid: %s.", link)
+			}
+		})
+
+		t.Run("Only follow selected namespaces", func(t *testing.T) {
+			t.Parallel()
+			c := NewCrawler("http://testing.com", WithNamespaces("Main", "Category"))
+			for raw, expected := range map[string]bool{
+				"http://testing.com?title=Accept":            true,
+				"http://testing.com?title=Category:Servers":  true,
+				"http://testing.com?title=Template:Infobox":  false,
+				"http://testing.com?title=Install:_Step_one": true,
+			} {
+				link, _ := url.Parse(raw)
+				if c.ValidateLink(link) != expected {
+					t.Errorf("Namespace scope mismatch for %s, want: %t.", link, expected)
+				}
+			}
+		})
+
 		t.Run("Skip forbidden pages", func(t *testing.T) {
 			t.Parallel()
 			link, _ := url.Parse("http://testing.com?title=Help:Skip")
-			c := NewCrawler("http://testing.com", "")
+			c := NewCrawler("http://testing.com")
 			if c.ValidateLink(link) {
 				t.Errorf("Url incorrectly marked as valid: %s.", link)
 			}
@@ -128,11 +254,11 @@ func TestValidateLink(t *testing.T) {
 	})
 }
 
-func validateParseLinks(t *testing.T, html string, expected LinkSet) {
+func validateParseLinks(t *testing.T, html string, expected *LinkSet) {
 	found := ParseLinks(strings.NewReader(html))
 
-	if !reflect.DeepEqual(found, expected) {
-		t.Errorf("Parsing links failed, got: %v, want: %v.", found, expected)
+	if !reflect.DeepEqual(found.Set, expected.Set) {
+		t.Errorf("Parsing links failed, got: %v, want: %v.", found.Set, expected.Set)
 	}
 }
 
@@ -143,9 +269,9 @@ func TestParseLinks(t *testing.T) {
 			html := `<html><body><a href="testing"></body></html>`
 
 			expected := NewLinkSet()
-			expected.Add("testing")
+			expected.Add(NewLink("testing"))
 
-			validateParseLinks(t, html, expected)
+			validateParseLinks(t, html, &expected)
 		})
 
 		t.Run("Malformed HTML missing closing body tag", func(t *testing.T) {
@@ -153,9 +279,9 @@ func TestParseLinks(t *testing.T) {
 			html := `<html><body><a href="testing"></html>`
 
 			expected := NewLinkSet()
-			expected.Add("testing")
+			expected.Add(NewLink("testing"))
 
-			validateParseLinks(t, html, expected)
+			validateParseLinks(t, html, &expected)
 		})
 
 		t.Run("Parsing self closing tag", func(t *testing.T) {
@@ -163,9 +289,29 @@ func TestParseLinks(t *testing.T) {
 			html := `<html><body><a href="testing" /></body></html>`
 
 			expected := NewLinkSet()
-			expected.Add("testing")
+			expected.Add(NewLink("testing"))
+
+			validateParseLinks(t, html, &expected)
+		})
+
+		t.Run("Upper case tags and escaped values", func(t *testing.T) {
+			t.Parallel()
+			html := `<HTML><BODY><A HREF="/index.php?title=A&amp;action=view">A</A></BODY></HTML>`
+
+			expected := NewLinkSet()
+			expected.Add(NewLink("/index.php?title=A&action=view"))
+
+			validateParseLinks(t, html, &expected)
+		})
+
+		t.Run("Parsing image map areas", func(t *testing.T) {
+			t.Parallel()
+			html := `<html><body><map><area shape="rect" href="testing" /></map></body></html>`
+
+			expected := NewLinkSet()
+			expected.Add(NewLink("testing"))
 
-			validateParseLinks(t, html, expected)
+			validateParseLinks(t, html, &expected)
 		})
 	})
 }
@@ -208,6 +354,31 @@ func TestNormalizeUrl(t *testing.T) {
 			}
 		})
 
+		t.Run("Keep parameters of matching query rule", func(t *testing.T) {
+			t.Parallel()
+			base, _ := url.Parse("http://testing.com")
+			link, _ := url.Parse("http://testing.com/index.php?title=Special:AllPages&from=B&useskin=vector#top")
+			rules := []QueryRule{{URL: regexp.MustCompile(`title=Special:AllPages`), Keep: []string{"title", "from"}}}
+
+			found := NormalizeUrlRules(link, base, append(rules, DefaultQueryRules...)).String()
+			expected := "http://testing.com/index.php?from=B&title=Special%3AAllPages"
+			if found != expected {
+				t.Errorf("Url malformed, got: %s, want: %s.", found, expected)
+			}
+		})
+
+		t.Run("Keep every parameter without matching rule", func(t *testing.T) {
+			t.Parallel()
+			base, _ := url.Parse("http://testing.com")
+			link, _ := url.Parse("http://testing.com/search?q=wiki&page=2")
+
+			found := NormalizeUrl(link, base).String()
+			expected := "http://testing.com/search?q=wiki&page=2"
+			if found != expected {
+				t.Errorf("Url malformed, got: %s, want: %s.", found, expected)
+			}
+		})
+
 		t.Run("Lowercase host and scheme", func(t *testing.T) {
 			t.Parallel()
 			base, _ := url.Parse("HTTP://Testing.Com")
@@ -221,3 +392,436 @@ func TestNormalizeUrl(t *testing.T) {
 		})
 	})
 }
+
+func TestDecodeBody(t *testing.T) {
+	t.Run("Transcode page bodies to UTF-8", func(t *testing.T) {
+		t.Run("Charset from Content-Type header", func(t *testing.T) {
+			t.Parallel()
+			found := string(DecodeBody([]byte("<a href=\"/Caf\xe9\">"), "text/html; charset=ISO-8859-1"))
+			expected := `<a href="/Café">`
+			if found != expected {
+				t.Errorf("Decoded body mismatch, got: %s, want: %s.", found, expected)
+			}
+		})
+
+		t.Run("Charset from meta tag", func(t *testing.T) {
+			t.Parallel()
+			body := "<html><head><meta charset=\"iso-8859-1\"></head><a href=\"/Caf\xe9\"></html>"
+			found := ParseLinks(bytes.NewReader(DecodeBody([]byte(body), "text/html")))
+			if !found.Contains(NewLink("/Café").String()) {
+				t.Errorf("Decoded link missing, got: %v.", found.Set)
+			}
+		})
+
+		t.Run("UTF-8 byte order mark stripped", func(t *testing.T) {
+			t.Parallel()
+			found := string(DecodeBody([]byte("\xef\xbb\xbf<a href=\"/Café\">"), "text/html"))
+			expected := `<a href="/Café">`
+			if found != expected {
+				t.Errorf("Decoded body mismatch, got: %s, want: %s.", found, expected)
+			}
+		})
+
+		t.Run("UTF-16 byte order mark", func(t *testing.T) {
+			t.Parallel()
+			body := []byte{0xff, 0xfe, '<', 0, 'a', 0, '>', 0}
+			found := string(DecodeBody(body, "text/html"))
+			if found != "<a>" {
+				t.Errorf("Decoded body mismatch, got: %q, want: %q.", found, "<a>")
+			}
+		})
+	})
+}
+
+func TestParsePage(t *testing.T) {
+	t.Run("Parse HTML reporting tokenizer errors", func(t *testing.T) {
+		t.Run("Clean end of page", func(t *testing.T) {
+			t.Parallel()
+			links, err := ParsePage(strings.NewReader(`<a href="testing">`))
+			if err != nil || !links.Contains("testing") {
+				t.Errorf("Parsing page failed, got: %v, %v.", links.Set, err)
+			}
+		})
+
+		t.Run("Reader failing mid page", func(t *testing.T) {
+			t.Parallel()
+			reader := iotest.TimeoutReader(iotest.OneByteReader(strings.NewReader(`<a href="testing">`)))
+			if _, err := ParsePage(reader); err == nil {
+				t.Errorf("Tokenizer error before EOF should be reported.")
+			}
+		})
+	})
+}
+
+func TestLinkCounts(t *testing.T) {
+	t.Run("Record links per page", func(t *testing.T) {
+		t.Parallel()
+		server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			if req.URL.Path == "/" {
+				fmt.Fprintf(rw, `<html><body><a href="/a" /><a href="/b" /><a href="/c" /></body></html>`)
+				return
+			}
+			fmt.Fprintf(rw, `<html><body><a href="/" /></body></html>`)
+		}))
+		defer server.Close()
+
+		result := NewCrawler(server.URL).Crawl(server.URL + "/")
+		if info, _ := result.Pages.Get(server.URL + "/"); info.LinkCount != 3 || info.Status != 200 {
+			t.Errorf("Link count mismatch, got: %d, want: %d.", info.LinkCount, 3)
+		}
+
+		if info, _ := result.Pages.Get(server.URL + "/a"); !reflect.DeepEqual(info.Referrers, []string{server.URL + "/"}) {
+			t.Errorf("Referrers mismatch, got: %v, want: %v.", info.Referrers, []string{server.URL + "/"})
+		}
+
+		sparse := result.SparsePages(2)
+		expected := []string{server.URL + "/a", server.URL + "/b", server.URL + "/c"}
+		if !reflect.DeepEqual(sparse, expected) {
+			t.Errorf("Sparse pages mismatch, got: %v, want: %v.", sparse, expected)
+		}
+	})
+
+	t.Run("Report pages with the most links and largest HTML", func(t *testing.T) {
+		t.Parallel()
+		server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			switch req.URL.Path {
+			case "/":
+				fmt.Fprint(rw, `<html><body><a href="/a">A</a><a href="/b">B</a><a href="http://elsewhere.test/">E</a></body></html>`)
+			case "/a":
+				fmt.Fprintf(rw, `<html><body>%s</body></html>`, strings.Repeat("text ", 100))
+			default:
+				fmt.Fprint(rw, `<html><body></body></html>`)
+			}
+		}))
+		defer server.Close()
+
+		result := NewCrawler(server.URL).Crawl(server.URL + "/")
+		linked := result.PagesByLinks(1)
+		if len(linked) != 1 || linked[0].Link.String() != server.URL+"/" || linked[0].InternalLinks != 2 || linked[0].ExternalLinks != 1 {
+			t.Errorf("Most linked page mismatch, got: %v.", linked)
+		}
+
+		largest := result.PagesBySize(2)
+		if len(largest) != 2 || largest[0].Link.String() != server.URL+"/a" || largest[0].Size <= largest[1].Size {
+			t.Errorf("Largest pages mismatch, got: %v.", largest)
+		}
+	})
+}
+
+func TestBrokenByReferrer(t *testing.T) {
+	t.Run("Group broken links under linking pages", func(t *testing.T) {
+		t.Parallel()
+		server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			switch req.URL.Path {
+			case "/":
+				fmt.Fprint(rw, `<html><body><a href="/a">A</a><a href="/gone">Gone</a><a href="/lost">Lost</a></body></html>`)
+			case "/a":
+				fmt.Fprint(rw, `<html><body><a href="/gone">Gone</a></body></html>`)
+			default:
+				rw.WriteHeader(http.StatusNotFound)
+			}
+		}))
+		defer server.Close()
+
+		result := NewCrawler(server.URL).Crawl(server.URL + "/")
+		expected := map[string][]string{
+			server.URL + "/":  {server.URL + "/gone", server.URL + "/lost"},
+			server.URL + "/a": {server.URL + "/gone"},
+		}
+		if found := result.BrokenByReferrer(); !reflect.DeepEqual(found, expected) {
+			t.Errorf("Broken links by referrer mismatch, got: %v, want: %v.", found, expected)
+		}
+	})
+}
+
+func TestDepthLevels(t *testing.T) {
+	t.Run("Count pages per depth", func(t *testing.T) {
+		t.Parallel()
+		server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			switch req.URL.Path {
+			case "/":
+				fmt.Fprint(rw, `<a href="/a">A</a><a href="/b">B</a>`)
+			case "/a":
+				fmt.Fprint(rw, `<a href="/c">C</a>`)
+			default:
+				fmt.Fprint(rw, `<html><body></body></html>`)
+			}
+		}))
+		defer server.Close()
+
+		c := NewCrawler(server.URL)
+		c.Deterministic = true
+		result := c.Crawl(server.URL + "/")
+		expected := []DepthLevel{
+			{Depth: 0, Pages: 1, Cumulative: 1, Coverage: 0.25},
+			{Depth: 1, Pages: 2, Cumulative: 3, Coverage: 0.75},
+			{Depth: 2, Pages: 1, Cumulative: 4, Coverage: 1},
+		}
+		if found := result.DepthLevels(); !reflect.DeepEqual(found, expected) {
+			t.Errorf("Depth levels mismatch, got: %v, want: %v.", found, expected)
+		}
+	})
+}
+
+func TestSample(t *testing.T) {
+	t.Run("Follow a fraction of discovered links", func(t *testing.T) {
+		t.Parallel()
+		server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			if req.URL.Path != "/" {
+				rw.WriteHeader(http.StatusNotFound)
+				return
+			}
+			for i := 0; i < 100; i++ {
+				fmt.Fprintf(rw, `<a href="/page%d">Page</a>`, i)
+			}
+		}))
+		defer server.Close()
+
+		first := NewCrawler(server.URL, WithSample(0.5)).Crawl(server.URL + "/")
+		if visited := first.Visited.Len(); visited < 20 || visited > 80 {
+			t.Errorf("Sampled links out of range, got: %v, want about: %v.", visited, 51)
+		}
+		if rate := first.BrokenRate(); rate != float64(first.Visited.Len()-1)/float64(first.Visited.Len()) {
+			t.Errorf("Broken rate mismatch, got: %v.", rate)
+		}
+		if first.Metadata.Sample != 0.5 {
+			t.Errorf("Sample metadata mismatch, got: %v, want: %v.", first.Metadata.Sample, 0.5)
+		}
+
+		second := NewCrawler(server.URL, WithSample(0.5)).Crawl(server.URL + "/")
+		if !reflect.DeepEqual(first.Visited.Keys(), second.Visited.Keys()) {
+			t.Errorf("Sampled links should be stable, got: %v, want: %v.", second.Visited.Keys(), first.Visited.Keys())
+		}
+	})
+}
+
+func TestVerifyOnly(t *testing.T) {
+	t.Run("Verify matching pages without expanding them", func(t *testing.T) {
+		t.Parallel()
+		server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			switch req.URL.Path {
+			case "/":
+				fmt.Fprint(rw, `<html><body><a href="/index/all">Index</a></body></html>`)
+			case "/index/all":
+				fmt.Fprint(rw, `<html><body><a href="/generated">Generated</a></body></html>`)
+			default:
+				fmt.Fprint(rw, `<html><body></body></html>`)
+			}
+		}))
+		defer server.Close()
+
+		result := NewCrawler(server.URL, WithVerifyOnly(regexp.MustCompile(`/index/`))).Crawl(server.URL + "/")
+		expected := []string{server.URL + "/", server.URL + "/index/all"}
+		if found := result.Visited.Keys(); !reflect.DeepEqual(found, expected) {
+			t.Errorf("Visited mismatch, got: %v, want: %v.", found, expected)
+		}
+
+		if info, _ := result.Pages.Get(server.URL + "/index/all"); info.Status != 200 || info.Parsed {
+			t.Errorf("Verify-only page should be fetched but not parsed, got: %v.", info)
+		}
+	})
+}
+
+func TestCaptureHeaders(t *testing.T) {
+	t.Run("Record configured response headers", func(t *testing.T) {
+		t.Parallel()
+		server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			rw.Header().Set("X-Cache", "HIT")
+			rw.Header().Set("X-Other", "ignored")
+			fmt.Fprint(rw, `<html><body></body></html>`)
+		}))
+		defer server.Close()
+
+		result := NewCrawler(server.URL, WithCaptureHeaders("x-cache", "Server")).Crawl(server.URL + "/")
+		info, _ := result.Pages.Get(server.URL + "/")
+		expected := map[string]string{"X-Cache": "HIT"}
+		if !reflect.DeepEqual(info.Headers, expected) {
+			t.Errorf("Headers mismatch, got: %v, want: %v.", info.Headers, expected)
+		}
+	})
+}
+
+func TestErrorBody(t *testing.T) {
+	handler := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/blocked" {
+			rw.Header().Set("Server", "firewall")
+			rw.WriteHeader(http.StatusForbidden)
+			fmt.Fprint(rw, "Request blocked by the web application firewall")
+			return
+		}
+		fmt.Fprint(rw, `<html><a href="/blocked">blocked</a></html>`)
+	})
+
+	t.Run("Keep the start of broken responses", func(t *testing.T) {
+		t.Parallel()
+		server := httptest.NewServer(handler)
+		defer server.Close()
+
+		result := NewCrawler(server.URL, WithErrorBody(15)).Crawl(server.URL + "/")
+		info, _ := result.Pages.Get(server.URL + "/blocked")
+		if want := "Request blocked"; info.ErrorBody != want {
+			t.Errorf("Error body mismatch, got: %q, want: %q.", info.ErrorBody, want)
+		}
+		if got := info.Headers["Server"]; got != "firewall" {
+			t.Errorf("Server header mismatch, got: %q, want: firewall.", got)
+		}
+	})
+
+	t.Run("Disabled by default", func(t *testing.T) {
+		t.Parallel()
+		server := httptest.NewServer(handler)
+		defer server.Close()
+
+		result := NewCrawler(server.URL).Crawl(server.URL + "/")
+		if info, _ := result.Pages.Get(server.URL + "/blocked"); len(info.ErrorBody) > 0 || len(info.Headers) > 0 {
+			t.Errorf("Error capture mismatch, got: %q and %v, want none.", info.ErrorBody, info.Headers)
+		}
+	})
+}
+
+func TestFollowFrames(t *testing.T) {
+	t.Run("Frame traversal option", func(t *testing.T) {
+		page := `<html><body><a href="/path" /><iframe src="/embedded"></iframe></body></html>`
+
+		t.Run("Ignore frames by default", func(t *testing.T) {
+			t.Parallel()
+			ex := expectedCounts{linkCount: 2, brokenCount: 0, requestCount: 2}
+			validateCrawl(t, ex, func(rw http.ResponseWriter, req *http.Request) {
+				fmt.Fprint(rw, page)
+			})
+		})
+
+		t.Run("Follow frames when enabled", func(t *testing.T) {
+			t.Parallel()
+			server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				fmt.Fprint(rw, page)
+			}))
+			defer server.Close()
+
+			c := NewCrawler(server.URL)
+			c.FollowFrames = true
+			result := c.Crawl(server.URL)
+			if !result.Visited.Contains(server.URL + "/embedded") {
+				t.Errorf("Frame source not crawled, got: %v.", result.Visited.Set)
+			}
+		})
+	})
+}
+
+func TestParsePageAttrs(t *testing.T) {
+	t.Run("Parse configurable link attributes", func(t *testing.T) {
+		t.Run("Attributes on any tag", func(t *testing.T) {
+			t.Parallel()
+			html := `<html><body><a href="a" data-href="b" /><span data-href="c">x</span><div id="d" /></body></html>`
+			c := NewCrawler("http://testing.com")
+			c.ExtraAttrs = []string{"data-href"}
+
+			found, _ := ParsePageAttrs(strings.NewReader(html), c.LinkAttrs())
+			for _, link := range []string{"a", "b", "c"} {
+				if !found.Contains(link) {
+					t.Errorf("Link %s missing, got: %v.", link, found.Set)
+				}
+			}
+
+			if len(found.Set) != 3 {
+				t.Errorf("Unexpected links found, got: %v.", found.Set)
+			}
+		})
+	})
+}
+
+// Wiki page with links to the following pages, wrapping around.
+func benchmarkPage(page, pages, links int) string {
+	var body strings.Builder
+	body.WriteString(`<html><head><title>Page</title></head><body><div id="content"><p>Some text.</p><ul>`)
+	for i := 1; i <= links; i++ {
+		fmt.Fprintf(&body, `<li><a href="/index.php?title=Page_%d" class="internal">Page %d</a></li>`, (page+i)%pages, i)
+	}
+	body.WriteString(`</ul></div></body></html>`)
+
+	return body.String()
+}
+
+func BenchmarkParseLinks(b *testing.B) {
+	page := []byte(benchmarkPage(0, 1000, 200))
+	b.SetBytes(int64(len(page)))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		ParseLinks(bytes.NewReader(page))
+	}
+}
+
+func BenchmarkNormalizeUrl(b *testing.B) {
+	base, _ := url.Parse("http://testing.com/index.php")
+	link, _ := url.Parse("/index.php?title=Main_Page&action=view&oldid=42#History")
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		NormalizeUrl(link, base)
+	}
+}
+
+func BenchmarkCrawl(b *testing.B) {
+	const pages = 200
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		var page int
+		fmt.Sscanf(req.URL.Query().Get("title"), "Page_%d", &page)
+		fmt.Fprint(rw, benchmarkPage(page, pages, 20))
+	}))
+	defer server.Close()
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		logger := log.New()
+		logger.Out = io.Discard
+		c := NewCrawler(server.URL+"/index.php", WithLogger(logger))
+		if visited := c.Crawl(server.URL + "/index.php?title=Page_0").Visited.Len(); visited != pages {
+			b.Fatalf("Visited mismatch, got: %d, want: %d.", visited, pages)
+		}
+	}
+}
+
+func TestMergeResults(t *testing.T) {
+	t.Run("Combine sharded crawl results", func(t *testing.T) {
+		t.Parallel()
+		a, b := NewCrawlResult(), NewCrawlResult()
+		a.Visited.Add(NewLink("http://testing.com/a"))
+		a.Broken.Add(NewLink("http://testing.com/gone"))
+		a.record(NewLink("http://testing.com/gone"), func(info *PageInfo) {
+			info.AddReferrer("http://testing.com/a")
+		})
+		a.Queue = QueueStats{Capacity: 10, ProducerStall: time.Second, Dropped: 1}
+		a.Metadata = Metadata{Tool: "wikicrawl", Seed: "http://testing.com/a", ConfigHash: "same", Started: time.Unix(10, 0), Finished: time.Unix(20, 0)}
+
+		b.Visited.Add(NewLink("http://testing.com/b"))
+		b.Broken.Add(NewLink("http://testing.com/gone"))
+		b.record(NewLink("http://testing.com/gone"), func(info *PageInfo) {
+			info.AddReferrer("http://testing.com/b")
+		})
+		b.Queue = QueueStats{Capacity: 10, ProducerStall: time.Second, Dropped: 2}
+		b.Metadata = Metadata{Tool: "wikicrawl", Seed: "http://testing.com/b", ConfigHash: "same", Started: time.Unix(5, 0), Finished: time.Unix(15, 0)}
+		b.Aborted = true
+
+		merged := MergeResults(a, b)
+		if expected := []string{"http://testing.com/a", "http://testing.com/b"}; !reflect.DeepEqual(merged.Visited.Keys(), expected) {
+			t.Errorf("Visited mismatch, got: %v, want: %v.", merged.Visited.Keys(), expected)
+		}
+
+		info, _ := merged.Pages.Get("http://testing.com/gone")
+		if expected := []string{"http://testing.com/a", "http://testing.com/b"}; !reflect.DeepEqual(info.Referrers, expected) {
+			t.Errorf("Referrers mismatch, got: %v, want: %v.", info.Referrers, expected)
+		}
+
+		if merged.Queue.Capacity != 20 || merged.Queue.ProducerStall != 2*time.Second || merged.Queue.Dropped != 3 {
+			t.Errorf("Queue stats mismatch, got: %+v.", merged.Queue)
+		}
+
+		metadata := merged.Metadata
+		if metadata.ConfigHash != "same" || !metadata.Started.Equal(time.Unix(5, 0)) || !metadata.Finished.Equal(time.Unix(20, 0)) {
+			t.Errorf("Metadata mismatch, got: %+v.", metadata)
+		}
+
+		if !merged.Aborted {
+			t.Errorf("Aborted shard should abort the merged result.")
+		}
+	})
+}
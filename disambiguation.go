@@ -0,0 +1,105 @@
+package wikicrawl
+
+import (
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// Titles looked up per API request, the MediaWiki limit for most users.
+const titlesPerQuery = 50
+
+// Article link pointing at a disambiguation page instead of a concrete
+// topic, directly or through a redirect.
+type DisambiguationLink struct {
+	Page   string `json:"page"`
+	Target string `json:"target"`
+}
+
+// Finds the links of articles to disambiguation pages among the crawled
+// pages. Disambiguation pages are those the wiki API flags with the
+// disambiguation page property (Disambiguator extension), links between
+// disambiguation pages are expected and not reported.
+func (c *Crawler) DisambiguationLinks(result *CrawlResult) ([]DisambiguationLink, error) {
+	var titles []string
+	for _, link := range result.Visited.Values() {
+		if len(link.Title) > 0 {
+			titles = append(titles, link.Title)
+		}
+	}
+
+	disambiguation, err := c.disambiguationTitles(titles)
+	if err != nil {
+		return nil, err
+	}
+
+	isDisambiguation := func(key string) bool {
+		info, _ := result.Pages.Get(key)
+		if len(info.RedirectTo) > 0 {
+			key = info.RedirectTo
+		}
+		return disambiguation[NormalizeTitle(NewLink(key).Title)]
+	}
+
+	links := []DisambiguationLink{}
+	for _, info := range result.Pages.Values() {
+		target := info.Link.String()
+		if len(info.Link.Title) == 0 || !isDisambiguation(target) {
+			continue
+		}
+
+		for _, referrer := range info.Referrers {
+			page := NewLink(referrer)
+			if len(page.Namespace) > 0 || isDisambiguation(referrer) {
+				continue
+			}
+			links = append(links, DisambiguationLink{Page: referrer, Target: target})
+		}
+	}
+	sort.Slice(links, func(i, j int) bool {
+		a, b := links[i], links[j]
+		return a.Page < b.Page || a.Page == b.Page && a.Target < b.Target
+	})
+
+	return links, nil
+}
+
+// Normalized titles of the disambiguation pages among titles, looked up
+// in batches through the API.
+func (c *Crawler) disambiguationTitles(titles []string) (map[string]bool, error) {
+	disambiguation := make(map[string]bool)
+	for start := 0; start < len(titles); start += titlesPerQuery {
+		end := start + titlesPerQuery
+		if end > len(titles) {
+			end = len(titles)
+		}
+
+		var pages struct {
+			Query struct {
+				Pages []struct {
+					Title     string            `json:"title"`
+					PageProps map[string]string `json:"pageprops"`
+				} `json:"pages"`
+			} `json:"query"`
+		}
+		query := url.Values{
+			"action":        {"query"},
+			"prop":          {"pageprops"},
+			"ppprop":        {"disambiguation"},
+			"titles":        {strings.Join(titles[start:end], "|")},
+			"format":        {"json"},
+			"formatversion": {"2"},
+		}
+		if err := c.apiGet(query, &pages); err != nil {
+			return disambiguation, err
+		}
+
+		for _, page := range pages.Query.Pages {
+			if _, found := page.PageProps["disambiguation"]; found {
+				disambiguation[NormalizeTitle(page.Title)] = true
+			}
+		}
+	}
+
+	return disambiguation, nil
+}
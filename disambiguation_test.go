@@ -0,0 +1,53 @@
+package wikicrawl
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDisambiguationLinks(t *testing.T) {
+	t.Run("Report article links to disambiguation pages", func(t *testing.T) {
+		t.Parallel()
+		pages := map[string]string{
+			"Main":             `<a href="/index.php?title=Mercury">m</a><a href="/index.php?title=Venus">v</a><a href="/index.php?title=Talk:Main">t</a>`,
+			"Talk:Main":        `<a href="/index.php?title=Mercury">m</a>`,
+			"Mercury":          `<a href="/index.php?title=Mercury_(planet)">p</a><a href="/index.php?title=Hermes">h</a>`,
+			"Hermes":           `<a href="/index.php?title=Mercury">m</a>`,
+			"Venus":            ``,
+			"Mercury_(planet)": ``,
+		}
+		server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			if req.URL.Path == "/api.php" {
+				var found []map[string]interface{}
+				for _, title := range strings.Split(req.URL.Query().Get("titles"), "|") {
+					page := map[string]interface{}{"title": strings.Replace(title, "_", " ", -1)}
+					if title == "Mercury" || title == "Hermes" {
+						page["pageprops"] = map[string]string{"disambiguation": ""}
+					}
+					found = append(found, page)
+				}
+				json.NewEncoder(rw).Encode(map[string]interface{}{"query": map[string]interface{}{"pages": found}})
+				return
+			}
+			fmt.Fprintf(rw, `<html>%s</html>`, pages[req.URL.Query().Get("title")])
+		}))
+		defer server.Close()
+
+		c := NewCrawler(server.URL + "/index.php")
+		result := c.Crawl(server.URL + "/index.php?title=Main")
+		links, err := c.DisambiguationLinks(result)
+		if err != nil {
+			t.Fatalf("Lookup failed: %s.", err)
+		}
+
+		want := []DisambiguationLink{{Page: server.URL + "/index.php?title=Main", Target: server.URL + "/index.php?title=Mercury"}}
+		if !reflect.DeepEqual(links, want) {
+			t.Errorf("Links mismatch, got: %v, want: %v.", links, want)
+		}
+	})
+}
@@ -0,0 +1,354 @@
+package wikicrawl
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// Batch of links leased to a worker, Done once the whole crawl finished.
+type Lease struct {
+	ID    int
+	Links []Link
+	Done  bool
+}
+
+// Outcome of a lease reported back to the coordinator.
+//
+//  1. Lease and Links: ID of the lease and keys of the leased links that
+//     were followed.
+//  2. Discovered: New links found while following them.
+//  3. Sets, Pages and Errors: Worker side results for the leased links.
+type Report struct {
+	Lease      int
+	Links      []string
+	Discovered []Link
+	Sets       map[string][]Link
+	Pages      []PageInfo
+	Errors     []ReportedError
+	Aborted    bool
+}
+
+// LinkError as sent over the wire: the failure class (e.g. ErrFetch), or
+// the status of an ErrBadStatus, and the message.
+type ReportedError struct {
+	URL     string
+	Class   string `json:",omitempty"`
+	Code    int    `json:",omitempty"`
+	Status  string `json:",omitempty"`
+	Message string
+}
+
+// Failure classes a reported error is matched against.
+var reportedClasses = []error{ErrFetch, ErrScope, ErrParse, ErrPanic}
+
+// Error received from a worker, keeps the message and the failure class.
+type remoteError struct {
+	class   error
+	message string
+}
+
+func (e remoteError) Error() string {
+	return e.message
+}
+
+func (e remoteError) Unwrap() error {
+	return e.class
+}
+
+func reportError(err *LinkError) ReportedError {
+	reported := ReportedError{URL: err.URL, Message: err.Err.Error()}
+	var status ErrBadStatus
+	if errors.As(err, &status) {
+		reported.Code, reported.Status = status.Code, status.Status
+	}
+	for _, class := range reportedClasses {
+		if errors.Is(err, class) {
+			reported.Class = class.Error()
+		}
+	}
+
+	return reported
+}
+
+// Typed LinkError of a reported error.
+func (re ReportedError) linkError() *LinkError {
+	if re.Code != 0 {
+		return &LinkError{URL: re.URL, Err: ErrBadStatus{Code: re.Code, Status: re.Status}}
+	}
+
+	err := remoteError{message: re.Message}
+	for _, class := range reportedClasses {
+		if class.Error() == re.Class {
+			err.class = class
+		}
+	}
+
+	return &LinkError{URL: re.URL, Err: err}
+}
+
+// Shares the crawl frontier and visited set between worker processes.
+// Leases not reported within LeaseTimeout are handed out again.
+type Coordinator struct {
+	sync.Mutex
+
+	pending      []Link
+	seen         map[string]bool
+	leased       map[string]leasedLink
+	leases       int
+	done         chan struct{}
+	LeaseTimeout time.Duration
+	Result       *CrawlResult
+}
+
+// Link handed out to a worker, with the lease and when it was leased.
+type leasedLink struct {
+	link  Link
+	lease int
+	at    time.Time
+}
+
+// Simple constructor for a Coordinator seeded with the initial link.
+// The ConfigHash of the result metadata is left to the caller, workers
+// may be configured differently.
+func NewCoordinator(source Link) *Coordinator {
+	co := &Coordinator{
+		pending:      []Link{source},
+		seen:         map[string]bool{source.String(): true},
+		leased:       make(map[string]leasedLink),
+		done:         make(chan struct{}),
+		LeaseTimeout: 5 * time.Minute,
+		Result:       NewCrawlResult(),
+	}
+	co.Result.Metadata = Metadata{Tool: "wikicrawl", Version: Version, Seed: source.String(), Started: time.Now()}
+
+	return co
+}
+
+// Hands out up to size pending links, at least one.
+func (co *Coordinator) Lease(size int) Lease {
+	co.Lock()
+	defer co.Unlock()
+
+	now := time.Now()
+	for key, leased := range co.leased {
+		if now.Sub(leased.at) > co.LeaseTimeout {
+			log.WithFields(co.Result.Metadata.logFields()).WithFields(log.Fields{"link": key}).Warn("Lease expired, queueing link again")
+			delete(co.leased, key)
+			co.pending = append(co.pending, leased.link)
+		}
+	}
+
+	if len(co.pending) == 0 && len(co.leased) == 0 {
+		return Lease{Done: true}
+	}
+
+	if size < 1 {
+		size = 1
+	}
+	if size > len(co.pending) {
+		size = len(co.pending)
+	}
+
+	co.leases++
+	lease := Lease{ID: co.leases, Links: co.pending[:size]}
+	co.pending = append([]Link{}, co.pending[size:]...)
+	for _, link := range lease.Links {
+		co.leased[link.String()] = leasedLink{link: link, lease: lease.ID, at: now}
+	}
+
+	return lease
+}
+
+// Records the outcome of a lease and queues newly discovered links.
+// Late reports of expired leases are ignored, their links were queued
+// again and are reported by the next lease.
+func (co *Coordinator) Complete(report Report) {
+	co.Lock()
+	defer co.Unlock()
+
+	current := false
+	for _, key := range report.Links {
+		if leased, found := co.leased[key]; found && leased.lease == report.Lease {
+			delete(co.leased, key)
+			current = true
+		}
+	}
+	if !current {
+		log.WithFields(co.Result.Metadata.logFields()).WithFields(log.Fields{"lease": report.Lease}).Warn("Ignoring report of an expired lease")
+		return
+	}
+
+	for _, link := range report.Discovered {
+		if !co.seen[link.String()] {
+			co.seen[link.String()] = true
+			co.pending = append(co.pending, link)
+		}
+	}
+
+	co.Result.merge(resultFromReport(report))
+
+	if len(co.pending) == 0 && len(co.leased) == 0 {
+		select {
+		case <-co.done:
+		default:
+			co.Result.Metadata.Finished = time.Now()
+			close(co.done)
+		}
+	}
+}
+
+// Blocks until every discovered link has been reported.
+func (co *Coordinator) Wait() *CrawlResult {
+	<-co.done
+	return co.Result
+}
+
+// HTTP interface used by workers.
+//
+//  1. POST /lease?size=n: Returns a Lease.
+//  2. POST /report: Accepts a Report.
+func (co *Coordinator) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	if req.Method != "POST" {
+		http.Error(rw, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	switch req.URL.Path {
+	case "/lease":
+		size := 1
+		fmt.Sscan(req.URL.Query().Get("size"), &size)
+		json.NewEncoder(rw).Encode(co.Lease(size))
+	case "/report":
+		var report Report
+		if err := json.NewDecoder(req.Body).Decode(&report); err != nil {
+			http.Error(rw, err.Error(), http.StatusBadRequest)
+			return
+		}
+		co.Complete(report)
+	default:
+		http.NotFound(rw, req)
+	}
+}
+
+// Crawls links leased from a coordinator until the shared frontier is
+// exhausted. Discovered links go back to the coordinator for deduplication.
+func (c *Crawler) CrawlRemote(coordinator string) error {
+	client := &http.Client{Timeout: time.Minute}
+	c.limiter = newRateLimiter(c.RateLimit)
+	c.throttle = newAdaptiveThrottle(c.MaxLatency, c.workers())
+	c.ramp = newRampUp(c.WarmUp, c.workers())
+
+	for {
+		var lease Lease
+		if err := postJSON(client, fmt.Sprintf("%s/lease?size=%d", coordinator, c.workers()), nil, &lease); err != nil {
+			return err
+		}
+
+		if lease.Done {
+			return nil
+		}
+
+		if len(lease.Links) == 0 {
+			time.Sleep(time.Second)
+			continue
+		}
+
+		report := c.followLease(lease)
+		if err := postJSON(client, coordinator+"/report", report, nil); err != nil {
+			return err
+		}
+	}
+}
+
+// Follows every leased link concurrently, collecting discovered links.
+func (c *Crawler) followLease(lease Lease) Report {
+	discovered := NewLinkSet()
+	queue := NewWorkQueue(*c, 1)
+	queue.forward = func(link Link) {
+		discovered.Add(link)
+	}
+
+	var wait sync.WaitGroup
+	for _, link := range lease.Links {
+		wait.Add(1)
+		go func(link Link) {
+			defer wait.Done()
+			c.followIsolated(link, queue)
+		}(link)
+	}
+	wait.Wait()
+
+	report := Report{
+		Lease:   lease.ID,
+		Sets:    make(map[string][]Link),
+		Pages:   queue.Result.Pages.Values(),
+		Aborted: queue.Result.Aborted,
+	}
+	for _, link := range lease.Links {
+		report.Links = append(report.Links, link.String())
+	}
+	report.Discovered = append(report.Discovered, discovered.Values()...)
+	for _, err := range queue.Result.Errors.Values() {
+		report.Errors = append(report.Errors, reportError(err))
+	}
+	for name, set := range queue.Result.LinkSets() {
+		set.Range(func(_ string, link Link) bool {
+			report.Sets[name] = append(report.Sets[name], link)
+			return true
+		})
+	}
+
+	return report
+}
+
+func resultFromReport(report Report) *CrawlResult {
+	result := NewCrawlResult()
+	sets := result.LinkSets()
+	for name, links := range report.Sets {
+		if set, found := sets[name]; found {
+			for _, link := range links {
+				set.Add(link)
+			}
+		}
+	}
+
+	for _, info := range report.Pages {
+		result.Pages.Put(info)
+	}
+	for _, err := range report.Errors {
+		result.Errors.Put(err.linkError())
+	}
+	result.Aborted = report.Aborted
+
+	return result
+}
+
+func postJSON(client *http.Client, link string, body interface{}, target interface{}) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	resp, err := client.Post(link, "application/json", bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return fmt.Errorf("POST %s returned %s", link, resp.Status)
+	}
+
+	if target == nil {
+		return nil
+	}
+
+	return json.NewDecoder(resp.Body).Decode(target)
+}
@@ -0,0 +1,166 @@
+package wikicrawl
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCoordinator(t *testing.T) {
+	t.Run("Shared crawl frontier", func(t *testing.T) {
+		t.Run("Lease and complete work", func(t *testing.T) {
+			t.Parallel()
+			co := NewCoordinator(NewLink("http://testing.com"))
+
+			lease := co.Lease(10)
+			if len(lease.Links) != 1 || lease.Done {
+				t.Fatalf("Lease mismatch, got: %+v.", lease)
+			}
+
+			if empty := co.Lease(10); empty.Done || len(empty.Links) != 0 {
+				t.Errorf("Crawl should not be done while links are leased, got: %+v.", empty)
+			}
+
+			co.Complete(Report{
+				Lease:      lease.ID,
+				Links:      []string{"http://testing.com"},
+				Discovered: []Link{NewLink("http://testing.com"), NewLink("http://testing.com/a")},
+				Sets:       map[string][]Link{"visited": lease.Links},
+			})
+
+			next := co.Lease(10)
+			if len(next.Links) != 1 || next.Links[0].String() != "http://testing.com/a" {
+				t.Errorf("Only new links should be queued, got: %+v.", next)
+			}
+
+			co.Complete(Report{Lease: next.ID, Links: []string{"http://testing.com/a"}, Sets: map[string][]Link{"broken": next.Links}})
+			if !co.Lease(10).Done {
+				t.Errorf("Crawl should be done once every link is reported.")
+			}
+
+			result := co.Wait()
+			if !result.Visited.Contains("http://testing.com") || !result.Broken.Contains("http://testing.com/a") {
+				t.Errorf("Coordinator result mismatch, got: %v, %v.", result.Visited.Set, result.Broken.Set)
+			}
+		})
+
+		t.Run("Expired leases handed out again", func(t *testing.T) {
+			t.Parallel()
+			co := NewCoordinator(NewLink("http://testing.com"))
+			co.LeaseTimeout = time.Millisecond
+			co.Lease(1)
+			time.Sleep(5 * time.Millisecond)
+
+			if lease := co.Lease(1); len(lease.Links) != 1 {
+				t.Errorf("Expired lease should be handed out again, got: %+v.", lease)
+			}
+		})
+
+		t.Run("Ignore reports of expired leases", func(t *testing.T) {
+			t.Parallel()
+			co := NewCoordinator(NewLink("http://testing.com"))
+			co.LeaseTimeout = time.Millisecond
+			expired := co.Lease(1)
+			time.Sleep(5 * time.Millisecond)
+			current := co.Lease(1)
+			co.LeaseTimeout = time.Minute
+
+			co.Complete(Report{Lease: expired.ID, Links: []string{"http://testing.com"}, Sets: map[string][]Link{"broken": expired.Links}})
+			if empty := co.Lease(1); empty.Done {
+				t.Errorf("Late report should not complete the lease handed out again.")
+			}
+
+			co.Complete(Report{Lease: current.ID, Links: []string{"http://testing.com"}, Sets: map[string][]Link{"visited": current.Links}})
+			result := co.Wait()
+			if !result.Visited.Contains("http://testing.com") || len(result.Broken.Set) != 0 {
+				t.Errorf("Coordinator result mismatch, got: %v, %v.", result.Visited.Set, result.Broken.Set)
+			}
+		})
+	})
+
+	t.Run("Typed errors in reports", func(t *testing.T) {
+		t.Parallel()
+		co := NewCoordinator(NewLink("http://testing.com"))
+		lease := co.Lease(1)
+		failure := linkError(lease.Links[0], ErrParse, fmt.Errorf("bad markup"))
+		co.Complete(Report{Lease: lease.ID, Links: []string{"http://testing.com"}, Errors: []ReportedError{reportError(failure)}})
+
+		err := co.Wait().Err("http://testing.com")
+		if !errors.Is(err, ErrParse) || err.Error() != failure.Error() {
+			t.Errorf("Reported error mismatch, got: %v, want: %v.", err, failure)
+		}
+	})
+
+	t.Run("Lease at least one link", func(t *testing.T) {
+		t.Parallel()
+		co := NewCoordinator(NewLink("http://testing.com"))
+		server := httptest.NewServer(co)
+		defer server.Close()
+
+		var lease Lease
+		if err := postJSON(server.Client(), server.URL+"/lease?size=-1", nil, &lease); err != nil {
+			t.Fatalf("Lease failed: %s.", err)
+		}
+		if len(lease.Links) != 1 {
+			t.Errorf("Lease mismatch, got: %+v, want one link.", lease)
+		}
+	})
+}
+
+func TestCrawlRemote(t *testing.T) {
+	t.Run("Crawl with several workers", func(t *testing.T) {
+		t.Parallel()
+		var mu sync.Mutex
+		requests := make(map[string]int)
+		wiki := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			mu.Lock()
+			requests[req.URL.Path]++
+			mu.Unlock()
+
+			if req.URL.Path == "/error" {
+				rw.WriteHeader(500)
+				return
+			}
+			fmt.Fprintf(rw, `<html><body><a href="/a" /><a href="/b" /><a href="/c" /><a href="/error" /></body></html>`)
+		}))
+		defer wiki.Close()
+
+		co := NewCoordinator(NewLink(wiki.URL))
+		coordinator := httptest.NewServer(co)
+		defer coordinator.Close()
+
+		var wait sync.WaitGroup
+		for i := 0; i < 3; i++ {
+			wait.Add(1)
+			go func() {
+				defer wait.Done()
+				c := NewCrawler(wiki.URL)
+				c.Workers = 2
+				if err := c.CrawlRemote(coordinator.URL); err != nil {
+					t.Errorf("Remote crawl failed: %s.", err)
+				}
+			}()
+		}
+		wait.Wait()
+
+		result := co.Wait()
+		if len(result.Visited.Set) != 5 || len(result.Broken.Set) != 1 {
+			t.Errorf("Remote crawl result mismatch, got: %v, %v.", result.Visited.Set, result.Broken.Set)
+		}
+
+		var status ErrBadStatus
+		if err := result.Err(wiki.URL + "/error"); !errors.As(err, &status) || status.Code != 500 {
+			t.Errorf("Remote crawl error mismatch, got: %v, want: 500 status.", err)
+		}
+
+		for path, count := range requests {
+			if count != 1 {
+				t.Errorf("Pages should only be requested once across workers, %s requested %d times.", path, count)
+			}
+		}
+	})
+}
@@ -0,0 +1,26 @@
+// Package wikicrawl crawls a wiki and reports on its health, broken links
+// first of all.
+//
+// # Stable API
+//
+// The following surface is stable: it keeps its behavior and signatures
+// within a major version, and anything deprecated keeps working for at
+// least one more minor release before it is removed in the next major one.
+//
+//   - Crawling: NewCrawler with its Option functions (With...), then
+//     Crawler.Crawl, Crawler.Start returning a WorkQueue to wait for or
+//     abort, Crawler.Resume and Crawler.Recheck.
+//   - Results: CrawlResult and its link sets, read through the Set methods
+//     (Contains, Get, Len, Keys, Values and Range), PageInfo, LinkError,
+//     Metadata and the derived reports (Severity, Metrics, Maintenance,
+//     SecurityFindings, PageRank, Summarize).
+//   - Exporters: the JSON report (CrawlResult.MarshalJSON, read back by
+//     ReadReport), WriteGEXF, WriteGraphML, WriteHrefMap,
+//     WriteSuggestedFixes, WriteBatchSummary and the ReportWriter targets.
+//   - Extension points: Fetcher, PageProcessor, ContentChecker,
+//     Middleware, Authenticator, EventSink and Store.
+//
+// Exported struct fields of the Crawler are settings, the options setting
+// them are preferred. Other exported identifiers, e.g. the parsing
+// helpers, may still change between minor releases.
+package wikicrawl
@@ -0,0 +1,175 @@
+package wikicrawl
+
+import (
+	"context"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// Page of a MediaWiki XML dump.
+type DumpPage struct {
+	Title     string `xml:"title"`
+	Namespace int    `xml:"ns"`
+	Redirect  struct {
+		Title string `xml:"title,attr"`
+	} `xml:"redirect"`
+	Text string `xml:"revision>text"`
+}
+
+// Streams the pages of a MediaWiki XML dump (e.g. pages-articles.xml)
+// without loading the whole dump in memory.
+func ReadDump(reader io.Reader, page func(DumpPage) error) error {
+	decoder := xml.NewDecoder(reader)
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		start, ok := token.(xml.StartElement)
+		if !ok || start.Name.Local != "page" {
+			continue
+		}
+
+		var dumped DumpPage
+		if err := decoder.DecodeElement(&dumped, &start); err != nil {
+			return err
+		}
+		if err := page(dumped); err != nil {
+			return err
+		}
+	}
+}
+
+// Url of a wiki page, the base url with the title query parameter.
+func (c *Crawler) TitleURL(title string) *url.URL {
+	link := *c.base
+	link.RawQuery = url.Values{"title": {NormalizeTitle(title)}}.Encode()
+	return &link
+}
+
+// Builds the page inventory and link graph from a MediaWiki XML dump
+// instead of crawling the wiki. Links to pages missing from the dump are
+// reported broken, external links are verified over HTTP.
+func (c *Crawler) CrawlDump(reader io.Reader) (*CrawlResult, error) {
+	result := NewCrawlResult()
+	result.Metadata = c.metadata("dump:" + c.base.String())
+	if c.Localize {
+		c.loadNamespaces()
+	}
+
+	titles := make(map[string]bool)
+	links := make(map[string][]string)
+	externals := NewLinkSet()
+
+	err := ReadDump(reader, func(page DumpPage) error {
+		title := NormalizeTitle(page.Title)
+		titles[title] = true
+
+		source := c.classify(linkFromURL(c.TitleURL(title)))
+		result.Visited.Add(source)
+
+		targets := WikiLinks(page.Text)
+		if len(page.Redirect.Title) > 0 {
+			targets = append(targets, page.Redirect.Title)
+			result.record(source, func(info *PageInfo) {
+				info.RedirectTo = c.TitleURL(page.Redirect.Title).String()
+			})
+		}
+
+		for _, target := range targets {
+			if isInterwiki(target) {
+				continue
+			}
+			namespace := TitleNamespace(c.canonicalTitle(NormalizeTitle(target)))
+			if namespace == "Special" || namespace == "Media" {
+				continue
+			}
+			links[title] = append(links[title], NormalizeTitle(target))
+		}
+
+		found := ExternalLinks(page.Text)
+		for _, raw := range found {
+			parsed, err := url.Parse(raw)
+			if err != nil {
+				continue
+			}
+
+			external := c.classify(linkFromURL(parsed))
+			external.Depth = 1
+			externals.Add(external)
+			result.record(external, func(info *PageInfo) {
+				info.AddReferrer(source.String())
+			})
+		}
+
+		c.recordCitations(result, source, page.Text)
+
+		result.record(source, func(info *PageInfo) {
+			info.Parsed = true
+			info.LinkCount = len(targets) + len(found)
+			info.InternalLinks = len(targets)
+			info.ExternalLinks = len(found)
+			info.Size = len(page.Text)
+		})
+
+		return nil
+	})
+	if err != nil {
+		return result, err
+	}
+
+	for title, targets := range links {
+		source := c.TitleURL(title).String()
+		for _, target := range targets {
+			link := c.classify(linkFromURL(c.TitleURL(target)))
+			link.Depth = 1
+			result.record(link, func(info *PageInfo) {
+				info.AddReferrer(source)
+			})
+
+			if !titles[target] {
+				c.broken(result, link, statusError(link, http.StatusNotFound, "404 missing from dump"))
+			}
+		}
+	}
+
+	c.verifyAll(&externals, result)
+	result.Metadata.Finished = time.Now()
+
+	return result, nil
+}
+
+// Verifies external links concurrently, with every worker of the crawler.
+func (c *Crawler) verifyAll(links *LinkSet, result *CrawlResult) {
+	verifier := *c
+	verifier.CheckExternal = true
+	queue := NewWorkQueue(verifier, 1)
+	queue.Result = result
+
+	var wait sync.WaitGroup
+	workers := make(chan struct{}, c.workers())
+	for _, link := range links.Values() {
+		if link.URL == nil || !verifier.ShouldVerify(link.URL) {
+			continue
+		}
+
+		workers <- struct{}{}
+		wait.Add(1)
+		go func(link Link) {
+			defer wait.Done()
+			defer func() { <-workers }()
+
+			result.Visited.Add(link)
+			verifier.VerifyExternal(context.Background(), link, queue)
+		}(link)
+	}
+	wait.Wait()
+}
@@ -0,0 +1,55 @@
+package wikicrawl
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCrawlDump(t *testing.T) {
+	t.Run("Crawl from a MediaWiki XML dump", func(t *testing.T) {
+		t.Parallel()
+		external := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			if req.URL.Path == "/gone" {
+				rw.WriteHeader(404)
+				return
+			}
+			fmt.Fprint(rw, `<html></html>`)
+		}))
+		defer external.Close()
+		externalURL := strings.Replace(external.URL, "127.0.0.1", "localhost", 1)
+
+		dump := fmt.Sprintf(`<mediawiki>
+			<page><title>Main Page</title><ns>0</ns><revision><text>
+				[[Install]], [[missing page]], [[wikipedia:Go]], [[Special:Search]]
+				[%s/ok docs] %s/gone
+			</text></revision></page>
+			<page><title>Install</title><ns>0</ns><revision><text>[[Main Page]]</text></revision></page>
+			<page><title>Setup</title><ns>0</ns><redirect title="Install" /><revision><text>#REDIRECT [[Install]]</text></revision></page>
+		</mediawiki>`, externalURL, externalURL)
+
+		c := NewCrawler("http://testing.com/index.php")
+		result, err := c.CrawlDump(strings.NewReader(dump))
+		if err != nil {
+			t.Fatalf("Reading dump failed: %s.", err)
+		}
+
+		for _, title := range []string{"Main_Page", "Install", "Setup"} {
+			if !result.Visited.Contains(c.TitleURL(title).String()) {
+				t.Errorf("Dump page %s missing, got: %v.", title, result.Visited.Keys())
+			}
+		}
+
+		expected := []string{c.TitleURL("Missing_page").String(), externalURL + "/gone"}
+		if broken := result.Broken.Keys(); len(broken) != 2 || !result.Broken.Contains(expected[0]) || !result.Broken.Contains(expected[1]) {
+			t.Errorf("Broken links mismatch, got: %v, want: %v.", broken, expected)
+		}
+
+		info, _ := result.Pages.Get(c.TitleURL("Install").String())
+		if len(info.Referrers) != 2 {
+			t.Errorf("Referrers mismatch, got: %v.", info.Referrers)
+		}
+	})
+}
@@ -0,0 +1,78 @@
+package wikicrawl
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// Classes of link failures, test with errors.Is.
+var (
+	// Request failed without response: network, TLS, timeout or redirect loop.
+	ErrFetch = errors.New("fetch failed")
+
+	// Link or redirect target outside of the crawled wiki.
+	ErrScope = errors.New("outside of the crawled wiki")
+
+	// Malformed url or HTML.
+	ErrParse = errors.New("parse failed")
+
+	// Following the link panicked, the crawl went on without it.
+	ErrPanic = errors.New("panic")
+)
+
+// Response with an unexpected HTTP status, test with errors.As.
+type ErrBadStatus struct {
+	Code   int
+	Status string
+}
+
+func (e ErrBadStatus) Error() string {
+	return "bad status: " + e.Status
+}
+
+// Failure of a link, wrapping one of the failure classes.
+type LinkError struct {
+	URL string
+	Err error
+}
+
+func (e *LinkError) Error() string {
+	return e.URL + ": " + e.Err.Error()
+}
+
+func (e *LinkError) Unwrap() error {
+	return e.Err
+}
+
+// Failure of a link of the given class, with the underlying cause.
+func linkError(link Link, class, cause error) *LinkError {
+	return &LinkError{URL: link.String(), Err: fmt.Errorf("%w: %w", class, cause)}
+}
+
+// Failure of a link answered with a status other than 200.
+func statusError(link Link, code int, status string) *LinkError {
+	if len(status) == 0 {
+		status = fmt.Sprintf("%d %s", code, http.StatusText(code))
+	}
+
+	return &LinkError{URL: link.String(), Err: ErrBadStatus{Code: code, Status: status}}
+}
+
+// Failures of links, keyed by url.
+type ErrorSet = Set[string, *LinkError]
+
+func NewErrorSet() ErrorSet {
+	return NewSet(func(err *LinkError) string {
+		return err.URL
+	})
+}
+
+// Failure recorded for a link, nil if none.
+func (cr *CrawlResult) Err(link string) error {
+	if err, found := cr.Errors.Get(link); found {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,86 @@
+package wikicrawl
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// Processor panicking on pages with the given path.
+type panickingProcessor string
+
+func (pp panickingProcessor) Process(page Link, body []byte) bool {
+	if page.URL.Path == string(pp) {
+		panic("processor failed")
+	}
+
+	return false
+}
+
+func TestLinkErrors(t *testing.T) {
+	t.Run("Record typed failures of links", func(t *testing.T) {
+		t.Parallel()
+		server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			switch req.URL.Path {
+			case "/":
+				fmt.Fprint(rw, `<html><body><a href="/gone">Gone</a><a href="http://[::1">Bad</a></body></html>`)
+			default:
+				rw.WriteHeader(http.StatusGone)
+			}
+		}))
+		defer server.Close()
+
+		result := NewCrawler(server.URL).Crawl(server.URL + "/")
+
+		var status ErrBadStatus
+		if err := result.Err(server.URL + "/gone"); !errors.As(err, &status) || status.Code != http.StatusGone {
+			t.Errorf("Bad status error mismatch, got: %v, want: %v.", err, http.StatusGone)
+		}
+
+		if err := result.Err("http://[::1"); !errors.Is(err, ErrParse) {
+			t.Errorf("Parse error mismatch, got: %v, want: %v.", err, ErrParse)
+		}
+
+		if err := result.Err(server.URL + "/"); err != nil {
+			t.Errorf("Healthy page should have no error, got: %v.", err)
+		}
+	})
+
+	t.Run("Isolate panics of a link", func(t *testing.T) {
+		t.Parallel()
+		server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			if req.URL.Path == "/" {
+				fmt.Fprint(rw, `<html><body><a href="/boom">Boom</a><a href="/fine">Fine</a></body></html>`)
+				return
+			}
+			fmt.Fprint(rw, `<html><body></body></html>`)
+		}))
+		defer server.Close()
+
+		c := NewCrawler(server.URL, WithProcessors(panickingProcessor("/boom")))
+		result := c.Crawl(server.URL + "/")
+
+		if !result.Visited.Contains(server.URL + "/fine") {
+			t.Errorf("Crawl should go on after a panic, got: %v.", result.Visited.Keys())
+		}
+
+		if err := result.Err(server.URL + "/boom"); !errors.Is(err, ErrPanic) || !result.Broken.Contains(server.URL+"/boom") {
+			t.Errorf("Panic error mismatch, got: %v, want: %v.", err, ErrPanic)
+		}
+	})
+
+	t.Run("Fetch failures", func(t *testing.T) {
+		t.Parallel()
+		server := httptest.NewServer(http.NotFoundHandler())
+		server.Close()
+
+		result := NewCrawler(server.URL).Crawl(server.URL + "/")
+		err := result.Err(server.URL + "/")
+		var failure *LinkError
+		if !errors.Is(err, ErrFetch) || !errors.As(err, &failure) || failure.URL != server.URL+"/" {
+			t.Errorf("Fetch error mismatch, got: %v, want: %v.", err, ErrFetch)
+		}
+	})
+}
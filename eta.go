@@ -0,0 +1,84 @@
+package wikicrawl
+
+import (
+	"time"
+)
+
+// Span of recent progress the estimate of a crawl is based on, so the
+// burst of new links at the start of a crawl fades from it.
+const estimateWindow = time.Minute
+
+// Estimated end of a running crawl.
+//
+//  1. Done: Links followed so far.
+//  2. Discovered: Links queued so far, followed or not.
+//  3. PagesPerSecond: Recent completion rate.
+//  4. Total: Expected links once no new ones are found, 0 while the
+//     frontier grows as fast as it is crawled.
+//  5. Remaining and Finish: Expected time left and end of the crawl, zero
+//     when Total is unknown.
+type Estimate struct {
+	Done           int           `json:"done"`
+	Discovered     int           `json:"discovered"`
+	PagesPerSecond float64       `json:"pagesPerSecond"`
+	Total          int           `json:"total,omitempty"`
+	Remaining      time.Duration `json:"remaining,omitempty"`
+	Finish         time.Time     `json:"finish,omitempty"`
+}
+
+// Progress counts of a crawl at some time.
+type progressSample struct {
+	at         time.Time
+	done       int
+	discovered int
+}
+
+// Projects the end of a crawl from its progress between two samples: every
+// followed link recently found new ones at some rate, the pending links are
+// expected to find as many in turn until the frontier dries up.
+func projectEstimate(from, to progressSample) Estimate {
+	estimate := Estimate{Done: to.done, Discovered: to.discovered}
+	if to.done > 0 && to.done >= to.discovered {
+		estimate.Total, estimate.Finish = to.done, to.at
+	}
+	elapsed := to.at.Sub(from.at).Seconds()
+	followed := to.done - from.done
+	if elapsed <= 0 || followed <= 0 {
+		return estimate
+	}
+	estimate.PagesPerSecond = float64(followed) / elapsed
+
+	growth := float64(to.discovered-from.discovered) / float64(followed)
+	if growth >= 1 || estimate.Total > 0 {
+		return estimate
+	}
+
+	remaining := float64(to.discovered-to.done) / (1 - growth)
+	estimate.Total = to.done + int(remaining+0.5)
+	estimate.Remaining = time.Duration(remaining / estimate.PagesPerSecond * float64(time.Second)).Round(time.Second)
+	estimate.Finish = to.at.Add(estimate.Remaining)
+	return estimate
+}
+
+// Estimated end of the crawl, based on the progress of the last minute.
+func (wq *WorkQueue) Estimate() Estimate {
+	now := progressSample{at: time.Now(), discovered: wq.reserved.Len()}
+
+	wq.metrics.Lock()
+	defer wq.metrics.Unlock()
+	now.done = wq.metrics.followed
+
+	// Samples older than the window are dropped, the oldest kept one is
+	// the start of the projection.
+	samples := wq.metrics.progress
+	if len(samples) == 0 {
+		samples = []progressSample{{at: wq.metrics.started}}
+	}
+	samples = append(samples, now)
+	for len(samples) > 2 && now.at.Sub(samples[1].at) >= estimateWindow {
+		samples = samples[1:]
+	}
+	wq.metrics.progress = samples
+
+	return projectEstimate(samples[0], now)
+}
@@ -0,0 +1,59 @@
+package wikicrawl
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestProjectEstimate(t *testing.T) {
+	t.Run("Estimate the end of a crawl", func(t *testing.T) {
+		start := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+
+		t.Run("Frontier drying up", func(t *testing.T) {
+			t.Parallel()
+			// 100 pages followed in 50 seconds found 50 new links: the 200
+			// pending links are expected to find 200 more.
+			estimate := projectEstimate(
+				progressSample{at: start, done: 100, discovered: 350},
+				progressSample{at: start.Add(50 * time.Second), done: 200, discovered: 400},
+			)
+			if estimate.PagesPerSecond != 2 || estimate.Total != 600 || estimate.Remaining != 200*time.Second {
+				t.Errorf("Estimate mismatch, got: %+v, want: 600 pages in 200s at 2 pages/s.", estimate)
+			}
+			if !estimate.Finish.Equal(start.Add(250 * time.Second)) {
+				t.Errorf("Finish mismatch, got: %s, want: %s.", estimate.Finish, start.Add(250*time.Second))
+			}
+		})
+
+		t.Run("Frontier still growing", func(t *testing.T) {
+			t.Parallel()
+			estimate := projectEstimate(
+				progressSample{at: start},
+				progressSample{at: start.Add(10 * time.Second), done: 10, discovered: 80},
+			)
+			if estimate.Total != 0 || estimate.Remaining != 0 || estimate.PagesPerSecond != 1 {
+				t.Errorf("Estimate mismatch, got: %+v, want: unknown total at 1 page/s.", estimate)
+			}
+		})
+	})
+}
+
+func TestEstimate(t *testing.T) {
+	t.Run("Estimate of a finished crawl", func(t *testing.T) {
+		t.Parallel()
+		server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			fmt.Fprint(rw, `<html><a href="/a">a</a><a href="/b">b</a></html>`)
+		}))
+		defer server.Close()
+
+		queue := NewCrawler(server.URL).Start(server.URL + "/start")
+		queue.Wait()
+		estimate := queue.Estimate()
+		if estimate.Done != 3 || estimate.Discovered != 3 || estimate.Total != 3 || estimate.Remaining != 0 {
+			t.Errorf("Estimate mismatch, got: %+v, want: 3 of 3 pages.", estimate)
+		}
+	})
+}
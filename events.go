@@ -0,0 +1,85 @@
+package wikicrawl
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// Types of crawl events.
+const (
+	EventFetch    = "fetch"
+	EventSkip     = "skip"
+	EventBroken   = "broken"
+	EventRedirect = "redirect"
+)
+
+// Crawl event, reported as it happens.
+//
+//  1. Time: When the event happened.
+//  2. Type: One of fetch, skip, broken or redirect.
+//  3. URL: Link the event is about.
+//  4. Status: HTTP status of the response, when there was one.
+//  5. Target: Final location of a redirect.
+//  6. Reason: Cause of a broken link.
+type Event struct {
+	Time   time.Time `json:"time"`
+	Type   string    `json:"type"`
+	URL    string    `json:"url"`
+	Status int       `json:"status,omitempty"`
+	Target string    `json:"target,omitempty"`
+	Reason string    `json:"reason,omitempty"`
+}
+
+// Receives crawl events from concurrent workers.
+type EventSink interface {
+	Event(event Event)
+}
+
+// Event sink writing one JSON object per line (NDJSON), allowing tail -f
+// monitoring of a running crawl.
+type EventLog struct {
+	sync.Mutex
+	encoder *json.Encoder
+}
+
+// Simple constructor for an EventLog writing to writer.
+func NewEventLog(writer io.Writer) *EventLog {
+	return &EventLog{encoder: json.NewEncoder(writer)}
+}
+
+func (el *EventLog) Event(event Event) {
+	el.Lock()
+	defer el.Unlock()
+
+	if err := el.encoder.Encode(event); err != nil {
+		log.WithFields(log.Fields{"err": err}).Warn("Writing event failed")
+	}
+}
+
+// Reports an event to the configured sink.
+func (c *Crawler) emit(event Event) {
+	if c.Events == nil {
+		return
+	}
+
+	event.Time = time.Now()
+	c.Events.Event(event)
+}
+
+// Records a broken link with its failure and reports it.
+func (c *Crawler) broken(result *CrawlResult, link Link, err *LinkError) {
+	result.Broken.Add(link)
+	result.Errors.Put(err)
+
+	event := Event{Type: EventBroken, URL: link.String(), Reason: err.Err.Error()}
+	var status ErrBadStatus
+	if errors.As(err, &status) {
+		event.Status = status.Code
+	}
+	c.emit(event)
+}
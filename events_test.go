@@ -0,0 +1,55 @@
+package wikicrawl
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestEventLog(t *testing.T) {
+	t.Run("Write crawl events as NDJSON", func(t *testing.T) {
+		t.Parallel()
+		server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			switch req.URL.Path {
+			case "/":
+				fmt.Fprint(rw, `<html><body><a href="/moved">M</a><a href="/gone">G</a><a href="http://elsewhere.test/page">E</a></body></html>`)
+			case "/moved":
+				http.Redirect(rw, req, "/target", http.StatusFound)
+			case "/gone":
+				rw.WriteHeader(http.StatusNotFound)
+			default:
+				fmt.Fprint(rw, `<html><body></body></html>`)
+			}
+		}))
+		defer server.Close()
+
+		var buffer bytes.Buffer
+		NewCrawler(server.URL, WithDeterministic(), WithEventLog(&buffer)).Crawl(server.URL + "/")
+
+		var found []string
+		for _, line := range strings.Split(strings.TrimSpace(buffer.String()), "\n") {
+			var event Event
+			if err := json.Unmarshal([]byte(line), &event); err != nil {
+				t.Fatalf("Invalid event line %q: %s.", line, err)
+			}
+			found = append(found, fmt.Sprintf("%s %s %d", event.Type, strings.TrimPrefix(event.URL, server.URL), event.Status))
+		}
+
+		expected := []string{
+			"fetch / 200",
+			"skip http://elsewhere.test/page 0",
+			"fetch /gone 404",
+			"broken /gone 404",
+			"fetch /moved 200",
+			"redirect /moved 200",
+		}
+		if !reflect.DeepEqual(found, expected) {
+			t.Errorf("Events mismatch, got: %v, want: %v.", found, expected)
+		}
+	})
+}
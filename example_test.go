@@ -0,0 +1,78 @@
+package wikicrawl_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"jalandis.com/wikicrawl"
+)
+
+// Small wiki with one broken link.
+func exampleWiki() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/wiki/Main_Page":
+			fmt.Fprint(rw, `<html><a href="/wiki/Help">Help</a><a href="/wiki/Gone">Gone</a></html>`)
+		case "/wiki/Help":
+			fmt.Fprint(rw, `<html><a href="/wiki/Main_Page">Main page</a></html>`)
+		default:
+			http.NotFound(rw, req)
+		}
+	}))
+}
+
+func ExampleNewCrawler() {
+	wiki := exampleWiki()
+	defer wiki.Close()
+
+	c := wikicrawl.NewCrawler(wiki.URL+"/wiki", wikicrawl.WithConcurrency(2))
+	result := c.Crawl(wiki.URL + "/wiki/Main_Page")
+
+	fmt.Println("Visited:", result.Visited.Len())
+	for _, link := range result.Broken.Keys() {
+		info, _ := result.Pages.Get(link)
+		fmt.Println("Broken:", strings.TrimPrefix(link, wiki.URL), info.Status, info.Referrers[0] == wiki.URL+"/wiki/Main_Page")
+	}
+	// Output:
+	// Visited: 3
+	// Broken: /wiki/Gone 404 true
+}
+
+func ExampleCrawler_Start() {
+	wiki := exampleWiki()
+	defer wiki.Close()
+
+	queue := wikicrawl.NewCrawler(wiki.URL + "/wiki").Start(wiki.URL + "/wiki/Main_Page")
+	// queue.Abort() stops the crawl early, e.g. on a signal.
+	queue.Wait()
+
+	fmt.Println("Severity:", queue.Result.Severity())
+	// Output:
+	// Severity: warning
+}
+
+func ExampleSet_Range() {
+	links, _ := wikicrawl.ParsePage(strings.NewReader(`<a href="/wiki/A">A</a><a href="/wiki/B">B</a>`))
+
+	count := 0
+	links.Range(func(key string, link wikicrawl.Link) bool {
+		count++
+		return true
+	})
+	fmt.Println(count, links.Keys())
+	// Output:
+	// 2 [/wiki/A /wiki/B]
+}
+
+func ExampleCrawlResult_PageRank() {
+	wiki := exampleWiki()
+	defer wiki.Close()
+
+	result := wikicrawl.NewCrawler(wiki.URL + "/wiki").Crawl(wiki.URL + "/wiki/Main_Page")
+	rank := result.PageRank()
+	fmt.Println(rank[wiki.URL+"/wiki/Main_Page"] > rank[wiki.URL+"/wiki/Help"])
+	// Output:
+	// true
+}
@@ -0,0 +1,195 @@
+package wikicrawl
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// Bytes of an external page inspected for soft 404 detection.
+const maxSoft404Body = 1 << 20
+
+// Concurrent cache of DNS lookups for external hosts.
+// Lookups start as soon as a host is discovered so that by the time a
+// worker verifies the link the answer is usually known.
+type HostCache struct {
+	sync.Mutex
+
+	Lookup func(ctx context.Context, host string) ([]string, error)
+	hosts  map[string]*hostEntry
+}
+
+type hostEntry struct {
+	done   chan struct{}
+	exists bool
+}
+
+// Simple constructor for HostCache type using the default resolver.
+func NewHostCache() *HostCache {
+	return &HostCache{
+		Lookup: net.DefaultResolver.LookupHost,
+		hosts:  make(map[string]*hostEntry),
+	}
+}
+
+// Starts resolving a host in the background if not already known.
+func (hc *HostCache) Prefetch(host string) {
+	hc.entry(host)
+}
+
+// Reports if a host resolves, blocking until its lookup finishes.
+// Only NXDOMAIN answers are considered missing; other DNS failures are
+// left for the HTTP request to report.
+func (hc *HostCache) Exists(host string) bool {
+	entry := hc.entry(host)
+	<-entry.done
+	return entry.exists
+}
+
+func (hc *HostCache) entry(host string) *hostEntry {
+	hc.Lock()
+	defer hc.Unlock()
+
+	host = strings.ToLower(host)
+	if entry, found := hc.hosts[host]; found {
+		return entry
+	}
+
+	entry := &hostEntry{done: make(chan struct{}), exists: true}
+	hc.hosts[host] = entry
+
+	go func() {
+		defer close(entry.done)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		if _, err := hc.Lookup(ctx, host); err != nil {
+			if dnsErr, ok := err.(*net.DNSError); ok && dnsErr.IsNotFound {
+				entry.exists = false
+			}
+		}
+	}()
+
+	return entry
+}
+
+// List of host names, each matching itself and any of its subdomains.
+type HostList []string
+
+func (hl HostList) Match(host string) bool {
+	host = strings.ToLower(host)
+	for _, entry := range hl {
+		entry = strings.ToLower(entry)
+		if host == entry || strings.HasSuffix(host, "."+entry) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Reports if an external link should be verified.
+//
+//  1. Hosts in SkipHosts are never checked.
+//  2. Hosts in CheckHosts are always checked.
+//  3. Everything else is checked when CheckExternal is on.
+func (c *Crawler) ShouldVerify(link *url.URL) bool {
+	if !c.IsExternal(link) || c.SkipHosts.Match(link.Hostname()) {
+		return false
+	}
+
+	return c.CheckExternal || c.CheckHosts.Match(link.Hostname())
+}
+
+// Reports if a link points to a web page outside of the crawled host.
+func (c *Crawler) IsExternal(link *url.URL) bool {
+	if link.Scheme != "http" && link.Scheme != "https" {
+		return false
+	}
+
+	return !strings.EqualFold(link.Host, c.base.Host)
+}
+
+// Checks an external link resolves without parsing its content.
+func (c *Crawler) VerifyExternal(ctx context.Context, source Link, queue *WorkQueue) {
+	link := source.URL
+	if link == nil {
+		c.broken(queue.Result, source, linkError(source, ErrParse, errors.New("invalid url")))
+		return
+	}
+
+	if !c.hosts.Exists(link.Hostname()) {
+		c.logger().WithFields(log.Fields{
+			"source": source,
+			"host":   link.Hostname(),
+		}).Warn("External host does not exist")
+		c.broken(queue.Result, source, linkError(source, ErrFetch, errors.New("host does not exist")))
+		return
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", source.String(), nil)
+	if err != nil {
+		c.broken(queue.Result, source, linkError(source, ErrParse, err))
+		return
+	}
+
+	resp, err := c.Client.Do(req)
+	if err != nil {
+		c.logger().WithFields(log.Fields{
+			"source": source,
+			"err":    err,
+		}).Warn("External GET returned with error")
+		c.broken(queue.Result, source, linkError(source, ErrFetch, err))
+		return
+	}
+	defer resp.Body.Close()
+
+	queue.Result.record(source, func(info *PageInfo) {
+		info.Status = resp.StatusCode
+		c.captureHeaders(info, resp.Header)
+	})
+	c.emit(Event{Type: EventFetch, URL: source.String(), Status: resp.StatusCode})
+
+	redirected := !strings.EqualFold(resp.Request.URL.Host, link.Host)
+	if resp.StatusCode != 200 && c.acceptStatus(source, resp.StatusCode, redirected) {
+		return
+	}
+	if resp.StatusCode != 200 {
+		c.logger().WithFields(log.Fields{
+			"source": source,
+			"status": resp.Status,
+		}).Warn("External GET returned with non 200 response")
+		if c.ErrorBody > 0 {
+			body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, c.ErrorBody))
+			queue.Result.record(source, func(info *PageInfo) {
+				c.captureError(info, resp.Header, DecodeBody(body, resp.Header.Get("Content-Type")))
+			})
+		}
+		if c.restricted(queue.Result, source, resp.StatusCode) {
+			return
+		}
+		c.broken(queue.Result, source, statusError(source, resp.StatusCode, resp.Status))
+		return
+	}
+
+	if c.Soft404 != nil {
+		body, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxSoft404Body))
+		if err == nil && c.Soft404.Detect(link, resp.Request.URL, body) {
+			c.logger().WithFields(log.Fields{
+				"source": source,
+				"final":  resp.Request.URL,
+			}).Warn("External link looks like a soft 404")
+			queue.Result.SuspectBroken.Add(source)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package wikicrawl
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+)
+
+type fakeResolver struct {
+	sync.Mutex
+
+	missing map[string]bool
+	lookups []string
+}
+
+func (fr *fakeResolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	fr.Lock()
+	defer fr.Unlock()
+
+	fr.lookups = append(fr.lookups, host)
+	if fr.missing[host] {
+		return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+
+	return []string{"127.0.0.1"}, nil
+}
+
+func TestHostCache(t *testing.T) {
+	t.Run("External host DNS cache", func(t *testing.T) {
+		t.Run("Resolves each host once", func(t *testing.T) {
+			t.Parallel()
+			resolver := &fakeResolver{missing: map[string]bool{"gone.invalid": true}}
+			cache := NewHostCache()
+			cache.Lookup = resolver.LookupHost
+
+			cache.Prefetch("gone.invalid")
+			cache.Prefetch("Gone.Invalid")
+			if cache.Exists("gone.invalid") {
+				t.Errorf("NXDOMAIN host should be reported missing.")
+			}
+
+			if !cache.Exists("example.com") {
+				t.Errorf("Resolvable host should be reported as existing.")
+			}
+
+			if len(resolver.lookups) != 2 {
+				t.Errorf("Hosts should only be resolved once, got: %v.", resolver.lookups)
+			}
+		})
+	})
+}
+
+func TestHostList(t *testing.T) {
+	t.Run("Host allow and deny lists", func(t *testing.T) {
+		t.Run("Match host and subdomains", func(t *testing.T) {
+			t.Parallel()
+			hosts := HostList{"Example.com"}
+			for _, host := range []string{"example.com", "www.example.com", "a.b.EXAMPLE.com"} {
+				if !hosts.Match(host) {
+					t.Errorf("Host should match list: %s.", host)
+				}
+			}
+
+			for _, host := range []string{"notexample.com", "example.com.evil.org"} {
+				if hosts.Match(host) {
+					t.Errorf("Host should not match list: %s.", host)
+				}
+			}
+		})
+
+		t.Run("Skip and check lists override external mode", func(t *testing.T) {
+			t.Parallel()
+			c := NewCrawler("http://testing.com")
+			c.SkipHosts = HostList{"tracker.com"}
+			c.CheckHosts = HostList{"docs.org"}
+
+			skipped, _ := url.Parse("http://tracker.com/pixel")
+			checked, _ := url.Parse("http://docs.org/page")
+			other, _ := url.Parse("http://other.com/page")
+
+			if c.ShouldVerify(skipped) || !c.ShouldVerify(checked) || c.ShouldVerify(other) {
+				t.Errorf("Host lists not applied with external mode off.")
+			}
+
+			c.CheckExternal = true
+			if c.ShouldVerify(skipped) || !c.ShouldVerify(checked) || !c.ShouldVerify(other) {
+				t.Errorf("Host lists not applied with external mode on.")
+			}
+		})
+	})
+}
+
+func TestCheckExternal(t *testing.T) {
+	t.Run("External link verification", func(t *testing.T) {
+		t.Run("Verify external links without crawling them", func(t *testing.T) {
+			t.Parallel()
+			externalRequests := 0
+			external := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				externalRequests++
+				if req.URL.Path == "/missing" {
+					rw.WriteHeader(404)
+					return
+				}
+				fmt.Fprintf(rw, `<html><body><a href="/never" /></body></html>`)
+			}))
+			defer external.Close()
+			externalURL := strings.Replace(external.URL, "127.0.0.1", "localhost", 1)
+
+			server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				fmt.Fprintf(rw, `<html><body>
+					<a href="%s/ok" /><a href="%s/missing" /><a href="http://gone.invalid/page" />
+					</body></html>`, externalURL, externalURL)
+			}))
+			defer server.Close()
+
+			resolver := &fakeResolver{missing: map[string]bool{"gone.invalid": true}}
+			c := NewCrawler(server.URL)
+			c.CheckExternal = true
+			c.hosts.Lookup = resolver.LookupHost
+			result := c.Crawl(server.URL)
+
+			if len(result.Visited.Set) != 4 {
+				t.Errorf("Visited links mismatch, got: %v.", result.Visited.Set)
+			}
+
+			if len(result.Broken.Set) != 2 ||
+				!result.Broken.Contains(externalURL+"/missing") ||
+				!result.Broken.Contains("http://gone.invalid/page") {
+				t.Errorf("Broken links mismatch, got: %v.", result.Broken.Set)
+			}
+
+			if externalRequests != 2 {
+				t.Errorf("External links should be requested once and never crawled, got %d requests.", externalRequests)
+			}
+		})
+
+		t.Run("Skip external links by default", func(t *testing.T) {
+			t.Parallel()
+			ex := expectedCounts{linkCount: 1, brokenCount: 0, requestCount: 1}
+			validateCrawl(t, ex, func(rw http.ResponseWriter, req *http.Request) {
+				fmt.Fprintf(rw, `<html><body><a href="http://gone.invalid/page" /></body></html>`)
+			})
+		})
+	})
+}
@@ -0,0 +1,16 @@
+//go:build !windows
+// +build !windows
+
+package wikicrawl
+
+import "syscall"
+
+// Maximum number of files the process may open, sockets included.
+func fileLimit() (uint64, error) {
+	var limit syscall.Rlimit
+	if err := syscall.Getrlimit(syscall.RLIMIT_NOFILE, &limit); err != nil {
+		return 0, err
+	}
+
+	return uint64(limit.Cur), nil
+}
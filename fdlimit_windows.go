@@ -0,0 +1,6 @@
+package wikicrawl
+
+// Windows has no per process limit on open sockets worth checking.
+func fileLimit() (uint64, error) {
+	return 0, nil
+}
@@ -0,0 +1,157 @@
+package wikicrawl
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+
+	"golang.org/x/net/html/charset"
+)
+
+// Page retrieved by a Fetcher.
+//
+//  1. URL: Final location after any redirects.
+//  2. StatusCode: HTTP status of the final response.
+//  3. Body: Page HTML transcoded to UTF-8, only read for 200 responses.
+//     The start of error responses is kept when the fetcher is asked to.
+//  4. Links: Links parsed while the body was read, nil when the fetcher
+//     leaves parsing to the crawler.
+//  5. ParseErr: Tokenizer error of the streamed parsing.
+//  6. Redirects: Redirects followed to reach URL.
+type Page struct {
+	URL        *url.URL
+	StatusCode int
+	Status     string
+	Header     http.Header
+	Body       []byte
+	Links      *LinkSet
+	ParseErr   error
+	Redirects  int
+}
+
+// Retrieves pages for the crawler to parse.
+type Fetcher interface {
+	Fetch(link Link) (*Page, error)
+}
+
+// Fetcher passing a context on, used for cancellation and tracing.
+type ContextFetcher interface {
+	Fetcher
+	FetchContext(ctx context.Context, link Link) (*Page, error)
+}
+
+// Default fetcher issuing plain GET requests.
+//
+//  1. Attrs: Tag attributes parsed for links while the body downloads,
+//     links are left to the crawler when nil.
+//  2. MaxBytes: Bytes of the body read at most, pages are truncated past
+//     it. Unlimited when 0.
+//  3. Methods: Request methods of matching urls, GET for the others.
+//  4. ErrorBytes: Bytes of the body of non 200 responses kept, none when 0.
+type HTTPFetcher struct {
+	Client     *http.Client
+	Attrs      LinkAttrs
+	MaxBytes   int64
+	Methods    []MethodRule
+	ErrorBytes int64
+}
+
+func (hf *HTTPFetcher) Fetch(link Link) (*Page, error) {
+	return hf.FetchContext(context.Background(), link)
+}
+
+func (hf *HTTPFetcher) FetchContext(ctx context.Context, link Link) (*Page, error) {
+	req, err := http.NewRequestWithContext(ctx, requestMethod(link.String(), hf.Methods), link.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := hf.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	page := &Page{
+		URL:        resp.Request.URL,
+		StatusCode: resp.StatusCode,
+		Status:     resp.Status,
+		Header:     resp.Header,
+	}
+	for req := resp.Request; req.Response != nil; req = req.Response.Request {
+		page.Redirects++
+	}
+
+	if resp.StatusCode != 200 {
+		if hf.ErrorBytes > 0 {
+			read, _ := ioutil.ReadAll(io.LimitReader(resp.Body, hf.ErrorBytes))
+			page.Body = DecodeBody(read, resp.Header.Get("Content-Type"))
+		}
+		return page, nil
+	}
+
+	var body io.Reader = resp.Body
+	if hf.MaxBytes > 0 {
+		body = io.LimitReader(body, hf.MaxBytes)
+	}
+
+	if hf.Attrs != nil {
+		if err := hf.stream(page, body, resp.Header.Get("Content-Type")); err != nil {
+			return nil, err
+		}
+		return page, nil
+	}
+
+	read, err := ioutil.ReadAll(body)
+	if err != nil {
+		return nil, err
+	}
+	page.Body = DecodeBody(read, resp.Header.Get("Content-Type"))
+
+	return page, nil
+}
+
+// Reader keeping the first read error, telling download failures apart
+// from tokenizer errors.
+type recordingReader struct {
+	reader io.Reader
+	err    error
+}
+
+func (rr *recordingReader) Read(p []byte) (int, error) {
+	n, err := rr.reader.Read(p)
+	if err != nil && err != io.EOF && rr.err == nil {
+		rr.err = err
+	}
+
+	return n, err
+}
+
+// Parses links as the body downloads, keeping a copy of the body for the
+// processors and assertions.
+func (hf *HTTPFetcher) stream(page *Page, body io.Reader, contentType string) error {
+	download := &recordingReader{reader: body}
+	decoded, err := charset.NewReader(download, contentType)
+	if err != nil {
+		decoded = download
+	}
+
+	var buffer bytes.Buffer
+	tee := io.TeeReader(decoded, &buffer)
+	links, parseErr := ParsePageAttrs(tee, hf.Attrs)
+	if _, err := io.Copy(ioutil.Discard, tee); err != nil && download.err == nil {
+		download.err = err
+	}
+	if download.err != nil {
+		return download.err
+	}
+
+	page.Body = bytes.TrimPrefix(buffer.Bytes(), []byte("\xef\xbb\xbf"))
+	page.Links = links
+	page.ParseErr = parseErr
+
+	return nil
+}
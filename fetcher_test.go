@@ -0,0 +1,109 @@
+package wikicrawl
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+type staticFetcher map[string]string
+
+func (sf staticFetcher) Fetch(link Link) (*Page, error) {
+	location, _ := url.Parse(link.String())
+	body, found := sf[link.String()]
+	if !found {
+		return &Page{URL: location, StatusCode: 404, Status: "404 Not Found"}, nil
+	}
+
+	return &Page{URL: location, StatusCode: 200, Status: "200 OK", Body: []byte(body)}, nil
+}
+
+func TestHTTPFetcher(t *testing.T) {
+	t.Run("Fetch pages over HTTP", func(t *testing.T) {
+		t.Run("Follow redirects and read body", func(t *testing.T) {
+			t.Parallel()
+			server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				if req.URL.Path == "/old" {
+					http.Redirect(rw, req, "/new", http.StatusFound)
+					return
+				}
+				fmt.Fprintf(rw, "page")
+			}))
+			defer server.Close()
+
+			page, err := (&HTTPFetcher{Client: http.DefaultClient}).Fetch(NewLink(server.URL + "/old"))
+			if err != nil {
+				t.Fatalf("Fetch failed: %s.", err)
+			}
+
+			if page.URL.String() != server.URL+"/new" || string(page.Body) != "page" {
+				t.Errorf("Fetched page mismatch, got: %s, %s.", page.URL, page.Body)
+			}
+		})
+
+		t.Run("Parse links while reading body", func(t *testing.T) {
+			t.Parallel()
+			server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				rw.Header().Set("Content-Type", "text/html; charset=iso-8859-1")
+				fmt.Fprintf(rw, "<a href=\"/caf\xe9\">caf\xe9</a>")
+			}))
+			defer server.Close()
+
+			fetcher := &HTTPFetcher{Client: http.DefaultClient, Attrs: AnchorAttrs}
+			page, err := fetcher.Fetch(NewLink(server.URL))
+			if err != nil {
+				t.Fatalf("Fetch failed: %s.", err)
+			}
+
+			if page.Links == nil || !page.Links.Contains("/caf%C3%A9") {
+				t.Errorf("Streamed links mismatch, got: %v, want: %v.", page.Links.Keys(), "/caf%C3%A9")
+			}
+			if string(page.Body) != "<a href=\"/café\">café</a>" {
+				t.Errorf("Body mismatch, got: %s, want: %s.", page.Body, "<a href=\"/café\">café</a>")
+			}
+		})
+
+		t.Run("Stop reading after MaxBytes", func(t *testing.T) {
+			t.Parallel()
+			server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				fmt.Fprintf(rw, `<a href="/first">first</a><a href="/second">second</a>`)
+			}))
+			defer server.Close()
+
+			fetcher := &HTTPFetcher{Client: http.DefaultClient, Attrs: AnchorAttrs, MaxBytes: 30}
+			page, err := fetcher.Fetch(NewLink(server.URL))
+			if err != nil {
+				t.Fatalf("Fetch failed: %s.", err)
+			}
+
+			if !page.Links.Contains("/first") || page.Links.Contains("/second") {
+				t.Errorf("Truncated links mismatch, got: %v, want: %v.", page.Links.Keys(), []string{"/first"})
+			}
+			if len(page.Body) != 30 {
+				t.Errorf("Body length mismatch, got: %d, want: %d.", len(page.Body), 30)
+			}
+		})
+	})
+}
+
+func TestCustomFetcher(t *testing.T) {
+	t.Run("Crawl with custom fetcher", func(t *testing.T) {
+		t.Parallel()
+		c := NewCrawler("http://testing.com")
+		c.Fetcher = staticFetcher{
+			"http://testing.com":          `<a href="/rendered" /><a href="/missing" />`,
+			"http://testing.com/rendered": `<a href="/rendered" />`,
+		}
+		result := c.Crawl("http://testing.com")
+
+		if !result.Visited.Contains("http://testing.com/rendered") {
+			t.Errorf("Fetched page links not crawled, got: %v.", result.Visited.Set)
+		}
+
+		if len(result.Broken.Set) != 1 || !result.Broken.Contains("http://testing.com/missing") {
+			t.Errorf("Broken links mismatch, got: %v.", result.Broken.Set)
+		}
+	})
+}
@@ -0,0 +1,372 @@
+package wikicrawl
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Filter selecting the pages of a result with an expression, e.g.
+// status>=500 && namespace=="Template".
+//
+// Comparisons (==, !=, <, <=, >, >=, and =~ or !~ for regexps) test a
+// field against a number, a quoted string or true/false, and combine with
+// &&, ||, ! and parentheses. A boolean field alone tests if it is true.
+// Fields are listed in FilterFields.
+type Filter struct {
+	source string
+	match  func(record filterRecord) bool
+}
+
+// Page of a result evaluated by a filter.
+type filterRecord struct {
+	result *CrawlResult
+	info   PageInfo
+}
+
+type filterKind int
+
+const (
+	filterNumber filterKind = iota
+	filterString
+	filterBool
+)
+
+type filterField struct {
+	kind  filterKind
+	value func(record filterRecord) interface{}
+}
+
+// Fields of the pages a filter can test.
+//
+//  1. url, title, namespace (Main for articles), class and redirect
+//     (final url): Strings.
+//  2. status (0 when never fetched), depth, size (HTML bytes), links and
+//     referrers (counts): Numbers.
+//  3. visited and broken: Booleans, membership of the result sets.
+var FilterFields = []string{"url", "title", "namespace", "class", "redirect",
+	"status", "depth", "size", "links", "referrers", "visited", "broken"}
+
+var filterFields = map[string]filterField{
+	"url": {filterString, func(r filterRecord) interface{} { return r.info.Link.String() }},
+	"title": {filterString, func(r filterRecord) interface{} {
+		return NormalizeTitle(r.info.Link.Title)
+	}},
+	"namespace": {filterString, func(r filterRecord) interface{} {
+		if len(r.info.Link.Namespace) == 0 {
+			return "Main"
+		}
+		return r.info.Link.Namespace
+	}},
+	"class":     {filterString, func(r filterRecord) interface{} { return r.info.Link.Class.String() }},
+	"redirect":  {filterString, func(r filterRecord) interface{} { return r.info.RedirectTo }},
+	"status":    {filterNumber, func(r filterRecord) interface{} { return float64(r.info.Status) }},
+	"depth":     {filterNumber, func(r filterRecord) interface{} { return float64(r.info.Link.Depth) }},
+	"size":      {filterNumber, func(r filterRecord) interface{} { return float64(r.info.Size) }},
+	"links":     {filterNumber, func(r filterRecord) interface{} { return float64(r.info.LinkCount) }},
+	"referrers": {filterNumber, func(r filterRecord) interface{} { return float64(len(r.info.Referrers)) }},
+	"visited": {filterBool, func(r filterRecord) interface{} {
+		return r.result.Visited.Contains(r.info.Link.String())
+	}},
+	"broken": {filterBool, func(r filterRecord) interface{} {
+		return r.result.Broken.Contains(r.info.Link.String())
+	}},
+}
+
+// Parses a filter expression.
+func ParseFilter(expression string) (*Filter, error) {
+	tokens, err := filterTokens(expression)
+	if err != nil {
+		return nil, err
+	}
+
+	parser := &filterParser{tokens: tokens}
+	match, err := parser.or()
+	if err != nil {
+		return nil, err
+	}
+	if parser.position < len(tokens) {
+		return nil, fmt.Errorf("filter %q: unexpected %q", expression, tokens[parser.position])
+	}
+
+	return &Filter{source: expression, match: match}, nil
+}
+
+func (f *Filter) String() string {
+	return f.source
+}
+
+// Reports if the page of the result matches the filter.
+func (f *Filter) Match(result *CrawlResult, info PageInfo) bool {
+	return f.match(filterRecord{result: result, info: info})
+}
+
+// Result narrowed to the links matching the filter, for every output to
+// show the same slice: link sets, pages, errors and findings of other
+// links are left out, the metadata of the run is kept.
+func (cr *CrawlResult) Filter(filter *Filter) *CrawlResult {
+	filtered := NewCrawlResult()
+	filtered.Aborted, filtered.Truncated, filtered.Store, filtered.Metadata = cr.Aborted, cr.Truncated, cr.Store, cr.Metadata
+	filtered.Queue, filtered.Stall, filtered.Hrefs = cr.Queue, cr.Stall, cr.Hrefs
+	filtered.WikiReports = cr.WikiReports
+
+	matched := make(map[string]bool)
+	matches := func(link Link) bool {
+		key := link.String()
+		if match, found := matched[key]; found {
+			return match
+		}
+		info, found := cr.Pages.Get(key)
+		if !found {
+			info.Link = link
+		}
+		matched[key] = filter.Match(cr, info)
+		return matched[key]
+	}
+
+	sets := filtered.LinkSets()
+	for name, set := range cr.LinkSets() {
+		for _, link := range set.Values() {
+			if matches(link) {
+				sets[name].Add(link)
+			}
+		}
+	}
+	for _, info := range cr.Pages.Values() {
+		if matches(info.Link) {
+			filtered.Pages.Put(info)
+		}
+	}
+	for _, err := range cr.Errors.Values() {
+		if matched[err.URL] {
+			filtered.Errors.Put(err)
+		}
+	}
+	for _, finding := range cr.Accessibility {
+		if matched[finding.Page] {
+			filtered.Accessibility = append(filtered.Accessibility, finding)
+		}
+	}
+
+	return filtered
+}
+
+// Splits an expression into identifiers, numbers, quoted strings and
+// operators.
+func filterTokens(expression string) ([]string, error) {
+	var tokens []string
+	for i := 0; i < len(expression); {
+		r := rune(expression[i])
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '"':
+			end := i + 1
+			for ; end < len(expression) && expression[end] != '"'; end++ {
+				if expression[end] == '\\' {
+					end++
+				}
+			}
+			if end >= len(expression) {
+				return nil, fmt.Errorf("filter %q: unterminated string", expression)
+			}
+			tokens = append(tokens, expression[i:end+1])
+			i = end + 1
+		case isFilterWord(expression[i]):
+			end := i
+			for end < len(expression) && isFilterWord(expression[end]) {
+				end++
+			}
+			tokens = append(tokens, expression[i:end])
+			i = end
+		default:
+			operator := ""
+			for _, candidate := range []string{"&&", "||", "==", "!=", "<=", ">=", "=~", "!~", "<", ">", "!", "(", ")"} {
+				if strings.HasPrefix(expression[i:], candidate) {
+					operator = candidate
+					break
+				}
+			}
+			if len(operator) == 0 {
+				return nil, fmt.Errorf("filter %q: unexpected %q", expression, expression[i:i+1])
+			}
+			tokens = append(tokens, operator)
+			i += len(operator)
+		}
+	}
+
+	return tokens, nil
+}
+
+// Reports if b belongs to a field name or number.
+func isFilterWord(b byte) bool {
+	return unicode.IsLetter(rune(b)) || unicode.IsDigit(rune(b)) || b == '_' || b == '.' || b == '-'
+}
+
+// Recursive descent parser compiling tokens into a match function.
+type filterParser struct {
+	tokens   []string
+	position int
+}
+
+func (fp *filterParser) peek() string {
+	if fp.position < len(fp.tokens) {
+		return fp.tokens[fp.position]
+	}
+	return ""
+}
+
+func (fp *filterParser) next() (string, error) {
+	if fp.position >= len(fp.tokens) {
+		return "", fmt.Errorf("filter ends unexpectedly")
+	}
+	fp.position++
+	return fp.tokens[fp.position-1], nil
+}
+
+func (fp *filterParser) or() (func(filterRecord) bool, error) {
+	left, err := fp.and()
+	for err == nil && fp.peek() == "||" {
+		fp.position++
+		var right func(filterRecord) bool
+		if right, err = fp.and(); err == nil {
+			first := left
+			left = func(r filterRecord) bool { return first(r) || right(r) }
+		}
+	}
+	return left, err
+}
+
+func (fp *filterParser) and() (func(filterRecord) bool, error) {
+	left, err := fp.unary()
+	for err == nil && fp.peek() == "&&" {
+		fp.position++
+		var right func(filterRecord) bool
+		if right, err = fp.unary(); err == nil {
+			first := left
+			left = func(r filterRecord) bool { return first(r) && right(r) }
+		}
+	}
+	return left, err
+}
+
+func (fp *filterParser) unary() (func(filterRecord) bool, error) {
+	token, err := fp.next()
+	if err != nil {
+		return nil, err
+	}
+
+	switch token {
+	case "!":
+		operand, err := fp.unary()
+		if err != nil {
+			return nil, err
+		}
+		return func(r filterRecord) bool { return !operand(r) }, nil
+	case "(":
+		inner, err := fp.or()
+		if err != nil {
+			return nil, err
+		}
+		if closing, err := fp.next(); err != nil || closing != ")" {
+			return nil, fmt.Errorf("filter missing ) after %q", strings.Join(fp.tokens[:fp.position], " "))
+		}
+		return inner, nil
+	}
+
+	field, found := filterFields[token]
+	if !found {
+		return nil, fmt.Errorf("unknown filter field %q, known fields: %s", token, strings.Join(FilterFields, ", "))
+	}
+
+	switch fp.peek() {
+	case "==", "!=", "<", "<=", ">", ">=", "=~", "!~":
+	default:
+		if field.kind != filterBool {
+			return nil, fmt.Errorf("filter field %s is not a boolean, compare it", token)
+		}
+		return func(r filterRecord) bool { return field.value(r).(bool) }, nil
+	}
+
+	operator, _ := fp.next()
+	operand, err := fp.next()
+	if err != nil {
+		return nil, err
+	}
+
+	return compileComparison(token, field, operator, operand)
+}
+
+// Match function comparing a field with a literal operand.
+func compileComparison(name string, field filterField, operator, operand string) (func(filterRecord) bool, error) {
+	if operator == "=~" || operator == "!~" {
+		pattern, err := strconv.Unquote(operand)
+		if err != nil || field.kind != filterString {
+			return nil, fmt.Errorf("filter %s %s needs a string field and a quoted regexp", name, operator)
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, err
+		}
+		return func(r filterRecord) bool {
+			return re.MatchString(field.value(r).(string)) == (operator == "=~")
+		}, nil
+	}
+
+	switch field.kind {
+	case filterNumber:
+		number, err := strconv.ParseFloat(operand, 64)
+		if err != nil {
+			return nil, fmt.Errorf("filter %s %s needs a number, got %s", name, operator, operand)
+		}
+		return func(r filterRecord) bool {
+			value := field.value(r).(float64)
+			switch operator {
+			case "==":
+				return value == number
+			case "!=":
+				return value != number
+			case "<":
+				return value < number
+			case "<=":
+				return value <= number
+			case ">":
+				return value > number
+			default:
+				return value >= number
+			}
+		}, nil
+	case filterString:
+		text, err := strconv.Unquote(operand)
+		if err != nil {
+			return nil, fmt.Errorf("filter %s %s needs a quoted string, got %s", name, operator, operand)
+		}
+		return func(r filterRecord) bool {
+			value := field.value(r).(string)
+			switch operator {
+			case "==":
+				return value == text
+			case "!=":
+				return value != text
+			case "<":
+				return value < text
+			case "<=":
+				return value <= text
+			case ">":
+				return value > text
+			default:
+				return value >= text
+			}
+		}, nil
+	default:
+		truth, err := strconv.ParseBool(operand)
+		if err != nil || operator != "==" && operator != "!=" {
+			return nil, fmt.Errorf("filter %s %s needs == or != with true or false, got %s", name, operator, operand)
+		}
+		return func(r filterRecord) bool {
+			return field.value(r).(bool) == truth == (operator == "==")
+		}, nil
+	}
+}
@@ -0,0 +1,105 @@
+package wikicrawl
+
+import (
+	"reflect"
+	"testing"
+)
+
+// Result of two templates and an article, one template broken.
+func filterResult() *CrawlResult {
+	result := NewCrawlResult()
+	pages := map[string]int{
+		"http://testing.com/index.php?title=Template:Infobox": 503,
+		"http://testing.com/index.php?title=Template:Nav":     200,
+		"http://testing.com/index.php?title=Main_Page":        500,
+	}
+	for key, status := range pages {
+		link := NewLink(key)
+		result.Visited.Add(link)
+		result.record(link, func(info *PageInfo) {
+			info.Status = status
+		})
+		if status != 200 {
+			result.Broken.Add(link)
+			result.Errors.Put(statusError(link, status, ""))
+		}
+	}
+
+	return result
+}
+
+func TestParseFilter(t *testing.T) {
+	result := filterResult()
+	matching := func(t *testing.T, expression string) []string {
+		filter, err := ParseFilter(expression)
+		if err != nil {
+			t.Fatalf("Parsing %q failed: %s.", expression, err)
+		}
+		var keys []string
+		for _, info := range result.Pages.Values() {
+			if filter.Match(result, info) {
+				keys = append(keys, info.Link.Title)
+			}
+		}
+		return keys
+	}
+
+	t.Run("Comparisons", func(t *testing.T) {
+		t.Parallel()
+		if got, want := matching(t, `status>=500 && namespace=="Template"`), []string{"Template:Infobox"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("Matches mismatch, got: %v, want: %v.", got, want)
+		}
+		if got, want := matching(t, `namespace == "Main" || status < 300`), []string{"Main_Page", "Template:Nav"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("Matches mismatch, got: %v, want: %v.", got, want)
+		}
+	})
+
+	t.Run("Booleans, negation and regexps", func(t *testing.T) {
+		t.Parallel()
+		if got, want := matching(t, `broken && !(title =~ "^Template:")`), []string{"Main_Page"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("Matches mismatch, got: %v, want: %v.", got, want)
+		}
+		if got, want := matching(t, `broken == false`), []string{"Template:Nav"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("Matches mismatch, got: %v, want: %v.", got, want)
+		}
+	})
+
+	t.Run("Invalid expressions", func(t *testing.T) {
+		t.Parallel()
+		for _, expression := range []string{`status >= "500"`, `owner == "me"`, `status`, `(broken`, `title == "open`, `broken && `, `status >= 500 )`} {
+			if _, err := ParseFilter(expression); err == nil {
+				t.Errorf("Error mismatch for %q, got: nil, want: an error.", expression)
+			}
+		}
+	})
+}
+
+func TestFilterResult(t *testing.T) {
+	t.Run("Narrow every part of a result", func(t *testing.T) {
+		t.Parallel()
+		result := filterResult()
+		result.Metadata.Seed = "http://testing.com/index.php?title=Main_Page"
+		filter, err := ParseFilter(`namespace == "Template"`)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		filtered := result.Filter(filter)
+		want := []string{"http://testing.com/index.php?title=Template:Infobox", "http://testing.com/index.php?title=Template:Nav"}
+		if got := filtered.Visited.Keys(); !reflect.DeepEqual(got, want) {
+			t.Errorf("Visited mismatch, got: %v, want: %v.", got, want)
+		}
+		if got := filtered.Pages.Keys(); !reflect.DeepEqual(got, want) {
+			t.Errorf("Pages mismatch, got: %v, want: %v.", got, want)
+		}
+		if got := filtered.Broken.Keys(); !reflect.DeepEqual(got, want[:1]) {
+			t.Errorf("Broken mismatch, got: %v, want: %v.", got, want[:1])
+		}
+		if got := filtered.Errors.Keys(); !reflect.DeepEqual(got, want[:1]) {
+			t.Errorf("Errors mismatch, got: %v, want: %v.", got, want[:1])
+		}
+		if filtered.Metadata.Seed != result.Metadata.Seed {
+			t.Errorf("Seed mismatch, got: %s, want: %s.", filtered.Metadata.Seed, result.Metadata.Seed)
+		}
+	})
+}
@@ -0,0 +1,262 @@
+package wikicrawl
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// Rewrites wiki links pointing at redirects so they point at the final
+// page, editing the linking pages through the MediaWiki API.
+//
+//  1. Client: Logged in client allowed to edit (see MediaWikiLogin).
+//  2. API: Url of api.php.
+//  3. Summary: Edit summary, {redirects} is replaced with the bypassed
+//     redirects of the edit.
+//  4. DryRun: Only report the edits that would be made.
+type RedirectFixer struct {
+	Client  *http.Client
+	API     string
+	Summary string
+	DryRun  bool
+}
+
+// Simple constructor for a RedirectFixer using api.php next to base.
+func NewRedirectFixer(client *http.Client, base *url.URL) *RedirectFixer {
+	return &RedirectFixer{
+		Client:  client,
+		API:     base.ResolveReference(&url.URL{Path: "api.php"}).String(),
+		Summary: "Bypass redirects: {redirects}",
+	}
+}
+
+// Redirect bypassed by an edit.
+type Redirect struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// Edit of a page, made or planned in dry run mode.
+//
+//  1. Page: Title of the edited page.
+//  2. Redirects: Redirects bypassed by the edit.
+//  3. Replacements: Number of links rewritten.
+//  4. Summary: Edit summary used.
+type PageEdit struct {
+	Page         string     `json:"page"`
+	Redirects    []Redirect `json:"redirects"`
+	Replacements int        `json:"replacements"`
+	Summary      string     `json:"summary"`
+}
+
+// Edits every page of the result linking to a redirect. Pages are only
+// known by title when crawled with ?title= urls.
+func (rf *RedirectFixer) Fix(result *CrawlResult) ([]PageEdit, error) {
+	var edits []PageEdit
+	planned := redirectsByPage(result)
+
+	pages := make([]string, 0, len(planned))
+	for page := range planned {
+		pages = append(pages, page)
+	}
+	sort.Strings(pages)
+
+	for _, page := range pages {
+		edit, err := rf.fixPage(page, planned[page])
+		if err != nil {
+			return edits, err
+		}
+		if edit.Replacements > 0 {
+			edits = append(edits, edit)
+		}
+	}
+
+	return edits, nil
+}
+
+// Groups the redirects found in the result by the title of linking pages.
+func redirectsByPage(result *CrawlResult) map[string][]Redirect {
+	planned := make(map[string][]Redirect)
+	for _, info := range result.Pages.Values() {
+		// Links are not pointed at the missing targets of broken redirects.
+		if len(info.RedirectTo) == 0 || len(info.Link.Title) == 0 || result.Broken.Contains(info.Link.String()) {
+			continue
+		}
+
+		target := NewLink(info.RedirectTo).Title
+		if len(target) == 0 || target == info.Link.Title {
+			continue
+		}
+
+		redirect := Redirect{From: info.Link.Title, To: target}
+		for _, referrer := range info.Referrers {
+			if source, found := result.Pages.Get(referrer); found && len(source.Link.Title) > 0 {
+				planned[source.Link.Title] = append(planned[source.Link.Title], redirect)
+			}
+		}
+	}
+
+	return planned
+}
+
+func (rf *RedirectFixer) fixPage(page string, redirects []Redirect) (PageEdit, error) {
+	edit := PageEdit{Page: page}
+
+	text, timestamp, err := rf.wikitext(page)
+	if err != nil {
+		return edit, err
+	}
+
+	var bypassed []string
+	for _, redirect := range redirects {
+		var count int
+		text, count = ReplaceWikiLinks(text, redirect.From, redirect.To)
+		if count > 0 {
+			edit.Redirects = append(edit.Redirects, redirect)
+			edit.Replacements += count
+			bypassed = append(bypassed, fmt.Sprintf("[[%s]] → [[%s]]", redirect.From, redirect.To))
+		}
+	}
+	edit.Summary = strings.Replace(rf.Summary, "{redirects}", strings.Join(bypassed, ", "), -1)
+
+	if edit.Replacements == 0 || rf.DryRun {
+		return edit, nil
+	}
+
+	log.WithFields(log.Fields{
+		"page":         page,
+		"replacements": edit.Replacements,
+	}).Info("Editing page to bypass redirects")
+
+	return edit, rf.save(page, text, timestamp, edit.Summary)
+}
+
+// Current wikitext of a page and the timestamp of its revision.
+func (rf *RedirectFixer) wikitext(page string) (string, string, error) {
+	var revisions struct {
+		Query struct {
+			Pages []struct {
+				Revisions []struct {
+					Timestamp string `json:"timestamp"`
+					Slots     struct {
+						Main struct {
+							Content string `json:"content"`
+						} `json:"main"`
+					} `json:"slots"`
+				} `json:"revisions"`
+			} `json:"pages"`
+		} `json:"query"`
+	}
+	query := url.Values{
+		"action":        {"query"},
+		"prop":          {"revisions"},
+		"rvprop":        {"content|timestamp"},
+		"rvslots":       {"main"},
+		"titles":        {page},
+		"format":        {"json"},
+		"formatversion": {"2"},
+	}
+	if err := getJSON(rf.Client, rf.API+"?"+query.Encode(), &revisions); err != nil {
+		return "", "", err
+	}
+
+	if len(revisions.Query.Pages) == 0 || len(revisions.Query.Pages[0].Revisions) == 0 {
+		return "", "", fmt.Errorf("no revision found for %s", page)
+	}
+
+	revision := revisions.Query.Pages[0].Revisions[0]
+	return revision.Slots.Main.Content, revision.Timestamp, nil
+}
+
+// Saves the page, failing on edit conflicts with newer revisions.
+func (rf *RedirectFixer) save(page, text, timestamp, summary string) error {
+	var tokens struct {
+		Query struct {
+			Tokens struct {
+				CSRFToken string `json:"csrftoken"`
+			} `json:"tokens"`
+		} `json:"query"`
+	}
+	query := url.Values{"action": {"query"}, "meta": {"tokens"}, "format": {"json"}}
+	if err := getJSON(rf.Client, rf.API+"?"+query.Encode(), &tokens); err != nil {
+		return err
+	}
+
+	form := url.Values{
+		"action":        {"edit"},
+		"title":         {page},
+		"text":          {text},
+		"summary":       {summary},
+		"basetimestamp": {timestamp},
+		"bot":           {"1"},
+		"nocreate":      {"1"},
+		"token":         {tokens.Query.Tokens.CSRFToken},
+		"format":        {"json"},
+	}
+	resp, err := rf.Client.PostForm(rf.API, form)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		Edit struct {
+			Result string `json:"result"`
+		} `json:"edit"`
+		Error struct {
+			Code string `json:"code"`
+			Info string `json:"info"`
+		} `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return err
+	}
+
+	if result.Edit.Result != "Success" {
+		return fmt.Errorf("editing %s failed: %s %s", page, result.Error.Code, result.Error.Info)
+	}
+
+	return nil
+}
+
+// Rewrites wiki links to from so they link to to, keeping the displayed
+// text. Titles match with spaces or underscores and a case insensitive
+// first letter, as MediaWiki does.
+func ReplaceWikiLinks(text, from, to string) (string, int) {
+	count := 0
+	to = strings.Replace(to, "_", " ", -1)
+	pattern := regexp.MustCompile(`\[\[\s*(` + titlePattern(from) + `)\s*(\||#|\]\])`)
+	text = pattern.ReplaceAllStringFunc(text, func(match string) string {
+		groups := pattern.FindStringSubmatch(match)
+		count++
+
+		switch groups[2] {
+		case "]]":
+			return "[[" + to + "|" + groups[1] + "]]"
+		default:
+			return "[[" + to + groups[2]
+		}
+	})
+
+	return text, count
+}
+
+func titlePattern(title string) string {
+	title = strings.Replace(title, "_", " ", -1)
+	first, size := utf8.DecodeRuneInString(title)
+	if size == 0 {
+		return ""
+	}
+
+	letter := regexp.QuoteMeta(string(unicode.ToUpper(first)) + string(unicode.ToLower(first)))
+	rest := strings.Replace(regexp.QuoteMeta(title[size:]), " ", "[ _]+", -1)
+	return "[" + letter + "]" + rest
+}
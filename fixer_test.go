@@ -0,0 +1,94 @@
+package wikicrawl
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+func TestReplaceWikiLinks(t *testing.T) {
+	t.Run("Rewrite wiki links", func(t *testing.T) {
+		t.Run("Keep displayed text", func(t *testing.T) {
+			t.Parallel()
+			text := "See [[old Page]], [[Old_Page|the page]] and [[Old Page#Usage]] but not [[Old Pages]]."
+			found, count := ReplaceWikiLinks(text, "Old_Page", "New_Page")
+			expected := "See [[New Page|old Page]], [[New Page|the page]] and [[New Page#Usage]] but not [[Old Pages]]."
+			if found != expected || count != 3 {
+				t.Errorf("Rewritten text mismatch, got: %s (%d), want: %s (%d).", found, count, expected, 3)
+			}
+		})
+	})
+}
+
+// Wiki serving pages by title, Old redirecting to New, with an edit API.
+type editableWiki struct {
+	sync.Mutex
+
+	texts map[string]string
+	edits int
+}
+
+func (ew *editableWiki) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	ew.Lock()
+	defer ew.Unlock()
+
+	title := req.URL.Query().Get("title")
+	switch {
+	case req.URL.Path == "/api.php" && req.Method == "POST":
+		if req.FormValue("token") != "csrf" || req.FormValue("basetimestamp") != "2020-01-01T00:00:00Z" {
+			fmt.Fprint(rw, `{"error":{"code":"badtoken","info":"Invalid token"}}`)
+			return
+		}
+		ew.texts[req.FormValue("title")] = req.FormValue("text")
+		ew.edits++
+		fmt.Fprint(rw, `{"edit":{"result":"Success"}}`)
+	case req.URL.Path == "/api.php" && req.URL.Query().Get("meta") == "tokens":
+		fmt.Fprint(rw, `{"query":{"tokens":{"csrftoken":"csrf"}}}`)
+	case req.URL.Path == "/api.php":
+		page := req.URL.Query().Get("titles")
+		fmt.Fprintf(rw, `{"query":{"pages":[{"revisions":[{"timestamp":"2020-01-01T00:00:00Z","slots":{"main":{"content":%q}}}]}]}}`,
+			ew.texts[page])
+	case title == "Old":
+		http.Redirect(rw, req, "/index.php?title=New", http.StatusMovedPermanently)
+	default:
+		fmt.Fprint(rw, `<html><body><a href="/index.php?title=Old" /></body></html>`)
+	}
+}
+
+func TestRedirectFixer(t *testing.T) {
+	t.Run("Bypass redirects through the edit API", func(t *testing.T) {
+		for _, dryRun := range []bool{true, false} {
+			dryRun := dryRun
+			t.Run(fmt.Sprintf("Dry run %t", dryRun), func(t *testing.T) {
+				t.Parallel()
+				wiki := &editableWiki{texts: map[string]string{"Main": "Read [[Old]]."}}
+				server := httptest.NewServer(wiki)
+				defer server.Close()
+
+				c := NewCrawler(server.URL)
+				result := c.Crawl(server.URL + "/index.php?title=Main")
+
+				fixer := NewRedirectFixer(c.Client, c.Base())
+				fixer.DryRun = dryRun
+				edits, err := fixer.Fix(result)
+				if err != nil {
+					t.Fatalf("Fixing redirects failed: %s.", err)
+				}
+
+				if len(edits) != 1 || edits[0].Page != "Main" || edits[0].Summary != "Bypass redirects: [[Old]] → [[New]]" {
+					t.Errorf("Edits mismatch, got: %+v.", edits)
+				}
+
+				expected := "Read [[New|Old]]."
+				if dryRun {
+					expected = "Read [[Old]]."
+				}
+				if wiki.texts["Main"] != expected {
+					t.Errorf("Page text mismatch, got: %s, want: %s.", wiki.texts["Main"], expected)
+				}
+			})
+		}
+	})
+}
@@ -0,0 +1,94 @@
+package wikicrawl
+
+import (
+	"net/url"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// Reports if the crawl is focused on some titles or categories.
+func (c *Crawler) focused() bool {
+	return len(c.TitlePrefixes) > 0 || len(c.Categories) > 0
+}
+
+// Reports if link is a page of the focused area: a title starting with one
+// of TitlePrefixes or a member of one of Categories. Seeds are always in
+// focus, every link is when the crawl is not focused.
+func (c *Crawler) InFocus(link Link) bool {
+	if !c.focused() || link.Depth == 0 {
+		return true
+	}
+
+	title := NormalizeTitle(link.Title)
+	if len(title) == 0 {
+		return false
+	}
+
+	for _, prefix := range c.TitlePrefixes {
+		if strings.HasPrefix(title, NormalizeTitle(prefix)) {
+			return true
+		}
+	}
+
+	return c.members[title]
+}
+
+// Loads the titles of the focused categories, a category that cannot be
+// listed adding no page.
+func (c *Crawler) loadCategories() {
+	c.members = make(map[string]bool)
+	for _, category := range c.Categories {
+		titles, err := c.CategoryMembers(category)
+		if err != nil {
+			c.logger().WithFields(log.Fields{
+				"category": category,
+				"err":      err,
+			}).Warn("Listing category members failed")
+		}
+		for _, title := range titles {
+			c.members[NormalizeTitle(title)] = true
+		}
+	}
+}
+
+// Titles of the pages of a category, following API continuation.
+func (c *Crawler) CategoryMembers(category string) ([]string, error) {
+	if TitleNamespace(c.canonicalTitle(NormalizeTitle(category))) != "Category" {
+		category = "Category:" + category
+	}
+
+	var titles []string
+	query := url.Values{
+		"action":        {"query"},
+		"list":          {"categorymembers"},
+		"cmtitle":       {category},
+		"cmlimit":       {"max"},
+		"format":        {"json"},
+		"formatversion": {"2"},
+	}
+	for {
+		var members struct {
+			Query struct {
+				CategoryMembers []struct {
+					Title string `json:"title"`
+				} `json:"categorymembers"`
+			} `json:"query"`
+			Continue struct {
+				CMContinue string `json:"cmcontinue"`
+			} `json:"continue"`
+		}
+		if err := c.apiGet(query, &members); err != nil {
+			return titles, err
+		}
+
+		for _, member := range members.Query.CategoryMembers {
+			titles = append(titles, member.Title)
+		}
+
+		if len(members.Continue.CMContinue) == 0 {
+			return titles, nil
+		}
+		query.Set("cmcontinue", members.Continue.CMContinue)
+	}
+}
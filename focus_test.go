@@ -0,0 +1,64 @@
+package wikicrawl
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestFocusedCrawl(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		query := req.URL.Query()
+		switch {
+		case req.URL.Path == "/api.php" && query.Get("cmcontinue") == "":
+			fmt.Fprint(rw, `{"continue":{"cmcontinue":"next"},"query":{"categorymembers":[{"title":"Disk full"}]}}`)
+		case req.URL.Path == "/api.php":
+			fmt.Fprint(rw, `{"query":{"categorymembers":[{"title":"Host down"}]}}`)
+		case query.Get("title") == "Main":
+			fmt.Fprint(rw, `<a href="/index.php?title=Infra/Network">Network</a>
+				<a href="/index.php?title=Disk_full">Runbook</a>
+				<a href="/index.php?title=Other">Other</a>`)
+		case query.Get("title") == "Infra/Network":
+			fmt.Fprint(rw, `<a href="/index.php?title=Infra/Network/DNS">DNS</a>`)
+		case query.Get("title") == "Disk_full":
+			fmt.Fprint(rw, `<a href="/index.php?title=Storage">Storage</a>`)
+		case query.Get("title") == "Other":
+			fmt.Fprint(rw, `<a href="/index.php?title=Unrelated">Unrelated</a>`)
+		default:
+			fmt.Fprint(rw, `<html><body></body></html>`)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	t.Run("Follow pages of the focused area only", func(t *testing.T) {
+		t.Parallel()
+		c := NewCrawler(server.URL+"/index.php", WithTitlePrefixes("Infra/"), WithCategories("Runbooks"))
+		result := c.Crawl(server.URL + "/index.php?title=Main")
+
+		expected := []string{
+			server.URL + "/index.php?title=Disk_full",
+			server.URL + "/index.php?title=Infra%2FNetwork",
+			server.URL + "/index.php?title=Infra%2FNetwork%2FDNS",
+			server.URL + "/index.php?title=Main",
+			server.URL + "/index.php?title=Other",
+			server.URL + "/index.php?title=Storage",
+		}
+		if found := result.Visited.Keys(); !reflect.DeepEqual(found, expected) {
+			t.Errorf("Visited mismatch, got: %v, want: %v.", found, expected)
+		}
+	})
+
+	t.Run("List category members", func(t *testing.T) {
+		t.Parallel()
+		titles, err := NewCrawler(server.URL + "/index.php").CategoryMembers("Runbooks")
+		if err != nil {
+			t.Fatalf("Listing failed: %s.", err)
+		}
+
+		if expected := []string{"Disk full", "Host down"}; !reflect.DeepEqual(titles, expected) {
+			t.Errorf("Category members mismatch, got: %v, want: %v.", titles, expected)
+		}
+	})
+}
@@ -0,0 +1,209 @@
+package wikicrawl
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// Targets of a single form enumerated at most, forms with more option
+// combinations are cut short.
+const maxFormTargets = 100
+
+// Request method used for urls matching a pattern instead of GET, for
+// endpoints answering HEAD or POST only.
+type MethodRule struct {
+	URL    *regexp.Regexp
+	Method string
+}
+
+// Parses a rule written as "<url regexp>::<method>", the method being
+// GET, HEAD or POST.
+func ParseMethodRule(raw string) (MethodRule, error) {
+	split := strings.LastIndex(raw, "::")
+	if split < 0 {
+		return MethodRule{}, fmt.Errorf("method rule %q missing :: separator", raw)
+	}
+
+	link, err := regexp.Compile(raw[:split])
+	if err != nil {
+		return MethodRule{}, err
+	}
+
+	method := strings.ToUpper(raw[split+2:])
+	switch method {
+	case "GET", "HEAD", "POST":
+		return MethodRule{URL: link, Method: method}, nil
+	default:
+		return MethodRule{}, fmt.Errorf("unsupported method %q", raw[split+2:])
+	}
+}
+
+// Method of the first rule matching the url, GET when none does.
+func requestMethod(link string, rules []MethodRule) string {
+	for _, rule := range rules {
+		if rule.URL.MatchString(link) {
+			return rule.Method
+		}
+	}
+
+	return "GET"
+}
+
+// Field of a form with the values it can be submitted with.
+type formField struct {
+	name   string
+	values []string
+}
+
+// Form being parsed, simple while every field has known values.
+type form struct {
+	action string
+	get    bool
+	simple bool
+	fields []*formField
+}
+
+func (f *form) field(name string) *formField {
+	for _, field := range f.fields {
+		if field.name == name {
+			return field
+		}
+	}
+
+	field := &formField{name: name}
+	f.fields = append(f.fields, field)
+	return field
+}
+
+// Urls the simple GET forms of a page submit to, one per combination of
+// their select options and radio buttons, with hidden inputs kept. Forms
+// with free text fields (e.g. search boxes) cannot be enumerated and are
+// skipped. Empty actions submit to the page itself.
+func FormTargets(reader io.Reader, page *url.URL) []string {
+	var targets []string
+	var current *form
+	var selected *formField
+	var option *string
+
+	z := html.NewTokenizer(reader)
+	for {
+		tokenType := z.Next()
+		if tokenType == html.ErrorToken {
+			return targets
+		}
+
+		token := z.Token()
+		switch {
+		case tokenType == html.StartTagToken && token.Data == "form":
+			method := attr(token, "method")
+			current = &form{
+				action: attr(token, "action"),
+				get:    len(method) == 0 || strings.EqualFold(method, "get"),
+				simple: true,
+			}
+		case current == nil:
+			continue
+		case tokenType == html.EndTagToken && token.Data == "form":
+			if current.get && current.simple {
+				targets = append(targets, current.targets(page)...)
+			}
+			current = nil
+		case (tokenType == html.StartTagToken || tokenType == html.SelfClosingTagToken) && token.Data == "input":
+			name := attr(token, "name")
+			switch strings.ToLower(attr(token, "type")) {
+			case "hidden", "radio":
+				if len(name) > 0 {
+					field := current.field(name)
+					field.values = append(field.values, attr(token, "value"))
+				}
+			case "submit", "image", "reset", "button", "checkbox":
+			default:
+				current.simple = false
+			}
+		case tokenType == html.StartTagToken && token.Data == "textarea":
+			current.simple = false
+		case tokenType == html.StartTagToken && token.Data == "select":
+			selected = current.field(attr(token, "name"))
+		case tokenType == html.EndTagToken && token.Data == "select":
+			selected = nil
+		case tokenType == html.StartTagToken && token.Data == "option" && selected != nil:
+			value, found := attrFound(token, "value")
+			selected.values = append(selected.values, value)
+			option = nil
+			if !found {
+				// Options without a value submit their text.
+				option = &selected.values[len(selected.values)-1]
+			}
+		case tokenType == html.TextToken && option != nil:
+			*option += strings.TrimSpace(token.Data)
+		case tokenType == html.EndTagToken && token.Data == "option":
+			option = nil
+		}
+	}
+}
+
+// Urls of every combination of the field values, up to maxFormTargets.
+func (f *form) targets(page *url.URL) []string {
+	action, err := url.Parse(f.action)
+	if err != nil {
+		return nil
+	}
+	target := *page.ResolveReference(action)
+	target.Fragment = ""
+
+	sort.SliceStable(f.fields, func(i, j int) bool {
+		return f.fields[i].name < f.fields[j].name
+	})
+
+	queries := []url.Values{{}}
+	for _, field := range f.fields {
+		if len(field.name) == 0 || len(field.values) == 0 {
+			continue
+		}
+
+		var next []url.Values
+		for _, query := range queries {
+			for _, value := range field.values {
+				if len(next) == maxFormTargets {
+					break
+				}
+				combined := url.Values{}
+				for key, values := range query {
+					combined[key] = values
+				}
+				combined.Set(field.name, value)
+				next = append(next, combined)
+			}
+		}
+		queries = next
+	}
+
+	targets := make([]string, 0, len(queries))
+	for _, query := range queries {
+		target.RawQuery = query.Encode()
+		targets = append(targets, target.String())
+	}
+
+	return targets
+}
+
+func attr(token html.Token, key string) string {
+	value, _ := attrFound(token, key)
+	return value
+}
+
+func attrFound(token html.Token, key string) (string, bool) {
+	for _, attribute := range token.Attr {
+		if attribute.Key == key {
+			return attribute.Val, true
+		}
+	}
+
+	return "", false
+}
@@ -0,0 +1,109 @@
+package wikicrawl
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseMethodRule(t *testing.T) {
+	t.Run("Parse method rules", func(t *testing.T) {
+		t.Run("Method of matching urls", func(t *testing.T) {
+			t.Parallel()
+			rule, err := ParseMethodRule("/api/ping::head")
+			if err != nil {
+				t.Fatalf("Parsing failed: %s.", err)
+			}
+			if method := requestMethod("http://testing.com/api/ping", []MethodRule{rule}); method != "HEAD" {
+				t.Errorf("Method mismatch, got: %s, want: HEAD.", method)
+			}
+			if method := requestMethod("http://testing.com/wiki/Main", []MethodRule{rule}); method != "GET" {
+				t.Errorf("Method mismatch, got: %s, want: GET.", method)
+			}
+		})
+
+		t.Run("Unsupported method", func(t *testing.T) {
+			t.Parallel()
+			if _, err := ParseMethodRule("/api::DELETE"); err == nil {
+				t.Errorf("DELETE rule should fail.")
+			}
+		})
+	})
+}
+
+func TestFormTargets(t *testing.T) {
+	t.Run("Enumerate GET form targets", func(t *testing.T) {
+		page, _ := url.Parse("http://testing.com/wiki/Special:Nav")
+
+		t.Run("Select options and hidden inputs", func(t *testing.T) {
+			t.Parallel()
+			body := `<form action="/index.php">
+				<input type="hidden" name="title" value="Special:AllPages">
+				<select name="namespace"><option value="0">Main</option><option>4</option></select>
+				<input type="submit" value="Go">
+			</form>`
+			want := []string{
+				"http://testing.com/index.php?namespace=0&title=Special%3AAllPages",
+				"http://testing.com/index.php?namespace=4&title=Special%3AAllPages",
+			}
+			if targets := FormTargets(strings.NewReader(body), page); !reflect.DeepEqual(targets, want) {
+				t.Errorf("Targets mismatch, got: %v, want: %v.", targets, want)
+			}
+		})
+
+		t.Run("Radio buttons submitting to the page", func(t *testing.T) {
+			t.Parallel()
+			body := `<form><input type="radio" name="view" value="list"><input type="radio" name="view" value="grid"></form>`
+			want := []string{"http://testing.com/wiki/Special:Nav?view=list", "http://testing.com/wiki/Special:Nav?view=grid"}
+			if targets := FormTargets(strings.NewReader(body), page); !reflect.DeepEqual(targets, want) {
+				t.Errorf("Targets mismatch, got: %v, want: %v.", targets, want)
+			}
+		})
+
+		t.Run("Skip POST and free text forms", func(t *testing.T) {
+			t.Parallel()
+			body := `<form method="post" action="/edit"><select name="a"><option>1</option></select></form>
+				<form action="/index.php"><input name="search"><select name="ns"><option>0</option></select></form>`
+			if targets := FormTargets(strings.NewReader(body), page); len(targets) > 0 {
+				t.Errorf("Targets mismatch, got: %v, want none.", targets)
+			}
+		})
+	})
+}
+
+func TestForms(t *testing.T) {
+	t.Run("Crawl form targets with method overrides", func(t *testing.T) {
+		t.Parallel()
+		methods := make(chan string, 10)
+		server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			switch req.URL.Path {
+			case "/start":
+				fmt.Fprint(rw, `<html><form action="/list"><select name="page"><option>1</option><option>2</option></select></form>
+					<a href="/ping">ping</a></html>`)
+			case "/ping":
+				methods <- req.Method
+				if req.Method != "HEAD" {
+					rw.WriteHeader(http.StatusMethodNotAllowed)
+				}
+			default:
+				fmt.Fprint(rw, `<html></html>`)
+			}
+		}))
+		defer server.Close()
+
+		rule, _ := ParseMethodRule("/ping$::HEAD")
+		result := NewCrawler(server.URL, WithForms(), WithMethodRules(rule)).Crawl(server.URL + "/start")
+		for _, target := range []string{"/list?page=1", "/list?page=2", "/ping"} {
+			if !result.Visited.Contains(server.URL + target) {
+				t.Errorf("Visited mismatch, got: %v, want: %s.", result.Visited.Keys(), target)
+			}
+		}
+		if result.Broken.Len() > 0 || <-methods != "HEAD" {
+			t.Errorf("Broken mismatch, got: %v, want none.", result.Broken.Keys())
+		}
+	})
+}
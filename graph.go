@@ -0,0 +1,194 @@
+package wikicrawl
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// Damping factor of PageRank, the chance of following a link instead of
+// jumping to a random page.
+const pageRankDamping = 0.85
+
+// Iterations of PageRank, enough for the ranks of a wiki to settle.
+const pageRankIterations = 50
+
+// PageRank of every link of the result over the crawled link graph, the
+// ranks summing to 1. Links without outgoing links share their rank with
+// every page.
+func (cr *CrawlResult) PageRank() map[string]float64 {
+	edges := cr.Edges()
+	nodes := cr.Pages.Keys()
+	if len(nodes) == 0 {
+		return map[string]float64{}
+	}
+
+	count := float64(len(nodes))
+	rank := make(map[string]float64, len(nodes))
+	for _, node := range nodes {
+		rank[node] = 1 / count
+	}
+
+	for i := 0; i < pageRankIterations; i++ {
+		next := make(map[string]float64, len(nodes))
+		dangling := 0.0
+		for _, node := range nodes {
+			targets := edges[node]
+			if len(targets) == 0 {
+				dangling += rank[node]
+				continue
+			}
+			share := rank[node] / float64(len(targets))
+			for _, target := range targets {
+				next[target] += share
+			}
+		}
+
+		for _, node := range nodes {
+			rank[node] = (1-pageRankDamping)/count + pageRankDamping*(next[node]+dangling/count)
+		}
+	}
+
+	return rank
+}
+
+// Attributes of a node of an exported link graph.
+type graphNode struct {
+	id        string
+	status    int
+	depth     int
+	namespace string
+	pageRank  float64
+}
+
+// Nodes of the link graph sorted by url, with their edges.
+func (cr *CrawlResult) graph() ([]graphNode, map[string][]string) {
+	rank := cr.PageRank()
+	var nodes []graphNode
+	for _, info := range cr.Pages.Values() {
+		namespace := info.Link.Namespace
+		if len(namespace) == 0 && len(info.Link.Title) > 0 {
+			namespace = "Main"
+		}
+		key := info.Link.String()
+		nodes = append(nodes, graphNode{id: key, status: info.Status, depth: info.Link.Depth, namespace: namespace, pageRank: rank[key]})
+	}
+	sort.Slice(nodes, func(i, j int) bool {
+		return nodes[i].id < nodes[j].id
+	})
+
+	// Pages left out of a filtered result still appear as referrers, their
+	// edges would point at no node.
+	edges := cr.Edges()
+	for source := range edges {
+		if !cr.Pages.Contains(source) {
+			delete(edges, source)
+		}
+	}
+
+	return nodes, edges
+}
+
+// Generic XML element the graph exports are built from.
+type xmlElement struct {
+	XMLName xml.Name
+	Attrs   []xml.Attr   `xml:",any,attr"`
+	Values  []xmlElement `xml:",any"`
+	Text    string       `xml:",chardata"`
+}
+
+// Element with attributes given as name and value pairs.
+func element(name string, attrs ...string) xmlElement {
+	el := xmlElement{XMLName: xml.Name{Local: name}}
+	for i := 0; i+1 < len(attrs); i += 2 {
+		el.Attrs = append(el.Attrs, xml.Attr{Name: xml.Name{Local: attrs[i]}, Value: attrs[i+1]})
+	}
+
+	return el
+}
+
+// Writes the link graph as GEXF 1.3, the format of Gephi, nodes carrying
+// their status, depth, namespace and PageRank.
+func (cr *CrawlResult) WriteGEXF(writer io.Writer) error {
+	nodes, edges := cr.graph()
+
+	attributes := element("attributes", "class", "node")
+	for i, attribute := range [][2]string{{"status", "integer"}, {"depth", "integer"}, {"namespace", "string"}, {"pagerank", "double"}} {
+		attributes.Values = append(attributes.Values, element("attribute", "id", fmt.Sprint(i), "title", attribute[0], "type", attribute[1]))
+	}
+
+	nodeList := element("nodes")
+	edgeList := element("edges")
+	for _, node := range nodes {
+		values := element("attvalues")
+		for i, value := range node.values() {
+			values.Values = append(values.Values, element("attvalue", "for", fmt.Sprint(i), "value", value))
+		}
+		el := element("node", "id", node.id, "label", node.id)
+		el.Values = []xmlElement{values}
+		nodeList.Values = append(nodeList.Values, el)
+
+		for _, target := range edges[node.id] {
+			edgeList.Values = append(edgeList.Values, element("edge", "id", fmt.Sprint(len(edgeList.Values)), "source", node.id, "target", target))
+		}
+	}
+
+	graph := element("graph", "defaultedgetype", "directed")
+	graph.Values = []xmlElement{attributes, nodeList, edgeList}
+	gexf := element("gexf", "xmlns", "http://gexf.net/1.3", "version", "1.3")
+	gexf.Values = []xmlElement{graph}
+
+	return writeXML(writer, gexf)
+}
+
+// Writes the link graph as GraphML, nodes carrying their status, depth,
+// namespace and PageRank.
+func (cr *CrawlResult) WriteGraphML(writer io.Writer) error {
+	nodes, edges := cr.graph()
+
+	graphml := element("graphml", "xmlns", "http://graphml.graphdrawing.org/xmlns")
+	for i, key := range [][2]string{{"status", "int"}, {"depth", "int"}, {"namespace", "string"}, {"pagerank", "double"}} {
+		graphml.Values = append(graphml.Values, element("key", "id", fmt.Sprintf("d%d", i), "for", "node", "attr.name", key[0], "attr.type", key[1]))
+	}
+
+	graph := element("graph", "id", "wikicrawl", "edgedefault", "directed")
+	var edgeList []xmlElement
+	for _, node := range nodes {
+		el := element("node", "id", node.id)
+		for i, value := range node.values() {
+			data := element("data", "key", fmt.Sprintf("d%d", i))
+			data.Text = value
+			el.Values = append(el.Values, data)
+		}
+		graph.Values = append(graph.Values, el)
+
+		for _, target := range edges[node.id] {
+			edgeList = append(edgeList, element("edge", "source", node.id, "target", target))
+		}
+	}
+	graph.Values = append(graph.Values, edgeList...)
+	graphml.Values = append(graphml.Values, graph)
+
+	return writeXML(writer, graphml)
+}
+
+// Attribute values of a node, in the order the exports declare them.
+func (gn graphNode) values() []string {
+	return []string{fmt.Sprint(gn.status), fmt.Sprint(gn.depth), gn.namespace, fmt.Sprint(gn.pageRank)}
+}
+
+func writeXML(writer io.Writer, root xmlElement) error {
+	if _, err := io.WriteString(writer, xml.Header); err != nil {
+		return err
+	}
+
+	encoder := xml.NewEncoder(writer)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(root); err != nil {
+		return err
+	}
+
+	_, err := io.WriteString(writer, "\n")
+	return err
+}
@@ -0,0 +1,122 @@
+package wikicrawl
+
+import (
+	"bytes"
+	"encoding/xml"
+	"math"
+	"strings"
+	"testing"
+)
+
+// Result of a wiki where every page links to the main page.
+func starResult() *CrawlResult {
+	result := NewCrawlResult()
+	main := NewLink("http://testing.com/index.php?title=Main_Page")
+	result.record(main, func(info *PageInfo) {
+		info.Status = 200
+		info.AddReferrer("http://testing.com/index.php?title=Help:A")
+		info.AddReferrer("http://testing.com/index.php?title=B")
+	})
+	for _, title := range []string{"Help:A", "B"} {
+		link := NewLink("http://testing.com/index.php?title=" + title)
+		link.Depth = 1
+		result.record(link, func(info *PageInfo) {
+			info.Status = 200
+			info.AddReferrer(main.String())
+		})
+	}
+
+	return result
+}
+
+func TestPageRank(t *testing.T) {
+	t.Run("Rank the most linked page first", func(t *testing.T) {
+		t.Parallel()
+		rank := starResult().PageRank()
+		main, a := rank["http://testing.com/index.php?title=Main_Page"], rank["http://testing.com/index.php?title=Help:A"]
+		if main <= a {
+			t.Errorf("Rank mismatch, got: %v for the main page and %v for a leaf, want the main page first.", main, a)
+		}
+		total := 0.0
+		for _, value := range rank {
+			total += value
+		}
+		if math.Abs(total-1) > 1e-9 {
+			t.Errorf("Rank total mismatch, got: %v, want: 1.", total)
+		}
+	})
+}
+
+func TestWriteGraph(t *testing.T) {
+	t.Run("GEXF", func(t *testing.T) {
+		t.Parallel()
+		var out bytes.Buffer
+		if err := starResult().WriteGEXF(&out); err != nil {
+			t.Fatal(err)
+		}
+
+		var gexf struct {
+			Nodes []struct {
+				ID     string `xml:"id,attr"`
+				Values []struct {
+					For   string `xml:"for,attr"`
+					Value string `xml:"value,attr"`
+				} `xml:"attvalues>attvalue"`
+			} `xml:"graph>nodes>node"`
+			Edges []struct {
+				Source string `xml:"source,attr"`
+			} `xml:"graph>edges>edge"`
+		}
+		if err := xml.Unmarshal(out.Bytes(), &gexf); err != nil {
+			t.Fatalf("Invalid GEXF: %s.", err)
+		}
+		if len(gexf.Nodes) != 3 || len(gexf.Edges) != 4 {
+			t.Fatalf("Graph mismatch, got: %d nodes and %d edges, want: 3 and 4.", len(gexf.Nodes), len(gexf.Edges))
+		}
+		if node := gexf.Nodes[0]; node.ID != "http://testing.com/index.php?title=B" || node.Values[2].Value != "Main" {
+			t.Errorf("Node mismatch, got: %v, want B in the Main namespace.", node)
+		}
+		if namespace := gexf.Nodes[1].Values[2].Value; namespace != "Help" {
+			t.Errorf("Namespace mismatch, got: %s, want: Help.", namespace)
+		}
+	})
+
+	t.Run("Edges of filtered out pages", func(t *testing.T) {
+		t.Parallel()
+		filter, _ := ParseFilter(`namespace!="Help"`)
+		nodes, edges := starResult().Filter(filter).graph()
+		if len(nodes) != 2 || len(edges["http://testing.com/index.php?title=Help:A"]) != 0 || len(edges) != 2 {
+			t.Errorf("Graph mismatch, got: %v nodes and %v edges, want no edge of Help:A.", nodes, edges)
+		}
+	})
+
+	t.Run("GraphML", func(t *testing.T) {
+		t.Parallel()
+		var out bytes.Buffer
+		if err := starResult().WriteGraphML(&out); err != nil {
+			t.Fatal(err)
+		}
+
+		var graphml struct {
+			Keys []struct {
+				Name string `xml:"attr.name,attr"`
+			} `xml:"key"`
+			Nodes []struct {
+				Data []string `xml:"data"`
+			} `xml:"graph>node"`
+			Edges []struct{} `xml:"graph>edge"`
+		}
+		if err := xml.Unmarshal(out.Bytes(), &graphml); err != nil {
+			t.Fatalf("Invalid GraphML: %s.", err)
+		}
+		if len(graphml.Keys) != 4 || len(graphml.Nodes) != 3 || len(graphml.Edges) != 4 {
+			t.Fatalf("Graph mismatch, got: %d keys, %d nodes and %d edges, want: 4, 3 and 4.", len(graphml.Keys), len(graphml.Nodes), len(graphml.Edges))
+		}
+		if data := graphml.Nodes[2].Data; data[0] != "200" || data[1] != "0" {
+			t.Errorf("Node data mismatch, got: %v, want status 200 at depth 0.", data)
+		}
+		if !strings.Contains(out.String(), `xmlns="http://graphml.graphdrawing.org/xmlns"`) {
+			t.Errorf("Namespace missing, got: %s.", out.String())
+		}
+	})
+}
@@ -0,0 +1,142 @@
+package wikicrawl
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// Summary of a crawl run kept in the results history.
+//
+//  1. Finished: End of the run, ordering the history.
+//  2. Pages: Links seen by the run, visited or not.
+//  3. BrokenRate: Share of visited links found broken.
+type HistoryEntry struct {
+	Finished   time.Time         `json:"finished"`
+	Seed       string            `json:"seed"`
+	ConfigHash string            `json:"configHash"`
+	Visited    int               `json:"visited"`
+	Pages      int               `json:"pages"`
+	Broken     int               `json:"broken"`
+	BrokenRate float64           `json:"brokenRate"`
+	Suspect    int               `json:"suspectBroken"`
+	Aborted    bool              `json:"aborted,omitempty"`
+	Labels     map[string]string `json:"labels,omitempty"`
+}
+
+// History entry summarizing the result.
+func (cr *CrawlResult) HistoryEntry() HistoryEntry {
+	finished := cr.Metadata.Finished
+	if finished.IsZero() {
+		finished = time.Now()
+	}
+
+	return HistoryEntry{
+		Finished:   finished,
+		Seed:       cr.Metadata.Seed,
+		ConfigHash: cr.Metadata.ConfigHash,
+		Visited:    cr.Visited.Len(),
+		Pages:      cr.Pages.Len(),
+		Broken:     cr.Broken.Len(),
+		BrokenRate: cr.BrokenRate(),
+		Suspect:    cr.SuspectBroken.Len(),
+		Aborted:    cr.Aborted,
+		Labels:     cr.Metadata.Labels,
+	}
+}
+
+// Results history kept as a JSON lines file, one run per line, so runs can
+// be appended without reading the whole history.
+type HistoryFile struct {
+	Path string
+}
+
+// Appends the summary of a run to the history.
+func (hf HistoryFile) Append(entry HistoryEntry) error {
+	file, err := os.OpenFile(hf.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	return json.NewEncoder(file).Encode(entry)
+}
+
+// Every run of the history, oldest first. A missing file is an empty
+// history.
+func (hf HistoryFile) Entries() ([]HistoryEntry, error) {
+	file, err := os.Open(hf.Path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return ReadHistory(file)
+}
+
+// Reads history entries written one JSON object per line.
+func ReadHistory(reader io.Reader) ([]HistoryEntry, error) {
+	var entries []HistoryEntry
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		if len(strings.TrimSpace(scanner.Text())) == 0 {
+			continue
+		}
+
+		var entry HistoryEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return entries, err
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, scanner.Err()
+}
+
+// Renders the broken links and pages of every run over time as a text
+// table, with a bar chart of broken links and the change since the
+// previous run of the same seed.
+func WriteTrend(writer io.Writer, entries []HistoryEntry) error {
+	most := 0
+	for _, entry := range entries {
+		if entry.Broken > most {
+			most = entry.Broken
+		}
+	}
+
+	if _, err := fmt.Fprintf(writer, "%-20s %8s %8s %8s %7s  %s\n", "Finished", "Pages", "Visited", "Broken", "Rate", "Change"); err != nil {
+		return err
+	}
+
+	previous := make(map[string]HistoryEntry)
+	for _, entry := range entries {
+		change := ""
+		if last, found := previous[entry.Seed]; found {
+			change = fmt.Sprintf("%+d", entry.Broken-last.Broken)
+		}
+		previous[entry.Seed] = entry
+
+		bar := ""
+		if most > 0 {
+			bar = strings.Repeat("#", (entry.Broken*30+most-1)/most)
+		}
+		if entry.Aborted {
+			change += " (aborted)"
+		}
+
+		_, err := fmt.Fprintf(writer, "%-20s %8d %8d %8d %6.2f%%  %-8s %s\n", entry.Finished.Format("2006-01-02 15:04"),
+			entry.Pages, entry.Visited, entry.Broken, entry.BrokenRate*100, change, bar)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,71 @@
+package wikicrawl
+
+import (
+	"bytes"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHistoryFile(t *testing.T) {
+	t.Run("Append and read runs", func(t *testing.T) {
+		t.Parallel()
+		history := HistoryFile{Path: filepath.Join(t.TempDir(), "runs.jsonl")}
+		if entries, err := history.Entries(); err != nil || entries != nil {
+			t.Errorf("Missing history should be empty, got: %v, err: %v.", entries, err)
+		}
+
+		result := NewCrawlResult()
+		result.Metadata = Metadata{Seed: "http://testing.com", Finished: time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)}
+		result.Visited.Add(NewLink("http://testing.com/a"))
+		result.Visited.Add(NewLink("http://testing.com/gone"))
+		result.Broken.Add(NewLink("http://testing.com/gone"))
+
+		first := result.HistoryEntry()
+		second := first
+		second.Finished = first.Finished.AddDate(0, 0, 7)
+		second.Broken = 0
+		for _, entry := range []HistoryEntry{first, second} {
+			if err := history.Append(entry); err != nil {
+				t.Fatalf("Append failed: %s.", err)
+			}
+		}
+
+		entries, err := history.Entries()
+		if err != nil {
+			t.Fatalf("Reading failed: %s.", err)
+		}
+		if expected := []HistoryEntry{first, second}; !reflect.DeepEqual(entries, expected) {
+			t.Errorf("History mismatch, got: %v, want: %v.", entries, expected)
+		}
+		if first.BrokenRate != 0.5 {
+			t.Errorf("Broken rate mismatch, got: %v, want: %v.", first.BrokenRate, 0.5)
+		}
+	})
+}
+
+func TestWriteTrend(t *testing.T) {
+	t.Run("Render broken links over time", func(t *testing.T) {
+		t.Parallel()
+		start := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+		entries := []HistoryEntry{
+			{Finished: start, Seed: "http://testing.com", Pages: 100, Visited: 90, Broken: 10, BrokenRate: 0.111},
+			{Finished: start.AddDate(0, 0, 7), Seed: "http://testing.com", Pages: 110, Visited: 100, Broken: 4, BrokenRate: 0.04},
+		}
+
+		var out bytes.Buffer
+		if err := WriteTrend(&out, entries); err != nil {
+			t.Fatalf("Rendering failed: %s.", err)
+		}
+
+		lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+		if len(lines) != 3 {
+			t.Fatalf("Trend lines mismatch, got: %v, want: %v.", len(lines), 3)
+		}
+		if !strings.HasSuffix(lines[1], strings.Repeat("#", 30)) || !strings.Contains(lines[2], "-6") || !strings.HasSuffix(lines[2], " "+strings.Repeat("#", 12)) {
+			t.Errorf("Trend mismatch, got:\n%s", out.String())
+		}
+	})
+}
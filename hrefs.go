@@ -0,0 +1,80 @@
+package wikicrawl
+
+import (
+	"encoding/csv"
+	"io"
+)
+
+// How a discovered href was merged into a crawled link.
+//
+//  1. Href: The href as written on the page.
+//  2. Page: First page the href was found on.
+//  3. Canonical: Normalized url the href was deduplicated as, empty when
+//     the href is not a valid url.
+//  4. Final: Url the canonical one redirects to, empty without redirect.
+//  5. Skipped: Why the href was not followed, empty when it was.
+type HrefMapping struct {
+	Href      string `json:"href"`
+	Page      string `json:"page"`
+	Canonical string `json:"canonical,omitempty"`
+	Final     string `json:"final,omitempty"`
+	Skipped   string `json:"skipped,omitempty"`
+}
+
+// Mapping of every discovered href, keyed by the href as written.
+type HrefMap = Set[string, HrefMapping]
+
+func NewHrefMap() HrefMap {
+	return NewSet(func(mapping HrefMapping) string {
+		return mapping.Href
+	})
+}
+
+// Reasons an href was not followed.
+const (
+	SkippedInvalid  = "invalid url"
+	SkippedExcluded = "out of scope or excluded"
+)
+
+// Records how an href found on page was mapped, keeping the first page it
+// was found on.
+func (cr *CrawlResult) mapHref(page, href, canonical, skipped string) {
+	if cr.Hrefs == nil {
+		return
+	}
+
+	cr.Hrefs.Update(href, func(mapping HrefMapping, found bool) HrefMapping {
+		if found {
+			return mapping
+		}
+		return HrefMapping{Href: href, Page: page, Canonical: canonical, Skipped: skipped}
+	})
+}
+
+// Every tracked href mapping sorted by href, with the final url of
+// redirecting links.
+func (cr *CrawlResult) HrefMappings() []HrefMapping {
+	if cr.Hrefs == nil {
+		return nil
+	}
+
+	mappings := cr.Hrefs.Values()
+	for i, mapping := range mappings {
+		if info, found := cr.Pages.Get(mapping.Canonical); found {
+			mappings[i].Final = info.RedirectTo
+		}
+	}
+	return mappings
+}
+
+// Writes the href mappings as CSV, one href per row.
+func (cr *CrawlResult) WriteHrefMap(writer io.Writer) error {
+	table := csv.NewWriter(writer)
+	table.Write([]string{"href", "page", "canonical", "final", "skipped"})
+	for _, mapping := range cr.HrefMappings() {
+		table.Write([]string{mapping.Href, mapping.Page, mapping.Canonical, mapping.Final, mapping.Skipped})
+	}
+	table.Flush()
+
+	return table.Error()
+}
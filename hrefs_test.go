@@ -0,0 +1,60 @@
+package wikicrawl
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHrefMap(t *testing.T) {
+	t.Run("Map hrefs to canonical urls", func(t *testing.T) {
+		t.Parallel()
+		server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			switch req.URL.Path {
+			case "/old":
+				http.Redirect(rw, req, "/new", http.StatusMovedPermanently)
+			case "/start":
+				fmt.Fprint(rw, `<html><a href="/new#history">a</a><a href="/NEW">b</a><a href="/old">c</a>`+
+					`<a href="http://other.com/x">d</a><a href="%zz">e</a></html>`)
+			default:
+				fmt.Fprint(rw, `<html></html>`)
+			}
+		}))
+		defer server.Close()
+
+		result := NewCrawler(server.URL, WithHrefMap()).Crawl(server.URL + "/start")
+		mappings := make(map[string]HrefMapping)
+		for _, mapping := range result.HrefMappings() {
+			mappings[mapping.Href] = mapping
+		}
+
+		start := server.URL + "/start"
+		if mapping := mappings["/new#history"]; mapping.Canonical != server.URL+"/new" || mapping.Page != start || len(mapping.Skipped) > 0 {
+			t.Errorf("Fragment mapping mismatch, got: %+v.", mapping)
+		}
+		if mapping := mappings["/old"]; mapping.Canonical != server.URL+"/old" || mapping.Final != server.URL+"/new" {
+			t.Errorf("Redirect mapping mismatch, got: %+v, want final: %s/new.", mapping, server.URL)
+		}
+		if mapping := mappings["http://other.com/x"]; mapping.Skipped != SkippedExcluded {
+			t.Errorf("Excluded mapping mismatch, got: %+v.", mapping)
+		}
+		if mapping := mappings["%zz"]; mapping.Skipped != SkippedInvalid || len(mapping.Canonical) > 0 {
+			t.Errorf("Invalid mapping mismatch, got: %+v.", mapping)
+		}
+
+		var table bytes.Buffer
+		if err := result.WriteHrefMap(&table); err != nil {
+			t.Fatalf("Writing href map failed: %s.", err)
+		}
+		if lines := strings.Split(strings.TrimSpace(table.String()), "\n"); len(lines) != len(mappings)+1 || lines[0] != "href,page,canonical,final,skipped" {
+			t.Errorf("Href map mismatch, got: %s.", table.String())
+		}
+
+		if NewCrawler(server.URL).Crawl(start).HrefMappings() != nil {
+			t.Errorf("Hrefs should only be tracked when enabled.")
+		}
+	})
+}
@@ -0,0 +1,102 @@
+package wikicrawl
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// Full text index built from the visible text of crawled pages.
+// Implements PageProcessor so it can be populated during a crawl.
+type TextIndex struct {
+	sync.RWMutex
+
+	Terms map[string]map[string]int
+}
+
+// Simple constructor for TextIndex type.
+func NewTextIndex() *TextIndex {
+	return &TextIndex{Terms: make(map[string]map[string]int)}
+}
+
+// Indexes the page text, never flags a page.
+func (ti *TextIndex) Process(page Link, body []byte) bool {
+	ti.Add(page.String(), VisibleText(bytes.NewReader(body)))
+	return false
+}
+
+// Adds every term of the text to the index for the given page.
+func (ti *TextIndex) Add(page string, text string) {
+	ti.Lock()
+	defer ti.Unlock()
+
+	for _, term := range Terms(text) {
+		pages, found := ti.Terms[term]
+		if !found {
+			pages = make(map[string]int)
+			ti.Terms[term] = pages
+		}
+		pages[page]++
+	}
+}
+
+// Returns pages containing every term of the query, best matches first.
+func (ti *TextIndex) Search(query string) []string {
+	ti.RLock()
+	defer ti.RUnlock()
+
+	var scores map[string]int
+	for _, term := range Terms(query) {
+		matches := make(map[string]int)
+		for page, count := range ti.Terms[term] {
+			if scores == nil {
+				matches[page] = count
+			} else if score, found := scores[page]; found {
+				matches[page] = score + count
+			}
+		}
+		scores = matches
+	}
+
+	results := make([]string, 0, len(scores))
+	for page := range scores {
+		results = append(results, page)
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		if scores[results[i]] != scores[results[j]] {
+			return scores[results[i]] > scores[results[j]]
+		}
+		return results[i] < results[j]
+	})
+
+	return results
+}
+
+// Serializes the index as JSON.
+func (ti *TextIndex) Save(writer io.Writer) error {
+	ti.RLock()
+	defer ti.RUnlock()
+	return json.NewEncoder(writer).Encode(ti.Terms)
+}
+
+// Loads an index previously written with Save.
+func LoadTextIndex(reader io.Reader) (*TextIndex, error) {
+	ti := NewTextIndex()
+	if err := json.NewDecoder(reader).Decode(&ti.Terms); err != nil {
+		return nil, err
+	}
+
+	return ti, nil
+}
+
+// Splits text into lower case index terms.
+func Terms(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
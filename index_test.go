@@ -0,0 +1,73 @@
+package wikicrawl
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestTextIndex(t *testing.T) {
+	t.Run("Full text index", func(t *testing.T) {
+		t.Run("Search matches all terms", func(t *testing.T) {
+			t.Parallel()
+			index := NewTextIndex()
+			index.Add("a", "Install the server")
+			index.Add("b", "Server setup, server upgrade")
+			index.Add("c", "Unrelated")
+
+			found := index.Search("SERVER")
+			expected := []string{"b", "a"}
+			if !reflect.DeepEqual(found, expected) {
+				t.Errorf("Search results mismatch, got: %v, want: %v.", found, expected)
+			}
+
+			found = index.Search("install server")
+			expected = []string{"a"}
+			if !reflect.DeepEqual(found, expected) {
+				t.Errorf("Search results mismatch, got: %v, want: %v.", found, expected)
+			}
+		})
+
+		t.Run("Save and load round trip", func(t *testing.T) {
+			t.Parallel()
+			index := NewTextIndex()
+			index.Add("a", "wiki")
+
+			var buffer bytes.Buffer
+			if err := index.Save(&buffer); err != nil {
+				t.Fatalf("Saving index failed: %s.", err)
+			}
+
+			loaded, err := LoadTextIndex(&buffer)
+			if err != nil {
+				t.Fatalf("Loading index failed: %s.", err)
+			}
+
+			if !reflect.DeepEqual(loaded.Terms, index.Terms) {
+				t.Errorf("Loaded index mismatch, got: %v, want: %v.", loaded.Terms, index.Terms)
+			}
+		})
+
+		t.Run("Populated during crawl", func(t *testing.T) {
+			t.Parallel()
+			server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				fmt.Fprintf(rw, `<html><body>Page %s <a href="/other" /></body></html>`, req.URL.Path)
+			}))
+			defer server.Close()
+
+			index := NewTextIndex()
+			c := NewCrawler(server.URL)
+			c.Processors = append(c.Processors, index)
+			c.Crawl(server.URL)
+
+			found := index.Search("other")
+			expected := []string{server.URL + "/other"}
+			if !reflect.DeepEqual(found, expected) {
+				t.Errorf("Search results mismatch, got: %v, want: %v.", found, expected)
+			}
+		})
+	})
+}
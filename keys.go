@@ -0,0 +1,50 @@
+package wikicrawl
+
+// Canonical key of a link, links with equal keys being the same page and
+// crawled once.
+type KeyFunc func(link Link) string
+
+// Named key functions, selectable from the command line.
+var PageKeys = map[string]KeyFunc{
+	"url":   URLKey,
+	"title": TitleKey,
+	"curid": CurIDKey,
+}
+
+// Default key, the normalized url.
+func URLKey(link Link) string {
+	return link.String()
+}
+
+// Page title on the host for ?title= urls, so wikis reachable through
+// several paths or entry points are crawled once. Other links use their
+// url.
+func TitleKey(link Link) string {
+	if len(link.Title) == 0 || link.URL == nil {
+		return URLKey(link)
+	}
+
+	return "title:" + link.URL.Host + ":" + NormalizeTitle(link.Title)
+}
+
+// Page id on the host for ?curid= urls, falling back to TitleKey.
+func CurIDKey(link Link) string {
+	if link.URL == nil {
+		return URLKey(link)
+	}
+
+	if curid := link.URL.Query().Get("curid"); len(curid) > 0 {
+		return "curid:" + link.URL.Host + ":" + curid
+	}
+
+	return TitleKey(link)
+}
+
+// Key function deduplicating links, URLKey unless configured.
+func (c *Crawler) pageKey() KeyFunc {
+	if c.PageKey != nil {
+		return c.PageKey
+	}
+
+	return URLKey
+}
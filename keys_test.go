@@ -0,0 +1,55 @@
+package wikicrawl
+
+import "testing"
+
+func TestPageKeys(t *testing.T) {
+	t.Run("Canonical page keys", func(t *testing.T) {
+		t.Run("Url key tells entry points apart", func(t *testing.T) {
+			t.Parallel()
+			a, b := NewLink("http://testing.com/w/index.php?title=Page"), NewLink("http://testing.com/index.php?title=Page")
+			if URLKey(a) == URLKey(b) {
+				t.Errorf("Url keys should differ, got: %s.", URLKey(a))
+			}
+		})
+
+		t.Run("Title key joins entry points", func(t *testing.T) {
+			t.Parallel()
+			a, b := NewLink("http://testing.com/w/index.php?title=Page"), NewLink("http://testing.com/index.php?title=page")
+			if TitleKey(a) != TitleKey(b) {
+				t.Errorf("Title key mismatch, got: %s, want: %s.", TitleKey(b), TitleKey(a))
+			}
+
+			other := NewLink("http://other.com/index.php?title=Page")
+			if TitleKey(a) == TitleKey(other) {
+				t.Errorf("Title keys of other hosts should differ, got: %s.", TitleKey(other))
+			}
+		})
+
+		t.Run("Curid key joins page ids", func(t *testing.T) {
+			t.Parallel()
+			a, b := NewLink("http://testing.com/w/index.php?curid=12"), NewLink("http://testing.com/index.php?curid=12&action=view")
+			if CurIDKey(a) != CurIDKey(b) {
+				t.Errorf("Curid key mismatch, got: %s, want: %s.", CurIDKey(b), CurIDKey(a))
+			}
+
+			titled := NewLink("http://testing.com/index.php?title=Page")
+			if CurIDKey(titled) != TitleKey(titled) {
+				t.Errorf("Curid key fallback mismatch, got: %s, want: %s.", CurIDKey(titled), TitleKey(titled))
+			}
+		})
+	})
+
+	t.Run("Deduplicate queued pages by key", func(t *testing.T) {
+		t.Parallel()
+		queue := NewWorkQueue(*NewCrawler("http://testing.com", WithPageKey(TitleKey)), 10)
+		queue.AddWork(NewLink("http://testing.com/w/index.php?title=Page"))
+		queue.AddWork(NewLink("http://testing.com/index.php?title=Page"))
+
+		if queue.Pending() != 1 {
+			t.Errorf("Pending links mismatch, got: %v, want: %v.", queue.Pending(), 1)
+		}
+		queue.Abort()
+		queue.Start(1)
+		queue.Wait()
+	})
+}
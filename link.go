@@ -1,36 +1,283 @@
 package wikicrawl
 
 import (
-	"sync"
+	"encoding/json"
+	"net/url"
+	"strings"
+	"time"
 )
 
-type Link = string
+// Classification of a link relative to the crawled wiki.
+type LinkClass int
 
-// Unique set of url links.
-type LinkSet struct {
-	sync.RWMutex
+const (
+	UnknownLink LinkClass = iota
+	InternalLink
+	ExternalLink
+	InvalidLink
+)
+
+var linkClassNames = []string{"unknown", "internal", "external", "invalid"}
+
+func (lc LinkClass) String() string {
+	if int(lc) < len(linkClassNames) {
+		return linkClassNames[lc]
+	}
+
+	return "unknown"
+}
+
+// Standard MediaWiki namespaces, pages without one belong to Main.
+var Namespaces = []string{
+	"Media", "Special",
+	"Talk",
+	"User", "User_talk",
+	"Project", "Project_talk",
+	"File", "File_talk",
+	"MediaWiki", "MediaWiki_talk",
+	"Template", "Template_talk",
+	"Help", "Help_talk",
+	"Category", "Category_talk",
+}
 
-	Set map[Link]bool
+// Link found during a crawl.
+//
+//  1. Href: Link as written in the page (or given as seed).
+//  2. URL: Parsed url, nil when Href is not a valid url.
+//  3. Title: MediaWiki page title including namespace.
+//  4. Namespace: MediaWiki namespace of the title, empty for Main.
+//  5. Depth: Number of links followed from the seed.
+//  6. Class: Internal, external or invalid.
+type Link struct {
+	Href      string
+	URL       *url.URL
+	Title     string
+	Namespace string
+	Depth     int
+	Class     LinkClass
 }
 
-func (ls *LinkSet) Add(link Link) bool {
-	ls.Lock()
-	defer ls.Unlock()
+// Builds a Link from a raw href, parsing its url and wiki title.
+func NewLink(href string) Link {
+	link := Link{Href: href}
 
-	_, found := ls.Set[link]
-	if !found {
-		ls.Set[link] = true
+	parsed, err := url.Parse(href)
+	if err != nil {
+		link.Class = InvalidLink
+		return link
 	}
 
-	return !found
+	return linkFromURL(parsed)
+}
+
+func linkFromURL(parsed *url.URL) Link {
+	link := Link{Href: parsed.String(), URL: parsed}
+	link.Title = parsed.Query().Get("title")
+	link.Namespace = TitleNamespace(link.Title)
+	return link
+}
+
+// Key identifying the link, its url or the raw href when invalid.
+func (l Link) String() string {
+	if l.URL != nil {
+		return l.URL.String()
+	}
+
+	return l.Href
+}
+
+type linkJSON struct {
+	URL       string `json:"url"`
+	Title     string `json:"title,omitempty"`
+	Namespace string `json:"namespace,omitempty"`
+	Depth     int    `json:"depth"`
+	Class     string `json:"class"`
 }
 
-func (ls *LinkSet) Contains(link Link) bool {
-	ls.RLock()
-	defer ls.RUnlock()
-	return ls.Set[link]
+func (l Link) MarshalJSON() ([]byte, error) {
+	return json.Marshal(linkJSON{
+		URL:       l.String(),
+		Title:     l.Title,
+		Namespace: l.Namespace,
+		Depth:     l.Depth,
+		Class:     l.Class.String(),
+	})
 }
 
+func (l *Link) UnmarshalJSON(data []byte) error {
+	var raw linkJSON
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	*l = NewLink(raw.URL)
+	l.Depth = raw.Depth
+	for class, name := range linkClassNames {
+		if name == raw.Class {
+			l.Class = LinkClass(class)
+		}
+	}
+
+	return nil
+}
+
+// Namespace prefix of a MediaWiki title, empty for the Main namespace.
+func TitleNamespace(title string) string {
+	split := strings.Index(title, ":")
+	if split < 0 {
+		return ""
+	}
+
+	prefix := strings.Replace(title[:split], " ", "_", -1)
+	for _, namespace := range Namespaces {
+		if strings.EqualFold(prefix, namespace) {
+			return namespace
+		}
+	}
+
+	return ""
+}
+
+// Unique set of url links, keyed by Link.String().
+type LinkSet = Set[string, Link]
+
 func NewLinkSet() LinkSet {
-	return LinkSet{Set: make(map[Link]bool, 1)}
+	return NewSet(Link.String)
+}
+
+// Metadata collected for a link during a crawl.
+//
+//  1. Link: The link itself.
+//  2. Status: HTTP status code of the last fetch, 0 if never fetched.
+//  3. Parsed: The page body was parsed, so LinkCount is known.
+//  4. LinkCount: Number of links extracted from the page.
+//  5. InternalLinks and ExternalLinks: Web links of the page to the wiki
+//     and to other hosts.
+//  6. Size: Bytes of the page HTML (wikitext for dumps).
+//  7. Referrers: Pages linking to the link.
+//  8. Archive: Nearest archived snapshot of a broken external link.
+//  9. RedirectTo: Final url when the link redirects.
+//  10. Citations: Citation templates citing the link.
+//  11. Headers: Captured response headers.
+//  12. Findings: Security audit findings of the page.
+//  13. MixedContent: Plain http resources embedded by an https page.
+//  14. FreshUntil: End of the freshness of the page per its Cache-Control
+//     or Expires header, zero when it must be fetched again.
+//  15. ContentHash and ContentSize: Hash and characters of the article
+//     text, compared between runs.
+//  16. BrokenSince: Start of the first stored run the link was found
+//     broken in, while it stayed broken.
+//  17. ErrorBody: Start of the body of a broken response, its key headers
+//     are kept in Headers.
+//  18. RedirectHops: Redirects followed to reach RedirectTo, more than one
+//     for double redirects.
+type PageInfo struct {
+	Link          Link              `json:"link"`
+	Status        int               `json:"status,omitempty"`
+	Parsed        bool              `json:"parsed,omitempty"`
+	LinkCount     int               `json:"linkCount,omitempty"`
+	InternalLinks int               `json:"internalLinks,omitempty"`
+	ExternalLinks int               `json:"externalLinks,omitempty"`
+	Size          int               `json:"size,omitempty"`
+	Referrers     []string          `json:"referrers,omitempty"`
+	Archive       string            `json:"archive,omitempty"`
+	RedirectTo    string            `json:"redirectTo,omitempty"`
+	Citations     []Citation        `json:"citations,omitempty"`
+	Headers       map[string]string `json:"headers,omitempty"`
+	Findings      []Finding         `json:"findings,omitempty"`
+	MixedContent  []string          `json:"mixedContent,omitempty"`
+	FreshUntil    time.Time         `json:"freshUntil,omitempty"`
+	ContentHash   string            `json:"contentHash,omitempty"`
+	ContentSize   int               `json:"contentSize,omitempty"`
+	BrokenSince   time.Time         `json:"brokenSince,omitempty"`
+	ErrorBody     string            `json:"errorBody,omitempty"`
+	RedirectHops  int               `json:"redirectHops,omitempty"`
+}
+
+// Adds a referring page unless already known.
+func (pi *PageInfo) AddReferrer(referrer string) {
+	if !contains(pi.Referrers, referrer) {
+		pi.Referrers = append(pi.Referrers, referrer)
+	}
+}
+
+// Adds a citation of the link unless already known.
+func (pi *PageInfo) AddCitation(citation Citation) {
+	for _, known := range pi.Citations {
+		if known == citation {
+			return
+		}
+	}
+	pi.Citations = append(pi.Citations, citation)
+}
+
+func (pi *PageInfo) captureHeader(name, value string) {
+	if pi.Headers == nil {
+		pi.Headers = make(map[string]string)
+	}
+	pi.Headers[name] = value
+}
+
+// Combines metadata recorded for the same link by different crawls.
+func (pi PageInfo) merge(other PageInfo) PageInfo {
+	if other.Status != 0 {
+		pi.Status = other.Status
+	}
+	if other.Parsed {
+		pi.Parsed = true
+		pi.LinkCount = other.LinkCount
+		pi.InternalLinks = other.InternalLinks
+		pi.ExternalLinks = other.ExternalLinks
+		pi.Size = other.Size
+	}
+	for _, referrer := range other.Referrers {
+		pi.AddReferrer(referrer)
+	}
+	if len(other.Archive) > 0 {
+		pi.Archive = other.Archive
+	}
+	if len(other.RedirectTo) > 0 {
+		pi.RedirectTo = other.RedirectTo
+	}
+	for _, citation := range other.Citations {
+		pi.AddCitation(citation)
+	}
+	if len(other.Findings) > 0 {
+		pi.Findings = other.Findings
+	}
+	if len(other.MixedContent) > 0 {
+		pi.MixedContent = other.MixedContent
+	}
+	for name, value := range other.Headers {
+		pi.captureHeader(name, value)
+	}
+	if !other.FreshUntil.IsZero() {
+		pi.FreshUntil = other.FreshUntil
+	}
+	if len(other.ContentHash) > 0 {
+		pi.ContentHash = other.ContentHash
+	}
+	if other.ContentSize > 0 {
+		pi.ContentSize = other.ContentSize
+	}
+	if !other.BrokenSince.IsZero() {
+		pi.BrokenSince = other.BrokenSince
+	}
+	if len(other.ErrorBody) > 0 {
+		pi.ErrorBody = other.ErrorBody
+	}
+	if other.RedirectHops > 0 {
+		pi.RedirectHops = other.RedirectHops
+	}
+
+	return pi
+}
+
+// Per link metadata, keyed by Link.String().
+type LinkMap = Set[string, PageInfo]
+
+func NewLinkMap() LinkMap {
+	return NewSet(func(info PageInfo) string {
+		return info.Link.String()
+	})
 }
@@ -1,18 +1,79 @@
 package wikicrawl
 
 import (
+	"encoding/json"
 	"testing"
 )
 
+func TestNewLink(t *testing.T) {
+	t.Run("Parse link metadata", func(t *testing.T) {
+		t.Run("Wiki page title and namespace", func(t *testing.T) {
+			t.Parallel()
+			link := NewLink("http://testing.com/index.php?title=Help:Contents")
+
+			if link.Title != "Help:Contents" {
+				t.Errorf("Title mismatch, got: %s, want: %s.", link.Title, "Help:Contents")
+			}
+
+			if link.Namespace != "Help" {
+				t.Errorf("Namespace mismatch, got: %s, want: %s.", link.Namespace, "Help")
+			}
+		})
+
+		t.Run("Main namespace", func(t *testing.T) {
+			t.Parallel()
+			link := NewLink("http://testing.com/index.php?title=Install:_step_one")
+
+			if link.Namespace != "" {
+				t.Errorf("Namespace mismatch, got: %s, want empty namespace.", link.Namespace)
+			}
+		})
+
+		t.Run("Invalid href", func(t *testing.T) {
+			t.Parallel()
+			link := NewLink("http://[::1")
+
+			if link.Class != InvalidLink || link.URL != nil {
+				t.Errorf("Class mismatch, got: %s, want: %s.", link.Class, InvalidLink)
+			}
+
+			if link.String() != "http://[::1" {
+				t.Errorf("Key mismatch, got: %s, want: %s.", link.String(), "http://[::1")
+			}
+		})
+
+		t.Run("JSON round trip", func(t *testing.T) {
+			t.Parallel()
+			link := NewLink("http://testing.com/index.php?title=Talk:Main")
+			link.Depth = 2
+			link.Class = InternalLink
+
+			data, err := json.Marshal(link)
+			if err != nil {
+				t.Fatalf("Marshal failed: %s.", err)
+			}
+
+			var found Link
+			if err := json.Unmarshal(data, &found); err != nil {
+				t.Fatalf("Unmarshal failed: %s.", err)
+			}
+
+			if found.String() != link.String() || found.Depth != 2 || found.Class != InternalLink || found.Namespace != "Talk" {
+				t.Errorf("Link mismatch, got: %+v, want: %+v.", found, link)
+			}
+		})
+	})
+}
+
 func TestLinkSet(t *testing.T) {
 	t.Run("Simple LinkSet data structure", func(t *testing.T) {
 		t.Run("Enforces uniqueness", func(t *testing.T) {
 			t.Parallel()
 
 			found := NewLinkSet()
-			found.Add("1")
+			found.Add(NewLink("1"))
 
-			if found.Add("1") {
+			if found.Add(NewLink("1")) {
 				t.Errorf("LinkSet Add should report false for duplicates.")
 			}
 
@@ -22,3 +83,28 @@ func TestLinkSet(t *testing.T) {
 		})
 	})
 }
+
+func TestPageInfo(t *testing.T) {
+	t.Run("Per link metadata", func(t *testing.T) {
+		t.Run("Referrers are unique", func(t *testing.T) {
+			t.Parallel()
+			var info PageInfo
+			info.AddReferrer("a")
+			info.AddReferrer("a")
+
+			if len(info.Referrers) != 1 {
+				t.Errorf("Referrers mismatch, got: %v, want: %v.", info.Referrers, []string{"a"})
+			}
+		})
+
+		t.Run("Merge keeps known values", func(t *testing.T) {
+			t.Parallel()
+			info := PageInfo{Status: 200, Parsed: true, LinkCount: 3, Referrers: []string{"a"}}
+			merged := info.merge(PageInfo{Referrers: []string{"b"}})
+
+			if merged.Status != 200 || merged.LinkCount != 3 || len(merged.Referrers) != 2 {
+				t.Errorf("Merged info mismatch, got: %+v.", merged)
+			}
+		})
+	})
+}
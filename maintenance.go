@@ -0,0 +1,266 @@
+package wikicrawl
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Error of a request redirected back to an url it already visited.
+type RedirectCycleError struct {
+	Chain []string
+}
+
+func (e *RedirectCycleError) Error() string {
+	return "redirect cycle: " + strings.Join(e.Chain, " -> ")
+}
+
+// Redirect policy of the crawler client, failing as soon as a redirect
+// loops instead of after ten redirects like the default policy.
+func checkRedirect(req *http.Request, via []*http.Request) error {
+	var chain []string
+	for _, previous := range via {
+		chain = append(chain, previous.URL.String())
+	}
+
+	for _, visited := range chain {
+		if visited == req.URL.String() {
+			return &RedirectCycleError{Chain: append(chain, visited)}
+		}
+	}
+
+	if len(via) >= 10 {
+		return errors.New("stopped after 10 redirects")
+	}
+
+	return nil
+}
+
+// Records every hop of a redirect chain so cycles show up in the result.
+func (c *Crawler) recordRedirects(result *CrawlResult, chain []string) {
+	for i := 0; i+1 < len(chain); i++ {
+		target := chain[i+1]
+		result.record(c.classify(NewLink(chain[i])), func(info *PageInfo) {
+			info.RedirectTo = target
+		})
+	}
+}
+
+// Maintenance issues of the wiki found in a crawl result.
+//
+//  1. SelfLinks: Links of a page leading back to it, directly or through a
+//     redirect, keyed by page.
+//  2. RedirectCycles: Redirects looping back (A -> B -> A), each listing the
+//     pages of the loop from its smallest url.
+type Maintenance struct {
+	SelfLinks      map[string][]string `json:"selfLinks"`
+	RedirectCycles [][]string          `json:"redirectCycles"`
+}
+
+// Finds self-links and redirect cycles in the result.
+func (cr *CrawlResult) Maintenance() Maintenance {
+	maintenance := Maintenance{SelfLinks: make(map[string][]string)}
+
+	redirects := make(map[string]string)
+	for _, info := range cr.Pages.Values() {
+		if len(info.RedirectTo) > 0 {
+			redirects[info.Link.String()] = info.RedirectTo
+		}
+	}
+
+	for _, info := range cr.Pages.Values() {
+		link := info.Link.String()
+		target := link
+		if redirect, found := redirects[link]; found {
+			target = redirect
+		}
+
+		for _, referrer := range info.Referrers {
+			if referrer == target {
+				maintenance.SelfLinks[referrer] = append(maintenance.SelfLinks[referrer], link)
+			}
+		}
+	}
+
+	seen := make(map[string]bool)
+	for _, start := range cr.Pages.Keys() {
+		if cycle := redirectCycle(redirects, start); len(cycle) > 0 && !seen[cycle[0]] {
+			seen[cycle[0]] = true
+			maintenance.RedirectCycles = append(maintenance.RedirectCycles, cycle)
+		}
+	}
+
+	return maintenance
+}
+
+// Maintenance reports of MediaWiki (special pages) cross-checked with the
+// crawl: links to missing pages, redirects to missing pages and redirects
+// to redirects.
+var WikiReports = []string{"Wantedpages", "BrokenRedirects", "DoubleRedirects"}
+
+// Page flagged by a maintenance report of the wiki, by the crawl or both.
+//
+//  1. Report: Name of the report, e.g. Wantedpages.
+//  2. Title: Normalized title of the page.
+//  3. Wiki: Listed by the report of the wiki.
+//  4. Crawl: Found by the crawl. Pages only one of them flags are
+//     discrepancies: pages out of reach of the crawl, stale (cached)
+//     reports or links the wiki does not track, e.g. hardcoded urls.
+type ReportEntry struct {
+	Report string `json:"report"`
+	Title  string `json:"title"`
+	Wiki   bool   `json:"wiki"`
+	Crawl  bool   `json:"crawl"`
+}
+
+// Reports if only one of the wiki and the crawl flags the page.
+func (re ReportEntry) Discrepancy() bool {
+	return re.Wiki != re.Crawl
+}
+
+// Titles listed by a maintenance report of the wiki (Special:WantedPages
+// is Wantedpages), following API continuation.
+func (c *Crawler) WikiReport(name string) ([]string, error) {
+	var titles []string
+	query := url.Values{
+		"action":        {"query"},
+		"list":          {"querypage"},
+		"qppage":        {name},
+		"qplimit":       {"max"},
+		"format":        {"json"},
+		"formatversion": {"2"},
+	}
+	for {
+		var report struct {
+			Query struct {
+				QueryPage struct {
+					Results []struct {
+						Title string `json:"title"`
+					} `json:"results"`
+				} `json:"querypage"`
+			} `json:"query"`
+			Continue struct {
+				QPOffset int `json:"qpoffset"`
+			} `json:"continue"`
+		}
+		if err := c.apiGet(query, &report); err != nil {
+			return titles, err
+		}
+
+		for _, result := range report.Query.QueryPage.Results {
+			titles = append(titles, result.Title)
+		}
+
+		if report.Continue.QPOffset == 0 {
+			return titles, nil
+		}
+		query.Set("qpoffset", strconv.Itoa(report.Continue.QPOffset))
+	}
+}
+
+// Cross-checks the maintenance reports of the wiki with the findings of
+// the crawl, returning every flagged page sorted by report and title.
+// Reports failing to load are returned as error after checking the others.
+func (c *Crawler) CrossCheck(result *CrawlResult) ([]ReportEntry, error) {
+	crawled := result.reportTitles()
+
+	var entries []ReportEntry
+	var failed []string
+	for _, report := range WikiReports {
+		titles, err := c.WikiReport(report)
+		if err != nil {
+			failed = append(failed, report+": "+err.Error())
+			continue
+		}
+
+		flagged := make(map[string]*ReportEntry)
+		for _, title := range titles {
+			title = NormalizeTitle(title)
+			flagged[title] = &ReportEntry{Report: report, Title: title, Wiki: true}
+		}
+		for title := range crawled[report] {
+			if entry, found := flagged[title]; found {
+				entry.Crawl = true
+			} else {
+				flagged[title] = &ReportEntry{Report: report, Title: title, Crawl: true}
+			}
+		}
+
+		start := len(entries)
+		for _, entry := range flagged {
+			entries = append(entries, *entry)
+		}
+		sort.Slice(entries[start:], func(i, j int) bool {
+			return entries[start+i].Title < entries[start+j].Title
+		})
+	}
+
+	if len(failed) > 0 {
+		return entries, errors.New("loading wiki reports failed: " + strings.Join(failed, "; "))
+	}
+
+	return entries, nil
+}
+
+// Titles of the pages the crawl flags for each maintenance report: missing
+// internal pages, redirects to missing pages and redirects followed by
+// another redirect. Only links with a title are compared.
+func (cr *CrawlResult) reportTitles() map[string]map[string]bool {
+	titles := make(map[string]map[string]bool)
+	for _, report := range WikiReports {
+		titles[report] = make(map[string]bool)
+	}
+	add := func(report string, link Link) {
+		if title := NormalizeTitle(link.Title); len(title) > 0 {
+			titles[report][title] = true
+		}
+	}
+
+	for _, link := range cr.Broken.Values() {
+		info, _ := cr.Pages.Get(link.String())
+		if link.Class == ExternalLink || info.Status != http.StatusNotFound && info.Status != http.StatusGone {
+			continue
+		}
+		if len(info.RedirectTo) == 0 {
+			add("Wantedpages", link)
+			continue
+		}
+		add("Wantedpages", NewLink(info.RedirectTo))
+		add("BrokenRedirects", link)
+	}
+
+	for _, info := range cr.Pages.Values() {
+		if info.RedirectHops > 1 {
+			add("DoubleRedirects", info.Link)
+		}
+	}
+
+	return titles
+}
+
+// Loop reached by following redirects from start, rotated to begin with
+// its smallest url, nil when the redirects end.
+func redirectCycle(redirects map[string]string, start string) []string {
+	position := make(map[string]int)
+	var path []string
+	for current := start; len(current) > 0; current = redirects[current] {
+		if index, found := position[current]; found {
+			cycle := path[index:]
+			smallest := 0
+			for i, link := range cycle {
+				if link < cycle[smallest] {
+					smallest = i
+				}
+			}
+			return append(append([]string(nil), cycle[smallest:]...), cycle[:smallest]...)
+		}
+
+		position[current] = len(path)
+		path = append(path, current)
+	}
+
+	return nil
+}
@@ -0,0 +1,130 @@
+package wikicrawl
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMaintenance(t *testing.T) {
+	t.Run("Self-links and redirect cycles of a crawl", func(t *testing.T) {
+		t.Parallel()
+		server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			switch req.URL.Path {
+			case "/Main":
+				fmt.Fprint(rw, `<html><body>
+					<a href="/Main">Self</a>
+					<a href="/Alias">Alias</a>
+					<a href="/Loop">Loop</a>
+				</body></html>`)
+			case "/Alias":
+				http.Redirect(rw, req, "/Main", http.StatusFound)
+			case "/Loop":
+				http.Redirect(rw, req, "/Back", http.StatusFound)
+			case "/Back":
+				http.Redirect(rw, req, "/Loop", http.StatusFound)
+			}
+		}))
+		defer server.Close()
+
+		result := NewCrawler(server.URL, WithDeterministic()).Crawl(server.URL + "/Main")
+		found := result.Maintenance()
+		expected := Maintenance{
+			SelfLinks: map[string][]string{
+				server.URL + "/Main": {server.URL + "/Alias", server.URL + "/Main"},
+			},
+			RedirectCycles: [][]string{{server.URL + "/Back", server.URL + "/Loop"}},
+		}
+		if !reflect.DeepEqual(found, expected) {
+			t.Errorf("Maintenance mismatch, got: %v, want: %v.", found, expected)
+		}
+
+		if !result.Broken.Contains(server.URL + "/Loop") {
+			t.Errorf("Redirect cycle should be broken, got: %v.", result.Broken.Keys())
+		}
+	})
+
+	t.Run("Redirect cycles of a dump", func(t *testing.T) {
+		t.Parallel()
+		dump := `<mediawiki>
+			<page><title>A</title><ns>0</ns><redirect title="B" /><revision><text>#REDIRECT [[B]]</text></revision></page>
+			<page><title>B</title><ns>0</ns><redirect title="A" /><revision><text>#REDIRECT [[A]]</text></revision></page>
+		</mediawiki>`
+		c := NewCrawler("http://wiki.test/index.php")
+		result, err := c.CrawlDump(strings.NewReader(dump))
+		if err != nil {
+			t.Fatalf("Reading dump failed: %s.", err)
+		}
+
+		expected := [][]string{{c.TitleURL("A").String(), c.TitleURL("B").String()}}
+		if found := result.Maintenance().RedirectCycles; !reflect.DeepEqual(found, expected) {
+			t.Errorf("Redirect cycles mismatch, got: %v, want: %v.", found, expected)
+		}
+	})
+}
+
+func TestCrossCheck(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		query := req.URL.Query()
+		switch {
+		case req.URL.Path == "/api.php" && query.Get("qppage") == "Wantedpages" && query.Get("qpoffset") == "":
+			fmt.Fprint(rw, `{"continue":{"qpoffset":2},"query":{"querypage":{"results":[{"title":"Missing"},{"title":"Gone"}]}}}`)
+		case req.URL.Path == "/api.php" && query.Get("qppage") == "Wantedpages":
+			fmt.Fprint(rw, `{"query":{"querypage":{"results":[{"title":"Unlinked"}]}}}`)
+		case req.URL.Path == "/api.php" && query.Get("qppage") == "BrokenRedirects":
+			fmt.Fprint(rw, `{"query":{"querypage":{"results":[{"title":"Old"}]}}}`)
+		case req.URL.Path == "/api.php":
+			fmt.Fprint(rw, `{"query":{"querypage":{"results":[]}}}`)
+		case query.Get("title") == "Main":
+			fmt.Fprint(rw, `<a href="/index.php?title=Missing">Missing</a>
+				<a href="/index.php?title=Old">Old</a>
+				<a href="/index.php?title=Double">Double</a>`)
+		case query.Get("title") == "Old":
+			http.Redirect(rw, req, "/index.php?title=Gone", http.StatusMovedPermanently)
+		case query.Get("title") == "Double":
+			http.Redirect(rw, req, "/index.php?title=Alias", http.StatusMovedPermanently)
+		case query.Get("title") == "Alias":
+			http.Redirect(rw, req, "/index.php?title=Target", http.StatusMovedPermanently)
+		case query.Get("title") == "Target":
+			fmt.Fprint(rw, `<html><body></body></html>`)
+		default:
+			http.NotFound(rw, req)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	t.Run("Reports of the wiki and findings of the crawl", func(t *testing.T) {
+		t.Parallel()
+		c := NewCrawler(server.URL + "/index.php")
+		result := c.Crawl(server.URL + "/index.php?title=Main")
+		entries, err := c.CrossCheck(result)
+		if err != nil {
+			t.Fatalf("Cross-check failed: %s.", err)
+		}
+
+		want := []ReportEntry{
+			{Report: "Wantedpages", Title: "Gone", Wiki: true, Crawl: true},
+			{Report: "Wantedpages", Title: "Missing", Wiki: true, Crawl: true},
+			{Report: "Wantedpages", Title: "Unlinked", Wiki: true},
+			{Report: "BrokenRedirects", Title: "Old", Wiki: true, Crawl: true},
+			{Report: "DoubleRedirects", Title: "Double", Crawl: true},
+		}
+		if !reflect.DeepEqual(entries, want) {
+			t.Errorf("Entries mismatch, got: %v, want: %v.", entries, want)
+		}
+		if entries[2].Discrepancy() != true || entries[0].Discrepancy() != false {
+			t.Errorf("Discrepancy mismatch, got: %t and %t, want: true and false.", entries[2].Discrepancy(), entries[0].Discrepancy())
+		}
+	})
+
+	t.Run("Wiki without reports", func(t *testing.T) {
+		t.Parallel()
+		c := NewCrawler(server.URL + "/missing/index.php")
+		if _, err := c.CrossCheck(NewCrawlResult()); err == nil {
+			t.Errorf("Error mismatch, got: nil, want: loading wiki reports failed.")
+		}
+	})
+}
@@ -0,0 +1,164 @@
+package wikicrawl
+
+import (
+	"bytes"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+
+	log "github.com/Sirupsen/logrus"
+	"golang.org/x/net/html"
+)
+
+// Well known MediaWiki error pages served with a 200 status.
+var mediaWikiErrors = []*regexp.Regexp{
+	regexp.MustCompile(`There is currently no text in this page`),
+	regexp.MustCompile(`A database (query )?error has occurred`),
+	regexp.MustCompile(`Cannot access the database`),
+	regexp.MustCompile(`Fatal exception of type`),
+	regexp.MustCompile(`MediaWiki internal error`),
+}
+
+// Reports if a page body is one of the well known MediaWiki error pages.
+func IsMediaWikiError(body []byte) bool {
+	text := VisibleText(bytes.NewReader(body))
+	for _, pattern := range mediaWikiErrors {
+		if pattern.MatchString(text) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Markers of disambiguation pages: the box of the usual templates and the
+// category they add.
+var disambiguationMarkers = [][]byte{
+	[]byte(`id="disambigbox"`),
+	[]byte(`class="dmbox`),
+	[]byte(`Category:Disambiguation_pages`),
+}
+
+// Reports if a page body is a disambiguation page, per the usual templates
+// and category.
+func IsDisambiguationPage(body []byte) bool {
+	for _, marker := range disambiguationMarkers {
+		if bytes.Contains(body, marker) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Reports if a page body is a redirect page shown without following it,
+// e.g. with redirect=no.
+func IsRedirectPage(body []byte) bool {
+	return bytes.Contains(body, []byte(`class="redirectMsg"`)) || bytes.Contains(body, []byte(`class="redirectText"`))
+}
+
+// Reports if a fetched page is the wiki login form instead of content,
+// either after a redirect to Special:UserLogin or by its password field.
+func IsLoginPage(page *Page) bool {
+	if strings.HasPrefix(WikiPageTitle(page.URL), "Special:UserLogin") {
+		return true
+	}
+
+	if path, err := url.PathUnescape(page.URL.Path); err == nil && strings.Contains(path, "Special:UserLogin") {
+		return true
+	}
+
+	z := html.NewTokenizer(bytes.NewReader(page.Body))
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			return false
+		case html.StartTagToken, html.SelfClosingTagToken:
+			token := z.Token()
+			if token.Data != "input" {
+				continue
+			}
+
+			for _, attr := range token.Attr {
+				if attr.Key == "name" && attr.Val == "wpPassword" {
+					return true
+				}
+			}
+		}
+	}
+}
+
+// Titles of page ids resolved through the API, shared by crawler copies.
+type pageIDCache struct {
+	sync.Mutex
+	titles map[string]string
+}
+
+// Rewrites ?curid= links of the wiki to the title url of the page, so
+// pages reached by id and by title are crawled once. Ids that cannot be
+// resolved are kept.
+func (c *Crawler) resolvePageID(link *url.URL) *url.URL {
+	id := link.Query().Get("curid")
+	if len(id) == 0 || len(WikiPageTitle(link)) > 0 || !strings.EqualFold(link.Host, c.base.Host) {
+		return link
+	}
+
+	c.pageIDs.Lock()
+	title, found := c.pageIDs.titles[id]
+	c.pageIDs.Unlock()
+
+	if !found {
+		var err error
+		if title, err = c.lookupPageID(id); err != nil {
+			c.logger().WithFields(log.Fields{
+				"curid": id,
+				"err":   err,
+			}).Warn("Resolving page id failed")
+			return link
+		}
+
+		c.pageIDs.Lock()
+		c.pageIDs.titles[id] = title
+		c.pageIDs.Unlock()
+	}
+
+	if len(title) == 0 {
+		return link
+	}
+
+	return c.TitleURL(title)
+}
+
+// Decodes the JSON response of an api.php request relative to the wiki.
+func (c *Crawler) apiGet(query url.Values, target interface{}) error {
+	api := c.base.ResolveReference(&url.URL{Path: "api.php", RawQuery: query.Encode()})
+	return getJSON(c.Client, api.String(), target)
+}
+
+// Title of a page id, empty for unknown ids.
+func (c *Crawler) lookupPageID(id string) (string, error) {
+	var pages struct {
+		Query struct {
+			Pages []struct {
+				Title   string `json:"title"`
+				Missing bool   `json:"missing"`
+			} `json:"pages"`
+		} `json:"query"`
+	}
+	query := url.Values{
+		"action":        {"query"},
+		"pageids":       {id},
+		"format":        {"json"},
+		"formatversion": {"2"},
+	}
+	if err := c.apiGet(query, &pages); err != nil {
+		return "", err
+	}
+
+	if len(pages.Query.Pages) == 0 || pages.Query.Pages[0].Missing {
+		return "", nil
+	}
+
+	return pages.Query.Pages[0].Title, nil
+}
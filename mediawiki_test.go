@@ -0,0 +1,161 @@
+package wikicrawl
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func TestIsMediaWikiError(t *testing.T) {
+	t.Run("Detect MediaWiki error pages", func(t *testing.T) {
+		t.Run("Missing page", func(t *testing.T) {
+			t.Parallel()
+			body := `<html><body><p>There is currently no text in this page.</p></body></html>`
+			if !IsMediaWikiError([]byte(body)) {
+				t.Errorf("Missing page text should be detected.")
+			}
+		})
+
+		t.Run("Database error", func(t *testing.T) {
+			t.Parallel()
+			body := `<html><body><h1>Database error</h1><p>A database query error has occurred.</p></body></html>`
+			if !IsMediaWikiError([]byte(body)) {
+				t.Errorf("Database error should be detected.")
+			}
+		})
+
+		t.Run("Healthy page", func(t *testing.T) {
+			t.Parallel()
+			body := `<html><body><p>Installing the server.</p></body></html>`
+			if IsMediaWikiError([]byte(body)) {
+				t.Errorf("Healthy page incorrectly detected as error.")
+			}
+		})
+
+		t.Run("Reported during crawl", func(t *testing.T) {
+			t.Parallel()
+			server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				if req.URL.Path == "/missing" {
+					fmt.Fprintf(rw, `<html><body>There is currently no text in this page.</body></html>`)
+					return
+				}
+				fmt.Fprintf(rw, `<html><body><a href="/missing" /></body></html>`)
+			}))
+			defer server.Close()
+
+			result := NewCrawler(server.URL).Crawl(server.URL)
+			if len(result.ErrorPages.Set) != 1 || !result.ErrorPages.Contains(server.URL+"/missing") {
+				t.Errorf("Error pages mismatch, got: %v.", result.ErrorPages.Set)
+			}
+		})
+	})
+}
+
+func TestIsLoginPage(t *testing.T) {
+	t.Run("Detect login wall", func(t *testing.T) {
+		t.Run("Redirect to login page", func(t *testing.T) {
+			t.Parallel()
+			location, _ := url.Parse("http://testing.com/index.php?title=Special:UserLogin&returnto=Main")
+			if !IsLoginPage(&Page{URL: location}) {
+				t.Errorf("Login redirect should be detected: %s.", location)
+			}
+		})
+
+		t.Run("Login form in page", func(t *testing.T) {
+			t.Parallel()
+			location, _ := url.Parse("http://testing.com/index.php?title=Private")
+			body := `<form name="userlogin"><input name="wpName"><input type="password" name="wpPassword"></form>`
+			if !IsLoginPage(&Page{URL: location, Body: []byte(body)}) {
+				t.Errorf("Login form should be detected.")
+			}
+		})
+
+		t.Run("Login link on content page", func(t *testing.T) {
+			t.Parallel()
+			location, _ := url.Parse("http://testing.com/index.php?title=Main")
+			body := `<a href="/index.php?title=Special:UserLogin">Log in</a>`
+			if IsLoginPage(&Page{URL: location, Body: []byte(body)}) {
+				t.Errorf("Content page incorrectly detected as login form.")
+			}
+		})
+	})
+}
+
+func TestCrawlLoginWall(t *testing.T) {
+	handler := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/private" {
+			http.Redirect(rw, req, "/index.php?title=Special:UserLogin", http.StatusFound)
+			return
+		}
+		if req.URL.Path == "/index.php" {
+			fmt.Fprintf(rw, `<html><body><form><input name="wpPassword"></form></body></html>`)
+			return
+		}
+		fmt.Fprintf(rw, `<html><body><a href="/private" /><a href="/public" /></body></html>`)
+	})
+
+	t.Run("Report login wall as access denied", func(t *testing.T) {
+		t.Parallel()
+		server := httptest.NewServer(handler)
+		defer server.Close()
+
+		result := NewCrawler(server.URL).Crawl(server.URL)
+		if !result.AccessDenied.Contains(server.URL+"/private") || result.Visited.Contains(server.URL+"/private") {
+			t.Errorf("Login wall not reported as access denied, got: %v.", result.AccessDenied.Set)
+		}
+
+		if result.Aborted || !result.Visited.Contains(server.URL+"/public") {
+			t.Errorf("Crawl should continue past login wall, got: %v.", result.Visited.Set)
+		}
+	})
+
+	t.Run("Abort crawl on login wall", func(t *testing.T) {
+		t.Parallel()
+		server := httptest.NewServer(handler)
+		defer server.Close()
+
+		c := NewCrawler(server.URL)
+		c.AbortOnLogin = true
+		result := c.Crawl(server.URL + "/private")
+		if !result.Aborted {
+			t.Errorf("Crawl should abort on login wall.")
+		}
+	})
+}
+
+func TestResolvePageID(t *testing.T) {
+	t.Run("Crawl curid links once by title", func(t *testing.T) {
+		t.Parallel()
+		server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			switch {
+			case req.URL.Path == "/api.php" && req.URL.Query().Get("pageids") == "7":
+				fmt.Fprint(rw, `{"query":{"pages":[{"pageid":7,"title":"Target page"}]}}`)
+			case req.URL.Path == "/api.php":
+				fmt.Fprint(rw, `{"query":{"pages":[{"pageid":8,"missing":true}]}}`)
+			case req.URL.Query().Get("title") == "Main":
+				fmt.Fprint(rw, `<html><body>
+					<a href="/index.php?curid=7">By id</a>
+					<a href="/index.php?title=Target_page">By title</a>
+					<a href="/index.php?curid=8">Deleted</a>
+				</body></html>`)
+			default:
+				fmt.Fprint(rw, `<html><body></body></html>`)
+			}
+		}))
+		defer server.Close()
+
+		c := NewCrawler(server.URL + "/index.php")
+		result := c.Crawl(server.URL + "/index.php?title=Main")
+		expected := []string{
+			server.URL + "/index.php?curid=8",
+			server.URL + "/index.php?title=Main",
+			server.URL + "/index.php?title=Target_page",
+		}
+		if found := result.Visited.Keys(); !reflect.DeepEqual(found, expected) {
+			t.Errorf("Visited mismatch, got: %v, want: %v.", found, expected)
+		}
+	})
+}
@@ -0,0 +1,55 @@
+package wikicrawl
+
+import (
+	"runtime"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// Interval between heap checks of LimitMemory.
+const memoryCheckInterval = time.Second
+
+// Watches the heap, once above limit bytes the queue stops accepting new
+// links so the crawl finishes the work at hand instead of being OOM-killed.
+// Dropped links are counted in the queue stats and the result is marked
+// truncated.
+func (wq *WorkQueue) LimitMemory(limit uint64) {
+	go func() {
+		ticker := time.NewTicker(memoryCheckInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if wq.checkMemory(limit) {
+					return
+				}
+			case <-wq.metrics.done:
+				return
+			}
+		}
+	}()
+}
+
+// Stops queueing new links when the heap is above limit after a garbage
+// collection, reporting if it did.
+func (wq *WorkQueue) checkMemory(limit uint64) bool {
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+	if stats.HeapAlloc <= limit {
+		return false
+	}
+
+	runtime.GC()
+	runtime.ReadMemStats(&stats)
+	if stats.HeapAlloc <= limit {
+		return false
+	}
+
+	wq.crawler.logger().WithFields(log.Fields{
+		"heap":  stats.HeapAlloc,
+		"limit": limit,
+	}).Warn("Memory limit reached, no longer queueing new links")
+	wq.capped.Store(true)
+	return true
+}
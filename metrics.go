@@ -0,0 +1,172 @@
+package wikicrawl
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Summary metrics of a run, keyed by metric name.
+func (cr *CrawlResult) Metrics() map[string]float64 {
+	metrics := map[string]float64{
+		"visited":            float64(cr.Visited.Len()),
+		"pages":              float64(cr.Pages.Len()),
+		"broken":             float64(cr.Broken.Len()),
+		"broken_rate":        cr.BrokenRate(),
+		"suspect_broken":     float64(cr.SuspectBroken.Len()),
+		"assertion_failures": float64(cr.AssertionFailures.Len()),
+		"parse_errors":       float64(cr.ParseErrors.Len()),
+		"error_pages":        float64(cr.ErrorPages.Len()),
+		"restricted":         float64(cr.Restricted.Len()),
+		"aborted":            0,
+		"truncated":          0,
+	}
+	if cr.Aborted {
+		metrics["aborted"] = 1
+	}
+	if cr.Truncated {
+		metrics["truncated"] = 1
+	}
+	if !cr.Metadata.Finished.IsZero() {
+		metrics["duration_seconds"] = cr.Metadata.Finished.Sub(cr.Metadata.Started).Seconds()
+	}
+
+	return metrics
+}
+
+// Endpoint the metrics of every run are pushed to, so crawl health can be
+// plotted without running the crawler as a service.
+//
+//  1. Format: "pushgateway" (Prometheus Pushgateway url), "influxdb" (full
+//     write url, e.g. http://host:8086/api/v2/write?org=o&bucket=b, token
+//     read from INFLUX_TOKEN) or "graphite" (plaintext protocol address,
+//     e.g. tcp://host:2003).
+//  2. URL: Address of the endpoint.
+type MetricsPush struct {
+	Format string
+	URL    string
+	Client *http.Client
+}
+
+// Parses an endpoint written as "<format>=<url>".
+func ParseMetricsPush(raw string) (MetricsPush, error) {
+	split := strings.Index(raw, "=")
+	if split < 0 {
+		return MetricsPush{}, fmt.Errorf("metrics push %q missing = separator", raw)
+	}
+
+	push := MetricsPush{Format: raw[:split], URL: raw[split+1:]}
+	switch push.Format {
+	case "pushgateway", "influxdb", "graphite":
+		return push, nil
+	default:
+		return MetricsPush{}, fmt.Errorf("unknown metrics format %q", push.Format)
+	}
+}
+
+// Pushes the metrics of the result, labelled with the host of the seed and
+// the labels of the run.
+func (mp MetricsPush) Push(result *CrawlResult) error {
+	metrics := result.Metrics()
+	names := make([]string, 0, len(metrics))
+	for name := range metrics {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	wiki := "unknown"
+	if seed, err := url.Parse(result.Metadata.Seed); err == nil && len(seed.Host) > 0 {
+		wiki = seed.Host
+	}
+	labels := result.Metadata.Labels
+	now := time.Now()
+	if !result.Metadata.Finished.IsZero() {
+		now = result.Metadata.Finished
+	}
+
+	switch mp.Format {
+	case "pushgateway":
+		var body bytes.Buffer
+		for _, name := range names {
+			fmt.Fprintf(&body, "# TYPE wikicrawl_%s gauge\nwikicrawl_%s %v\n", name, name, metrics[name])
+		}
+		link := strings.TrimSuffix(mp.URL, "/") + "/metrics/job/wikicrawl/wiki/" + url.PathEscape(wiki)
+		for _, key := range labelKeys(labels) {
+			link += "/" + url.PathEscape(key) + "/" + url.PathEscape(labels[key])
+		}
+		return mp.send("PUT", link, "text/plain; version=0.0.4", body.Bytes(), "")
+	case "influxdb":
+		var fields []string
+		for _, name := range names {
+			fields = append(fields, fmt.Sprintf("%s=%v", name, metrics[name]))
+		}
+		tags := "wiki=" + influxEscape(wiki)
+		for _, key := range labelKeys(labels) {
+			tags += "," + influxEscape(key) + "=" + influxEscape(labels[key])
+		}
+		line := fmt.Sprintf("wikicrawl,%s %s %d\n", tags, strings.Join(fields, ","), now.UnixNano())
+		var auth string
+		if token := os.Getenv("INFLUX_TOKEN"); len(token) > 0 {
+			auth = "Token " + token
+		}
+		return mp.send("POST", mp.URL, "text/plain; charset=utf-8", []byte(line), auth)
+	default:
+		var body bytes.Buffer
+		prefix := "wikicrawl." + strings.Replace(wiki, ".", "_", -1) + "."
+		// Labels become Graphite tags.
+		var tags string
+		for _, key := range labelKeys(labels) {
+			tags += ";" + key + "=" + labels[key]
+		}
+		for _, name := range names {
+			fmt.Fprintf(&body, "%s%s%s %v %d\n", prefix, name, tags, metrics[name], now.Unix())
+		}
+		return mp.sendGraphite(body.Bytes())
+	}
+}
+
+func (mp MetricsPush) send(method, link, contentType string, body []byte, auth string) error {
+	req, err := http.NewRequest(method, link, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	if len(auth) > 0 {
+		req.Header.Set("Authorization", auth)
+	}
+
+	client := mp.Client
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Second}
+	}
+
+	return upload(client, req)
+}
+
+// Writes plaintext protocol lines to a Graphite (carbon) listener.
+func (mp MetricsPush) sendGraphite(lines []byte) error {
+	address := mp.URL
+	if location, err := url.Parse(mp.URL); err == nil && len(location.Host) > 0 {
+		address = location.Host
+	}
+
+	conn, err := net.DialTimeout("tcp", address, 10*time.Second)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	_, err = conn.Write(lines)
+	return err
+}
+
+// Escapes commas, equal signs and spaces of InfluxDB tag values.
+func influxEscape(value string) string {
+	return strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `).Replace(value)
+}
@@ -0,0 +1,148 @@
+package wikicrawl
+
+import (
+	"bufio"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func metricsResult() *CrawlResult {
+	result := NewCrawlResult()
+	result.Metadata = Metadata{Seed: "http://testing.com/wiki", Started: time.Unix(100, 0), Finished: time.Unix(160, 0)}
+	result.Visited.Add(NewLink("http://testing.com/a"))
+	result.Visited.Add(NewLink("http://testing.com/gone"))
+	result.Broken.Add(NewLink("http://testing.com/gone"))
+	return result
+}
+
+func TestParseMetricsPush(t *testing.T) {
+	t.Run("Parse metrics endpoints", func(t *testing.T) {
+		t.Parallel()
+		push, err := ParseMetricsPush("influxdb=http://influx:8086/write?db=wiki")
+		if err != nil || push.Format != "influxdb" || push.URL != "http://influx:8086/write?db=wiki" {
+			t.Errorf("Endpoint mismatch, got: %+v, err: %v.", push, err)
+		}
+
+		for _, raw := range []string{"pushgateway", "statsd=udp://x:8125"} {
+			if _, err := ParseMetricsPush(raw); err == nil {
+				t.Errorf("Parsing %s should fail.", raw)
+			}
+		}
+	})
+}
+
+func TestMetricsPush(t *testing.T) {
+	t.Run("Push run metrics", func(t *testing.T) {
+		t.Run("Pushgateway", func(t *testing.T) {
+			t.Parallel()
+			var method, path, body string
+			server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				payload, _ := ioutil.ReadAll(req.Body)
+				method, path, body = req.Method, req.URL.Path, string(payload)
+			}))
+			defer server.Close()
+
+			if err := (MetricsPush{Format: "pushgateway", URL: server.URL}).Push(metricsResult()); err != nil {
+				t.Fatalf("Push failed: %s.", err)
+			}
+
+			if method != "PUT" || path != "/metrics/job/wikicrawl/wiki/testing.com" {
+				t.Errorf("Push request mismatch, got: %s %s.", method, path)
+			}
+			for _, expected := range []string{"# TYPE wikicrawl_broken gauge\nwikicrawl_broken 1\n", "wikicrawl_broken_rate 0.5\n", "wikicrawl_duration_seconds 60\n"} {
+				if !strings.Contains(body, expected) {
+					t.Errorf("Metrics missing %q, got: %s.", expected, body)
+				}
+			}
+		})
+
+		t.Run("InfluxDB", func(t *testing.T) {
+			t.Parallel()
+			var body string
+			server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				payload, _ := ioutil.ReadAll(req.Body)
+				body = string(payload)
+				rw.WriteHeader(http.StatusNoContent)
+			}))
+			defer server.Close()
+
+			if err := (MetricsPush{Format: "influxdb", URL: server.URL + "/write?db=wiki"}).Push(metricsResult()); err != nil {
+				t.Fatalf("Push failed: %s.", err)
+			}
+
+			if !strings.HasPrefix(body, "wikicrawl,wiki=testing.com aborted=0,") || !strings.Contains(body, ",broken=1,") || !strings.HasSuffix(body, " 160000000000\n") {
+				t.Errorf("Line protocol mismatch, got: %s.", body)
+			}
+		})
+
+		t.Run("Labels", func(t *testing.T) {
+			t.Parallel()
+			var paths, bodies []string
+			var lock sync.Mutex
+			server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				payload, _ := ioutil.ReadAll(req.Body)
+				lock.Lock()
+				defer lock.Unlock()
+				paths, bodies = append(paths, req.URL.Path), append(bodies, string(payload))
+			}))
+			defer server.Close()
+
+			result := metricsResult()
+			result.Metadata.Labels = map[string]string{"team": "docs", "env": "prod us"}
+			if err := (MetricsPush{Format: "pushgateway", URL: server.URL}).Push(result); err != nil {
+				t.Fatalf("Push failed: %s.", err)
+			}
+			if err := (MetricsPush{Format: "influxdb", URL: server.URL + "/write"}).Push(result); err != nil {
+				t.Fatalf("Push failed: %s.", err)
+			}
+
+			if paths[0] != "/metrics/job/wikicrawl/wiki/testing.com/env/prod us/team/docs" {
+				t.Errorf("Grouping key mismatch, got: %s, want: /metrics/job/wikicrawl/wiki/testing.com/env/prod us/team/docs.", paths[0])
+			}
+			if !strings.HasPrefix(bodies[1], `wikicrawl,wiki=testing.com,env=prod\ us,team=docs aborted=0,`) {
+				t.Errorf("Line protocol mismatch, got: %s.", bodies[1])
+			}
+		})
+
+		t.Run("Graphite", func(t *testing.T) {
+			t.Parallel()
+			listener, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatalf("Listen failed: %s.", err)
+			}
+			defer listener.Close()
+
+			lines := make(chan []string)
+			go func() {
+				conn, err := listener.Accept()
+				if err != nil {
+					close(lines)
+					return
+				}
+				defer conn.Close()
+
+				var received []string
+				scanner := bufio.NewScanner(conn)
+				for scanner.Scan() {
+					received = append(received, scanner.Text())
+				}
+				lines <- received
+			}()
+
+			if err := (MetricsPush{Format: "graphite", URL: "tcp://" + listener.Addr().String()}).Push(metricsResult()); err != nil {
+				t.Fatalf("Push failed: %s.", err)
+			}
+
+			received := <-lines
+			if !contains(received, "wikicrawl.testing_com.broken 1 160") {
+				t.Errorf("Graphite lines mismatch, got: %v.", received)
+			}
+		})
+	})
+}
@@ -0,0 +1,50 @@
+package wikicrawl
+
+import (
+	"net/http"
+)
+
+// Wraps the transport used for every request of the crawler, allowing
+// request signing, tracing headers, fault injection or recording without
+// replacing the client.
+type Middleware func(next http.RoundTripper) http.RoundTripper
+
+// Adapter allowing ordinary functions to be used as http.RoundTripper.
+type RoundTripperFunc func(req *http.Request) (*http.Response, error)
+
+func (f RoundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// Wraps the transport with every middleware, the first one outermost.
+func Chain(transport http.RoundTripper, middleware ...Middleware) http.RoundTripper {
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+
+	for i := len(middleware) - 1; i >= 0; i-- {
+		transport = middleware[i](transport)
+	}
+
+	return transport
+}
+
+// Adds middleware around the transport of the crawler client. The client is
+// copied first so clients shared with other code are left untouched.
+// Pages rendered by a browser Fetcher do not pass through the middleware.
+func (c *Crawler) Use(middleware ...Middleware) {
+	client := *c.Client
+	client.Transport = Chain(client.Transport, middleware...)
+	c.Client = &client
+}
+
+// Middleware setting a header on every request.
+func SetHeader(name, value string) Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			req = req.Clone(req.Context())
+			req.Header.Set(name, value)
+			return next.RoundTrip(req)
+		})
+	}
+}
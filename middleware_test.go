@@ -0,0 +1,61 @@
+package wikicrawl
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestMiddleware(t *testing.T) {
+	t.Run("Transport middleware chain", func(t *testing.T) {
+		t.Run("Applied in order", func(t *testing.T) {
+			t.Parallel()
+			var calls []string
+			record := func(name string) Middleware {
+				return func(next http.RoundTripper) http.RoundTripper {
+					return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+						calls = append(calls, name)
+						return next.RoundTrip(req)
+					})
+				}
+			}
+
+			server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {}))
+			defer server.Close()
+
+			client := &http.Client{Transport: Chain(nil, record("outer"), record("inner"))}
+			if _, err := client.Get(server.URL); err != nil {
+				t.Fatalf("Request failed: %s.", err)
+			}
+
+			expected := []string{"outer", "inner"}
+			if !reflect.DeepEqual(calls, expected) {
+				t.Errorf("Middleware order mismatch, got: %v, want: %v.", calls, expected)
+			}
+		})
+
+		t.Run("Headers added to crawl requests", func(t *testing.T) {
+			t.Parallel()
+			var mu sync.Mutex
+			var traces []string
+			server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				mu.Lock()
+				defer mu.Unlock()
+				traces = append(traces, req.Header.Get("X-Trace"))
+			}))
+			defer server.Close()
+
+			shared := &http.Client{}
+			NewCrawler(server.URL, WithMiddleware(SetHeader("X-Trace", "abc")), WithClient(shared)).Crawl(server.URL)
+			if len(traces) != 1 || traces[0] != "abc" {
+				t.Errorf("Trace header mismatch, got: %v, want: %v.", traces, []string{"abc"})
+			}
+
+			if shared.Transport != nil {
+				t.Errorf("Shared client should not be modified.")
+			}
+		})
+	})
+}
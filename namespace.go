@@ -0,0 +1,107 @@
+package wikicrawl
+
+import (
+	"encoding/json"
+	"io"
+	"net/url"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// Reads localized namespace names written as a JSON object mapping each
+// name or alias to its canonical (English) namespace, e.g.
+// {"Hilfe": "Help", "Benutzer": "User", "Benutzerin": "User"}.
+func ReadNamespaceMap(reader io.Reader) (map[string]string, error) {
+	var names map[string]string
+	if err := json.NewDecoder(reader).Decode(&names); err != nil {
+		return nil, err
+	}
+
+	return names, nil
+}
+
+// Localized names and aliases of the namespaces of the wiki, keyed to
+// their canonical namespace, as listed by its siteinfo API.
+func (c *Crawler) SiteNamespaces() (map[string]string, error) {
+	query := url.Values{
+		"action":        {"query"},
+		"meta":          {"siteinfo"},
+		"siprop":        {"namespaces|namespacealiases"},
+		"format":        {"json"},
+		"formatversion": {"2"},
+	}
+	var siteinfo struct {
+		Query struct {
+			Namespaces map[string]struct {
+				ID        int    `json:"id"`
+				Name      string `json:"name"`
+				Canonical string `json:"canonical"`
+			} `json:"namespaces"`
+			NamespaceAliases []struct {
+				ID    int    `json:"id"`
+				Alias string `json:"alias"`
+			} `json:"namespacealiases"`
+		} `json:"query"`
+	}
+	if err := c.apiGet(query, &siteinfo); err != nil {
+		return nil, err
+	}
+
+	names := make(map[string]string)
+	canonical := make(map[int]string)
+	for _, namespace := range siteinfo.Query.Namespaces {
+		// Main (0) has neither a name nor a canonical name.
+		if len(namespace.Canonical) == 0 {
+			continue
+		}
+		canonical[namespace.ID] = namespace.Canonical
+		names[namespace.Name] = namespace.Canonical
+	}
+	for _, alias := range siteinfo.Query.NamespaceAliases {
+		if name, found := canonical[alias.ID]; found {
+			names[alias.Alias] = name
+		}
+	}
+
+	return names, nil
+}
+
+// Adds the namespace names of the siteinfo API to NamespaceMap, names
+// given to the crawler win.
+func (c *Crawler) loadNamespaces() {
+	names, err := c.SiteNamespaces()
+	if err != nil {
+		c.logger().WithFields(log.Fields{"err": err}).Warn("Loading the namespaces of the wiki failed")
+		return
+	}
+
+	for name, namespace := range c.NamespaceMap {
+		names[name] = namespace
+	}
+	c.NamespaceMap = names
+}
+
+// Title with a localized namespace prefix (Hilfe:Index) replaced by its
+// canonical name (Help:Index), so English namespace rules apply to every
+// wiki. Other titles are returned as is.
+func (c *Crawler) canonicalTitle(title string) string {
+	split := strings.Index(title, ":")
+	if split < 0 || len(c.NamespaceMap) == 0 {
+		return title
+	}
+
+	prefix := namespaceKey(title[:split])
+	for name, namespace := range c.NamespaceMap {
+		if namespaceKey(name) == prefix {
+			return strings.Replace(strings.TrimSpace(namespace), " ", "_", -1) + title[split:]
+		}
+	}
+
+	return title
+}
+
+// Compares namespace names case insensitively, spaces and underscores alike.
+func namespaceKey(name string) string {
+	return strings.ToLower(strings.Replace(strings.TrimSpace(name), " ", "_", -1))
+}
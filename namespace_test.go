@@ -0,0 +1,90 @@
+package wikicrawl
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadNamespaceMap(t *testing.T) {
+	t.Run("Read names of a mapping file", func(t *testing.T) {
+		t.Parallel()
+		names, err := ReadNamespaceMap(strings.NewReader(`{"Hilfe": "Help", "Benutzer": "User"}`))
+		if err != nil {
+			t.Fatalf("Reading names failed: %s.", err)
+		}
+		if want := map[string]string{"Hilfe": "Help", "Benutzer": "User"}; !reflect.DeepEqual(names, want) {
+			t.Errorf("Names mismatch, got: %v, want: %v.", names, want)
+		}
+	})
+
+	t.Run("Invalid file", func(t *testing.T) {
+		t.Parallel()
+		if _, err := ReadNamespaceMap(strings.NewReader(`["Hilfe"]`)); err == nil {
+			t.Errorf("Error mismatch, got: nil, want: an error.")
+		}
+	})
+}
+
+func TestLocalizedNamespaces(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		query := req.URL.Query()
+		switch {
+		case req.URL.Path == "/api.php":
+			fmt.Fprint(rw, `{"query":{"namespaces":{
+				"0":{"id":0,"name":""},
+				"2":{"id":2,"name":"Benutzer","canonical":"User"},
+				"12":{"id":12,"name":"Hilfe","canonical":"Help"},
+				"13":{"id":13,"name":"Hilfe Diskussion","canonical":"Help talk"}},
+				"namespacealiases":[{"id":2,"alias":"Benutzerin"}]}}`)
+		case query.Get("title") == "Hauptseite":
+			fmt.Fprint(rw, `<a href="/index.php?title=Hilfe:Index">Hilfe</a>
+				<a href="/index.php?title=Benutzerin:Anna">Anna</a>
+				<a href="/index.php?title=Vorlage:Box">Box</a>`)
+		default:
+			fmt.Fprint(rw, `<html><body></body></html>`)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	t.Run("Names of the siteinfo API", func(t *testing.T) {
+		t.Parallel()
+		names, err := NewCrawler(server.URL + "/index.php").SiteNamespaces()
+		if err != nil {
+			t.Fatalf("Loading namespaces failed: %s.", err)
+		}
+		want := map[string]string{"Benutzer": "User", "Benutzerin": "User", "Hilfe": "Help", "Hilfe Diskussion": "Help talk"}
+		if !reflect.DeepEqual(names, want) {
+			t.Errorf("Names mismatch, got: %v, want: %v.", names, want)
+		}
+	})
+
+	t.Run("Skip and classify localized namespaces", func(t *testing.T) {
+		t.Parallel()
+		c := NewCrawler(server.URL+"/index.php", WithLocalizedNamespaces(), WithNamespaceMap(map[string]string{"Vorlage": "Template"}))
+		result := c.Crawl(server.URL + "/index.php?title=Hauptseite")
+
+		// Help and User pages are skipped as in English wikis.
+		want := []string{server.URL + "/index.php?title=Hauptseite", server.URL + "/index.php?title=Vorlage%3ABox"}
+		if got := result.Visited.Keys(); !reflect.DeepEqual(got, want) {
+			t.Errorf("Visited mismatch, got: %v, want: %v.", got, want)
+		}
+		if info, _ := result.Pages.Get(want[1]); info.Link.Namespace != "Template" {
+			t.Errorf("Namespace mismatch, got: %s, want: Template.", info.Link.Namespace)
+		}
+	})
+
+	t.Run("Namespaces to crawl", func(t *testing.T) {
+		t.Parallel()
+		c := NewCrawler(server.URL+"/index.php", WithNamespaceMap(map[string]string{"Vorlage Diskussion": "Template talk"}), WithNamespaces("Template_talk"))
+		if link := NewLink(server.URL + "/index.php?title=Vorlage_Diskussion:Box").URL; !c.ValidateLink(link) {
+			t.Errorf("Url incorrectly marked as invalid: %s.", link)
+		}
+		if link := NewLink(server.URL + "/index.php?title=Hauptseite").URL; c.ValidateLink(link) {
+			t.Errorf("Url incorrectly marked as valid: %s.", link)
+		}
+	})
+}
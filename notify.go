@@ -0,0 +1,292 @@
+package wikicrawl
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Severity of a crawl outcome, deciding which channels are notified.
+type Severity int
+
+const (
+	SeverityOK Severity = iota
+	SeverityWarning
+	SeverityCritical
+)
+
+var severityNames = []string{"ok", "warning", "critical"}
+
+func (s Severity) String() string {
+	return severityNames[s]
+}
+
+// Parses a severity name: ok, warning or critical.
+func ParseSeverity(name string) (Severity, error) {
+	for severity, known := range severityNames {
+		if strings.EqualFold(name, known) {
+			return Severity(severity), nil
+		}
+	}
+
+	return SeverityOK, fmt.Errorf("unknown severity %q", name)
+}
+
+// Severity of the result: critical when the crawl did not finish, was
+// truncated at the memory limit or links stayed broken for too long,
+// warning when broken or restricted links or failed checks were found.
+func (cr *CrawlResult) Severity() Severity {
+	switch {
+	case cr.Aborted || cr.Truncated || cr.Stall != nil || cr.LongBroken.Len() > 0:
+		return SeverityCritical
+	case cr.Broken.Len() > 0 || cr.Restricted.Len() > 0 || cr.AssertionFailures.Len() > 0 || cr.ErrorPages.Len() > 0:
+		return SeverityWarning
+	default:
+		return SeverityOK
+	}
+}
+
+// Channel notified of crawl results through an incoming webhook.
+//
+//  1. Format: Message format, "slack", "teams" or "webhook" for the plain
+//     JSON summary.
+//  2. URL: Incoming webhook url of the channel.
+//  3. MinSeverity: Results below it are not sent to the channel.
+//  4. ReportURL: Link to the full report included in messages.
+type Notification struct {
+	Format      string
+	URL         string
+	MinSeverity Severity
+	ReportURL   string
+	Client      *http.Client
+}
+
+// Parses a channel written as "<format>[@<severity>]=<webhook url>", e.g.
+// "slack@critical=https://hooks.slack.com/services/...".
+func ParseNotification(raw string) (Notification, error) {
+	split := strings.Index(raw, "=")
+	if split < 0 {
+		return Notification{}, fmt.Errorf("notification %q missing = separator", raw)
+	}
+
+	notification := Notification{Format: raw[:split], URL: raw[split+1:], MinSeverity: SeverityWarning}
+	if at := strings.Index(notification.Format, "@"); at >= 0 {
+		severity, err := ParseSeverity(notification.Format[at+1:])
+		if err != nil {
+			return Notification{}, err
+		}
+		notification.Format, notification.MinSeverity = notification.Format[:at], severity
+	}
+
+	switch notification.Format {
+	case "slack", "teams", "webhook":
+		return notification, nil
+	default:
+		return Notification{}, fmt.Errorf("unknown notification format %q", notification.Format)
+	}
+}
+
+// Counts and top broken pages of a result, the content of notifications.
+type Summary struct {
+	Seed         string            `json:"seed"`
+	Severity     string            `json:"severity"`
+	Duration     time.Duration     `json:"duration"`
+	Visited      int               `json:"visited"`
+	Broken       int               `json:"broken"`
+	Suspect      int               `json:"suspectBroken"`
+	Assertions   int               `json:"assertionFailures"`
+	ErrorPages   int               `json:"errorPages"`
+	Aborted      bool              `json:"aborted"`
+	Truncated    bool              `json:"truncated,omitempty"`
+	BrokenByPage []PageCount       `json:"brokenByPage,omitempty"`
+	Labels       map[string]string `json:"labels,omitempty"`
+	ReportURL    string            `json:"reportUrl,omitempty"`
+}
+
+// Number of broken links of a page.
+type PageCount struct {
+	Page  string `json:"page"`
+	Count int    `json:"count"`
+}
+
+// Summary of the result, with the top pages holding the most broken links.
+func (cr *CrawlResult) Summarize(top int) Summary {
+	summary := Summary{
+		Seed:       cr.Metadata.Seed,
+		Severity:   cr.Severity().String(),
+		Visited:    cr.Visited.Len(),
+		Broken:     cr.Broken.Len(),
+		Suspect:    cr.SuspectBroken.Len(),
+		Assertions: cr.AssertionFailures.Len(),
+		ErrorPages: cr.ErrorPages.Len(),
+		Aborted:    cr.Aborted,
+		Truncated:  cr.Truncated,
+		Labels:     cr.Metadata.Labels,
+	}
+	if !cr.Metadata.Finished.IsZero() {
+		summary.Duration = cr.Metadata.Finished.Sub(cr.Metadata.Started).Round(time.Second)
+	}
+
+	for page, broken := range cr.BrokenByReferrer() {
+		if len(page) > 0 {
+			summary.BrokenByPage = append(summary.BrokenByPage, PageCount{Page: page, Count: len(broken)})
+		}
+	}
+	sort.Slice(summary.BrokenByPage, func(i, j int) bool {
+		a, b := summary.BrokenByPage[i], summary.BrokenByPage[j]
+		return a.Count > b.Count || a.Count == b.Count && a.Page < b.Page
+	})
+	if len(summary.BrokenByPage) > top {
+		summary.BrokenByPage = summary.BrokenByPage[:top]
+	}
+
+	return summary
+}
+
+// Sends the result to the channel unless below its severity threshold.
+func (n Notification) Notify(result *CrawlResult) error {
+	if result.Severity() < n.MinSeverity {
+		return nil
+	}
+
+	summary := result.Summarize(5)
+	summary.ReportURL = n.ReportURL
+
+	var message interface{}
+	switch n.Format {
+	case "slack":
+		message = slackMessage(summary)
+	case "teams":
+		message = teamsMessage(summary)
+	default:
+		message = summary
+	}
+
+	payload, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+
+	client := n.Client
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Second}
+	}
+	req, err := http.NewRequest("POST", n.URL, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	return upload(client, req)
+}
+
+func (s Summary) title() string {
+	return fmt.Sprintf("Wiki crawl of %s: %d broken links (%s)", s.Seed, s.Broken, s.Severity)
+}
+
+func (s Summary) facts() [][2]string {
+	facts := [][2]string{
+		{"Visited", fmt.Sprint(s.Visited)},
+		{"Broken", fmt.Sprint(s.Broken)},
+		{"Suspected broken", fmt.Sprint(s.Suspect)},
+		{"Failed assertions", fmt.Sprint(s.Assertions)},
+		{"Error pages", fmt.Sprint(s.ErrorPages)},
+		{"Duration", s.Duration.String()},
+	}
+	for _, key := range labelKeys(s.Labels) {
+		facts = append(facts, [2]string{key, s.Labels[key]})
+	}
+	if s.Aborted {
+		facts = append(facts, [2]string{"Aborted", "results are incomplete"})
+	}
+	if s.Truncated {
+		facts = append(facts, [2]string{"Truncated", "memory limit reached, results are incomplete"})
+	}
+
+	return facts
+}
+
+// Slack Block Kit message of a summary.
+func slackMessage(s Summary) map[string]interface{} {
+	var fields []map[string]string
+	for _, fact := range s.facts() {
+		fields = append(fields, map[string]string{"type": "mrkdwn", "text": "*" + fact[0] + "*\n" + fact[1]})
+	}
+
+	blocks := []map[string]interface{}{
+		{"type": "header", "text": map[string]string{"type": "plain_text", "text": s.title()}},
+		{"type": "section", "fields": fields},
+	}
+
+	if len(s.BrokenByPage) > 0 {
+		var pages []string
+		for _, page := range s.BrokenByPage {
+			pages = append(pages, fmt.Sprintf("• <%s> (%d)", page.Page, page.Count))
+		}
+		blocks = append(blocks, map[string]interface{}{
+			"type": "section",
+			"text": map[string]string{"type": "mrkdwn", "text": "*Top pages with broken links*\n" + strings.Join(pages, "\n")},
+		})
+	}
+
+	if len(s.ReportURL) > 0 {
+		blocks = append(blocks, map[string]interface{}{
+			"type": "actions",
+			"elements": []map[string]interface{}{{
+				"type": "button",
+				"text": map[string]string{"type": "plain_text", "text": "Full report"},
+				"url":  s.ReportURL,
+			}},
+		})
+	}
+
+	return map[string]interface{}{"text": s.title(), "blocks": blocks}
+}
+
+// Microsoft Teams message with an Adaptive Card of a summary.
+func teamsMessage(s Summary) map[string]interface{} {
+	var facts []map[string]string
+	for _, fact := range s.facts() {
+		facts = append(facts, map[string]string{"title": fact[0], "value": fact[1]})
+	}
+
+	color := map[string]string{"ok": "Good", "warning": "Warning", "critical": "Attention"}[s.Severity]
+	body := []map[string]interface{}{
+		{"type": "TextBlock", "text": s.title(), "weight": "Bolder", "size": "Medium", "wrap": true, "color": color},
+		{"type": "FactSet", "facts": facts},
+	}
+
+	if len(s.BrokenByPage) > 0 {
+		var pages []string
+		for _, page := range s.BrokenByPage {
+			pages = append(pages, fmt.Sprintf("- [%s](%s) (%d)", page.Page, page.Page, page.Count))
+		}
+		body = append(body, map[string]interface{}{
+			"type": "TextBlock",
+			"text": "**Top pages with broken links**\n\n" + strings.Join(pages, "\n"),
+			"wrap": true,
+		})
+	}
+
+	card := map[string]interface{}{
+		"$schema": "http://adaptivecards.io/schemas/adaptive-card.json",
+		"type":    "AdaptiveCard",
+		"version": "1.4",
+		"body":    body,
+	}
+	if len(s.ReportURL) > 0 {
+		card["actions"] = []map[string]string{{"type": "Action.OpenUrl", "title": "Full report", "url": s.ReportURL}}
+	}
+
+	return map[string]interface{}{
+		"type": "message",
+		"attachments": []map[string]interface{}{{
+			"contentType": "application/vnd.microsoft.card.adaptive",
+			"content":     card,
+		}},
+	}
+}
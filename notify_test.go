@@ -0,0 +1,105 @@
+package wikicrawl
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestParseNotification(t *testing.T) {
+	t.Run("Parse notification channels", func(t *testing.T) {
+		t.Run("Default severity", func(t *testing.T) {
+			t.Parallel()
+			channel, err := ParseNotification("slack=https://hooks.slack.com/services/T/B/X")
+			if err != nil || channel.Format != "slack" || channel.URL != "https://hooks.slack.com/services/T/B/X" || channel.MinSeverity != SeverityWarning {
+				t.Errorf("Channel mismatch, got: %+v, err: %v.", channel, err)
+			}
+		})
+
+		t.Run("Severity threshold", func(t *testing.T) {
+			t.Parallel()
+			channel, err := ParseNotification("teams@critical=https://example.webhook.office.com/x")
+			if err != nil || channel.Format != "teams" || channel.MinSeverity != SeverityCritical {
+				t.Errorf("Channel mismatch, got: %+v, err: %v.", channel, err)
+			}
+		})
+
+		t.Run("Invalid channels", func(t *testing.T) {
+			t.Parallel()
+			for _, raw := range []string{"slack", "irc=http://x", "slack@loud=http://x"} {
+				if _, err := ParseNotification(raw); err == nil {
+					t.Errorf("Parsing %s should fail.", raw)
+				}
+			}
+		})
+	})
+}
+
+func TestNotify(t *testing.T) {
+	result := NewCrawlResult()
+	result.Metadata.Seed = "http://testing.com"
+	result.Visited.Add(NewLink("http://testing.com/a"))
+	for _, broken := range []string{"http://testing.com/gone", "http://testing.com/lost"} {
+		result.Broken.Add(NewLink(broken))
+		result.record(NewLink(broken), func(info *PageInfo) {
+			info.AddReferrer("http://testing.com/a")
+		})
+	}
+
+	send := func(t *testing.T, channel Notification) map[string]interface{} {
+		var message map[string]interface{}
+		server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			body, _ := ioutil.ReadAll(req.Body)
+			if err := json.Unmarshal(body, &message); err != nil {
+				t.Errorf("Invalid message: %s.", body)
+			}
+		}))
+		defer server.Close()
+
+		channel.URL = server.URL
+		if err := channel.Notify(result); err != nil {
+			t.Fatalf("Notify failed: %s.", err)
+		}
+		return message
+	}
+
+	t.Run("Slack blocks", func(t *testing.T) {
+		t.Parallel()
+		message := send(t, Notification{Format: "slack", ReportURL: "https://reports/wiki.json"})
+		encoded, _ := json.Marshal(message)
+		for _, expected := range []string{`"type":"header"`, `\u003chttp://testing.com/a\u003e (2)`, `"url":"https://reports/wiki.json"`} {
+			if !strings.Contains(string(encoded), expected) {
+				t.Errorf("Slack message missing %s, got: %s.", expected, encoded)
+			}
+		}
+	})
+
+	t.Run("Teams adaptive card", func(t *testing.T) {
+		t.Parallel()
+		message := send(t, Notification{Format: "teams"})
+		encoded, _ := json.Marshal(message)
+		for _, expected := range []string{`"contentType":"application/vnd.microsoft.card.adaptive"`, `"type":"FactSet"`, `"color":"Warning"`} {
+			if !strings.Contains(string(encoded), expected) {
+				t.Errorf("Teams message missing %s, got: %s.", expected, encoded)
+			}
+		}
+	})
+
+	t.Run("Plain webhook summary", func(t *testing.T) {
+		t.Parallel()
+		message := send(t, Notification{Format: "webhook"})
+		if message["broken"] != float64(2) || message["severity"] != "warning" {
+			t.Errorf("Summary mismatch, got: %v.", message)
+		}
+	})
+
+	t.Run("Skip results below threshold", func(t *testing.T) {
+		t.Parallel()
+		if message := send(t, Notification{Format: "slack", MinSeverity: SeverityCritical}); message != nil {
+			t.Errorf("Channel should not be notified, got: %v.", message)
+		}
+	})
+}
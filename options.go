@@ -0,0 +1,442 @@
+package wikicrawl
+
+import (
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Configures a Crawler in NewCrawler.
+type Option func(*Crawler)
+
+// Sends the MediaWiki session cookie with every request.
+func WithSession(session string) Option {
+	return func(c *Crawler) {
+		c.sessionID = session
+	}
+}
+
+// Replaces the default http client (10 second timeout, cookie jar).
+func WithClient(client *http.Client) Option {
+	return func(c *Crawler) {
+		c.Client = client
+	}
+}
+
+// Connects hosts to the given addresses instead of resolving them.
+func WithHostOverrides(overrides ...HostOverride) Option {
+	return func(c *Crawler) {
+		c.overrides = append(c.overrides, overrides...)
+	}
+}
+
+// Sets the number of pages fetched concurrently.
+func WithConcurrency(workers int) Option {
+	return func(c *Crawler) {
+		c.Workers = workers
+	}
+}
+
+// Applies a politeness Profile.
+func WithProfile(profile Profile) Option {
+	return func(c *Crawler) {
+		c.ApplyProfile(profile)
+	}
+}
+
+// Adds a check every internal link must pass before being followed.
+func WithValidator(validator func(link *url.URL) bool) Option {
+	return func(c *Crawler) {
+		c.Validator = validator
+	}
+}
+
+// Sends crawl messages to the logger instead of the logrus standard logger.
+func WithLogger(logger *log.Logger) Option {
+	return func(c *Crawler) {
+		c.Logger = logger
+	}
+}
+
+// Backs off (longer delays, fewer workers) while the average response time
+// is above max, protecting slow wikis from the crawler.
+func WithMaxLatency(max time.Duration) Option {
+	return func(c *Crawler) {
+		c.MaxLatency = max
+	}
+}
+
+// Reuses the pages of a previous run still fresh per their Cache-Control
+// or Expires headers instead of fetching them again.
+func WithPrevious(previous *CrawlResult) Option {
+	return func(c *Crawler) {
+		c.Previous = previous
+	}
+}
+
+// Records how every discovered href was normalized and merged, see
+// CrawlResult.HrefMappings.
+func WithHrefMap() Option {
+	return func(c *Crawler) {
+		c.TrackHrefs = true
+	}
+}
+
+// Adds fields to every crawl message, e.g. the wiki of a batch. Copies the
+// existing fields since crawlers copied by value share the map.
+func WithLogFields(fields log.Fields) Option {
+	return func(c *Crawler) {
+		merged := make(log.Fields, len(c.LogFields)+len(fields))
+		for key, value := range c.LogFields {
+			merged[key] = value
+		}
+		for key, value := range fields {
+			merged[key] = value
+		}
+		c.LogFields = merged
+	}
+}
+
+// Names the run in its metadata and every crawl message, a random id
+// unless set.
+func WithRunID(id string) Option {
+	return func(c *Crawler) {
+		c.RunID = id
+	}
+}
+
+// Retrieves pages with the fetcher instead of plain GET requests.
+func WithFetcher(fetcher Fetcher) Option {
+	return func(c *Crawler) {
+		c.Fetcher = fetcher
+	}
+}
+
+// Runs the processors on every crawled page.
+func WithProcessors(processors ...PageProcessor) Option {
+	return func(c *Crawler) {
+		c.Processors = append(c.Processors, processors...)
+	}
+}
+
+// Logs in with the authenticator whenever the session expires.
+func WithAuthenticator(authenticator Authenticator) Option {
+	return func(c *Crawler) {
+		c.Authenticator = authenticator
+	}
+}
+
+// Verifies external links without crawling them.
+func WithExternalLinks() Option {
+	return func(c *Crawler) {
+		c.CheckExternal = true
+	}
+}
+
+// Follows links one at a time in a reproducible order, for tests and debugging.
+func WithDeterministic() Option {
+	return func(c *Crawler) {
+		c.Deterministic = true
+	}
+}
+
+// Wraps the client transport with middleware, applied after WithClient
+// regardless of the option order.
+func WithMiddleware(middleware ...Middleware) Option {
+	return func(c *Crawler) {
+		c.middleware = append(c.middleware, middleware...)
+	}
+}
+
+// Records crawl, page and HTTP client spans with the provider.
+func WithTracerProvider(provider trace.TracerProvider) Option {
+	return func(c *Crawler) {
+		c.Tracer = provider.Tracer(tracerName)
+		c.middleware = append(c.middleware, TraceTransport(c.Tracer))
+	}
+}
+
+// Limits the combined download speed of the crawl in bytes per second.
+func WithBandwidth(bytesPerSecond float64) Option {
+	return func(c *Crawler) {
+		c.middleware = append(c.middleware, ThrottleBandwidth(bytesPerSecond))
+	}
+}
+
+// Limits the connections open at once, e.g. below the file limit.
+func WithMaxConnections(max int) Option {
+	return func(c *Crawler) {
+		c.middleware = append(c.middleware, LimitConnections(max))
+	}
+}
+
+// Also follows the targets of simple GET forms.
+func WithForms() Option {
+	return func(c *Crawler) {
+		c.Forms = true
+	}
+}
+
+// Requests urls matching the rules with their method instead of GET.
+func WithMethodRules(rules ...MethodRule) Option {
+	return func(c *Crawler) {
+		c.Methods = append(c.Methods, rules...)
+	}
+}
+
+// Seeds the crawl with the pages listed by the sitemaps of the wiki host and
+// skips the pages its robots.txt disallows.
+func WithDiscover() Option {
+	return func(c *Crawler) {
+		c.Discover = true
+	}
+}
+
+// Counts the statuses of the rules as success for matching urls.
+func WithStatusRules(rules ...StatusRule) Option {
+	return func(c *Crawler) {
+		c.StatusRules = append(c.StatusRules, rules...)
+	}
+}
+
+// Records a hash of the article text of every page, compared between runs.
+func WithContentHashes() Option {
+	return func(c *Crawler) {
+		c.HashContent = true
+	}
+}
+
+// Starts with a single worker, doubling them every stage of pages served
+// without many errors until every worker runs.
+func WithWarmUp(pages int) Option {
+	return func(c *Crawler) {
+		c.WarmUp = pages
+	}
+}
+
+// Reports articles with fewer characters of text, e.g. blanked by accident.
+func WithMinContent(characters int) Option {
+	return func(c *Crawler) {
+		c.MinContent = characters
+	}
+}
+
+// Only follows pages of the given MediaWiki namespaces, Main for pages
+// without a namespace prefix.
+func WithNamespaces(namespaces ...string) Option {
+	return func(c *Crawler) {
+		c.InNamespaces = append(c.InNamespaces, namespaces...)
+	}
+}
+
+// Recognizes localized namespace names (Hilfe:, Benutzer:), mapped to
+// their canonical namespace, in validation and classification.
+func WithNamespaceMap(names map[string]string) Option {
+	return func(c *Crawler) {
+		if c.NamespaceMap == nil {
+			c.NamespaceMap = make(map[string]string)
+		}
+		for name, namespace := range names {
+			c.NamespaceMap[name] = namespace
+		}
+	}
+}
+
+// Loads the localized namespace names and aliases of the wiki from its
+// siteinfo API when the crawl starts.
+func WithLocalizedNamespaces() Option {
+	return func(c *Crawler) {
+		c.Localize = true
+	}
+}
+
+// Reports links answered with 401 or 403 as restricted instead of broken,
+// for partly private wikis.
+func WithRestricted() Option {
+	return func(c *Crawler) {
+		c.Restricted = true
+	}
+}
+
+// Only follows pages below the path prefix, on any host unless sameHost.
+// The prefix is NFC normalized like the paths of links.
+func WithScope(sameHost bool, pathPrefix string) Option {
+	return func(c *Crawler) {
+		c.SameHost = sameHost
+		c.PathPrefix = norm.NFC.String(pathPrefix)
+	}
+}
+
+// Adds a random pause up to jitter after each request, so traffic looks
+// less like a bot.
+func WithJitter(jitter time.Duration) Option {
+	return func(c *Crawler) {
+		c.Jitter = jitter
+	}
+}
+
+// Shuffles the order links of a page are queued in instead of following
+// them in page order.
+func WithShuffle() Option {
+	return func(c *Crawler) {
+		c.Shuffle = true
+	}
+}
+
+// Also follows links found in the wikitext of every page (action=raw).
+func WithWikitext() Option {
+	return func(c *Crawler) {
+		c.Wikitext = true
+	}
+}
+
+// Capacity of the work queue, links found while it is full stall workers.
+func WithQueueSize(size int) Option {
+	return func(c *Crawler) {
+		c.QueueSize = size
+	}
+}
+
+// Crawls the mobile variant of the wiki, see MobileVariant.
+func WithMobile(host string) Option {
+	return func(c *Crawler) {
+		c.middleware = append(c.middleware, MobileVariant(c.base.Host, host))
+	}
+}
+
+// Sets the Accept-Language header of every request.
+func WithAcceptLanguage(language string) Option {
+	return func(c *Crawler) {
+		c.middleware = append(c.middleware, SetHeader("Accept-Language", language))
+	}
+}
+
+// Writes crawl events to writer as they happen, one JSON object per line.
+func WithEventLog(writer io.Writer) Option {
+	return func(c *Crawler) {
+		c.Events = NewEventLog(writer)
+	}
+}
+
+// Records the named response headers of every link, e.g. X-Cache or
+// Content-Security-Policy.
+func WithCaptureHeaders(names ...string) Option {
+	return func(c *Crawler) {
+		c.CaptureHeaders = append(c.CaptureHeaders, names...)
+	}
+}
+
+// Keeps the first bytes of the body and the key headers of broken
+// responses, showing the error page served without requesting it again.
+func WithErrorBody(bytes int64) Option {
+	return func(c *Crawler) {
+		c.ErrorBody = bytes
+	}
+}
+
+// Also verifies the style sheets of pages and the urls their styles
+// reference, e.g. background images of the skin.
+func WithStyles() Option {
+	return func(c *Crawler) {
+		c.Styles = true
+	}
+}
+
+// Also verifies the scripts embedded by pages, e.g. gadgets and the
+// scripts of extensions.
+func WithScripts() Option {
+	return func(c *Crawler) {
+		c.Scripts = true
+	}
+}
+
+// Audits the security headers and mixed content of every internal page.
+func WithAudit() Option {
+	return func(c *Crawler) {
+		c.Audit = true
+	}
+}
+
+// Keeps only the listed query parameters of urls matching the rules,
+// checked before DefaultQueryRules.
+func WithQueryRules(rules ...QueryRule) Option {
+	return func(c *Crawler) {
+		c.QueryRules = append(c.QueryRules, rules...)
+	}
+}
+
+// Fetches links matching any pattern to confirm they resolve, without
+// following the links of their content.
+func WithVerifyOnly(patterns ...*regexp.Regexp) Option {
+	return func(c *Crawler) {
+		c.VerifyOnly = append(c.VerifyOnly, patterns...)
+	}
+}
+
+// Aborts the crawl with diagnostics when no link is done for timeout.
+func WithStallTimeout(timeout time.Duration) Option {
+	return func(c *Crawler) {
+		c.StallTimeout = timeout
+	}
+}
+
+// Stops queueing new links once the heap grows above limit bytes.
+func WithMemoryLimit(limit uint64) Option {
+	return func(c *Crawler) {
+		c.MemoryLimit = limit
+	}
+}
+
+// Identifies pages by key instead of url, e.g. TitleKey.
+func WithPageKey(key KeyFunc) Option {
+	return func(c *Crawler) {
+		c.PageKey = key
+	}
+}
+
+// Focuses the crawl on pages whose titles start with one of prefixes, e.g.
+// "Project:Infra/". Links leaving the area are verified, not followed.
+func WithTitlePrefixes(prefixes ...string) Option {
+	return func(c *Crawler) {
+		c.TitlePrefixes = append(c.TitlePrefixes, prefixes...)
+	}
+}
+
+// Focuses the crawl on the pages of categories, listed through the API.
+func WithCategories(categories ...string) Option {
+	return func(c *Crawler) {
+		c.Categories = append(c.Categories, categories...)
+	}
+}
+
+// Labels the run, e.g. WithLabel("env", "prod"). Labels are kept in the
+// result metadata, stored runs and pushed metrics.
+func WithLabel(key, value string) Option {
+	return func(c *Crawler) {
+		if c.Labels == nil {
+			c.Labels = make(map[string]string)
+		}
+		c.Labels[key] = value
+	}
+}
+
+// Follows only a fraction of the discovered links, for quick estimates of
+// the broken link rate.
+func WithSample(fraction float64) Option {
+	return func(c *Crawler) {
+		c.Sample = fraction
+	}
+}
+
+// Reads at most max bytes of every page, links past it are not found.
+func WithMaxParseBytes(max int64) Option {
+	return func(c *Crawler) {
+		c.MaxParseBytes = max
+	}
+}
@@ -0,0 +1,132 @@
+package wikicrawl
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+func TestOptions(t *testing.T) {
+	t.Run("Functional crawler options", func(t *testing.T) {
+		t.Run("Session cookie sent", func(t *testing.T) {
+			t.Parallel()
+			var session string
+			server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				if cookie, err := req.Cookie("wikidb2_is__session"); err == nil {
+					session = cookie.Value
+				}
+			}))
+			defer server.Close()
+
+			NewCrawler(server.URL, WithSession("abc123")).Crawl(server.URL)
+			if session != "abc123" {
+				t.Errorf("Session cookie mismatch, got: %s, want: %s.", session, "abc123")
+			}
+		})
+
+		t.Run("Session cookie with custom client", func(t *testing.T) {
+			t.Parallel()
+			c := NewCrawler("http://testing.com", WithSession("abc123"), WithClient(&http.Client{}))
+			if c.Client.Jar == nil || len(c.Client.Jar.Cookies(c.Base())) != 1 {
+				t.Errorf("Session cookie should be added to custom clients.")
+			}
+		})
+
+		t.Run("Concurrency and profile", func(t *testing.T) {
+			t.Parallel()
+			c := NewCrawler("http://testing.com", WithProfile(Profiles["gentle"]), WithConcurrency(5))
+			if c.Workers != 5 || c.RateLimit != Profiles["gentle"].RateLimit {
+				t.Errorf("Options applied out of order, got: %d workers, %f rate.", c.Workers, c.RateLimit)
+			}
+		})
+
+		t.Run("Custom validator", func(t *testing.T) {
+			t.Parallel()
+			c := NewCrawler("http://testing.com", WithValidator(func(link *url.URL) bool {
+				return !strings.HasPrefix(link.Path, "/skip")
+			}))
+
+			skip, _ := url.Parse("http://testing.com/skip/page")
+			keep, _ := url.Parse("http://testing.com/keep")
+			if c.ValidateLink(skip) || !c.ValidateLink(keep) {
+				t.Errorf("Custom validator not applied.")
+			}
+		})
+
+		t.Run("Custom logger", func(t *testing.T) {
+			t.Parallel()
+			server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				if req.URL.Path == "/error" {
+					rw.WriteHeader(500)
+					return
+				}
+				fmt.Fprintf(rw, `<html><body><a href="/error" /></body></html>`)
+			}))
+			defer server.Close()
+
+			var output bytes.Buffer
+			logger := log.New()
+			logger.Out = &output
+
+			NewCrawler(server.URL, WithLogger(logger)).Crawl(server.URL)
+			if !strings.Contains(output.String(), "non 200 response") {
+				t.Errorf("Crawl messages missing from custom logger, got: %s.", output.String())
+			}
+		})
+
+		t.Run("Log fields", func(t *testing.T) {
+			t.Parallel()
+			server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				if req.URL.Path == "/error" {
+					rw.WriteHeader(500)
+					return
+				}
+				fmt.Fprintf(rw, `<html><body><a href="/error" /></body></html>`)
+			}))
+			defer server.Close()
+
+			var output bytes.Buffer
+			logger := log.New()
+			logger.Out = &output
+
+			host := strings.TrimPrefix(server.URL, "http://")
+			result := NewCrawler(server.URL, WithLogger(logger), WithRunID("nightly"), WithLogFields(log.Fields{"wiki": "docs"})).Crawl(server.URL)
+			for _, field := range []string{"run=nightly", "host=\"" + host + "\"", "wiki=docs"} {
+				if !strings.Contains(output.String(), field) {
+					t.Errorf("Log field %s missing, got: %s.", field, output.String())
+				}
+			}
+			if result.Metadata.Run != "nightly" {
+				t.Errorf("Run mismatch, got: %s, want: nightly.", result.Metadata.Run)
+			}
+
+			if run := NewCrawler(server.URL, WithLogger(logger)).Crawl(server.URL).Metadata.Run; len(run) != 16 {
+				t.Errorf("Runs should get a random id, got: %q.", run)
+			}
+		})
+
+		t.Run("Log fields of copied crawlers", func(t *testing.T) {
+			t.Parallel()
+			configured := NewCrawler("http://localhost/", WithLogFields(log.Fields{"wiki": "docs"}))
+			first, second := *configured, *configured
+			WithLogFields(log.Fields{"job": "1"})(&first)
+			WithLogFields(log.Fields{"job": "2"})(&second)
+
+			if job := first.LogFields["job"]; job != "1" {
+				t.Errorf("Job mismatch, got: %v, want: 1.", job)
+			}
+			if _, ok := configured.LogFields["job"]; ok {
+				t.Errorf("Copies should not add fields to the original, got: %v.", configured.LogFields)
+			}
+			if wiki := second.LogFields["wiki"]; wiki != "docs" {
+				t.Errorf("Wiki mismatch, got: %v, want: docs.", wiki)
+			}
+		})
+	})
+}
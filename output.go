@@ -0,0 +1,253 @@
+package wikicrawl
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// Destination a finished report is published to.
+type ReportWriter interface {
+	WriteReport(report []byte) error
+}
+
+// Report writer for a target selected by url scheme:
+//
+//  1. "-" or "stdout:": Standard output.
+//  2. A path or "file://" url: Local file.
+//  3. "s3://bucket/key": S3 object, credentials read from the AWS_*
+//     environment variables (see NewS3Writer).
+//  4. "gs://bucket/object": Google Cloud Storage object, authorized with
+//     the GOOGLE_OAUTH_ACCESS_TOKEN environment variable.
+func NewReportWriter(target string) (ReportWriter, error) {
+	if target == "-" || target == "stdout:" {
+		return &StreamWriter{Writer: os.Stdout}, nil
+	}
+
+	location, err := url.Parse(target)
+	if err != nil || len(location.Scheme) <= 1 {
+		// Plain paths, including Windows drive letters.
+		return &FileWriter{Path: target}, nil
+	}
+
+	switch location.Scheme {
+	case "file":
+		return &FileWriter{Path: location.Path}, nil
+	case "s3":
+		return NewS3Writer(location.Host, strings.TrimPrefix(location.Path, "/")), nil
+	case "gs":
+		return &GCSWriter{
+			Client: &http.Client{Timeout: time.Minute},
+			Bucket: location.Host,
+			Object: strings.TrimPrefix(location.Path, "/"),
+			Token:  os.Getenv("GOOGLE_OAUTH_ACCESS_TOKEN"),
+		}, nil
+	default:
+		return nil, fmt.Errorf("unsupported report output %q", target)
+	}
+}
+
+// Writes reports to a stream, e.g. standard output.
+type StreamWriter struct {
+	Writer io.Writer
+}
+
+func (sw *StreamWriter) WriteReport(report []byte) error {
+	_, err := sw.Writer.Write(report)
+	return err
+}
+
+// Writes reports to a local file.
+type FileWriter struct {
+	Path string
+}
+
+func (fw *FileWriter) WriteReport(report []byte) error {
+	return ioutil.WriteFile(fw.Path, report, 0644)
+}
+
+// Uploads reports as an S3 object, signing requests with AWS Signature
+// Version 4.
+//
+//  1. Endpoint: S3 compatible service (e.g. MinIO) addressed with path
+//     style urls, AWS virtual hosted buckets when empty.
+//  2. Region: Region of the bucket.
+//  3. AccessKey, SecretKey and SessionToken: Credentials signing the upload.
+type S3Writer struct {
+	Client       *http.Client
+	Bucket       string
+	Key          string
+	Endpoint     string
+	Region       string
+	AccessKey    string
+	SecretKey    string
+	SessionToken string
+}
+
+// Simple constructor for an S3Writer configured from the AWS_REGION,
+// AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY, AWS_SESSION_TOKEN and
+// AWS_ENDPOINT_URL environment variables.
+func NewS3Writer(bucket, key string) *S3Writer {
+	region := os.Getenv("AWS_REGION")
+	if len(region) == 0 {
+		region = "us-east-1"
+	}
+
+	return &S3Writer{
+		Client:       &http.Client{Timeout: time.Minute},
+		Bucket:       bucket,
+		Key:          key,
+		Endpoint:     os.Getenv("AWS_ENDPOINT_URL"),
+		Region:       region,
+		AccessKey:    os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretKey:    os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken: os.Getenv("AWS_SESSION_TOKEN"),
+	}
+}
+
+func (sw *S3Writer) WriteReport(report []byte) error {
+	object := &url.URL{
+		Scheme: "https",
+		Host:   sw.Bucket + ".s3." + sw.Region + ".amazonaws.com",
+		Path:   "/" + sw.Key,
+	}
+	if len(sw.Endpoint) > 0 {
+		endpoint, err := url.Parse(sw.Endpoint)
+		if err != nil {
+			return err
+		}
+		object = endpoint.ResolveReference(&url.URL{Path: "/" + sw.Bucket + "/" + sw.Key})
+	}
+	object.RawPath = awsEscape(object.Path)
+
+	req, err := http.NewRequest("PUT", object.String(), bytes.NewReader(report))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	sw.sign(req, report, time.Now().UTC())
+
+	return upload(sw.Client, req)
+}
+
+// Adds the AWS Signature Version 4 headers of an S3 request.
+func (sw *S3Writer) sign(req *http.Request, payload []byte, now time.Time) {
+	sum := sha256.Sum256(payload)
+	payloadHash := hex.EncodeToString(sum[:])
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	req.Header.Set("X-Amz-Date", amzDate)
+	signed := []string{"host", "x-amz-content-sha256", "x-amz-date"}
+	if len(sw.SessionToken) > 0 {
+		req.Header.Set("X-Amz-Security-Token", sw.SessionToken)
+		signed = append(signed, "x-amz-security-token")
+	}
+
+	var headers strings.Builder
+	for _, name := range signed {
+		value := req.Header.Get(name)
+		if name == "host" {
+			value = req.URL.Host
+		}
+		headers.WriteString(name + ":" + strings.TrimSpace(value) + "\n")
+	}
+
+	canonical := strings.Join([]string{
+		req.Method,
+		awsEscape(req.URL.Path),
+		req.URL.RawQuery,
+		headers.String(),
+		strings.Join(signed, ";"),
+		payloadHash,
+	}, "\n")
+	canonicalSum := sha256.Sum256([]byte(canonical))
+
+	scope := date + "/" + sw.Region + "/s3/aws4_request"
+	toSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(canonicalSum[:])
+
+	key := []byte("AWS4" + sw.SecretKey)
+	for _, part := range []string{date, sw.Region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		sw.AccessKey, scope, strings.Join(signed, ";"), hex.EncodeToString(hmacSHA256(key, toSign))))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// Escapes a path as AWS expects, every byte but unreserved characters and
+// slashes percent encoded.
+func awsEscape(path string) string {
+	var escaped strings.Builder
+	for i := 0; i < len(path); i++ {
+		b := path[i]
+		if 'A' <= b && b <= 'Z' || 'a' <= b && b <= 'z' || '0' <= b && b <= '9' || strings.IndexByte("-_.~/", b) >= 0 {
+			escaped.WriteByte(b)
+		} else {
+			fmt.Fprintf(&escaped, "%%%02X", b)
+		}
+	}
+
+	return escaped.String()
+}
+
+// Uploads reports as a Google Cloud Storage object through the JSON API.
+//
+//  1. Token: OAuth 2.0 access token allowed to create objects.
+//  2. Endpoint: Storage API root, https://storage.googleapis.com when empty.
+type GCSWriter struct {
+	Client   *http.Client
+	Bucket   string
+	Object   string
+	Token    string
+	Endpoint string
+}
+
+func (gw *GCSWriter) WriteReport(report []byte) error {
+	endpoint := gw.Endpoint
+	if len(endpoint) == 0 {
+		endpoint = "https://storage.googleapis.com"
+	}
+
+	query := url.Values{"uploadType": {"media"}, "name": {gw.Object}}
+	link := strings.TrimSuffix(endpoint, "/") + "/upload/storage/v1/b/" + url.PathEscape(gw.Bucket) + "/o?" + query.Encode()
+	req, err := http.NewRequest("POST", link, bytes.NewReader(report))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+gw.Token)
+
+	return upload(gw.Client, req)
+}
+
+func upload(client *http.Client, req *http.Request) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s %s returned %s: %s", req.Method, req.URL, resp.Status, bytes.TrimSpace(body))
+	}
+
+	return nil
+}
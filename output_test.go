@@ -0,0 +1,137 @@
+package wikicrawl
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestNewReportWriter(t *testing.T) {
+	t.Run("Select writer by scheme", func(t *testing.T) {
+		t.Parallel()
+		expected := map[string]ReportWriter{
+			"report.json":               &FileWriter{Path: "report.json"},
+			"file:///tmp/report.json":   &FileWriter{Path: "/tmp/report.json"},
+			`C:\reports\report.json`:    &FileWriter{Path: `C:\reports\report.json`},
+			"s3://reports/wiki/a.json":  nil,
+			"gs://reports/wiki/a.json":  nil,
+			"stdout:":                   nil,
+			"ftp://reports/wiki/a.json": nil,
+		}
+		for target, want := range expected {
+			writer, err := NewReportWriter(target)
+			if want != nil && !reflect.DeepEqual(writer, want) {
+				t.Errorf("Writer mismatch for %s, got: %#v, want: %#v.", target, writer, want)
+			}
+			if (err != nil) != strings.HasPrefix(target, "ftp:") {
+				t.Errorf("Error mismatch for %s, got: %v.", target, err)
+			}
+		}
+
+		if s3, ok := mustWriter(t, "s3://reports/wiki/a.json").(*S3Writer); !ok || s3.Bucket != "reports" || s3.Key != "wiki/a.json" {
+			t.Errorf("S3 writer mismatch, got: %#v.", s3)
+		}
+		if gcs, ok := mustWriter(t, "gs://reports/wiki/a.json").(*GCSWriter); !ok || gcs.Bucket != "reports" || gcs.Object != "wiki/a.json" {
+			t.Errorf("GCS writer mismatch, got: %#v.", gcs)
+		}
+	})
+}
+
+func mustWriter(t *testing.T, target string) ReportWriter {
+	writer, err := NewReportWriter(target)
+	if err != nil {
+		t.Fatalf("Creating writer for %s failed: %s.", target, err)
+	}
+	return writer
+}
+
+func TestReportWriters(t *testing.T) {
+	t.Run("Write reports", func(t *testing.T) {
+		t.Run("Stream", func(t *testing.T) {
+			t.Parallel()
+			var out bytes.Buffer
+			if err := (&StreamWriter{Writer: &out}).WriteReport([]byte(`{}`)); err != nil || out.String() != `{}` {
+				t.Errorf("Streamed report mismatch, got: %s, err: %v.", out.String(), err)
+			}
+		})
+
+		t.Run("File", func(t *testing.T) {
+			t.Parallel()
+			path := filepath.Join(t.TempDir(), "report.json")
+			if err := (&FileWriter{Path: path}).WriteReport([]byte(`{}`)); err != nil {
+				t.Fatalf("Writing failed: %s.", err)
+			}
+
+			if written, _ := ioutil.ReadFile(path); string(written) != `{}` {
+				t.Errorf("Written report mismatch, got: %s, want: %s.", written, `{}`)
+			}
+		})
+
+		t.Run("S3 upload signed with SigV4", func(t *testing.T) {
+			t.Parallel()
+			var method, path, auth, hash string
+			var body []byte
+			server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				method, path, auth, hash = req.Method, req.URL.EscapedPath(), req.Header.Get("Authorization"), req.Header.Get("X-Amz-Content-Sha256")
+				body, _ = ioutil.ReadAll(req.Body)
+			}))
+			defer server.Close()
+
+			writer := &S3Writer{Client: server.Client(), Bucket: "reports", Key: "wiki/latest run.json", Endpoint: server.URL,
+				Region: "eu-west-1", AccessKey: "AKID", SecretKey: "secret"}
+			if err := writer.WriteReport([]byte(`{}`)); err != nil {
+				t.Fatalf("Upload failed: %s.", err)
+			}
+
+			if method != "PUT" || path != "/reports/wiki/latest%20run.json" || string(body) != `{}` {
+				t.Errorf("Upload mismatch, got: %s %s %s.", method, path, body)
+			}
+			if !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=AKID/") || !strings.Contains(auth, "/eu-west-1/s3/aws4_request, SignedHeaders=host;x-amz-content-sha256;x-amz-date, Signature=") {
+				t.Errorf("Authorization mismatch, got: %s.", auth)
+			}
+			if hash != "44136fa355b3678a1146ad16f7e8649e94fb4fc21fe77e8310c060f61caaff8a" {
+				t.Errorf("Payload hash mismatch, got: %s.", hash)
+			}
+		})
+
+		t.Run("GCS upload", func(t *testing.T) {
+			t.Parallel()
+			var query, auth string
+			server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				if req.URL.Path != "/upload/storage/v1/b/reports/o" {
+					rw.WriteHeader(http.StatusNotFound)
+					return
+				}
+				query, auth = req.URL.RawQuery, req.Header.Get("Authorization")
+			}))
+			defer server.Close()
+
+			writer := &GCSWriter{Client: server.Client(), Bucket: "reports", Object: "wiki/a.json", Token: "token", Endpoint: server.URL}
+			if err := writer.WriteReport([]byte(`{}`)); err != nil {
+				t.Fatalf("Upload failed: %s.", err)
+			}
+
+			if query != "name=wiki%2Fa.json&uploadType=media" || auth != "Bearer token" {
+				t.Errorf("Upload mismatch, got: %s %s.", query, auth)
+			}
+		})
+
+		t.Run("Failed upload", func(t *testing.T) {
+			t.Parallel()
+			server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				http.Error(rw, "AccessDenied", http.StatusForbidden)
+			}))
+			defer server.Close()
+
+			writer := &GCSWriter{Client: server.Client(), Bucket: "reports", Object: "a.json", Endpoint: server.URL}
+			if err := writer.WriteReport([]byte(`{}`)); err == nil || !strings.Contains(err.Error(), "AccessDenied") {
+				t.Errorf("Upload error mismatch, got: %v.", err)
+			}
+		})
+	})
+}
@@ -0,0 +1,497 @@
+package wikicrawl
+
+import (
+	"context"
+	"io"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// Bundle of settings controlling how hard the crawler hits the wiki.
+//
+//  1. Workers: Number of pages fetched concurrently.
+//  2. RateLimit: Maximum requests per second, 0 for unlimited.
+//  3. Retries: Extra attempts for failed requests and 5xx responses.
+//  4. RetryBackoff: Pause before a retry, multiplied by the attempt.
+//  5. Delay: Pause of a worker after each request.
+//  6. MaxLatency: Average response time above which the crawler
+//     backs off, 0 to never adapt.
+type Profile struct {
+	Workers      int
+	RateLimit    float64
+	Retries      int
+	RetryBackoff time.Duration
+	Delay        time.Duration
+	MaxLatency   time.Duration
+}
+
+// Named politeness presets selectable from the command line.
+var Profiles = map[string]Profile{
+	"aggressive": {
+		Workers:      32,
+		Retries:      1,
+		RetryBackoff: 500 * time.Millisecond,
+	},
+	"default": {
+		Workers:      10,
+		RetryBackoff: time.Second,
+	},
+	"gentle": {
+		Workers:      2,
+		RateLimit:    2,
+		Retries:      3,
+		RetryBackoff: 5 * time.Second,
+		Delay:        500 * time.Millisecond,
+		MaxLatency:   2 * time.Second,
+	},
+}
+
+// Replaces all politeness settings with those of the profile.
+func (c *Crawler) ApplyProfile(profile Profile) {
+	c.Workers = profile.Workers
+	c.RateLimit = profile.RateLimit
+	c.Retries = profile.Retries
+	c.RetryBackoff = profile.RetryBackoff
+	c.Delay = profile.Delay
+	c.MaxLatency = profile.MaxLatency
+}
+
+// Fetches a page honoring the rate limit, retries and delay settings.
+func (c *Crawler) politeFetch(ctx context.Context, link Link) (*Page, error) {
+	var page *Page
+	var err error
+
+	for attempt := 0; attempt <= c.Retries; attempt++ {
+		if attempt > 0 {
+			c.logger().WithFields(log.Fields{
+				"source":  link,
+				"attempt": attempt,
+			}).Info("Retrying request")
+			time.Sleep(c.RetryBackoff * time.Duration(attempt))
+		}
+
+		c.limiter.Wait()
+		c.ramp.Acquire()
+		c.throttle.Acquire()
+		started := time.Now()
+		if fetcher, ok := c.fetcher().(ContextFetcher); ok {
+			page, err = fetcher.FetchContext(ctx, link)
+		} else {
+			page, err = c.fetcher().Fetch(link)
+		}
+		failed := err != nil || page.StatusCode >= 500 || page.StatusCode == 429
+		if stage, ended := c.ramp.Release(time.Since(started), failed); ended {
+			entry := c.logger().WithFields(log.Fields{
+				"latency":   stage.Latency,
+				"errorRate": stage.ErrorRate,
+				"workers":   stage.Workers,
+			})
+			if stage.ErrorRate > maxWarmUpErrors {
+				entry.Warn("Errors during warm-up, holding concurrency")
+			} else {
+				entry.Info("Warm-up stage done, raising concurrency")
+			}
+		}
+		if state, changed := c.throttle.Release(time.Since(started)); changed {
+			c.logger().WithFields(log.Fields{
+				"latency": state.Latency,
+				"delay":   state.Delay,
+				"workers": state.Workers,
+			}).Warn("Response time changed, adapting request pace")
+		}
+		if pause := c.Delay + c.throttle.Delay() + c.jitter(); pause > 0 {
+			time.Sleep(pause)
+		}
+
+		if err == nil && page.StatusCode < 500 && page.StatusCode != 429 {
+			break
+		}
+	}
+
+	return page, err
+}
+
+// Random extra pause up to Jitter, none in deterministic mode.
+func (c *Crawler) jitter() time.Duration {
+	if c.Jitter <= 0 || c.Deterministic {
+		return 0
+	}
+
+	return time.Duration(rand.Int63n(int64(c.Jitter)))
+}
+
+// Spaces requests evenly to stay under a requests per second limit.
+type rateLimiter struct {
+	sync.Mutex
+
+	interval time.Duration
+	next     time.Time
+}
+
+// Builds a limiter, nil (never waiting) when perSecond is not positive.
+func newRateLimiter(perSecond float64) *rateLimiter {
+	if perSecond <= 0 {
+		return nil
+	}
+
+	return &rateLimiter{interval: time.Duration(float64(time.Second) / perSecond)}
+}
+
+// Blocks until the next request is allowed.
+func (rl *rateLimiter) Wait() {
+	rl.WaitN(1)
+}
+
+// Blocks until n more units (requests or bytes) are allowed.
+func (rl *rateLimiter) WaitN(n int) {
+	if rl == nil || n <= 0 {
+		return
+	}
+
+	rl.Lock()
+	now := time.Now()
+	if rl.next.Before(now) {
+		rl.next = now
+	}
+	wait := rl.next.Sub(now)
+	rl.next = rl.next.Add(rl.interval * time.Duration(n))
+	rl.Unlock()
+
+	time.Sleep(wait)
+}
+
+// Pace of an adaptive throttle.
+//
+//  1. Latency: Moving average of the response time.
+//  2. Delay: Extra pause of a worker after each request.
+//  3. Workers: Requests allowed at the same time.
+type ThrottleState struct {
+	Latency time.Duration
+	Delay   time.Duration
+	Workers int
+}
+
+// Extra delays of a throttle backing off, doubling from the minimum.
+const (
+	minThrottleDelay = 100 * time.Millisecond
+	maxThrottleDelay = 30 * time.Second
+)
+
+// Slows the crawler down when the wiki slows down. Every round of
+// responses (one per allowed worker) the average response time is
+// checked: above the threshold the extra delay doubles and a worker is
+// parked, below half of it both recover a step.
+type adaptiveThrottle struct {
+	sync.Mutex
+
+	wake      *sync.Cond
+	threshold time.Duration
+	workers   int
+	active    int
+	samples   int
+	state     ThrottleState
+}
+
+// Builds a throttle, nil (never adapting) when threshold is not positive.
+func newAdaptiveThrottle(threshold time.Duration, workers int) *adaptiveThrottle {
+	if threshold <= 0 {
+		return nil
+	}
+	if workers < 1 {
+		workers = 1
+	}
+
+	at := &adaptiveThrottle{threshold: threshold, workers: workers, state: ThrottleState{Workers: workers}}
+	at.wake = sync.NewCond(at)
+	return at
+}
+
+// Blocks until fewer requests than the allowed workers are running.
+func (at *adaptiveThrottle) Acquire() {
+	if at == nil {
+		return
+	}
+
+	at.Lock()
+	defer at.Unlock()
+	for at.active >= at.state.Workers {
+		at.wake.Wait()
+	}
+	at.active++
+}
+
+// Records the response time of a finished request, returning the new
+// pace when it changed.
+func (at *adaptiveThrottle) Release(elapsed time.Duration) (ThrottleState, bool) {
+	if at == nil {
+		return ThrottleState{}, false
+	}
+
+	at.Lock()
+	defer at.Unlock()
+	defer at.wake.Broadcast()
+
+	at.active--
+	if at.state.Latency == 0 {
+		at.state.Latency = elapsed
+	} else {
+		at.state.Latency = (4*at.state.Latency + elapsed) / 5
+	}
+
+	at.samples++
+	if at.samples < at.state.Workers {
+		return at.state, false
+	}
+	at.samples = 0
+
+	switch {
+	case at.state.Latency > at.threshold:
+		at.state.Delay *= 2
+		if at.state.Delay < minThrottleDelay {
+			at.state.Delay = minThrottleDelay
+		}
+		if at.state.Delay > maxThrottleDelay {
+			at.state.Delay = maxThrottleDelay
+		}
+		if at.state.Workers > 1 {
+			at.state.Workers--
+		}
+		return at.state, true
+	case at.state.Latency < at.threshold/2 && (at.state.Delay > 0 || at.state.Workers < at.workers):
+		at.state.Delay /= 2
+		if at.state.Delay < minThrottleDelay {
+			at.state.Delay = 0
+		}
+		if at.state.Workers < at.workers {
+			at.state.Workers++
+		}
+		return at.state, true
+	default:
+		return at.state, false
+	}
+}
+
+// Extra pause after each request.
+func (at *adaptiveThrottle) Delay() time.Duration {
+	if at == nil {
+		return 0
+	}
+
+	at.Lock()
+	defer at.Unlock()
+	return at.state.Delay
+}
+
+// Share of failed requests of a warm-up stage above which concurrency is
+// held instead of raised.
+const maxWarmUpErrors = 0.1
+
+// Outcome of a warm-up stage.
+//
+//  1. Workers: Requests allowed at the same time from now on.
+//  2. Latency: Average response time of the stage.
+//  3. ErrorRate: Share of failed requests (errors, 429 and 5xx) of the stage.
+type WarmUpStage struct {
+	Workers   int
+	Latency   time.Duration
+	ErrorRate float64
+}
+
+// Ramps concurrency up at the start of a crawl so cold wiki caches warm up
+// before the full load hits them: one request at a time for the first
+// stage of pages, then the allowed requests double after every stage
+// unless its error rate is too high.
+type rampUp struct {
+	sync.Mutex
+
+	wake    *sync.Cond
+	pages   int
+	workers int
+	allowed int
+	active  int
+	done    int
+	failed  int
+	elapsed time.Duration
+}
+
+// Builds a warm-up of stages of pages, nil (running every worker at once)
+// when pages is not positive or a single worker runs anyway.
+func newRampUp(pages, workers int) *rampUp {
+	if pages <= 0 || workers <= 1 {
+		return nil
+	}
+
+	ru := &rampUp{pages: pages, workers: workers, allowed: 1}
+	ru.wake = sync.NewCond(ru)
+	return ru
+}
+
+// Blocks until fewer requests than allowed so far are running.
+func (ru *rampUp) Acquire() {
+	if ru == nil {
+		return
+	}
+
+	ru.Lock()
+	defer ru.Unlock()
+	for ru.active >= ru.allowed {
+		ru.wake.Wait()
+	}
+	ru.active++
+}
+
+// Records a finished request, returning the outcome of the stage when it
+// ended it.
+func (ru *rampUp) Release(elapsed time.Duration, failed bool) (WarmUpStage, bool) {
+	if ru == nil {
+		return WarmUpStage{}, false
+	}
+
+	ru.Lock()
+	defer ru.Unlock()
+	defer ru.wake.Broadcast()
+
+	ru.active--
+	if ru.allowed >= ru.workers {
+		return WarmUpStage{}, false
+	}
+
+	ru.done++
+	ru.elapsed += elapsed
+	if failed {
+		ru.failed++
+	}
+	if ru.done < ru.pages {
+		return WarmUpStage{}, false
+	}
+
+	stage := WarmUpStage{
+		Latency:   ru.elapsed / time.Duration(ru.done),
+		ErrorRate: float64(ru.failed) / float64(ru.done),
+	}
+	if stage.ErrorRate <= maxWarmUpErrors {
+		ru.allowed *= 2
+		if ru.allowed > ru.workers {
+			ru.allowed = ru.workers
+		}
+	}
+	stage.Workers = ru.allowed
+	ru.done, ru.failed, ru.elapsed = 0, 0, 0
+
+	return stage, true
+}
+
+// Middleware limiting the combined download speed of every response body,
+// independent of the request rate.
+func ThrottleBandwidth(bytesPerSecond float64) Middleware {
+	limiter := newRateLimiter(bytesPerSecond)
+	return func(next http.RoundTripper) http.RoundTripper {
+		if limiter == nil {
+			return next
+		}
+
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			resp, err := next.RoundTrip(req)
+			if err == nil {
+				resp.Body = throttledBody{ReadCloser: resp.Body, limiter: limiter}
+			}
+			return resp, err
+		})
+	}
+}
+
+// Response body read no faster than its limiter allows.
+type throttledBody struct {
+	io.ReadCloser
+
+	limiter *rateLimiter
+}
+
+func (tb throttledBody) Read(p []byte) (int, error) {
+	n, err := tb.ReadCloser.Read(p)
+	tb.limiter.WaitN(n)
+	return n, err
+}
+
+// Middleware limiting the requests in flight, and so the connections open
+// at once, to max. A connection is held until the response body is closed.
+// Crawlers using the same middleware share the limit.
+func LimitConnections(max int) Middleware {
+	if max <= 0 {
+		return func(next http.RoundTripper) http.RoundTripper { return next }
+	}
+
+	slots := make(chan struct{}, max)
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			select {
+			case slots <- struct{}{}:
+			case <-req.Context().Done():
+				return nil, req.Context().Err()
+			}
+
+			release := new(sync.Once)
+			resp, err := next.RoundTrip(req)
+			if err != nil {
+				release.Do(func() { <-slots })
+				return resp, err
+			}
+			resp.Body = &connectionBody{ReadCloser: resp.Body, release: func() {
+				release.Do(func() { <-slots })
+			}}
+			return resp, err
+		})
+	}
+}
+
+// Response body giving its connection slot back once closed.
+type connectionBody struct {
+	io.ReadCloser
+
+	release func()
+}
+
+func (cb *connectionBody) Close() error {
+	defer cb.release()
+	return cb.ReadCloser.Close()
+}
+
+// File descriptors kept for logs, reports and stores, besides connections.
+const reservedFiles = 64
+
+// Workers the open file limit can hold, each needing up to two descriptors
+// (its page and an external link or API lookup). Unknown limits hold any
+// number of workers.
+func workersWithinFileLimit(workers int, limit uint64) int {
+	if limit == 0 || uint64(workers)*2+reservedFiles <= limit {
+		return workers
+	}
+	if limit <= reservedFiles+2 {
+		return 1
+	}
+
+	return int((limit - reservedFiles) / 2)
+}
+
+// Lowers Workers when the open file limit of the process cannot hold them,
+// running out of descriptors would surface as broken links.
+func (c *Crawler) limitWorkers() {
+	limit, err := fileLimit()
+	if err != nil {
+		c.logger().WithFields(log.Fields{"err": err}).Debug("Open file limit unknown")
+		return
+	}
+
+	if workers := workersWithinFileLimit(c.Workers, limit); workers < c.Workers {
+		c.logger().WithFields(log.Fields{
+			"workers": c.Workers,
+			"reduced": workers,
+			"limit":   limit,
+		}).Warn("Open file limit too low for the workers, reducing them")
+		c.Workers = workers
+	}
+}
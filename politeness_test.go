@@ -0,0 +1,344 @@
+package wikicrawl
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestProfiles(t *testing.T) {
+	t.Run("Politeness presets", func(t *testing.T) {
+		t.Run("New crawlers use default preset", func(t *testing.T) {
+			t.Parallel()
+			c := NewCrawler("http://testing.com")
+			if c.Workers != Profiles["default"].Workers || c.Retries != Profiles["default"].Retries {
+				t.Errorf("Default profile not applied, got: %d workers, %d retries.", c.Workers, c.Retries)
+			}
+		})
+
+		t.Run("Apply named preset", func(t *testing.T) {
+			t.Parallel()
+			c := NewCrawler("http://testing.com")
+			c.ApplyProfile(Profiles["gentle"])
+			if c.Workers != 2 || c.RateLimit != 2 || c.Delay != 500*time.Millisecond {
+				t.Errorf("Gentle profile not applied, got: %+v.", c)
+			}
+		})
+	})
+}
+
+func TestRetries(t *testing.T) {
+	t.Run("Retry failed requests", func(t *testing.T) {
+		t.Parallel()
+		requests := 0
+		server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			requests++
+			if requests < 3 {
+				rw.WriteHeader(503)
+				return
+			}
+			fmt.Fprintf(rw, `<html></html>`)
+		}))
+		defer server.Close()
+
+		c := NewCrawler(server.URL)
+		c.Retries = 2
+		c.RetryBackoff = time.Millisecond
+		result := c.Crawl(server.URL)
+
+		if requests != 3 || len(result.Broken.Set) != 0 {
+			t.Errorf("Retries mismatch, got: %d requests, broken: %v.", requests, result.Broken.Set)
+		}
+	})
+}
+
+func TestJitter(t *testing.T) {
+	t.Run("Random pause after requests", func(t *testing.T) {
+		t.Run("Bounded by jitter", func(t *testing.T) {
+			t.Parallel()
+			c := NewCrawler("http://testing.com", WithJitter(10*time.Millisecond))
+			for i := 0; i < 100; i++ {
+				if pause := c.jitter(); pause < 0 || pause >= 10*time.Millisecond {
+					t.Fatalf("Jitter out of bounds, got: %s.", pause)
+				}
+			}
+		})
+
+		t.Run("Link order kept", func(t *testing.T) {
+			t.Parallel()
+			c := NewCrawler("http://testing.com", WithJitter(10*time.Millisecond))
+			if c.Shuffle {
+				t.Errorf("Jitter should not shuffle links, use WithShuffle.")
+			}
+		})
+
+		t.Run("Disabled in deterministic mode", func(t *testing.T) {
+			t.Parallel()
+			c := NewCrawler("http://testing.com", WithJitter(time.Second), WithDeterministic())
+			if pause := c.jitter(); pause != 0 {
+				t.Errorf("Jitter mismatch, got: %s, want: 0s.", pause)
+			}
+		})
+	})
+}
+
+func TestThrottleBandwidth(t *testing.T) {
+	t.Run("Limit download speed", func(t *testing.T) {
+		t.Parallel()
+		body := strings.Repeat("x", 5000)
+		server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			fmt.Fprint(rw, body)
+		}))
+		defer server.Close()
+
+		client := &http.Client{Transport: Chain(nil, ThrottleBandwidth(50000))}
+		start := time.Now()
+		for i := 0; i < 3; i++ {
+			resp, err := client.Get(server.URL)
+			if err != nil {
+				t.Fatalf("Request failed: %s.", err)
+			}
+			ioutil.ReadAll(resp.Body)
+			resp.Body.Close()
+		}
+
+		if elapsed := time.Since(start); elapsed < 150*time.Millisecond {
+			t.Errorf("Bandwidth limit not enforced, 15000 bytes took %s.", elapsed)
+		}
+	})
+}
+
+func TestLimitConnections(t *testing.T) {
+	t.Run("Limit open connections", func(t *testing.T) {
+		t.Parallel()
+		var open, most int32
+		server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			current := atomic.AddInt32(&open, 1)
+			defer atomic.AddInt32(&open, -1)
+			for {
+				seen := atomic.LoadInt32(&most)
+				if current <= seen || atomic.CompareAndSwapInt32(&most, seen, current) {
+					break
+				}
+			}
+			time.Sleep(20 * time.Millisecond)
+		}))
+		defer server.Close()
+
+		client := &http.Client{Transport: Chain(nil, LimitConnections(2))}
+		var wg sync.WaitGroup
+		for i := 0; i < 6; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				resp, err := client.Get(server.URL)
+				if err != nil {
+					t.Errorf("Request failed: %s.", err)
+					return
+				}
+				resp.Body.Close()
+			}()
+		}
+		wg.Wait()
+
+		if got := atomic.LoadInt32(&most); got != 2 {
+			t.Errorf("Open connections mismatch, got: %d, want: 2.", got)
+		}
+	})
+}
+
+func TestWorkersWithinFileLimit(t *testing.T) {
+	t.Run("Reduce workers to the file limit", func(t *testing.T) {
+		t.Run("Enough files", func(t *testing.T) {
+			t.Parallel()
+			if workers := workersWithinFileLimit(50, 1024); workers != 50 {
+				t.Errorf("Workers mismatch, got: %d, want: 50.", workers)
+			}
+		})
+
+		t.Run("Too few files", func(t *testing.T) {
+			t.Parallel()
+			if workers := workersWithinFileLimit(500, 256); workers != 96 {
+				t.Errorf("Workers mismatch, got: %d, want: 96.", workers)
+			}
+			if workers := workersWithinFileLimit(10, 32); workers != 1 {
+				t.Errorf("Workers mismatch, got: %d, want: 1.", workers)
+			}
+		})
+
+		t.Run("Unknown limit", func(t *testing.T) {
+			t.Parallel()
+			if workers := workersWithinFileLimit(500, 0); workers != 500 {
+				t.Errorf("Workers mismatch, got: %d, want: 500.", workers)
+			}
+		})
+	})
+}
+
+func TestRateLimiter(t *testing.T) {
+	t.Run("Space requests evenly", func(t *testing.T) {
+		t.Parallel()
+		limiter := newRateLimiter(100)
+		start := time.Now()
+		for i := 0; i < 5; i++ {
+			limiter.Wait()
+		}
+
+		if elapsed := time.Since(start); elapsed < 40*time.Millisecond {
+			t.Errorf("Rate limit not enforced, 5 requests took %s.", elapsed)
+		}
+	})
+
+	t.Run("Unlimited rate never waits", func(t *testing.T) {
+		t.Parallel()
+		limiter := newRateLimiter(0)
+		start := time.Now()
+		for i := 0; i < 1000; i++ {
+			limiter.Wait()
+		}
+
+		if elapsed := time.Since(start); elapsed > 10*time.Millisecond {
+			t.Errorf("Unlimited rate should not wait, took %s.", elapsed)
+		}
+	})
+}
+
+func TestAdaptiveThrottle(t *testing.T) {
+	t.Run("Adapt to response times", func(t *testing.T) {
+		t.Run("Back off when slow", func(t *testing.T) {
+			t.Parallel()
+			throttle := newAdaptiveThrottle(100*time.Millisecond, 2)
+			for i := 0; i < 2; i++ {
+				throttle.Acquire()
+			}
+			throttle.Release(300 * time.Millisecond)
+			state, changed := throttle.Release(300 * time.Millisecond)
+			if !changed || state.Workers != 1 || state.Delay != minThrottleDelay {
+				t.Errorf("State mismatch, got: %+v, want: 1 worker and %s delay.", state, minThrottleDelay)
+			}
+
+			state, changed = throttle.Release(300 * time.Millisecond)
+			if !changed || state.Workers != 1 || state.Delay != 2*minThrottleDelay {
+				t.Errorf("State mismatch, got: %+v, want: 1 worker and %s delay.", state, 2*minThrottleDelay)
+			}
+			if throttle.Delay() != 2*minThrottleDelay {
+				t.Errorf("Delay mismatch, got: %s, want: %s.", throttle.Delay(), 2*minThrottleDelay)
+			}
+		})
+
+		t.Run("Recover when fast again", func(t *testing.T) {
+			t.Parallel()
+			throttle := newAdaptiveThrottle(100*time.Millisecond, 2)
+			for i := 0; i < 2; i++ {
+				throttle.Acquire()
+				throttle.Release(time.Second)
+			}
+
+			var state ThrottleState
+			for i := 0; i < 50; i++ {
+				throttle.Acquire()
+				state, _ = throttle.Release(time.Millisecond)
+			}
+			if state.Workers != 2 || state.Delay != 0 {
+				t.Errorf("State mismatch, got: %+v, want: 2 workers and no delay.", state)
+			}
+		})
+
+		t.Run("Limit concurrent requests", func(t *testing.T) {
+			t.Parallel()
+			throttle := newAdaptiveThrottle(time.Millisecond, 1)
+			throttle.Acquire()
+
+			acquired := make(chan struct{})
+			go func() {
+				throttle.Acquire()
+				close(acquired)
+			}()
+
+			select {
+			case <-acquired:
+				t.Fatalf("Second request should wait for the first.")
+			case <-time.After(20 * time.Millisecond):
+			}
+
+			throttle.Release(0)
+			select {
+			case <-acquired:
+			case <-time.After(time.Second):
+				t.Errorf("Second request should run once the first is done.")
+			}
+		})
+
+		t.Run("Disabled without threshold", func(t *testing.T) {
+			t.Parallel()
+			throttle := newAdaptiveThrottle(0, 10)
+			throttle.Acquire()
+			if _, changed := throttle.Release(time.Hour); changed || throttle.Delay() != 0 {
+				t.Errorf("Disabled throttle should never adapt.")
+			}
+		})
+	})
+}
+
+func TestRampUp(t *testing.T) {
+	t.Run("Warm up before running every worker", func(t *testing.T) {
+		t.Run("Double workers every stage", func(t *testing.T) {
+			t.Parallel()
+			ramp := newRampUp(2, 5)
+			var stages []int
+			for i := 0; i < 10; i++ {
+				ramp.Acquire()
+				if stage, ended := ramp.Release(10*time.Millisecond, false); ended {
+					stages = append(stages, stage.Workers)
+				}
+			}
+			if fmt.Sprint(stages) != "[2 4 5]" {
+				t.Errorf("Stages mismatch, got: %v, want: [2 4 5].", stages)
+			}
+		})
+
+		t.Run("Hold workers on errors", func(t *testing.T) {
+			t.Parallel()
+			ramp := newRampUp(2, 5)
+			ramp.Acquire()
+			ramp.Release(time.Millisecond, true)
+			ramp.Acquire()
+			stage, ended := ramp.Release(3*time.Millisecond, false)
+			if !ended || stage.Workers != 1 || stage.ErrorRate != 0.5 || stage.Latency != 2*time.Millisecond {
+				t.Errorf("Stage mismatch, got: %+v, want: 1 worker at 50%% errors.", stage)
+			}
+		})
+
+		t.Run("Limit concurrent requests", func(t *testing.T) {
+			t.Parallel()
+			ramp := newRampUp(10, 4)
+			ramp.Acquire()
+			acquired := make(chan struct{})
+			go func() {
+				ramp.Acquire()
+				close(acquired)
+			}()
+
+			select {
+			case <-acquired:
+				t.Errorf("Second request should wait for the first during warm-up.")
+			case <-time.After(20 * time.Millisecond):
+			}
+			ramp.Release(time.Millisecond, false)
+			<-acquired
+		})
+
+		t.Run("Disabled without stages", func(t *testing.T) {
+			t.Parallel()
+			if ramp := newRampUp(0, 10); ramp != nil {
+				t.Errorf("Warm-up mismatch, got: %+v, want: nil.", ramp)
+			}
+		})
+	})
+}
@@ -0,0 +1,83 @@
+package postgres
+
+import (
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"jalandis.com/wikicrawl"
+)
+
+// Opens the database of WIKICRAWL_POSTGRES_URL, skipping without one.
+func requireDatabase(t *testing.T) *Store {
+	dsn := os.Getenv("WIKICRAWL_POSTGRES_URL")
+	if len(dsn) == 0 {
+		t.Skip("WIKICRAWL_POSTGRES_URL not set.")
+	}
+
+	store, err := Open(dsn)
+	if err != nil {
+		t.Fatalf("Open failed: %s.", err)
+	}
+	return store
+}
+
+func testResult(started time.Time) *wikicrawl.CrawlResult {
+	result := wikicrawl.NewCrawlResult()
+	result.Metadata = wikicrawl.Metadata{Tool: "wikicrawl", Seed: "http://testing.com/", Started: started}
+	for _, link := range []string{"http://testing.com/", "http://testing.com/a", "http://testing.com/gone"} {
+		result.Visited.Add(wikicrawl.NewLink(link))
+	}
+	result.Broken.Add(wikicrawl.NewLink("http://testing.com/gone"))
+	result.Pages.Put(wikicrawl.PageInfo{Link: wikicrawl.NewLink("http://testing.com/a"), Status: 200, Referrers: []string{"http://testing.com/"}})
+	result.Pages.Put(wikicrawl.PageInfo{Link: wikicrawl.NewLink("http://testing.com/gone"), Status: 404, Referrers: []string{"http://testing.com/", "http://testing.com/a"}})
+	return result
+}
+
+func TestStore(t *testing.T) {
+	t.Run("Save, load and query runs", func(t *testing.T) {
+		store := requireDatabase(t)
+		defer store.Close()
+
+		run := "test-" + time.Now().Format("20060102T150405.000000000")
+		saved := testResult(time.Now().UTC().Truncate(time.Millisecond))
+		if err := store.SaveRun(run, saved); err != nil {
+			t.Fatalf("Save failed: %s.", err)
+		}
+		// Saving again replaces the run.
+		if err := store.SaveRun(run, saved); err != nil {
+			t.Fatalf("Save failed: %s.", err)
+		}
+		defer store.db.Exec(`DELETE FROM wikicrawl_runs WHERE id = $1`, run)
+
+		loaded, err := store.LoadRun(run)
+		if err != nil {
+			t.Fatalf("Load failed: %s.", err)
+		}
+		if !reflect.DeepEqual(loaded.Visited.Keys(), saved.Visited.Keys()) || !reflect.DeepEqual(loaded.Broken.Keys(), saved.Broken.Keys()) {
+			t.Errorf("Loaded sets mismatch, got: %v %v.", loaded.Visited.Keys(), loaded.Broken.Keys())
+		}
+		if info, _ := loaded.Pages.Get("http://testing.com/gone"); info.Status != 404 || len(info.Referrers) != 2 {
+			t.Errorf("Loaded page mismatch, got: %+v.", info)
+		}
+
+		edges, err := store.Edges(run, "http://testing.com/")
+		if expected := []string{"http://testing.com/a", "http://testing.com/gone"}; err != nil || !reflect.DeepEqual(edges, expected) {
+			t.Errorf("Edges mismatch, got: %v, want: %v, err: %v.", edges, expected, err)
+		}
+
+		result := store.Result(run)
+		if count, err := result.Count("visited"); err != nil || count != 3 {
+			t.Errorf("Count mismatch, got: %d, want: %d, err: %v.", count, 3, err)
+		}
+		page, err := result.VisitedPage(1, 1)
+		if expected := []string{"http://testing.com/a"}; err != nil || !reflect.DeepEqual(page, expected) {
+			t.Errorf("Page mismatch, got: %v, want: %v, err: %v.", page, expected, err)
+		}
+
+		if _, err := store.LoadRun(run + "-missing"); err == nil {
+			t.Errorf("Loading an unknown run should fail.")
+		}
+	})
+}
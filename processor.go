@@ -0,0 +1,112 @@
+package wikicrawl
+
+import (
+	"bytes"
+	"io"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+	"golang.org/x/net/html"
+)
+
+// Hook for inspecting the body of every successfully crawled page.
+// Returning true flags the page in the crawl results.
+type PageProcessor interface {
+	Process(page Link, body []byte) bool
+}
+
+// Pluggable checker run against the visible text of a page.
+// Returns a list of issues found, empty when the text is acceptable.
+type ContentChecker interface {
+	Check(text string) []string
+}
+
+// Default checker that never reports an issue.
+type NopChecker struct{}
+
+func (NopChecker) Check(text string) []string {
+	return nil
+}
+
+// Checker reporting any word found in a known list (e.g. common misspellings).
+type WordListChecker map[string]bool
+
+// Builds a case insensitive WordListChecker from a list of words.
+func NewWordListChecker(words []string) WordListChecker {
+	checker := make(WordListChecker, len(words))
+	for _, word := range words {
+		checker[strings.ToLower(word)] = true
+	}
+
+	return checker
+}
+
+func (wl WordListChecker) Check(text string) []string {
+	var issues []string
+	for _, word := range strings.Fields(text) {
+		word = strings.ToLower(strings.Trim(word, ".,;:!?()[]\"'"))
+		if wl[word] {
+			issues = append(issues, word)
+		}
+	}
+
+	return issues
+}
+
+// Example processor running a ContentChecker over the visible text of pages.
+type ContentProcessor struct {
+	Checker ContentChecker
+}
+
+// Simple constructor for ContentProcessor, defaults to a no-op checker.
+func NewContentProcessor(checker ContentChecker) *ContentProcessor {
+	if checker == nil {
+		checker = NopChecker{}
+	}
+
+	return &ContentProcessor{Checker: checker}
+}
+
+func (cp *ContentProcessor) Process(page Link, body []byte) bool {
+	issues := cp.Checker.Check(VisibleText(bytes.NewReader(body)))
+	if len(issues) == 0 {
+		return false
+	}
+
+	log.WithFields(log.Fields{
+		"page":   page,
+		"issues": issues,
+	}).Warn("Content check failed.")
+
+	return true
+}
+
+// Extracts the text a reader would see, skipping scripts and styles.
+func VisibleText(reader io.Reader) string {
+	var text []string
+	skip := 0
+	z := html.NewTokenizer(reader)
+	for {
+		tokenType := z.Next()
+
+		switch tokenType {
+		case html.ErrorToken:
+			return strings.Join(text, " ")
+		case html.StartTagToken, html.EndTagToken:
+			name, _ := z.TagName()
+			if tag := string(name); tag == "script" || tag == "style" {
+				if tokenType == html.StartTagToken {
+					skip++
+				} else if skip > 0 {
+					skip--
+				}
+			}
+		case html.TextToken:
+			if skip == 0 {
+				if chunk := strings.TrimSpace(string(z.Text())); len(chunk) > 0 {
+					text = append(text, chunk)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package wikicrawl
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestVisibleText(t *testing.T) {
+	t.Run("Extract visible page text", func(t *testing.T) {
+		t.Run("Skip scripts and styles", func(t *testing.T) {
+			t.Parallel()
+			html := `<html><head><style>p {}</style><script>var x;</script></head>
+				<body><p>Hello</p> <a href="/path">world</a></body></html>`
+
+			found := VisibleText(strings.NewReader(html))
+			expected := "Hello world"
+			if found != expected {
+				t.Errorf("Visible text mismatch, got: %s, want: %s.", found, expected)
+			}
+		})
+	})
+}
+
+func TestContentProcessor(t *testing.T) {
+	t.Run("Content check processor", func(t *testing.T) {
+		t.Run("Default checker never flags", func(t *testing.T) {
+			t.Parallel()
+			p := NewContentProcessor(nil)
+			if p.Process(NewLink("http://testing.com"), []byte("<p>teh recieve</p>")) {
+				t.Errorf("No-op checker should not flag pages.")
+			}
+		})
+
+		t.Run("Word list checker flags known words", func(t *testing.T) {
+			t.Parallel()
+			p := NewContentProcessor(NewWordListChecker([]string{"Teh"}))
+			if !p.Process(NewLink("http://testing.com"), []byte("<p>See teh page.</p>")) {
+				t.Errorf("Page containing listed word should be flagged.")
+			}
+
+			if p.Process(NewLink("http://testing.com"), []byte("<p>See the page.</p>")) {
+				t.Errorf("Page without listed words should not be flagged.")
+			}
+		})
+
+		t.Run("Flagged pages reported in crawl results", func(t *testing.T) {
+			t.Parallel()
+			server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				if req.URL.Path == "/typo" {
+					fmt.Fprintf(rw, `<html><body>teh</body></html>`)
+					return
+				}
+
+				fmt.Fprintf(rw, `<html><body><a href="/typo">the</a></body></html>`)
+			}))
+			defer server.Close()
+
+			c := NewCrawler(server.URL)
+			c.Processors = append(c.Processors, NewContentProcessor(NewWordListChecker([]string{"teh"})))
+			result := c.Crawl(server.URL)
+
+			if len(result.Flagged.Set) != 1 || !result.Flagged.Contains(server.URL+"/typo") {
+				t.Errorf("Flagged pages mismatch, got: %v.", result.Flagged.Set)
+			}
+		})
+	})
+}
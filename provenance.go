@@ -0,0 +1,265 @@
+package wikicrawl
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/url"
+	"os/user"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// Version of the tool recorded in crawl metadata, set at build time with
+// -ldflags "-X jalandis.com/wikicrawl.Version=v1.2.3".
+var Version = "dev"
+
+// Provenance of a crawl result, included in every exported format.
+//
+//  1. Tool and Version: Software producing the result.
+//  2. Seed: Url the crawl started from.
+//  3. ConfigHash: Hash of the crawler settings affecting the result.
+//  4. Started and Finished: Time span of the crawl.
+//  5. User: Account running the crawl.
+//  6. Sample: Fraction of discovered links followed, 0 for full crawls.
+//  7. Labels: Arbitrary labels of the run (e.g. env=prod), for filtering
+//     runs downstream.
+//  8. Run: Id of the run, also logged with every crawl message.
+type Metadata struct {
+	Tool       string            `json:"tool"`
+	Version    string            `json:"version"`
+	Seed       string            `json:"seed"`
+	ConfigHash string            `json:"configHash"`
+	Started    time.Time         `json:"started"`
+	Finished   time.Time         `json:"finished,omitempty"`
+	User       string            `json:"user,omitempty"`
+	Sample     float64           `json:"sample,omitempty"`
+	Labels     map[string]string `json:"labels,omitempty"`
+	Run        string            `json:"run,omitempty"`
+}
+
+// Parses a label written as "<key>=<value>".
+func ParseLabel(raw string) (string, string, error) {
+	split := strings.Index(raw, "=")
+	if split <= 0 {
+		return "", "", fmt.Errorf("label %q is not <key>=<value>", raw)
+	}
+
+	return raw[:split], raw[split+1:], nil
+}
+
+// Sorted keys of labels.
+func labelKeys(labels map[string]string) []string {
+	keys := make([]string, 0, len(labels))
+	for key := range labels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+// Random id of a run not named by the caller.
+func newRunID() string {
+	id := make([]byte, 8)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
+
+// Fields identifying the run in log messages about its result.
+func (m Metadata) logFields() log.Fields {
+	fields := log.Fields{}
+	if len(m.Run) > 0 {
+		fields["run"] = m.Run
+	}
+	if seed, err := url.Parse(m.Seed); err == nil && len(seed.Host) > 0 {
+		fields["host"] = seed.Host
+	}
+
+	return fields
+}
+
+// Metadata spanning two crawls. Seeds are listed, the config hash is only
+// kept when both crawls were configured alike.
+func mergeMetadata(a, b Metadata) Metadata {
+	if len(a.Tool) == 0 {
+		return b
+	}
+	if len(b.Tool) == 0 {
+		return a
+	}
+
+	merged := a
+	if a.Seed != b.Seed {
+		merged.Seed = a.Seed + " " + b.Seed
+	}
+	if a.ConfigHash != b.ConfigHash {
+		merged.ConfigHash = ""
+	}
+	if b.Started.Before(a.Started) {
+		merged.Started = b.Started
+	}
+	if b.Finished.After(a.Finished) {
+		merged.Finished = b.Finished
+	}
+	if a.User != b.User {
+		merged.User = ""
+	}
+
+	return merged
+}
+
+// Metadata of a crawl starting now from seed.
+func (c *Crawler) metadata(seed string) Metadata {
+	metadata := Metadata{
+		Tool:       "wikicrawl",
+		Version:    Version,
+		Seed:       seed,
+		ConfigHash: c.ConfigHash(),
+		Started:    time.Now(),
+		Run:        c.RunID,
+	}
+	if c.Sample > 0 && c.Sample < 1 {
+		metadata.Sample = c.Sample
+	}
+	if len(c.Labels) > 0 {
+		metadata.Labels = make(map[string]string)
+		for key, value := range c.Labels {
+			metadata.Labels[key] = value
+		}
+	}
+
+	if current, err := user.Current(); err == nil {
+		metadata.User = current.Username
+	}
+
+	return metadata
+}
+
+// Hash of the settings affecting which links are crawled and reported, so
+// results of identically configured crawls can be recognized.
+func (c *Crawler) ConfigHash() string {
+	var assertions []string
+	for _, assertion := range c.Assertions {
+		assertions = append(assertions, assertion.URL.String()+"::"+assertion.Content.String())
+	}
+
+	var queryRules []string
+	for _, rule := range c.QueryRules {
+		queryRules = append(queryRules, rule.URL.String()+"::"+strings.Join(rule.Keep, ","))
+	}
+
+	var verifyOnly []string
+	for _, pattern := range c.VerifyOnly {
+		verifyOnly = append(verifyOnly, pattern.String())
+	}
+
+	var methods []string
+	for _, rule := range c.Methods {
+		methods = append(methods, rule.URL.String()+"::"+rule.Method)
+	}
+
+	var statusRules []string
+	for _, rule := range c.StatusRules {
+		statuses := make([]string, 0, len(rule.Statuses))
+		for _, status := range rule.Statuses {
+			statuses = append(statuses, strconv.Itoa(status))
+		}
+		statusRules = append(statusRules, rule.URL.String()+"::"+strings.Join(statuses, ","))
+	}
+
+	config, _ := json.Marshal(map[string]interface{}{
+		"base":          c.base.String(),
+		"checkExternal": c.CheckExternal,
+		"skipHosts":     c.SkipHosts,
+		"checkHosts":    c.CheckHosts,
+		"soft404":       c.Soft404 != nil,
+		"followFrames":  c.FollowFrames,
+		"extraAttrs":    c.ExtraAttrs,
+		"workers":       c.Workers,
+		"rateLimit":     c.RateLimit,
+		"retries":       c.Retries,
+		"delay":         c.Delay,
+		"assertions":    assertions,
+		"abortOnLogin":  c.AbortOnLogin,
+		"deterministic": c.Deterministic,
+		"namespaces":    c.InNamespaces,
+		"sameHost":      c.SameHost,
+		"pathPrefix":    c.PathPrefix,
+		"jitter":        c.Jitter,
+		"shuffle":       c.Shuffle,
+		"wikitext":      c.Wikitext,
+		"audit":         c.Audit,
+		"queryRules":    queryRules,
+		"verifyOnly":    verifyOnly,
+		"maxParseBytes": c.MaxParseBytes,
+		"sample":        c.Sample,
+		"titlePrefixes": c.TitlePrefixes,
+		"categories":    c.Categories,
+		"forms":         c.Forms,
+		"methods":       methods,
+		"minContent":    c.MinContent,
+		"styles":        c.Styles,
+		"scripts":       c.Scripts,
+		"statusRules":   statusRules,
+		"discover":      c.Discover,
+		"namespaceMap":  c.NamespaceMap,
+		"localize":      c.Localize,
+		"restricted":    c.Restricted,
+	})
+
+	sum := sha256.Sum256(config)
+	return hex.EncodeToString(sum[:])
+}
+
+// Loads an Ed25519 private key from a PEM encoded PKCS #8 file, as created
+// by "openssl genpkey -algorithm ed25519".
+func LoadSigningKey(data []byte) (ed25519.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	private, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, errors.New("signing key is not an Ed25519 key")
+	}
+
+	return private, nil
+}
+
+// Detached signature of a JSON report.
+type ReportSignature struct {
+	Algorithm string `json:"algorithm"`
+	PublicKey []byte `json:"publicKey"`
+	Signature []byte `json:"signature"`
+}
+
+// Signs the exact bytes of a report.
+func SignReport(report []byte, key ed25519.PrivateKey) ReportSignature {
+	return ReportSignature{
+		Algorithm: "ed25519",
+		PublicKey: key.Public().(ed25519.PublicKey),
+		Signature: ed25519.Sign(key, report),
+	}
+}
+
+// Reports if the signature of the report is valid for the trusted key.
+func VerifyReport(report []byte, signature ReportSignature, trusted ed25519.PublicKey) bool {
+	return signature.Algorithm == "ed25519" && ed25519.Verify(trusted, report, signature.Signature)
+}
@@ -0,0 +1,93 @@
+package wikicrawl
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMetadata(t *testing.T) {
+	t.Run("Crawl provenance", func(t *testing.T) {
+		t.Run("Recorded in JSON results", func(t *testing.T) {
+			t.Parallel()
+			server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				fmt.Fprint(rw, `<html></html>`)
+			}))
+			defer server.Close()
+
+			c := NewCrawler(server.URL)
+			result := c.Crawl(server.URL)
+			data, _ := json.Marshal(result)
+
+			var decoded struct {
+				Metadata Metadata `json:"metadata"`
+			}
+			json.Unmarshal(data, &decoded)
+			metadata := decoded.Metadata
+			if metadata.Seed != server.URL || metadata.ConfigHash != c.ConfigHash() || metadata.Version != Version {
+				t.Errorf("Metadata mismatch, got: %+v.", metadata)
+			}
+
+			if metadata.Finished.Before(metadata.Started) {
+				t.Errorf("Crawl finished before it started, got: %+v.", metadata)
+			}
+		})
+
+		t.Run("Labels", func(t *testing.T) {
+			t.Parallel()
+			key, value, err := ParseLabel("env=prod=1")
+			if err != nil || key != "env" || value != "prod=1" {
+				t.Errorf("Label mismatch, got: %s=%s, err: %v.", key, value, err)
+			}
+			if _, _, err := ParseLabel("=prod"); err == nil {
+				t.Errorf("Parsing a label without key should fail.")
+			}
+
+			c := NewCrawler("http://testing.com", WithLabel("env", "prod"), WithLabel("team", "docs"))
+			metadata := c.metadata("http://testing.com")
+			c.Labels["env"] = "staging"
+			if len(metadata.Labels) != 2 || metadata.Labels["env"] != "prod" || metadata.Labels["team"] != "docs" {
+				t.Errorf("Labels mismatch, got: %v, want: map[env:prod team:docs].", metadata.Labels)
+			}
+		})
+
+		t.Run("Configuration hash", func(t *testing.T) {
+			t.Parallel()
+			if NewCrawler("http://testing.com").ConfigHash() != NewCrawler("http://testing.com").ConfigHash() {
+				t.Errorf("Identical configurations should hash the same.")
+			}
+
+			if NewCrawler("http://testing.com").ConfigHash() == NewCrawler("http://testing.com", WithExternalLinks()).ConfigHash() {
+				t.Errorf("Different configurations should hash differently.")
+			}
+		})
+	})
+}
+
+func TestSignReport(t *testing.T) {
+	t.Run("Sign and verify reports", func(t *testing.T) {
+		t.Parallel()
+		public, private, _ := ed25519.GenerateKey(rand.Reader)
+		der, _ := x509.MarshalPKCS8PrivateKey(private)
+		key, err := LoadSigningKey(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+		if err != nil {
+			t.Fatalf("Loading signing key failed: %s.", err)
+		}
+
+		report := []byte(`{"broken":[]}`)
+		signature := SignReport(report, key)
+		if !VerifyReport(report, signature, public) {
+			t.Errorf("Signature should be valid.")
+		}
+
+		if VerifyReport([]byte(`{"broken":["x"]}`), signature, public) {
+			t.Errorf("Signature should not be valid for a modified report.")
+		}
+	})
+}
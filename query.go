@@ -0,0 +1,67 @@
+package wikicrawl
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// Query parameters significant for urls matching a pattern, the other
+// parameters are dropped when normalizing.
+type QueryRule struct {
+	URL  *regexp.Regexp
+	Keep []string
+}
+
+// Rules applied after the configured ones: wiki page urls only keep their
+// title. Urls matching no rule keep every parameter.
+var DefaultQueryRules = []QueryRule{
+	{URL: regexp.MustCompile(`[?&]title=`), Keep: []string{"title"}},
+}
+
+// Parses a rule written as "<url regexp>::<param>,<param>", an empty list
+// dropping every parameter.
+func ParseQueryRule(raw string) (QueryRule, error) {
+	split := strings.LastIndex(raw, "::")
+	if split < 0 {
+		return QueryRule{}, fmt.Errorf("query rule %q missing :: separator", raw)
+	}
+
+	link, err := regexp.Compile(raw[:split])
+	if err != nil {
+		return QueryRule{}, err
+	}
+
+	var keep []string
+	if params := raw[split+2:]; len(params) > 0 {
+		keep = strings.Split(params, ",")
+	}
+
+	return QueryRule{URL: link, Keep: keep}, nil
+}
+
+// Drops the parameters not kept by the first rule matching the url.
+func applyQueryRules(link *url.URL, rules []QueryRule) {
+	for _, rule := range rules {
+		if !rule.URL.MatchString(link.String()) {
+			continue
+		}
+
+		query, _ := url.ParseQuery(link.RawQuery)
+		kept := url.Values{}
+		for _, name := range rule.Keep {
+			if values, found := query[name]; found {
+				kept[name] = values
+			}
+		}
+		link.RawQuery = kept.Encode()
+		return
+	}
+}
+
+// Normalizes a link of the wiki with the configured query rules first.
+func (c *Crawler) normalize(link *url.URL) *url.URL {
+	rules := append(append([]QueryRule{}, c.QueryRules...), DefaultQueryRules...)
+	return NormalizeUrlRules(link, c.base, rules)
+}
@@ -0,0 +1,37 @@
+package wikicrawl
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseQueryRule(t *testing.T) {
+	t.Run("Parse query rules", func(t *testing.T) {
+		t.Run("Kept parameters", func(t *testing.T) {
+			t.Parallel()
+			rule, err := ParseQueryRule("title=Special:AllPages::title,from")
+			if err != nil {
+				t.Fatalf("Parsing failed: %s.", err)
+			}
+
+			if rule.URL.String() != "title=Special:AllPages" || !reflect.DeepEqual(rule.Keep, []string{"title", "from"}) {
+				t.Errorf("Rule mismatch, got: %v %v.", rule.URL, rule.Keep)
+			}
+		})
+
+		t.Run("Drop every parameter", func(t *testing.T) {
+			t.Parallel()
+			rule, err := ParseQueryRule("/static/::")
+			if err != nil || rule.Keep != nil {
+				t.Errorf("Rule mismatch, got: %v, err: %v.", rule.Keep, err)
+			}
+		})
+
+		t.Run("Missing separator", func(t *testing.T) {
+			t.Parallel()
+			if _, err := ParseQueryRule("title=Main"); err == nil {
+				t.Errorf("Rule without separator should fail.")
+			}
+		})
+	})
+}
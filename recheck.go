@@ -0,0 +1,81 @@
+package wikicrawl
+
+import (
+	"regexp"
+	"time"
+)
+
+// Attempts of every link of a recheck, broken links are often flaky.
+const recheckRetries = 2
+
+// Bytes of the error pages kept by a recheck unless configured.
+const recheckErrorBody = 1024
+
+// Verifies again only the links broken in a previous result, much faster
+// than crawling the whole wiki after a batch of fixes. Links are fetched
+// but not expanded, with retries and their error pages captured. Returns
+// the previous result updated, the previous result is left untouched.
+// Links the recheck did not get to stay broken and mark it aborted.
+func (c *Crawler) Recheck(previous *CrawlResult) *CrawlResult {
+	recheck := *c
+	recheck.CheckExternal = true
+	recheck.VerifyOnly = []*regexp.Regexp{regexp.MustCompile("")}
+	recheck.Previous = nil
+	if recheck.Retries < recheckRetries {
+		recheck.Retries = recheckRetries
+	}
+	if recheck.ErrorBody == 0 {
+		recheck.ErrorBody = recheckErrorBody
+	}
+
+	result := NewCrawlResult()
+	result.merge(previous)
+	result.Metadata = previous.Metadata
+	result.Aborted = false
+
+	broken := previous.Broken.Values()
+	for _, link := range broken {
+		key := link.String()
+		result.Broken.Remove(key)
+		result.Visited.Remove(key)
+		result.Errors.Remove(key)
+		result.record(link, func(info *PageInfo) {
+			info.Status, info.ErrorBody = 0, ""
+		})
+	}
+
+	queue := recheck.start(result)
+	for _, link := range broken {
+		queue.AddWork(link)
+	}
+	queue.Wait()
+
+	for _, link := range broken {
+		key := link.String()
+		info, _ := result.Pages.Get(key)
+		switch {
+		case info.Status == 0 && result.Err(key) == nil:
+			// Never fetched again, e.g. the recheck was aborted, the link
+			// is as broken as it was and the result incomplete.
+			result.Aborted = true
+			result.Broken.Add(link)
+			if previous.Visited.Contains(key) {
+				result.Visited.Add(link)
+			}
+			if err, found := previous.Errors.Get(key); found {
+				result.Errors.Put(err)
+			}
+			if info, found := previous.Pages.Get(key); found {
+				result.Pages.Put(info)
+			}
+		case !result.Broken.Contains(key):
+			// Fixed links are no longer broken since any date.
+			result.LongBroken.Remove(key)
+			result.record(link, func(info *PageInfo) {
+				info.BrokenSince = time.Time{}
+			})
+		}
+	}
+
+	return result
+}
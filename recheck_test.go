@@ -0,0 +1,89 @@
+package wikicrawl
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRecheck(t *testing.T) {
+	t.Run("Verify again the broken links only", func(t *testing.T) {
+		t.Parallel()
+		var fixed atomic.Bool
+		var requests atomic.Int32
+		server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			requests.Add(1)
+			switch {
+			case req.URL.Path == "/":
+				fmt.Fprint(rw, `<html><a href="/a">a</a><a href="/fixed">fixed</a><a href="/gone">gone</a></html>`)
+			case req.URL.Path == "/a":
+				fmt.Fprint(rw, `<html></html>`)
+			case req.URL.Path == "/fixed" && fixed.Load():
+				fmt.Fprint(rw, `<html><a href="/new">new</a></html>`)
+			default:
+				http.Error(rw, "No such page", http.StatusNotFound)
+			}
+		}))
+		defer server.Close()
+
+		c := NewCrawler(server.URL)
+		previous := c.Crawl(server.URL + "/")
+		fixed.Store(true)
+		requests.Store(0)
+
+		result := c.Recheck(previous)
+		if got, want := result.Broken.Keys(), []string{server.URL + "/gone"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("Broken mismatch, got: %v, want: %v.", got, want)
+		}
+		// A 404 is final, only failures and 5xx responses are retried.
+		if got, want := requests.Load(), int32(2); got != want {
+			t.Errorf("Requests mismatch, got: %d, want: %d.", got, want)
+		}
+		if result.Visited.Contains(server.URL + "/new") {
+			t.Errorf("Fixed pages should not be expanded, got: %v.", result.Visited.Keys())
+		}
+		if info, _ := result.Pages.Get(server.URL + "/fixed"); info.Status != 200 || result.Err(info.Link.String()) != nil {
+			t.Errorf("Fixed page mismatch, got: %v.", info)
+		}
+		if info, _ := result.Pages.Get(server.URL + "/gone"); info.ErrorBody != "No such page\n" {
+			t.Errorf("Error body mismatch, got: %q, want: %q.", info.ErrorBody, "No such page\n")
+		}
+		if !previous.Broken.Contains(server.URL + "/fixed") {
+			t.Errorf("Previous result should be left untouched, got: %v.", previous.Broken.Keys())
+		}
+	})
+	t.Run("Links not checked again stay broken", func(t *testing.T) {
+		t.Parallel()
+		var stuck atomic.Bool
+		release := make(chan struct{})
+		server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			switch {
+			case req.URL.Path == "/":
+				fmt.Fprint(rw, `<html><a href="/stuck">stuck</a></html>`)
+			case stuck.Load():
+				<-release
+			default:
+				http.Error(rw, "No such page", http.StatusNotFound)
+			}
+		}))
+		defer server.Close()
+		defer close(release)
+
+		c := NewCrawler(server.URL, WithStallTimeout(50*time.Millisecond))
+		c.Client.Timeout = 0
+		previous := c.Crawl(server.URL + "/")
+		stuck.Store(true)
+
+		result := c.Recheck(previous)
+		if !result.Aborted || !result.Broken.Contains(server.URL+"/stuck") {
+			t.Errorf("Unchecked link should stay broken in an aborted result, got: %t, %v.", result.Aborted, result.Broken.Keys())
+		}
+		if info, _ := result.Pages.Get(server.URL + "/stuck"); info.Status != 404 {
+			t.Errorf("Status mismatch, got: %d, want: 404.", info.Status)
+		}
+	})
+}
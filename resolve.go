@@ -0,0 +1,111 @@
+package wikicrawl
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// Address a host is connected to instead of the one found in DNS, e.g. a
+// staging backend behind a hosts file trick or split-horizon DNS.
+//
+//  1. Host: Host name as written in urls, case insensitive.
+//  2. Port: Port the override applies to, every port when empty.
+//  3. IP: Address connected to, IPv4 or IPv6.
+type HostOverride struct {
+	Host string
+	Port string
+	IP   net.IP
+}
+
+// Parses an override written like the --resolve option of curl, as
+// "<host>:[<port>:]<ip>" with IPv6 addresses in brackets, e.g.
+// "wiki.example.com:443:[2001:db8::1]".
+func ParseHostOverride(raw string) (HostOverride, error) {
+	split := strings.Index(raw, ":")
+	if split <= 0 {
+		return HostOverride{}, fmt.Errorf("host override %q is not <host>:[<port>:]<ip>", raw)
+	}
+
+	override := HostOverride{Host: raw[:split]}
+	address := raw[split+1:]
+	if bracket := strings.Index(address, "["); bracket >= 0 && strings.HasSuffix(address, "]") {
+		override.Port = strings.TrimSuffix(address[:bracket], ":")
+		address = address[bracket+1 : len(address)-1]
+	} else if split := strings.LastIndex(address, ":"); split >= 0 {
+		override.Port, address = address[:split], address[split+1:]
+	}
+
+	if override.IP = net.ParseIP(address); override.IP == nil {
+		return HostOverride{}, fmt.Errorf("host override %q has no valid ip: %q", raw, address)
+	}
+	if port, err := strconv.Atoi(override.Port); len(override.Port) > 0 && (err != nil || port <= 0 || port > 65535) {
+		return HostOverride{}, fmt.Errorf("host override %q has no valid port: %q", raw, override.Port)
+	}
+
+	return override, nil
+}
+
+// Overridden address of a host and port, dialed instead of address.
+func pinnedAddress(overrides []HostOverride, address string) string {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return address
+	}
+
+	for _, override := range overrides {
+		if strings.EqualFold(override.Host, host) && (len(override.Port) == 0 || override.Port == port) {
+			return net.JoinHostPort(override.IP.String(), port)
+		}
+	}
+
+	return address
+}
+
+// Connects the hosts of the overrides to their address, copying the client
+// and its transport first. Only plain http.Transport clients can be pinned,
+// pages rendered by a browser Fetcher are not.
+func (c *Crawler) pinHosts() {
+	transport, ok := c.Client.Transport.(*http.Transport)
+	if c.Client.Transport == nil {
+		transport, ok = http.DefaultTransport.(*http.Transport)
+	}
+	if !ok {
+		c.logger().Warn("Host overrides ignored, the client transport cannot be pinned")
+		return
+	}
+
+	overrides := c.overrides
+	pinned := transport.Clone()
+	dial := pinned.DialContext
+	if dial == nil {
+		dial = (&net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}).DialContext
+	}
+	pinned.DialContext = func(ctx context.Context, network, address string) (net.Conn, error) {
+		if target := pinnedAddress(overrides, address); target != address {
+			c.logger().WithFields(log.Fields{"address": address, "pinned": target}).Debug("Dialing overridden host")
+			address = target
+		}
+		return dial(ctx, network, address)
+	}
+	client := *c.Client
+	client.Transport = pinned
+	c.Client = &client
+
+	// Overridden hosts exist even when missing from DNS.
+	lookup := c.hosts.Lookup
+	c.hosts.Lookup = func(ctx context.Context, host string) ([]string, error) {
+		for _, override := range overrides {
+			if strings.EqualFold(override.Host, host) {
+				return []string{override.IP.String()}, nil
+			}
+		}
+		return lookup(ctx, host)
+	}
+}
@@ -0,0 +1,70 @@
+package wikicrawl
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestParseHostOverride(t *testing.T) {
+	t.Run("Parse host overrides", func(t *testing.T) {
+		t.Run("Every port", func(t *testing.T) {
+			t.Parallel()
+			override, err := ParseHostOverride("wiki.example.com:10.0.0.5")
+			if err != nil || override.Host != "wiki.example.com" || len(override.Port) > 0 || override.IP.String() != "10.0.0.5" {
+				t.Errorf("Override mismatch, got: %+v (%v), want: wiki.example.com on any port to 10.0.0.5.", override, err)
+			}
+		})
+
+		t.Run("IPv6 address with port", func(t *testing.T) {
+			t.Parallel()
+			override, err := ParseHostOverride("wiki.example.com:443:[2001:db8::1]")
+			if err != nil || override.Port != "443" || override.IP.String() != "2001:db8::1" {
+				t.Errorf("Override mismatch, got: %+v (%v), want: port 443 to 2001:db8::1.", override, err)
+			}
+		})
+
+		t.Run("Invalid overrides", func(t *testing.T) {
+			t.Parallel()
+			for _, raw := range []string{"wiki.example.com", ":10.0.0.5", "wiki.example.com:backend", "wiki.example.com:http:10.0.0.5"} {
+				if override, err := ParseHostOverride(raw); err == nil {
+					t.Errorf("%s should not parse, got: %+v.", raw, override)
+				}
+			}
+		})
+	})
+}
+
+func TestPinHosts(t *testing.T) {
+	t.Run("Crawl a host pinned to a backend", func(t *testing.T) {
+		t.Parallel()
+		server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			if !strings.HasPrefix(req.Host, "staging.wiki.invalid:") {
+				http.NotFound(rw, req)
+				return
+			}
+			fmt.Fprint(rw, `<html><a href="/other">other</a></html>`)
+		}))
+		defer server.Close()
+
+		backend, _ := url.Parse(server.URL)
+		override, err := ParseHostOverride("staging.wiki.invalid:" + backend.Hostname())
+		if err != nil {
+			t.Fatal(err)
+		}
+		base := "http://staging.wiki.invalid:" + backend.Port()
+
+		c := NewCrawler(base, WithHostOverrides(override))
+		result := c.Crawl(base + "/start")
+		if result.Visited.Len() != 2 || result.Broken.Len() > 0 {
+			t.Errorf("Crawl mismatch, got: %v visited, %v broken, want: 2 visited.", result.Visited.Keys(), result.Broken.Keys())
+		}
+		if addresses, err := c.hosts.Lookup(context.Background(), "STAGING.wiki.invalid"); err != nil || addresses[0] != backend.Hostname() {
+			t.Errorf("Lookup mismatch, got: %v (%v), want: %s.", addresses, err, backend.Hostname())
+		}
+	})
+}
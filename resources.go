@@ -0,0 +1,165 @@
+package wikicrawl
+
+import (
+	"context"
+	"io"
+	"mime"
+	"net/url"
+	"regexp"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+	"golang.org/x/net/html"
+)
+
+// References of style sheets: url(...) values and @import rules.
+var cssURL = regexp.MustCompile(`url\(\s*['"]?([^'")\s]+)['"]?\s*\)|@import\s+['"]([^'"]+)['"]`)
+
+// Urls referenced by CSS, e.g. background images and imported sheets.
+// Inline data urls are skipped.
+func CSSURLs(css string) []string {
+	var urls []string
+	for _, match := range cssURL.FindAllStringSubmatch(css, -1) {
+		ref := match[1]
+		if len(ref) == 0 {
+			ref = match[2]
+		}
+		if !strings.HasPrefix(strings.ToLower(ref), "data:") {
+			urls = append(urls, ref)
+		}
+	}
+
+	return urls
+}
+
+// Stylesheets linked by a page and the urls referenced by its style
+// elements and inline style attributes.
+func StyleURLs(reader io.Reader) []string {
+	var urls []string
+	inStyle := false
+
+	z := html.NewTokenizer(reader)
+	for {
+		tokenType := z.Next()
+		if tokenType == html.ErrorToken {
+			return urls
+		}
+
+		token := z.Token()
+		switch tokenType {
+		case html.StartTagToken, html.SelfClosingTagToken:
+			if token.Data == "link" && hasToken(attr(token, "rel"), "stylesheet") && len(attr(token, "href")) > 0 {
+				urls = append(urls, attr(token, "href"))
+			}
+			if style := attr(token, "style"); len(style) > 0 {
+				urls = append(urls, CSSURLs(style)...)
+			}
+			inStyle = token.Data == "style" && tokenType == html.StartTagToken
+		case html.TextToken:
+			if inStyle {
+				urls = append(urls, CSSURLs(token.Data)...)
+			}
+		case html.EndTagToken:
+			inStyle = false
+		}
+	}
+}
+
+// Sources of the external scripts of a page.
+func ScriptURLs(reader io.Reader) []string {
+	links, _ := ParsePageAttrs(reader, LinkAttrs{"script": {"src"}})
+	return links.Keys()
+}
+
+// Reports if a space separated attribute value holds token, e.g. a rel.
+func hasToken(value, token string) bool {
+	for _, field := range strings.Fields(value) {
+		if strings.EqualFold(field, token) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Reports if a page was served as a style sheet.
+func isStylesheet(page *Page) bool {
+	mediaType, _, _ := mime.ParseMediaType(page.Header.Get("Content-Type"))
+	return mediaType == "text/css"
+}
+
+// Records resources embedded by source, resolved against base, and queues
+// those hosted on the wiki or verified as external links. Returns the
+// resources recorded.
+func (c *Crawler) queueResources(queue *WorkQueue, source Link, base *url.URL, raws []string) []Link {
+	key := source.String()
+	var resources []Link
+	for _, raw := range raws {
+		ref, err := url.Parse(strings.TrimSpace(raw))
+		if err != nil {
+			continue
+		}
+		resolved := base.ResolveReference(ref)
+		resolved.Fragment = ""
+		if resolved.Scheme != "http" && resolved.Scheme != "https" {
+			continue
+		}
+		if c.IsExternal(resolved) && !c.ShouldVerify(resolved) {
+			continue
+		}
+
+		link := c.child(resolved, source)
+		resources = append(resources, link)
+		queue.Result.Resources.Add(link)
+		queue.Result.record(link, func(info *PageInfo) {
+			info.AddReferrer(key)
+		})
+		if !queue.Result.Visited.Contains(link.String()) {
+			queue.AddWork(link)
+		}
+	}
+
+	return resources
+}
+
+// Fetches a resource embedded by pages without crawling it, style sheets
+// are searched for the resources they reference in turn.
+func (c *Crawler) checkResource(ctx context.Context, source Link, queue *WorkQueue) {
+	page, err := c.politeFetch(ctx, source)
+	if err != nil {
+		c.logger().WithFields(log.Fields{
+			"source": source,
+			"err":    err,
+		}).Warn("Resource GET returned with error")
+		c.broken(queue.Result, source, linkError(source, ErrFetch, err))
+		return
+	}
+
+	queue.Result.record(source, func(info *PageInfo) {
+		info.Status = page.StatusCode
+		c.captureHeaders(info, page.Header)
+	})
+	c.emit(Event{Type: EventFetch, URL: source.String(), Status: page.StatusCode})
+
+	if page.StatusCode != 200 && c.acceptStatus(source, page.StatusCode, false) {
+		return
+	}
+	if page.StatusCode != 200 {
+		c.logger().WithFields(log.Fields{
+			"source": source,
+			"status": page.Status,
+		}).Warn("Resource GET returned with non 200 response")
+		queue.Result.record(source, func(info *PageInfo) {
+			c.captureError(info, page.Header, page.Body)
+		})
+		if c.restricted(queue.Result, source, page.StatusCode) {
+			return
+		}
+		c.broken(queue.Result, source, statusError(source, page.StatusCode, page.Status))
+		return
+	}
+
+	if c.Styles && isStylesheet(page) {
+		c.queueResources(queue, source, page.URL, CSSURLs(string(page.Body)))
+	}
+}
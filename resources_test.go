@@ -0,0 +1,114 @@
+package wikicrawl
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCSSURLs(t *testing.T) {
+	t.Run("Extract url references and imports", func(t *testing.T) {
+		t.Parallel()
+		css := `@import "print.css"; body { background: url( '/images/bg.png' ) } .logo { background-image: url(data:image/png;base64,AAA=) } i { src: url(icons.woff) }`
+		expected := []string{"print.css", "/images/bg.png", "icons.woff"}
+		if found := CSSURLs(css); !reflect.DeepEqual(found, expected) {
+			t.Errorf("Urls mismatch, got: %v, want: %v.", found, expected)
+		}
+	})
+}
+
+func TestStyleURLs(t *testing.T) {
+	t.Run("Extract stylesheets and style urls", func(t *testing.T) {
+		t.Parallel()
+		page := `<html><head>
+<link rel="stylesheet" href="/w/load.php?only=styles">
+<link rel="icon" href="/favicon.ico">
+<style>.banner { background: url("/images/banner.png") }</style>
+</head><body><div style="background: url(/images/inline.png)"><a href="/wiki/A">A</a></div></body></html>`
+		expected := []string{"/w/load.php?only=styles", "/images/banner.png", "/images/inline.png"}
+		if found := StyleURLs(strings.NewReader(page)); !reflect.DeepEqual(found, expected) {
+			t.Errorf("Urls mismatch, got: %v, want: %v.", found, expected)
+		}
+	})
+}
+
+func TestStyles(t *testing.T) {
+	handler := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/wiki/Main":
+			fmt.Fprint(rw, `<html><head><link rel="stylesheet" href="/w/skin.css"></head>
+<body style="background: url(/images/body.png)"><a href="/wiki/Other">Other</a></body></html>`)
+		case "/wiki/Other":
+			fmt.Fprint(rw, `<html></html>`)
+		case "/w/skin.css":
+			rw.Header().Set("Content-Type", "text/css")
+			fmt.Fprint(rw, `.logo { background: url(../images/logo.png) }`)
+		case "/images/body.png":
+			fmt.Fprint(rw, "png")
+		default:
+			http.NotFound(rw, req)
+		}
+	})
+
+	t.Run("Verify style sheets and their images", func(t *testing.T) {
+		t.Parallel()
+		server := httptest.NewServer(handler)
+		defer server.Close()
+
+		result := NewCrawler(server.URL+"/wiki", WithStyles()).Crawl(server.URL + "/wiki/Main")
+		expected := []string{server.URL + "/images/body.png", server.URL + "/images/logo.png", server.URL + "/w/skin.css"}
+		if found := result.Resources.Keys(); !reflect.DeepEqual(found, expected) {
+			t.Errorf("Resources mismatch, got: %v, want: %v.", found, expected)
+		}
+		if found, want := result.Broken.Keys(), []string{server.URL + "/images/logo.png"}; !reflect.DeepEqual(found, want) {
+			t.Errorf("Broken mismatch, got: %v, want: %v.", found, want)
+		}
+		if info, _ := result.Pages.Get(server.URL + "/images/logo.png"); !reflect.DeepEqual(info.Referrers, []string{server.URL + "/w/skin.css"}) {
+			t.Errorf("Referrers mismatch, got: %v, want: %s.", info.Referrers, server.URL+"/w/skin.css")
+		}
+	})
+
+	t.Run("Ignore styles by default", func(t *testing.T) {
+		t.Parallel()
+		server := httptest.NewServer(handler)
+		defer server.Close()
+
+		result := NewCrawler(server.URL + "/wiki").Crawl(server.URL + "/wiki/Main")
+		if result.Resources.Len() > 0 || result.Broken.Len() > 0 {
+			t.Errorf("Resources mismatch, got: %v and broken %v, want none.", result.Resources.Keys(), result.Broken.Keys())
+		}
+	})
+}
+
+func TestScripts(t *testing.T) {
+	t.Run("Verify scripts apart from content links", func(t *testing.T) {
+		t.Parallel()
+		server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			switch req.URL.Path {
+			case "/wiki/Main":
+				fmt.Fprint(rw, `<html><head><script src="/w/load.php?modules=startup"></script>
+<script src="/w/extensions/Gone/gone.js"></script><script>var inline = true;</script></head>
+<body><a href="/wiki/Missing">Missing</a></body></html>`)
+			case "/w/load.php":
+				rw.Header().Set("Content-Type", "text/javascript")
+				fmt.Fprint(rw, "mw.loader.load();")
+			default:
+				http.NotFound(rw, req)
+			}
+		}))
+		defer server.Close()
+
+		result := NewCrawler(server.URL+"/wiki", WithScripts()).Crawl(server.URL + "/wiki/Main")
+		expected := []string{server.URL + "/w/extensions/Gone/gone.js", server.URL + "/w/load.php?modules=startup"}
+		if found := result.Scripts.Keys(); !reflect.DeepEqual(found, expected) {
+			t.Errorf("Scripts mismatch, got: %v, want: %v.", found, expected)
+		}
+		expected = []string{server.URL + "/w/extensions/Gone/gone.js", server.URL + "/wiki/Missing"}
+		if found := result.Broken.Keys(); !reflect.DeepEqual(found, expected) {
+			t.Errorf("Broken mismatch, got: %v, want: %v.", found, expected)
+		}
+	})
+}
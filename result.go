@@ -0,0 +1,68 @@
+package wikicrawl
+
+import (
+	"fmt"
+)
+
+// Paginated access to crawl results kept by a storage backend, so large
+// results do not have to be held in memory.
+type ResultStore interface {
+	CountLinks(set string) (int, error)
+	LinkPage(set string, offset, limit int) ([]string, error)
+}
+
+// Thin result handle answering every query from a storage backend.
+func NewStoredResult(store ResultStore) *CrawlResult {
+	result := NewCrawlResult()
+	result.Store = store
+	return result
+}
+
+// Number of links in a named result set (see LinkSets).
+func (cr *CrawlResult) Count(set string) (int, error) {
+	if cr.Store != nil {
+		return cr.Store.CountLinks(set)
+	}
+
+	links, found := cr.LinkSets()[set]
+	if !found {
+		return 0, fmt.Errorf("unknown result set %q", set)
+	}
+
+	return links.Len(), nil
+}
+
+// Sorted page of links from a named result set (see LinkSets).
+func (cr *CrawlResult) Page(set string, offset, limit int) ([]string, error) {
+	if cr.Store != nil {
+		return cr.Store.LinkPage(set, offset, limit)
+	}
+
+	links, found := cr.LinkSets()[set]
+	if !found {
+		return nil, fmt.Errorf("unknown result set %q", set)
+	}
+
+	return paginate(links.Keys(), offset, limit), nil
+}
+
+func (cr *CrawlResult) VisitedPage(offset, limit int) ([]string, error) {
+	return cr.Page("visited", offset, limit)
+}
+
+func (cr *CrawlResult) BrokenPage(offset, limit int) ([]string, error) {
+	return cr.Page("broken", offset, limit)
+}
+
+func paginate(links []string, offset, limit int) []string {
+	if offset >= len(links) || offset < 0 {
+		return []string{}
+	}
+
+	end := offset + limit
+	if limit < 0 || end > len(links) {
+		end = len(links)
+	}
+
+	return links[offset:end]
+}
@@ -0,0 +1,70 @@
+package wikicrawl
+
+import (
+	"reflect"
+	"testing"
+)
+
+type fakeStore map[string][]string
+
+func (fs fakeStore) CountLinks(set string) (int, error) {
+	return len(fs[set]), nil
+}
+
+func (fs fakeStore) LinkPage(set string, offset, limit int) ([]string, error) {
+	return paginate(fs[set], offset, limit), nil
+}
+
+func TestResultPages(t *testing.T) {
+	t.Run("Paginated result queries", func(t *testing.T) {
+		t.Run("In memory result", func(t *testing.T) {
+			t.Parallel()
+			result := NewCrawlResult()
+			for _, link := range []string{"d", "b", "a", "c"} {
+				result.Broken.Add(NewLink(link))
+			}
+
+			found, _ := result.BrokenPage(1, 2)
+			expected := []string{"b", "c"}
+			if !reflect.DeepEqual(found, expected) {
+				t.Errorf("Page mismatch, got: %v, want: %v.", found, expected)
+			}
+
+			found, _ = result.BrokenPage(3, 10)
+			expected = []string{"d"}
+			if !reflect.DeepEqual(found, expected) {
+				t.Errorf("Last page mismatch, got: %v, want: %v.", found, expected)
+			}
+
+			if found, _ = result.BrokenPage(10, 10); len(found) != 0 {
+				t.Errorf("Page past the end should be empty, got: %v.", found)
+			}
+
+			if count, _ := result.Count("broken"); count != 4 {
+				t.Errorf("Count mismatch, got: %d, want: %d.", count, 4)
+			}
+		})
+
+		t.Run("Unknown set", func(t *testing.T) {
+			t.Parallel()
+			if _, err := NewCrawlResult().Page("missing", 0, 10); err == nil {
+				t.Errorf("Unknown result set should fail.")
+			}
+		})
+
+		t.Run("Stored result", func(t *testing.T) {
+			t.Parallel()
+			result := NewStoredResult(fakeStore{"visited": {"a", "b", "c"}})
+
+			found, _ := result.VisitedPage(0, 2)
+			expected := []string{"a", "b"}
+			if !reflect.DeepEqual(found, expected) {
+				t.Errorf("Stored page mismatch, got: %v, want: %v.", found, expected)
+			}
+
+			if count, _ := result.Count("visited"); count != 3 {
+				t.Errorf("Stored count mismatch, got: %d, want: %d.", count, 3)
+			}
+		})
+	})
+}
@@ -0,0 +1,226 @@
+package wikicrawl
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// Product token matched against the user agents of robots.txt groups.
+const robotsAgent = "wikicrawl"
+
+// Sitemap files read at most, sitemap indexes included.
+const maxSitemaps = 50
+
+// Robots exclusion rules of a host and the sitemaps it lists.
+type Robots struct {
+	rules    []robotsRule
+	Sitemaps []string
+}
+
+type robotsRule struct {
+	pattern *regexp.Regexp
+	length  int
+	allow   bool
+}
+
+// Parses a robots.txt, keeping the rules of the groups naming agent or,
+// when none does, of the groups for every agent (*).
+func ParseRobots(reader io.Reader, agent string) *Robots {
+	robots := new(Robots)
+	var specific, wildcard []robotsRule
+	var matched, any, inAgents bool
+
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if comment := strings.Index(line, "#"); comment >= 0 {
+			line = line[:comment]
+		}
+		split := strings.Index(line, ":")
+		if split < 0 {
+			continue
+		}
+		key := strings.ToLower(strings.TrimSpace(line[:split]))
+		value := strings.TrimSpace(line[split+1:])
+
+		switch key {
+		case "user-agent":
+			// Consecutive user agents share the rules that follow.
+			if !inAgents {
+				matched, any = false, false
+			}
+			inAgents = true
+			if value == "*" {
+				any = true
+			} else if len(value) > 0 && strings.Contains(strings.ToLower(agent), strings.ToLower(value)) {
+				matched = true
+			}
+		case "allow", "disallow":
+			inAgents = false
+			if len(value) == 0 {
+				continue
+			}
+			rule := robotsRule{pattern: robotsPattern(value), length: len(value), allow: key == "allow"}
+			if matched {
+				specific = append(specific, rule)
+			}
+			if any {
+				wildcard = append(wildcard, rule)
+			}
+		case "sitemap":
+			robots.Sitemaps = append(robots.Sitemaps, value)
+		default:
+			inAgents = false
+		}
+	}
+
+	robots.rules = wildcard
+	if len(specific) > 0 {
+		robots.rules = specific
+	}
+
+	return robots
+}
+
+// Regexp of a robots.txt path, * matching anything and a final $ the end
+// of the url.
+func robotsPattern(path string) *regexp.Regexp {
+	anchored := strings.HasSuffix(path, "$")
+	pattern := "^" + strings.Replace(regexp.QuoteMeta(strings.TrimSuffix(path, "$")), `\*`, ".*", -1)
+	if anchored {
+		pattern += "$"
+	}
+
+	return regexp.MustCompile(pattern)
+}
+
+// Reports if the rules allow crawling link: the longest matching rule
+// applies, allow winning ties. Everything is allowed without rules.
+func (r *Robots) Allowed(link *url.URL) bool {
+	if r == nil {
+		return true
+	}
+
+	path := link.EscapedPath()
+	if len(path) == 0 {
+		path = "/"
+	}
+	if len(link.RawQuery) > 0 {
+		path += "?" + link.RawQuery
+	}
+
+	allowed, longest := true, -1
+	for _, rule := range r.rules {
+		if !rule.pattern.MatchString(path) {
+			continue
+		}
+		if rule.length > longest || rule.length == longest && rule.allow {
+			allowed, longest = rule.allow, rule.length
+		}
+	}
+
+	return allowed
+}
+
+// Page urls and nested sitemaps listed by a sitemap or sitemap index.
+func ParseSitemap(reader io.Reader) ([]string, []string, error) {
+	var sitemap struct {
+		URLs []struct {
+			Loc string `xml:"loc"`
+		} `xml:"url"`
+		Sitemaps []struct {
+			Loc string `xml:"loc"`
+		} `xml:"sitemap"`
+	}
+	if err := xml.NewDecoder(reader).Decode(&sitemap); err != nil {
+		return nil, nil, err
+	}
+
+	var pages, nested []string
+	for _, page := range sitemap.URLs {
+		pages = append(pages, strings.TrimSpace(page.Loc))
+	}
+	for _, child := range sitemap.Sitemaps {
+		nested = append(nested, strings.TrimSpace(child.Loc))
+	}
+
+	return pages, nested, nil
+}
+
+// Fetches robots.txt of the base host, keeping its rules to validate
+// links with.
+func (c *Crawler) loadRobots() {
+	root := &url.URL{Scheme: c.base.Scheme, Host: c.base.Host}
+	err := c.readFile(root.String()+"/robots.txt", func(body io.Reader) error {
+		c.robots = ParseRobots(body, robotsAgent)
+		return nil
+	})
+	if err != nil {
+		c.logger().WithFields(log.Fields{"err": err}).Warn("Reading robots.txt failed")
+	}
+}
+
+// Fetches the sitemaps of the base host, /sitemap.xml unless robots.txt
+// lists others. Returns the pages listed by the sitemaps. Sitemaps of
+// other hosts are skipped.
+func (c *Crawler) discover() []string {
+	root := &url.URL{Scheme: c.base.Scheme, Host: c.base.Host}
+	sitemaps := []string{root.String() + "/sitemap.xml"}
+	if c.robots != nil && len(c.robots.Sitemaps) > 0 {
+		sitemaps = c.robots.Sitemaps
+	}
+
+	var seeds []string
+	for i := 0; i < len(sitemaps) && i < maxSitemaps; i++ {
+		if link, err := url.Parse(sitemaps[i]); err != nil || !strings.EqualFold(asciiHost(link.Host), c.base.Host) {
+			c.logger().WithFields(log.Fields{"sitemap": sitemaps[i]}).Warn("Skipping sitemap outside of the wiki host")
+			continue
+		}
+
+		err := c.readFile(sitemaps[i], func(body io.Reader) error {
+			pages, nested, err := ParseSitemap(body)
+			seeds = append(seeds, pages...)
+			sitemaps = append(sitemaps, nested...)
+			return err
+		})
+		if err != nil {
+			c.logger().WithFields(log.Fields{"sitemap": sitemaps[i], "err": err}).Warn("Reading sitemap failed")
+		}
+	}
+
+	return seeds
+}
+
+// Fetches a file of the wiki host, gunzipping .gz files.
+func (c *Crawler) readFile(link string, read func(body io.Reader) error) error {
+	resp, err := c.Client.Get(link)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s returned %s", link, resp.Status)
+	}
+
+	var body io.Reader = resp.Body
+	if strings.HasSuffix(resp.Request.URL.Path, ".gz") {
+		unzipped, err := gzip.NewReader(resp.Body)
+		if err != nil {
+			return err
+		}
+		defer unzipped.Close()
+		body = unzipped
+	}
+
+	return read(body)
+}
@@ -0,0 +1,163 @@
+package wikicrawl
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestParseRobots(t *testing.T) {
+	robots := ParseRobots(strings.NewReader(`
+# Rules of every agent.
+User-agent: *
+Disallow: /wiki/Special:
+Allow: /wiki/Special:RecentChanges
+Disallow: /*action=edit
+Disallow: /private$
+
+User-agent: otherbot
+Disallow: /
+
+Sitemap: http://testing.com/sitemap-index.xml
+`), robotsAgent)
+
+	t.Run("Rules", func(t *testing.T) {
+		t.Parallel()
+		cases := map[string]bool{
+			"http://testing.com/wiki/Main_Page":             true,
+			"http://testing.com/wiki/Special:Random":        false,
+			"http://testing.com/wiki/Special:RecentChanges": true,
+			"http://testing.com/w/index.php?action=edit":    false,
+			"http://testing.com/private":                    false,
+			"http://testing.com/private/page":               true,
+		}
+		for link, want := range cases {
+			if got := robots.Allowed(NewLink(link).URL); got != want {
+				t.Errorf("Allowed mismatch for %s, got: %t, want: %t.", link, got, want)
+			}
+		}
+	})
+
+	t.Run("Sitemaps", func(t *testing.T) {
+		t.Parallel()
+		if want := []string{"http://testing.com/sitemap-index.xml"}; !reflect.DeepEqual(robots.Sitemaps, want) {
+			t.Errorf("Sitemaps mismatch, got: %v, want: %v.", robots.Sitemaps, want)
+		}
+	})
+
+	t.Run("Group of the agent", func(t *testing.T) {
+		t.Parallel()
+		robots := ParseRobots(strings.NewReader("User-agent: *\nDisallow: /\n\nUser-agent: Wikicrawl\nDisallow: /w/\n"), robotsAgent)
+		if !robots.Allowed(NewLink("http://testing.com/wiki/Main_Page").URL) {
+			t.Errorf("Allowed mismatch, got: false, want: true.")
+		}
+		if robots.Allowed(NewLink("http://testing.com/w/index.php").URL) {
+			t.Errorf("Allowed mismatch, got: true, want: false.")
+		}
+	})
+
+	t.Run("No rules", func(t *testing.T) {
+		t.Parallel()
+		var robots *Robots
+		if !robots.Allowed(NewLink("http://testing.com/wiki/Main_Page").URL) {
+			t.Errorf("Allowed mismatch, got: false, want: true.")
+		}
+	})
+}
+
+func TestDiscover(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/robots.txt":
+			fmt.Fprint(rw, "User-agent: *\nDisallow: /hidden\nSitemap: http://"+req.Host+"/sitemap-index.xml\n")
+		case "/sitemap-index.xml":
+			fmt.Fprintf(rw, `<sitemapindex><sitemap><loc>http://%s/sitemap-1.xml.gz</loc></sitemap></sitemapindex>`, req.Host)
+		case "/sitemap-1.xml.gz":
+			writer := gzip.NewWriter(rw)
+			fmt.Fprintf(writer, `<urlset><url><loc>http://%s/orphan</loc></url><url><loc>http://%s/hidden</loc></url></urlset>`, req.Host, req.Host)
+			writer.Close()
+		case "/start":
+			fmt.Fprint(rw, `<html><a href="/hidden">hidden</a></html>`)
+		default:
+			fmt.Fprint(rw, `<html></html>`)
+		}
+	}))
+	defer server.Close()
+
+	t.Run("Seeds from the sitemaps", func(t *testing.T) {
+		result := NewCrawler(server.URL, WithDiscover()).Crawl(server.URL + "/start")
+		if want := []string{server.URL + "/orphan", server.URL + "/start"}; !reflect.DeepEqual(result.Visited.Keys(), want) {
+			t.Errorf("Visited mismatch, got: %v, want: %v.", result.Visited.Keys(), want)
+		}
+	})
+
+	t.Run("Without discovery", func(t *testing.T) {
+		result := NewCrawler(server.URL).Crawl(server.URL + "/start")
+		if want := []string{server.URL + "/hidden", server.URL + "/start"}; !reflect.DeepEqual(result.Visited.Keys(), want) {
+			t.Errorf("Visited mismatch, got: %v, want: %v.", result.Visited.Keys(), want)
+		}
+	})
+
+	t.Run("Robots rules of resumed crawls", func(t *testing.T) {
+		previous := NewCrawlResult()
+		previous.Visited.Add(NewLink(server.URL + "/start"))
+		for _, path := range []string{"/start", "/hidden", "/orphan"} {
+			previous.record(NewLink(server.URL+path), func(info *PageInfo) {})
+		}
+
+		result := NewCrawler(server.URL, WithDiscover()).Resume(previous)
+		if want := []string{server.URL + "/orphan", server.URL + "/start"}; !reflect.DeepEqual(result.Visited.Keys(), want) {
+			t.Errorf("Visited mismatch, got: %v, want: %v.", result.Visited.Keys(), want)
+		}
+	})
+}
+
+func TestDiscoverSitemaps(t *testing.T) {
+	t.Run("Sitemaps of other hosts skipped", func(t *testing.T) {
+		t.Parallel()
+		var fetched atomic.Bool
+		other := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			fetched.Store(true)
+		}))
+		defer other.Close()
+
+		server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			if req.URL.Path == "/robots.txt" {
+				fmt.Fprint(rw, "Sitemap: "+other.URL+"/sitemap.xml\n")
+			}
+		}))
+		defer server.Close()
+
+		NewCrawler(server.URL, WithDiscover()).Crawl(server.URL + "/")
+		if fetched.Load() {
+			t.Errorf("Sitemap of another host should not be fetched.")
+		}
+	})
+
+	t.Run("Seeds normalized like links", func(t *testing.T) {
+		t.Parallel()
+		var requests atomic.Int32
+		server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			switch req.URL.Path {
+			case "/sitemap.xml":
+				fmt.Fprintf(rw, `<urlset><url><loc>http://%s/wiki/%%e6%%97%%a5</loc></url></urlset>`, req.Host)
+			case "/start":
+				io.WriteString(rw, `<html><a href="/wiki/%E6%97%A5">page</a></html>`)
+			case "/wiki/日":
+				requests.Add(1)
+			}
+		}))
+		defer server.Close()
+
+		result := NewCrawler(server.URL, WithDiscover()).Crawl(server.URL + "/start")
+		if requests.Load() != 1 || result.Visited.Len() != 2 {
+			t.Errorf("Sitemap page should be crawled once, got: %d requests, visited: %v.", requests.Load(), result.Visited.Keys())
+		}
+	})
+}
@@ -0,0 +1,141 @@
+package wikicrawl
+
+import (
+	"bytes"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// Minimum HSTS max-age accepted by the audit, six months.
+const minHSTSMaxAge = 15768000
+
+var maxAgePattern = regexp.MustCompile(`(?i)max-age\s*=\s*"?(\d+)`)
+
+// Security problem of a page found in audit mode, or accessibility problem
+// found by the AccessibilityProcessor.
+//
+//  1. Page: Url of the audited page.
+//  2. Check: Header checked, mixed-content, img-alt or link-text.
+//  3. Problem: What is wrong.
+type Finding struct {
+	Page    string `json:"page"`
+	Check   string `json:"check"`
+	Problem string `json:"problem"`
+}
+
+// Checks the security headers of a page and, on https pages, resources
+// loaded over plain http.
+func AuditPage(page *Page) []Finding {
+	var findings []Finding
+	add := func(check, problem string) {
+		findings = append(findings, Finding{Page: page.URL.String(), Check: check, Problem: problem})
+	}
+	secure := page.URL.Scheme == "https"
+
+	csp := page.Header.Get("Content-Security-Policy")
+	switch {
+	case len(csp) == 0:
+		add("Content-Security-Policy", "missing")
+	case strings.Contains(csp, "'unsafe-eval'"):
+		add("Content-Security-Policy", "allows 'unsafe-eval'")
+	}
+
+	if secure {
+		hsts := page.Header.Get("Strict-Transport-Security")
+		match := maxAgePattern.FindStringSubmatch(hsts)
+		switch {
+		case len(hsts) == 0:
+			add("Strict-Transport-Security", "missing")
+		case match == nil:
+			add("Strict-Transport-Security", "max-age missing")
+		default:
+			if age, _ := strconv.Atoi(match[1]); age < minHSTSMaxAge {
+				add("Strict-Transport-Security", "max-age below six months: "+match[1])
+			}
+		}
+	}
+
+	frame := strings.ToUpper(page.Header.Get("X-Frame-Options"))
+	switch {
+	case len(frame) == 0 && !strings.Contains(csp, "frame-ancestors"):
+		add("X-Frame-Options", "missing")
+	case len(frame) > 0 && frame != "DENY" && frame != "SAMEORIGIN":
+		add("X-Frame-Options", "unexpected value: "+frame)
+	}
+
+	if secure {
+		for _, resource := range MixedContent(page.Body, page.URL) {
+			add("mixed-content", resource)
+		}
+	}
+
+	return findings
+}
+
+// Plain http resources (images, scripts, stylesheets, frames, media) of a
+// page, which browsers block or warn about on https pages.
+func MixedContent(body []byte, base *url.URL) []string {
+	var resources []string
+	z := html.NewTokenizer(bytes.NewReader(body))
+	for {
+		tokenType := z.Next()
+		if tokenType == html.ErrorToken {
+			return resources
+		}
+		if tokenType != html.StartTagToken && tokenType != html.SelfClosingTagToken {
+			continue
+		}
+
+		token := z.Token()
+		key := "src"
+		switch token.Data {
+		case "img", "script", "iframe", "source", "video", "audio", "embed":
+		case "link":
+			if !isResourceLink(token) {
+				continue
+			}
+			key = "href"
+		default:
+			continue
+		}
+
+		for _, attr := range token.Attr {
+			if attr.Key != key {
+				continue
+			}
+			if link, err := base.Parse(strings.TrimSpace(attr.Val)); err == nil && link.Scheme == "http" {
+				resources = append(resources, link.String())
+			}
+		}
+	}
+}
+
+// Reports if a <link> tag loads a resource rather than referencing a page.
+func isResourceLink(token html.Token) bool {
+	for _, attr := range token.Attr {
+		if attr.Key != "rel" {
+			continue
+		}
+		for _, rel := range strings.Fields(strings.ToLower(attr.Val)) {
+			if rel == "stylesheet" || rel == "icon" || rel == "preload" || rel == "modulepreload" {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// Findings of every audited page.
+func (cr *CrawlResult) SecurityFindings() []Finding {
+	var findings []Finding
+	for _, info := range cr.Pages.Values() {
+		findings = append(findings, info.Findings...)
+	}
+
+	return findings
+}
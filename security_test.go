@@ -0,0 +1,92 @@
+package wikicrawl
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func TestAuditPage(t *testing.T) {
+	page := func(raw string, header http.Header, body string) *Page {
+		link, _ := url.Parse(raw)
+		return &Page{URL: link, StatusCode: 200, Header: header, Body: []byte(body)}
+	}
+
+	t.Run("Secure page", func(t *testing.T) {
+		t.Parallel()
+		header := http.Header{}
+		header.Set("Content-Security-Policy", "default-src 'self'; frame-ancestors 'none'")
+		header.Set("Strict-Transport-Security", "max-age=31536000; includeSubDomains")
+		found := AuditPage(page("https://wiki.test/Main", header, `<img src="/logo.png">`))
+		if len(found) != 0 {
+			t.Errorf("Secure page should have no findings, got: %v.", found)
+		}
+	})
+
+	t.Run("Missing headers and mixed content", func(t *testing.T) {
+		t.Parallel()
+		header := http.Header{}
+		header.Set("Strict-Transport-Security", "max-age=300")
+		header.Set("X-Frame-Options", "ALLOW-FROM http://a.test")
+		body := `<html><head>
+			<link rel="stylesheet" href="http://cdn.test/skin.css">
+			<link rel="canonical" href="http://wiki.test/Main">
+			<script src="//cdn.test/app.js"></script>
+		</head><body><img src="http://img.test/a.png"><a href="http://elsewhere.test/">Link</a></body></html>`
+		found := AuditPage(page("https://wiki.test/Main", header, body))
+		expected := []Finding{
+			{Page: "https://wiki.test/Main", Check: "Content-Security-Policy", Problem: "missing"},
+			{Page: "https://wiki.test/Main", Check: "Strict-Transport-Security", Problem: "max-age below six months: 300"},
+			{Page: "https://wiki.test/Main", Check: "X-Frame-Options", Problem: "unexpected value: ALLOW-FROM HTTP://A.TEST"},
+			{Page: "https://wiki.test/Main", Check: "mixed-content", Problem: "http://cdn.test/skin.css"},
+			{Page: "https://wiki.test/Main", Check: "mixed-content", Problem: "http://img.test/a.png"},
+		}
+		if !reflect.DeepEqual(found, expected) {
+			t.Errorf("Findings mismatch, got: %v, want: %v.", found, expected)
+		}
+	})
+
+	t.Run("Record findings in audit mode", func(t *testing.T) {
+		t.Parallel()
+		server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			rw.Header().Set("X-Frame-Options", "DENY")
+			fmt.Fprint(rw, `<html><body></body></html>`)
+		}))
+		defer server.Close()
+
+		result := NewCrawler(server.URL, WithAudit()).Crawl(server.URL + "/")
+		expected := []Finding{{Page: server.URL + "/", Check: "Content-Security-Policy", Problem: "missing"}}
+		if found := result.SecurityFindings(); !reflect.DeepEqual(found, expected) {
+			t.Errorf("Findings mismatch, got: %v, want: %v.", found, expected)
+		}
+	})
+}
+
+func TestMixedContent(t *testing.T) {
+	t.Run("Flag https pages embedding http resources", func(t *testing.T) {
+		t.Parallel()
+		server := httptest.NewTLSServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			switch req.URL.Path {
+			case "/":
+				fmt.Fprint(rw, `<html><body><a href="/clean">Clean</a><script src="http://cdn.test/app.js"></script></body></html>`)
+			default:
+				fmt.Fprint(rw, `<html><body><img src="/logo.png"></body></html>`)
+			}
+		}))
+		defer server.Close()
+
+		result := NewCrawler(server.URL, WithClient(server.Client())).Crawl(server.URL + "/")
+		expected := []string{server.URL + "/"}
+		if found := result.MixedContent.Keys(); !reflect.DeepEqual(found, expected) {
+			t.Errorf("Mixed content mismatch, got: %v, want: %v.", found, expected)
+		}
+
+		info, _ := result.Pages.Get(server.URL + "/")
+		if !reflect.DeepEqual(info.MixedContent, []string{"http://cdn.test/app.js"}) {
+			t.Errorf("Resources mismatch, got: %v, want: %v.", info.MixedContent, []string{"http://cdn.test/app.js"})
+		}
+	})
+}
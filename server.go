@@ -0,0 +1,331 @@
+package wikicrawl
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// Crawl started through the Server API.
+type Job struct {
+	ID       string
+	Source   string
+	State    string
+	Started  time.Time
+	Finished time.Time
+	queue    *WorkQueue
+	stop     bool
+	done     chan struct{}
+}
+
+// Progress of a Job as reported by the API.
+type JobStatus struct {
+	ID       string    `json:"id"`
+	Source   string    `json:"source"`
+	State    string    `json:"state"`
+	Started  time.Time `json:"started"`
+	Finished time.Time `json:"finished,omitempty"`
+	Visited  int       `json:"visited"`
+	Broken   int       `json:"broken"`
+	Pending  int       `json:"pending"`
+	Estimate *Estimate `json:"estimate,omitempty"`
+}
+
+// States of a Job.
+const (
+	JobRunning = "running"
+	JobDone    = "done"
+	JobStopped = "stopped"
+)
+
+// Returned when starting a crawl on a draining Server.
+var ErrDraining = errors.New("server is shutting down")
+
+// REST API for starting, stopping and inspecting crawls of a wiki.
+//
+//  1. POST /crawls {"source": url, "pathPrefix": path}: Starts a crawl,
+//     below the path prefix if given, returns its status.
+//  2. GET /crawls: Lists the status of every crawl.
+//  3. GET /crawls/{id}: Status of one crawl.
+//  4. POST /crawls/{id}/stop: Aborts a running crawl.
+//  5. GET /crawls/{id}/result: Crawl results once finished, 409 Conflict
+//     while running, the status reports progress until then.
+//  6. GET /healthz: Liveness, ok while the process serves requests.
+//  7. GET /readyz: Readiness, unavailable once the server is draining.
+type Server struct {
+	sync.Mutex
+
+	base       *url.URL
+	jobs       map[string]*Job
+	next       int
+	draining   bool
+	NewCrawler func() *Crawler
+}
+
+// Simple constructor for a Server crawling pages of a single wiki.
+// Every crawl gets a new Crawler from newCrawler.
+func NewServer(base string, newCrawler func() *Crawler) *Server {
+	parsed, err := url.Parse(base)
+	if err != nil {
+		panic(err)
+	}
+
+	return &Server{base: parsed, jobs: make(map[string]*Job), NewCrawler: newCrawler}
+}
+
+// Starts crawling from source, which must belong to the wiki, with the
+// options applied to the crawler of the job, e.g. WithScope.
+func (s *Server) StartJob(source string, opts ...Option) (*Job, error) {
+	link, err := url.Parse(source)
+	if err != nil {
+		return nil, err
+	}
+
+	crawler := s.NewCrawler()
+	for _, opt := range opts {
+		opt(crawler)
+	}
+	if !crawler.ValidateLink(crawler.normalize(link)) {
+		return nil, &LinkError{URL: source, Err: ErrScope}
+	}
+
+	s.Lock()
+	defer s.Unlock()
+	if s.draining {
+		return nil, ErrDraining
+	}
+
+	s.next++
+	job := &Job{
+		ID:      strconv.Itoa(s.next),
+		Source:  source,
+		State:   JobRunning,
+		Started: time.Now(),
+		done:    make(chan struct{}),
+	}
+	s.jobs[job.ID] = job
+	WithLogFields(log.Fields{"job": job.ID})(crawler)
+	go s.run(job, crawler)
+
+	return job, nil
+}
+
+// Crawls in the background. Starting a crawl fetches robots.txt, sitemaps
+// and the siteinfo of the wiki, so it runs outside of the server lock.
+func (s *Server) run(job *Job, crawler *Crawler) {
+	queue := crawler.Start(job.Source)
+
+	s.Lock()
+	job.queue = queue
+	if job.stop {
+		queue.Abort()
+	}
+	s.Unlock()
+
+	queue.Wait()
+
+	s.Lock()
+	defer s.Unlock()
+	defer close(job.done)
+	job.Finished = time.Now()
+	if queue.Result.Aborted {
+		job.State = JobStopped
+	} else {
+		job.State = JobDone
+	}
+}
+
+// Aborts a running job.
+func (s *Server) StopJob(id string) bool {
+	s.Lock()
+	defer s.Unlock()
+	job := s.jobs[id]
+	if job == nil {
+		return false
+	}
+
+	s.abort(job)
+	return true
+}
+
+// Aborts the crawl of a job, or once started if it is still starting.
+func (s *Server) abort(job *Job) {
+	job.stop = true
+	if job.queue != nil {
+		job.queue.Abort()
+	}
+}
+
+// Stops accepting crawls, aborts the running ones and waits until they
+// wrapped up or ctx is done, for a clean shutdown.
+func (s *Server) Drain(ctx context.Context) error {
+	s.Lock()
+	s.draining = true
+	var running []*Job
+	for _, job := range s.jobs {
+		if job.State == JobRunning {
+			running = append(running, job)
+			s.abort(job)
+		}
+	}
+	s.Unlock()
+
+	for _, job := range running {
+		select {
+		case <-job.done:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	return nil
+}
+
+// Whether the server accepts new crawls.
+func (s *Server) Ready() bool {
+	s.Lock()
+	defer s.Unlock()
+	return !s.draining
+}
+
+func (s *Server) job(id string) *Job {
+	s.Lock()
+	defer s.Unlock()
+	return s.jobs[id]
+}
+
+func (s *Server) status(job *Job) JobStatus {
+	s.Lock()
+	defer s.Unlock()
+
+	status := JobStatus{
+		ID:       job.ID,
+		Source:   job.Source,
+		State:    job.State,
+		Started:  job.Started,
+		Finished: job.Finished,
+	}
+	if job.queue == nil {
+		return status
+	}
+
+	status.Visited = job.queue.Result.Visited.Len()
+	status.Broken = job.queue.Result.Broken.Len()
+	status.Pending = job.queue.Pending()
+	if job.State == JobRunning {
+		estimate := job.queue.Estimate()
+		status.Estimate = &estimate
+	}
+
+	return status
+}
+
+func (s *Server) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	switch req.URL.Path {
+	case "/healthz":
+		rw.Write([]byte("ok\n"))
+		return
+	case "/readyz":
+		if !s.Ready() {
+			http.Error(rw, ErrDraining.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		rw.Write([]byte("ok\n"))
+		return
+	}
+
+	parts := strings.Split(strings.Trim(req.URL.Path, "/"), "/")
+	if parts[0] != "crawls" {
+		http.NotFound(rw, req)
+		return
+	}
+
+	switch {
+	case len(parts) == 1 && req.Method == "POST":
+		var body struct {
+			Source     string `json:"source"`
+			PathPrefix string `json:"pathPrefix"`
+		}
+		if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+			http.Error(rw, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		var opts []Option
+		if len(body.PathPrefix) > 0 {
+			opts = append(opts, func(c *Crawler) {
+				WithScope(c.SameHost, body.PathPrefix)(c)
+			})
+		}
+		job, err := s.StartJob(body.Source, opts...)
+		if err == ErrDraining {
+			http.Error(rw, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		if err != nil {
+			http.Error(rw, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		writeJSON(rw, http.StatusCreated, s.status(job))
+	case len(parts) == 1 && req.Method == "GET":
+		s.Lock()
+		jobs := make([]*Job, 0, len(s.jobs))
+		for _, job := range s.jobs {
+			jobs = append(jobs, job)
+		}
+		s.Unlock()
+
+		sort.Slice(jobs, func(i, j int) bool { return jobs[i].Started.Before(jobs[j].Started) })
+		statuses := make([]JobStatus, 0, len(jobs))
+		for _, job := range jobs {
+			statuses = append(statuses, s.status(job))
+		}
+		writeJSON(rw, http.StatusOK, statuses)
+	case len(parts) >= 2:
+		job := s.job(parts[1])
+		if job == nil {
+			http.NotFound(rw, req)
+			return
+		}
+
+		action := ""
+		if len(parts) == 3 {
+			action = parts[2]
+		}
+
+		switch {
+		case action == "" && req.Method == "GET":
+			writeJSON(rw, http.StatusOK, s.status(job))
+		case action == "result" && req.Method == "GET":
+			select {
+			case <-job.done:
+				writeJSON(rw, http.StatusOK, job.queue.Result)
+			default:
+				http.Error(rw, "crawl still running", http.StatusConflict)
+			}
+		case action == "stop" && req.Method == "POST":
+			s.StopJob(job.ID)
+			writeJSON(rw, http.StatusOK, s.status(job))
+		default:
+			http.NotFound(rw, req)
+		}
+	default:
+		http.Error(rw, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func writeJSON(rw http.ResponseWriter, status int, value interface{}) {
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(status)
+	json.NewEncoder(rw).Encode(value)
+}
@@ -0,0 +1,258 @@
+package wikicrawl
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func startServer(t *testing.T, wiki *httptest.Server) (*Server, *httptest.Server) {
+	api := NewServer(wiki.URL, func() *Crawler {
+		return NewCrawler(wiki.URL)
+	})
+
+	return api, httptest.NewServer(api)
+}
+
+func waitForState(t *testing.T, api *httptest.Server, id string, state string) JobStatus {
+	var status JobStatus
+	for i := 0; i < 100; i++ {
+		resp, err := http.Get(api.URL + "/crawls/" + id)
+		if err != nil {
+			t.Fatalf("Status request failed: %s.", err)
+		}
+		json.NewDecoder(resp.Body).Decode(&status)
+		resp.Body.Close()
+
+		if status.State == state {
+			return status
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	t.Fatalf("Crawl never reached state %s, got: %+v.", state, status)
+	return status
+}
+
+func TestServer(t *testing.T) {
+	t.Run("Crawl control API", func(t *testing.T) {
+		t.Run("Start crawl and fetch results", func(t *testing.T) {
+			t.Parallel()
+			wiki := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				if req.URL.Path == "/docs/error" {
+					rw.WriteHeader(500)
+					return
+				}
+				fmt.Fprintf(rw, `<html><body><a href="/docs/a" /><a href="/docs/error" /></body></html>`)
+			}))
+			defer wiki.Close()
+
+			_, api := startServer(t, wiki)
+			defer api.Close()
+
+			resp, err := http.Post(api.URL+"/crawls", "application/json",
+				strings.NewReader(fmt.Sprintf(`{"source": "%s/docs"}`, wiki.URL)))
+			if err != nil || resp.StatusCode != http.StatusCreated {
+				t.Fatalf("Starting crawl failed: %v, %v.", err, resp)
+			}
+
+			var started JobStatus
+			json.NewDecoder(resp.Body).Decode(&started)
+			resp.Body.Close()
+
+			status := waitForState(t, api, started.ID, JobDone)
+			if status.Visited != 3 || status.Broken != 1 {
+				t.Errorf("Crawl status mismatch, got: %+v.", status)
+			}
+
+			resp, err = http.Get(api.URL + "/crawls/" + started.ID + "/result")
+			if err != nil {
+				t.Fatalf("Result request failed: %s.", err)
+			}
+			defer resp.Body.Close()
+
+			var result map[string]interface{}
+			json.NewDecoder(resp.Body).Decode(&result)
+			if broken := result["broken"].([]interface{}); len(broken) != 1 || broken[0] != wiki.URL+"/docs/error" {
+				t.Errorf("Crawl result mismatch, got: %v.", result)
+			}
+		})
+
+		t.Run("Reject sources outside the wiki", func(t *testing.T) {
+			t.Parallel()
+			wiki := httptest.NewServer(http.NotFoundHandler())
+			defer wiki.Close()
+
+			_, api := startServer(t, wiki)
+			defer api.Close()
+
+			resp, err := http.Post(api.URL+"/crawls", "application/json",
+				strings.NewReader(`{"source": "http://otherdomain.com/"}`))
+			if err != nil || resp.StatusCode != http.StatusBadRequest {
+				t.Errorf("Out of scope source should be rejected, got: %v, %v.", err, resp)
+			}
+		})
+
+		t.Run("Stop running crawl", func(t *testing.T) {
+			t.Parallel()
+			wiki := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				time.Sleep(20 * time.Millisecond)
+				fmt.Fprintf(rw, `<html><body><a href="%s/next" /></body></html>`, req.URL.Path)
+			}))
+			defer wiki.Close()
+
+			server, api := startServer(t, wiki)
+			defer api.Close()
+
+			job, err := server.StartJob(wiki.URL + "/start")
+			if err != nil {
+				t.Fatalf("Starting crawl failed: %s.", err)
+			}
+
+			resp, err := http.Get(api.URL + "/crawls/" + job.ID + "/result")
+			if err != nil || resp.StatusCode != http.StatusConflict {
+				t.Errorf("Result of a running crawl should conflict, got: %v, %v.", err, resp)
+			}
+
+			resp, err = http.Post(api.URL+"/crawls/"+job.ID+"/stop", "application/json", nil)
+			if err != nil || resp.StatusCode != http.StatusOK {
+				t.Fatalf("Stopping crawl failed: %v, %v.", err, resp)
+			}
+
+			waitForState(t, api, job.ID, JobStopped)
+			if resp, err = http.Get(api.URL + "/crawls/" + job.ID + "/result"); err != nil || resp.StatusCode != http.StatusOK {
+				t.Errorf("Result of a stopped crawl mismatch, got: %v, %v.", err, resp)
+			}
+		})
+
+		t.Run("Health and readiness", func(t *testing.T) {
+			t.Parallel()
+			wiki := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				time.Sleep(20 * time.Millisecond)
+				fmt.Fprintf(rw, `<html><body><a href="%s/next" /></body></html>`, req.URL.Path)
+			}))
+			defer wiki.Close()
+
+			server, api := startServer(t, wiki)
+			defer api.Close()
+
+			for _, path := range []string{"/healthz", "/readyz"} {
+				resp, err := http.Get(api.URL + path)
+				if err != nil || resp.StatusCode != http.StatusOK {
+					t.Errorf("%s should be ok, got: %v, %v.", path, err, resp)
+				}
+			}
+
+			job, err := server.StartJob(wiki.URL + "/start")
+			if err != nil {
+				t.Fatalf("Starting crawl failed: %s.", err)
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if err := server.Drain(ctx); err != nil {
+				t.Fatalf("Draining failed: %s.", err)
+			}
+			if status := server.status(job); status.State != JobStopped {
+				t.Errorf("State mismatch, got: %s, want: %s.", status.State, JobStopped)
+			}
+
+			resp, err := http.Get(api.URL + "/readyz")
+			if err != nil || resp.StatusCode != http.StatusServiceUnavailable {
+				t.Errorf("Draining server should not be ready, got: %v, %v.", err, resp)
+			}
+			resp, err = http.Get(api.URL + "/healthz")
+			if err != nil || resp.StatusCode != http.StatusOK {
+				t.Errorf("Draining server should be healthy, got: %v, %v.", err, resp)
+			}
+			if _, err := server.StartJob(wiki.URL + "/start"); err != ErrDraining {
+				t.Errorf("Draining server should refuse crawls, got: %v.", err)
+			}
+		})
+
+		t.Run("Serve requests while a crawl starts", func(t *testing.T) {
+			t.Parallel()
+			release := make(chan struct{})
+			wiki := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				if req.URL.Path == "/robots.txt" {
+					<-release
+				}
+				fmt.Fprint(rw, `<html><body>No links</body></html>`)
+			}))
+			defer wiki.Close()
+			defer close(release)
+
+			server := NewServer(wiki.URL, func() *Crawler {
+				return NewCrawler(wiki.URL, WithDiscover())
+			})
+			api := httptest.NewServer(server)
+			defer api.Close()
+
+			job, err := server.StartJob(wiki.URL + "/start")
+			if err != nil {
+				t.Fatalf("Starting crawl failed: %s.", err)
+			}
+
+			client := &http.Client{Timeout: time.Second}
+			for _, path := range []string{"/readyz", "/crawls", "/crawls/" + job.ID} {
+				resp, err := client.Get(api.URL + path)
+				if err != nil || resp.StatusCode != http.StatusOK {
+					t.Errorf("%s should answer while the crawl starts, got: %v, %v.", path, err, resp)
+				}
+			}
+			if resp, err := http.Post(api.URL+"/crawls/"+job.ID+"/stop", "application/json", nil); err != nil || resp.StatusCode != http.StatusOK {
+				t.Errorf("Stopping a starting crawl failed: %v, %v.", err, resp)
+			}
+		})
+
+		t.Run("Crawl a subtree", func(t *testing.T) {
+			t.Parallel()
+			wiki := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				fmt.Fprint(rw, `<html><body><a href="/docs/guide/a" /><a href="/docs/other" /></body></html>`)
+			}))
+			defer wiki.Close()
+
+			_, api := startServer(t, wiki)
+			defer api.Close()
+
+			resp, err := http.Post(api.URL+"/crawls", "application/json",
+				strings.NewReader(fmt.Sprintf(`{"source": "%s/docs/other", "pathPrefix": "/docs/guide/"}`, wiki.URL)))
+			if err != nil || resp.StatusCode != http.StatusBadRequest {
+				t.Errorf("Source outside of the subtree should be rejected, got: %v, %v.", err, resp)
+			}
+
+			resp, err = http.Post(api.URL+"/crawls", "application/json",
+				strings.NewReader(fmt.Sprintf(`{"source": "%s/docs/guide/", "pathPrefix": "/docs/guide/"}`, wiki.URL)))
+			if err != nil || resp.StatusCode != http.StatusCreated {
+				t.Fatalf("Starting crawl failed: %v, %v.", err, resp)
+			}
+
+			var started JobStatus
+			json.NewDecoder(resp.Body).Decode(&started)
+			resp.Body.Close()
+
+			if status := waitForState(t, api, started.ID, JobDone); status.Visited != 2 {
+				t.Errorf("Visited mismatch, got: %d, want: 2 pages of the subtree.", status.Visited)
+			}
+		})
+
+		t.Run("Unknown crawl", func(t *testing.T) {
+			t.Parallel()
+			wiki := httptest.NewServer(http.NotFoundHandler())
+			defer wiki.Close()
+
+			_, api := startServer(t, wiki)
+			defer api.Close()
+
+			resp, err := http.Get(api.URL + "/crawls/42")
+			if err != nil || resp.StatusCode != http.StatusNotFound {
+				t.Errorf("Unknown crawl should not be found, got: %v, %v.", err, resp)
+			}
+		})
+	})
+}
@@ -0,0 +1,129 @@
+package wikicrawl
+
+import (
+	"cmp"
+	"slices"
+	"sync"
+)
+
+// Concurrency safe set of values, unique by the key derived from each value.
+// Values can carry metadata so results do not need parallel maps.
+type Set[K cmp.Ordered, V any] struct {
+	sync.RWMutex
+
+	// Deprecated: Reading the storage of the set directly races with
+	// concurrent writers, use Get, Contains, Len, Keys, Values or Range.
+	// It will be unexported in the next major version.
+	Set map[K]V
+	key func(V) K
+}
+
+// Simple constructor for Set type, key derives the unique key of a value.
+func NewSet[K cmp.Ordered, V any](key func(V) K) Set[K, V] {
+	return Set[K, V]{Set: make(map[K]V, 1), key: key}
+}
+
+// Adds the value unless its key is already present, reports if it was added.
+func (s *Set[K, V]) Add(value V) bool {
+	s.Lock()
+	defer s.Unlock()
+
+	key := s.key(value)
+	_, found := s.Set[key]
+	if !found {
+		s.Set[key] = value
+	}
+
+	return !found
+}
+
+// Adds the value, replacing any value stored under the same key.
+func (s *Set[K, V]) Put(value V) {
+	s.Lock()
+	defer s.Unlock()
+	s.Set[s.key(value)] = value
+}
+
+// Replaces the value stored under key with the result of update, which
+// receives the current value and whether it was found.
+func (s *Set[K, V]) Update(key K, update func(value V, found bool) V) V {
+	s.Lock()
+	defer s.Unlock()
+
+	value, found := s.Set[key]
+	value = update(value, found)
+	s.Set[key] = value
+
+	return value
+}
+
+func (s *Set[K, V]) Remove(key K) {
+	s.Lock()
+	defer s.Unlock()
+	delete(s.Set, key)
+}
+
+func (s *Set[K, V]) Contains(key K) bool {
+	s.RLock()
+	defer s.RUnlock()
+	_, found := s.Set[key]
+	return found
+}
+
+func (s *Set[K, V]) Get(key K) (V, bool) {
+	s.RLock()
+	defer s.RUnlock()
+	value, found := s.Set[key]
+	return value, found
+}
+
+func (s *Set[K, V]) Len() int {
+	s.RLock()
+	defer s.RUnlock()
+	return len(s.Set)
+}
+
+// Calls fn for every key and value in no particular order, until fn
+// returns false. The set is locked for reading meanwhile, so fn must not
+// modify it.
+func (s *Set[K, V]) Range(fn func(key K, value V) bool) {
+	s.RLock()
+	defer s.RUnlock()
+
+	for key, value := range s.Set {
+		if !fn(key, value) {
+			return
+		}
+	}
+}
+
+// Sorted copy of the keys in the set.
+func (s *Set[K, V]) Keys() []K {
+	s.RLock()
+	defer s.RUnlock()
+
+	keys := make([]K, 0, len(s.Set))
+	for key := range s.Set {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+
+	return keys
+}
+
+// Copy of the values in the set, sorted by key.
+func (s *Set[K, V]) Values() []V {
+	keys := s.Keys()
+
+	s.RLock()
+	defer s.RUnlock()
+
+	values := make([]V, 0, len(keys))
+	for _, key := range keys {
+		if value, found := s.Set[key]; found {
+			values = append(values, value)
+		}
+	}
+
+	return values
+}
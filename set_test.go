@@ -0,0 +1,60 @@
+package wikicrawl
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSet(t *testing.T) {
+	t.Run("Generic Set data structure", func(t *testing.T) {
+		key := func(value string) string {
+			return strings.ToLower(value)
+		}
+
+		t.Run("Add keeps first value", func(t *testing.T) {
+			t.Parallel()
+			found := NewSet(key)
+			found.Add("A")
+
+			if found.Add("a") {
+				t.Errorf("Set Add should report false for duplicates.")
+			}
+
+			if value, _ := found.Get("a"); value != "A" {
+				t.Errorf("Set value mismatch, got: %s, want: %s.", value, "A")
+			}
+		})
+
+		t.Run("Put replaces value", func(t *testing.T) {
+			t.Parallel()
+			found := NewSet(key)
+			found.Add("A")
+			found.Put("a")
+
+			if value, _ := found.Get("a"); value != "a" {
+				t.Errorf("Set value mismatch, got: %s, want: %s.", value, "a")
+			}
+		})
+
+		t.Run("Update creates and modifies values", func(t *testing.T) {
+			t.Parallel()
+			found := NewSet(key)
+			found.Update("b", func(value string, exists bool) string {
+				if exists {
+					t.Errorf("Update should not find missing keys.")
+				}
+				return "B"
+			})
+			found.Add("a")
+
+			if keys := found.Keys(); !reflect.DeepEqual(keys, []string{"a", "b"}) {
+				t.Errorf("Set keys mismatch, got: %v, want: %v.", keys, []string{"a", "b"})
+			}
+
+			if values := found.Values(); !reflect.DeepEqual(values, []string{"a", "B"}) {
+				t.Errorf("Set values mismatch, got: %v, want: %v.", values, []string{"a", "B"})
+			}
+		})
+	})
+}
@@ -0,0 +1,143 @@
+package wikicrawl
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// Reads back a result written as a JSON report. Only the link sets, pages
+// and metadata are read, enough to replay its link graph.
+func ReadReport(reader io.Reader) (*CrawlResult, error) {
+	var raw map[string]json.RawMessage
+	if err := json.NewDecoder(reader).Decode(&raw); err != nil {
+		return nil, err
+	}
+
+	result := NewCrawlResult()
+	var pages []PageInfo
+	if err := json.Unmarshal(raw["pages"], &pages); err != nil {
+		return nil, fmt.Errorf("pages: %s", err)
+	}
+	for _, info := range pages {
+		result.Pages.Put(info)
+	}
+
+	for name, set := range result.LinkSets() {
+		var keys []string
+		if data, found := raw[name]; found {
+			if err := json.Unmarshal(data, &keys); err != nil {
+				return nil, fmt.Errorf("%s: %s", name, err)
+			}
+		}
+		for _, key := range keys {
+			link := NewLink(key)
+			if info, found := result.Pages.Get(key); found {
+				link = info.Link
+			}
+			set.Add(link)
+		}
+	}
+
+	if data, found := raw["metadata"]; found {
+		if err := json.Unmarshal(data, &result.Metadata); err != nil {
+			return nil, fmt.Errorf("metadata: %s", err)
+		}
+	}
+	if data, found := raw["aborted"]; found {
+		json.Unmarshal(data, &result.Aborted)
+	}
+
+	return result, nil
+}
+
+// Outcome of replaying a saved crawl with the rules of the crawler.
+//
+//  1. Result: The replayed crawl, links keep their saved status.
+//  2. Added: Pages the replay reaches that the saved crawl never fetched.
+//     Their links are unknown, so the frontier stops there.
+//  3. Dropped: Internal pages the saved crawl visited that the replay no
+//     longer reaches.
+type Simulation struct {
+	Result  *CrawlResult
+	Added   []string
+	Dropped []string
+}
+
+// Replays the link graph of a saved crawl from source through the scope,
+// filters and validators of the crawler without any request, showing what
+// the crawl frontier would become with new rules. External links are not
+// verified, content checks, wikitext and categories are skipped.
+func (c *Crawler) Simulate(saved *CrawlResult, source string) Simulation {
+	replay := *c
+	replay.Client = &http.Client{Transport: offlineTransport{}}
+	replay.Fetcher = &graphFetcher{saved: saved, edges: saved.Edges()}
+	replay.CheckExternal, replay.CheckHosts = false, nil
+	replay.Processors, replay.Assertions, replay.Audit = nil, nil, false
+	replay.Wikitext, replay.Categories, replay.Authenticator = false, nil, nil
+	replay.Previous, replay.Soft404 = nil, nil
+	replay.RateLimit, replay.Retries, replay.MaxLatency = 0, 0, 0
+	replay.Delay, replay.Jitter = 0, 0
+
+	result := replay.Crawl(source)
+	simulation := Simulation{Result: result, Added: []string{}, Dropped: []string{}}
+	for _, key := range result.Visited.Keys() {
+		if !saved.Visited.Contains(key) {
+			simulation.Added = append(simulation.Added, key)
+		}
+	}
+	for _, link := range saved.Visited.Values() {
+		if link.URL != nil && c.IsExternal(link.URL) {
+			continue
+		}
+		if !result.Visited.Contains(link.String()) {
+			simulation.Dropped = append(simulation.Dropped, link.String())
+		}
+	}
+
+	return simulation
+}
+
+// Fetcher serving pages from the link graph of a saved crawl. Pages the
+// crawl never fetched are served empty.
+type graphFetcher struct {
+	saved *CrawlResult
+	edges map[string][]string
+}
+
+func (gf *graphFetcher) Fetch(link Link) (*Page, error) {
+	key := link.String()
+	page := &Page{URL: link.URL, StatusCode: http.StatusOK, Status: "200 OK"}
+	links := NewLinkSet()
+	page.Links = &links
+
+	info, found := gf.saved.Pages.Get(key)
+	if !found || !gf.saved.Visited.Contains(key) {
+		return page, nil
+	}
+	if info.Status == 0 && gf.saved.Broken.Contains(key) {
+		return nil, errors.New("failed in the saved crawl")
+	}
+	if info.Status != 0 {
+		page.StatusCode = info.Status
+		page.Status = fmt.Sprintf("%d %s", info.Status, http.StatusText(info.Status))
+	}
+	if len(info.RedirectTo) > 0 {
+		page.URL = NewLink(info.RedirectTo).URL
+	}
+
+	for _, target := range gf.edges[key] {
+		links.Add(NewLink(target))
+	}
+
+	return page, nil
+}
+
+// Transport refusing every request of a simulated crawl.
+type offlineTransport struct{}
+
+func (offlineTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return nil, fmt.Errorf("simulated crawl, no request to %s", req.URL)
+}
@@ -0,0 +1,112 @@
+package wikicrawl
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// Crawls a small wiki and shuts it down, returning its url and result.
+func savedCrawl(t *testing.T) (string, *CrawlResult) {
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/start":
+			fmt.Fprint(rw, `<html><a href="/a">a</a><a href="/b">b</a></html>`)
+		case "/a":
+			fmt.Fprint(rw, `<html><a href="/gone">gone</a></html>`)
+		case "/b":
+			fmt.Fprint(rw, `<html><a href="/d">d</a></html>`)
+		case "/d":
+			fmt.Fprint(rw, `<html></html>`)
+		default:
+			http.NotFound(rw, req)
+		}
+	}))
+	defer server.Close()
+
+	return server.URL, NewCrawler(server.URL).Crawl(server.URL + "/start")
+}
+
+func TestSimulate(t *testing.T) {
+	t.Run("Replay a saved crawl", func(t *testing.T) {
+		t.Parallel()
+		base, saved := savedCrawl(t)
+
+		t.Run("Same rules", func(t *testing.T) {
+			t.Parallel()
+			simulation := NewCrawler(base).Simulate(saved, base+"/start")
+			if got, want := simulation.Result.Visited.Keys(), saved.Visited.Keys(); !reflect.DeepEqual(got, want) {
+				t.Errorf("Visited mismatch, got: %v, want: %v.", got, want)
+			}
+			if !simulation.Result.Broken.Contains(base + "/gone") {
+				t.Errorf("Broken mismatch, got: %v, want: %s.", simulation.Result.Broken.Keys(), base+"/gone")
+			}
+			if len(simulation.Added) > 0 || len(simulation.Dropped) > 0 {
+				t.Errorf("Changes mismatch, got: %v and %v, want none.", simulation.Added, simulation.Dropped)
+			}
+		})
+
+		t.Run("New exclude rule", func(t *testing.T) {
+			t.Parallel()
+			c := NewCrawler(base, WithValidator(func(link *url.URL) bool {
+				return !strings.HasSuffix(link.Path, "/b")
+			}))
+			simulation := c.Simulate(saved, base+"/start")
+			if want := []string{base + "/b", base + "/d"}; !reflect.DeepEqual(simulation.Dropped, want) {
+				t.Errorf("Dropped mismatch, got: %v, want: %v.", simulation.Dropped, want)
+			}
+			if simulation.Result.Visited.Len() != 3 {
+				t.Errorf("Visited mismatch, got: %v, want: 3 pages.", simulation.Result.Visited.Keys())
+			}
+		})
+	})
+
+	t.Run("Pages never fetched", func(t *testing.T) {
+		t.Parallel()
+		saved := NewCrawlResult()
+		start, sampled := NewLink("http://testing.com/start"), NewLink("http://testing.com/sampled")
+		saved.Visited.Add(start)
+		saved.record(start, func(info *PageInfo) {
+			info.Status = 200
+		})
+		saved.record(sampled, func(info *PageInfo) {
+			info.AddReferrer(start.String())
+		})
+
+		simulation := NewCrawler("http://testing.com").Simulate(saved, start.String())
+		if want := []string{sampled.String()}; !reflect.DeepEqual(simulation.Added, want) {
+			t.Errorf("Added mismatch, got: %v, want: %v.", simulation.Added, want)
+		}
+	})
+}
+
+func TestReadReport(t *testing.T) {
+	t.Run("Read back a JSON report", func(t *testing.T) {
+		t.Parallel()
+		_, saved := savedCrawl(t)
+		report, err := json.Marshal(saved)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		result, err := ReadReport(bytes.NewReader(report))
+		if err != nil {
+			t.Fatalf("Reading report failed: %s.", err)
+		}
+		if got, want := result.Visited.Keys(), saved.Visited.Keys(); !reflect.DeepEqual(got, want) {
+			t.Errorf("Visited mismatch, got: %v, want: %v.", got, want)
+		}
+		if got, want := result.Edges(), saved.Edges(); !reflect.DeepEqual(got, want) {
+			t.Errorf("Edges mismatch, got: %v, want: %v.", got, want)
+		}
+		if result.Metadata.Seed != saved.Metadata.Seed {
+			t.Errorf("Seed mismatch, got: %s, want: %s.", result.Metadata.Seed, saved.Metadata.Seed)
+		}
+	})
+}
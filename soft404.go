@@ -0,0 +1,84 @@
+package wikicrawl
+
+import (
+	"bytes"
+	"io"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// Heuristics for pages answering 200 while actually being "not found".
+//
+//  1. TitlePatterns: Matched against the page title.
+//  2. BodyPatterns: Matched against the visible page text.
+//  3. RedirectToRoot: Deep links ending on the site's home page.
+type Soft404Detector struct {
+	TitlePatterns  []*regexp.Regexp
+	BodyPatterns   []*regexp.Regexp
+	RedirectToRoot bool
+}
+
+// Detector with patterns for the most common English error pages.
+func NewSoft404Detector() *Soft404Detector {
+	return &Soft404Detector{
+		TitlePatterns: []*regexp.Regexp{
+			regexp.MustCompile(`(?i)\b404\b`),
+			regexp.MustCompile(`(?i)(page|file|article) not found`),
+			regexp.MustCompile(`(?i)does not exist`),
+		},
+		BodyPatterns: []*regexp.Regexp{
+			regexp.MustCompile(`(?i)(page|file) (you requested |you are looking for )?(could not be|was not|cannot be) found`),
+		},
+		RedirectToRoot: true,
+	}
+}
+
+// Reports if a successful response is likely an error page.
+func (d *Soft404Detector) Detect(requested *url.URL, final *url.URL, body []byte) bool {
+	if d.RedirectToRoot && strings.Trim(requested.Path, "/") != "" &&
+		strings.Trim(final.Path, "/") == "" && len(final.RawQuery) == 0 {
+		return true
+	}
+
+	title := PageTitle(bytes.NewReader(body))
+	for _, pattern := range d.TitlePatterns {
+		if pattern.MatchString(title) {
+			return true
+		}
+	}
+
+	if len(d.BodyPatterns) > 0 {
+		text := VisibleText(bytes.NewReader(body))
+		for _, pattern := range d.BodyPatterns {
+			if pattern.MatchString(text) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// Parses HTML and returns the content of the title tag.
+func PageTitle(reader io.Reader) string {
+	z := html.NewTokenizer(reader)
+	inTitle := false
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			return ""
+		case html.StartTagToken:
+			name, _ := z.TagName()
+			inTitle = string(name) == "title"
+		case html.EndTagToken:
+			inTitle = false
+		case html.TextToken:
+			if inTitle {
+				return strings.TrimSpace(string(z.Text()))
+			}
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package wikicrawl
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestPageTitle(t *testing.T) {
+	t.Run("Parse HTML title", func(t *testing.T) {
+		t.Run("Title found", func(t *testing.T) {
+			t.Parallel()
+			found := PageTitle(strings.NewReader(`<html><head><title> Main Page </title></head></html>`))
+			if found != "Main Page" {
+				t.Errorf("Title mismatch, got: %s, want: %s.", found, "Main Page")
+			}
+		})
+
+		t.Run("Title missing", func(t *testing.T) {
+			t.Parallel()
+			found := PageTitle(strings.NewReader(`<html><body>Main Page</body></html>`))
+			if found != "" {
+				t.Errorf("Title mismatch, got: %s, want empty title.", found)
+			}
+		})
+	})
+}
+
+func validateSoft404(t *testing.T, requested, final, body string, expected bool) {
+	d := NewSoft404Detector()
+	req, _ := url.Parse(requested)
+	res, _ := url.Parse(final)
+	if d.Detect(req, res, []byte(body)) != expected {
+		t.Errorf("Soft 404 detection mismatch for %s -> %s, want: %t.", requested, final, expected)
+	}
+}
+
+func TestSoft404Detector(t *testing.T) {
+	t.Run("Soft 404 heuristics", func(t *testing.T) {
+		t.Run("Healthy page", func(t *testing.T) {
+			t.Parallel()
+			validateSoft404(t, "http://a.com/doc", "http://a.com/doc",
+				`<html><head><title>Docs</title></head><body>Content</body></html>`, false)
+		})
+
+		t.Run("Title pattern", func(t *testing.T) {
+			t.Parallel()
+			validateSoft404(t, "http://a.com/doc", "http://a.com/doc",
+				`<html><head><title>Page Not Found</title></head></html>`, true)
+		})
+
+		t.Run("Body pattern", func(t *testing.T) {
+			t.Parallel()
+			validateSoft404(t, "http://a.com/doc", "http://a.com/doc",
+				`<html><body>Sorry, the page you requested could not be found.</body></html>`, true)
+		})
+
+		t.Run("Redirect to home page", func(t *testing.T) {
+			t.Parallel()
+			validateSoft404(t, "http://a.com/doc", "http://a.com/", `<html></html>`, true)
+		})
+
+		t.Run("Home page requested", func(t *testing.T) {
+			t.Parallel()
+			validateSoft404(t, "http://a.com/", "http://a.com/", `<html></html>`, false)
+		})
+	})
+}
+
+func TestCrawlSoft404(t *testing.T) {
+	t.Run("Report suspected broken external links", func(t *testing.T) {
+		t.Parallel()
+		external := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			if req.URL.Path == "/gone" {
+				http.Redirect(rw, req, "/", http.StatusFound)
+				return
+			}
+			fmt.Fprintf(rw, `<html><head><title>Home</title></head></html>`)
+		}))
+		defer external.Close()
+		externalURL := strings.Replace(external.URL, "127.0.0.1", "localhost", 1)
+
+		server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			fmt.Fprintf(rw, `<html><body><a href="%s/gone" /><a href="%s/" /></body></html>`,
+				externalURL, externalURL)
+		}))
+		defer server.Close()
+
+		c := NewCrawler(server.URL)
+		c.CheckExternal = true
+		c.Soft404 = NewSoft404Detector()
+		result := c.Crawl(server.URL)
+
+		if len(result.SuspectBroken.Set) != 1 || !result.SuspectBroken.Contains(externalURL+"/gone") {
+			t.Errorf("Suspected broken links mismatch, got: %v.", result.SuspectBroken.Set)
+		}
+
+		if len(result.Broken.Set) != 0 {
+			t.Errorf("Soft 404 pages should not be reported broken, got: %v.", result.Broken.Set)
+		}
+	})
+}
@@ -0,0 +1,93 @@
+package wikicrawl
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// Statuses counted as success besides 200 for urls matching a pattern,
+// e.g. 401 and 403 of pages existing behind a login. A redirect status
+// (3xx) accepts links redirected out of the crawl whatever their target
+// answers.
+type StatusRule struct {
+	URL      *regexp.Regexp
+	Statuses []int
+}
+
+// Parses a rule written as "<url regexp>::<status>,<status>".
+func ParseStatusRule(raw string) (StatusRule, error) {
+	split := strings.LastIndex(raw, "::")
+	if split < 0 {
+		return StatusRule{}, fmt.Errorf("status rule %q missing :: separator", raw)
+	}
+
+	link, err := regexp.Compile(raw[:split])
+	if err != nil {
+		return StatusRule{}, err
+	}
+
+	rule := StatusRule{URL: link}
+	for _, field := range strings.Split(raw[split+2:], ",") {
+		status, err := strconv.Atoi(strings.TrimSpace(field))
+		if err != nil || status < 100 || status > 599 {
+			return StatusRule{}, fmt.Errorf("invalid status %q in rule %q", field, raw)
+		}
+		rule.Statuses = append(rule.Statuses, status)
+	}
+
+	return rule, nil
+}
+
+// Reports if the first rule matching the link accepts its status, or its
+// redirect out of the crawl when redirected.
+func acceptedStatus(link string, status int, redirected bool, rules []StatusRule) bool {
+	for _, rule := range rules {
+		if !rule.URL.MatchString(link) {
+			continue
+		}
+
+		for _, accepted := range rule.Statuses {
+			if accepted == status || redirected && accepted >= 300 && accepted < 400 {
+				return true
+			}
+		}
+		return false
+	}
+
+	return false
+}
+
+// Records a link answered with 401 or 403 as restricted instead of
+// broken when enabled, reporting if it was. Partly private wikis expect
+// those, elsewhere they are a crawler blocked by a firewall.
+func (c *Crawler) restricted(result *CrawlResult, link Link, status int) bool {
+	if !c.Restricted || status != http.StatusUnauthorized && status != http.StatusForbidden {
+		return false
+	}
+
+	c.logger().WithFields(log.Fields{
+		"source": link,
+		"status": status,
+	}).Info("Access restricted")
+	result.Restricted.Add(link)
+	return true
+}
+
+// Reports if a non 200 status of link counts as success per the status
+// rules of the crawler, logging it when it does.
+func (c *Crawler) acceptStatus(link Link, status int, redirected bool) bool {
+	if !acceptedStatus(link.String(), status, redirected, c.StatusRules) {
+		return false
+	}
+
+	c.logger().WithFields(log.Fields{
+		"source": link,
+		"status": status,
+	}).Debug("Status accepted by status rule")
+	return true
+}
@@ -0,0 +1,130 @@
+package wikicrawl
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestParseStatusRule(t *testing.T) {
+	t.Run("Parse status rules", func(t *testing.T) {
+		t.Run("Statuses of matching urls", func(t *testing.T) {
+			t.Parallel()
+			rule, err := ParseStatusRule("/private/::401, 403")
+			if err != nil {
+				t.Fatalf("Parsing failed: %s.", err)
+			}
+			if want := []int{401, 403}; !reflect.DeepEqual(rule.Statuses, want) {
+				t.Errorf("Statuses mismatch, got: %v, want: %v.", rule.Statuses, want)
+			}
+			if !acceptedStatus("http://testing.com/private/a", 403, false, []StatusRule{rule}) {
+				t.Errorf("403 of a private page should be accepted.")
+			}
+			if acceptedStatus("http://testing.com/private/a", 404, false, []StatusRule{rule}) {
+				t.Errorf("404 of a private page should not be accepted.")
+			}
+			if acceptedStatus("http://testing.com/public", 403, false, []StatusRule{rule}) {
+				t.Errorf("403 of a public page should not be accepted.")
+			}
+		})
+
+		t.Run("Redirects out of the crawl", func(t *testing.T) {
+			t.Parallel()
+			rule, _ := ParseStatusRule("/go/::301")
+			if !acceptedStatus("http://testing.com/go/sso", 401, true, []StatusRule{rule}) {
+				t.Errorf("Redirect out of the crawl should be accepted.")
+			}
+			if acceptedStatus("http://testing.com/go/sso", 401, false, []StatusRule{rule}) {
+				t.Errorf("401 without redirect should not be accepted.")
+			}
+		})
+
+		t.Run("Invalid status", func(t *testing.T) {
+			t.Parallel()
+			if _, err := ParseStatusRule("/private/::forbidden"); err == nil {
+				t.Errorf("Invalid status should fail.")
+			}
+			if _, err := ParseStatusRule("/private/"); err == nil {
+				t.Errorf("Missing separator should fail.")
+			}
+		})
+	})
+}
+
+func TestStatusRules(t *testing.T) {
+	t.Run("Accept configured statuses", func(t *testing.T) {
+		t.Parallel()
+		server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			switch req.URL.Path {
+			case "/":
+				fmt.Fprint(rw, `<html><a href="/private/a">a</a><a href="/gone">gone</a></html>`)
+			case "/private/a":
+				rw.WriteHeader(http.StatusForbidden)
+			default:
+				http.NotFound(rw, req)
+			}
+		}))
+		defer server.Close()
+
+		rule, _ := ParseStatusRule("/private/::401,403")
+		result := NewCrawler(server.URL, WithStatusRules(rule)).Crawl(server.URL + "/")
+		if found, want := result.Broken.Keys(), []string{server.URL + "/gone"}; !reflect.DeepEqual(found, want) {
+			t.Errorf("Broken mismatch, got: %v, want: %v.", found, want)
+		}
+		if info, _ := result.Pages.Get(server.URL + "/private/a"); info.Status != http.StatusForbidden {
+			t.Errorf("Status mismatch, got: %d, want: 403.", info.Status)
+		}
+	})
+}
+
+func TestRestricted(t *testing.T) {
+	t.Run("Report 401 and 403 apart from broken links", func(t *testing.T) {
+		t.Parallel()
+		server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			switch req.URL.Path {
+			case "/":
+				fmt.Fprint(rw, `<html><a href="/private">private</a><a href="/staff">staff</a><a href="/gone">gone</a></html>`)
+			case "/private":
+				rw.WriteHeader(http.StatusUnauthorized)
+			case "/staff":
+				rw.WriteHeader(http.StatusForbidden)
+			default:
+				http.NotFound(rw, req)
+			}
+		}))
+		defer server.Close()
+
+		result := NewCrawler(server.URL, WithRestricted()).Crawl(server.URL + "/")
+		if found, want := result.Restricted.Keys(), []string{server.URL + "/private", server.URL + "/staff"}; !reflect.DeepEqual(found, want) {
+			t.Errorf("Restricted mismatch, got: %v, want: %v.", found, want)
+		}
+		if found, want := result.Broken.Keys(), []string{server.URL + "/gone"}; !reflect.DeepEqual(found, want) {
+			t.Errorf("Broken mismatch, got: %v, want: %v.", found, want)
+		}
+		if err := result.Err(server.URL + "/staff"); err != nil {
+			t.Errorf("Restricted page should have no error, got: %s.", err)
+		}
+		if severity := result.Severity(); severity != SeverityWarning {
+			t.Errorf("Severity mismatch, got: %s, want: warning.", severity)
+		}
+	})
+
+	t.Run("Broken unless enabled", func(t *testing.T) {
+		t.Parallel()
+		server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			if req.URL.Path == "/blocked" {
+				rw.WriteHeader(http.StatusForbidden)
+				return
+			}
+			fmt.Fprint(rw, `<html><a href="/blocked">blocked</a></html>`)
+		}))
+		defer server.Close()
+
+		result := NewCrawler(server.URL).Crawl(server.URL + "/")
+		if !result.Broken.Contains(server.URL+"/blocked") || result.Restricted.Len() != 0 {
+			t.Errorf("Blocked page should be broken, got: %v, restricted: %v.", result.Broken.Keys(), result.Restricted.Keys())
+		}
+	})
+}
@@ -0,0 +1,153 @@
+package wikicrawl
+
+import (
+	"sort"
+	"time"
+)
+
+// Persistent storage of crawl runs: the pages seen with their metadata,
+// the edges of the link graph between them and the runs themselves.
+type Store interface {
+	// Saves every page, edge and link set of a result under run.
+	SaveRun(run string, result *CrawlResult) error
+	// Loads a saved run back in memory.
+	LoadRun(run string) (*CrawlResult, error)
+	// Saved runs, oldest first.
+	Runs() ([]Run, error)
+	// Urls linked from page in run, the outgoing edges of the page.
+	Edges(run, page string) ([]string, error)
+	Close() error
+}
+
+// Crawl run kept by a Store.
+type Run struct {
+	ID       string   `json:"id"`
+	Metadata Metadata `json:"metadata"`
+	Aborted  bool     `json:"aborted,omitempty"`
+}
+
+// Outgoing edges of the link graph of a result, keyed by linking page. Each
+// list of targets is sorted.
+func (cr *CrawlResult) Edges() map[string][]string {
+	edges := make(map[string][]string)
+	for _, info := range cr.Pages.Values() {
+		for _, referrer := range info.Referrers {
+			edges[referrer] = append(edges[referrer], info.Link.String())
+		}
+	}
+
+	for _, targets := range edges {
+		sort.Strings(targets)
+	}
+
+	return edges
+}
+
+// Latest saved run, nil when the store holds none.
+func LatestRun(store Store) (*Run, error) {
+	runs, err := store.Runs()
+	if err != nil || len(runs) == 0 {
+		return nil, err
+	}
+
+	return &runs[len(runs)-1], nil
+}
+
+// Changes between two runs of the same wiki.
+//
+//  1. NewlyBroken: Links broken in the new run only.
+//  2. Fixed: Links broken in the old run only.
+//  3. Added: Pages visited in the new run only.
+//  4. Removed: Pages visited in the old run only.
+type RunDiff struct {
+	NewlyBroken []string `json:"newlyBroken"`
+	Fixed       []string `json:"fixed"`
+	Added       []string `json:"added"`
+	Removed     []string `json:"removed"`
+}
+
+// Differences of the new result from the old one.
+func DiffRuns(old, new *CrawlResult) RunDiff {
+	return RunDiff{
+		NewlyBroken: missingFrom(new.Broken.Keys(), &old.Broken),
+		Fixed:       missingFrom(old.Broken.Keys(), &new.Broken),
+		Added:       missingFrom(new.Visited.Keys(), &old.Visited),
+		Removed:     missingFrom(old.Visited.Keys(), &new.Visited),
+	}
+}
+
+// Dates every broken link of the result with the first run it was found
+// broken in, carried over from the previous run while the link stays
+// broken. Links broken for longer than escalate are added to LongBroken,
+// making the result critical; no link is escalated when escalate is 0.
+func (cr *CrawlResult) TrackBrokenSince(previous *CrawlResult, escalate time.Duration) {
+	started := cr.Metadata.Started
+	if started.IsZero() {
+		started = time.Now()
+	}
+
+	for _, link := range cr.Broken.Values() {
+		since := started
+		if info, _ := cr.Pages.Get(link.String()); !info.BrokenSince.IsZero() {
+			// Resumed runs keep the dates of their first part.
+			since = info.BrokenSince
+		} else if previous != nil && previous.Broken.Contains(link.String()) {
+			// Runs saved before the tracking only tell the link was broken then.
+			since = previous.Metadata.Started
+			if info, _ := previous.Pages.Get(link.String()); !info.BrokenSince.IsZero() {
+				since = info.BrokenSince
+			}
+		}
+		if since.IsZero() {
+			since = started
+		}
+
+		cr.record(link, func(info *PageInfo) {
+			info.BrokenSince = since
+		})
+		if escalate > 0 && started.Sub(since) > escalate {
+			cr.LongBroken.Add(link)
+		}
+	}
+}
+
+// Keys not contained in set.
+func missingFrom(keys []string, set *LinkSet) []string {
+	missing := []string{}
+	for _, key := range keys {
+		if !set.Contains(key) {
+			missing = append(missing, key)
+		}
+	}
+
+	return missing
+}
+
+// Continues a crawl from a previous, usually aborted, result: links seen
+// but never visited are queued again and everything found is added to the
+// previous result.
+func (c *Crawler) Resume(previous *CrawlResult) *CrawlResult {
+	queue := c.StartResume(previous)
+	queue.Wait()
+	return queue.Result
+}
+
+// Resumes a crawl in the background like Resume, the returned queue allows
+// waiting for, inspecting and aborting it.
+func (c *Crawler) StartResume(previous *CrawlResult) *WorkQueue {
+	previous.Aborted = false
+	previous.Stall = nil
+	queue := c.start(previous)
+
+	for _, info := range previous.Pages.Values() {
+		link := info.Link
+		if link.URL == nil || previous.Visited.Contains(link.String()) {
+			continue
+		}
+		if (c.ShouldVerify(link.URL) || c.ValidateLink(link.URL)) && c.sampled(link) {
+			queue.AddWork(link)
+		}
+	}
+
+	return queue
+}
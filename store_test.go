@@ -0,0 +1,110 @@
+package wikicrawl
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDiffRuns(t *testing.T) {
+	t.Run("Compare two runs", func(t *testing.T) {
+		t.Parallel()
+		old, new := NewCrawlResult(), NewCrawlResult()
+		for _, link := range []string{"http://testing.com/a", "http://testing.com/fixed", "http://testing.com/removed"} {
+			old.Visited.Add(NewLink(link))
+		}
+		old.Broken.Add(NewLink("http://testing.com/fixed"))
+		for _, link := range []string{"http://testing.com/a", "http://testing.com/fixed", "http://testing.com/gone"} {
+			new.Visited.Add(NewLink(link))
+		}
+		new.Broken.Add(NewLink("http://testing.com/gone"))
+
+		expected := RunDiff{
+			NewlyBroken: []string{"http://testing.com/gone"},
+			Fixed:       []string{"http://testing.com/fixed"},
+			Added:       []string{"http://testing.com/gone"},
+			Removed:     []string{"http://testing.com/removed"},
+		}
+		if found := DiffRuns(old, new); !reflect.DeepEqual(found, expected) {
+			t.Errorf("Diff mismatch, got: %+v, want: %+v.", found, expected)
+		}
+	})
+}
+
+func TestTrackBrokenSince(t *testing.T) {
+	t.Run("Date broken links across runs", func(t *testing.T) {
+		t.Parallel()
+		first := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+		run := func(started time.Time, broken ...string) *CrawlResult {
+			result := NewCrawlResult()
+			result.Metadata.Started = started
+			for _, key := range broken {
+				result.Broken.Add(NewLink("http://testing.com/" + key))
+			}
+			return result
+		}
+
+		old := run(first, "old", "fixed")
+		old.TrackBrokenSince(nil, 0)
+		middle := run(first.AddDate(0, 0, 20), "old", "recent")
+		middle.TrackBrokenSince(old, 0)
+		latest := run(first.AddDate(0, 0, 40), "old", "recent", "new")
+		latest.TrackBrokenSince(middle, 30*24*time.Hour)
+
+		for key, want := range map[string]time.Time{"old": first, "recent": first.AddDate(0, 0, 20), "new": first.AddDate(0, 0, 40)} {
+			if info, _ := latest.Pages.Get("http://testing.com/" + key); !info.BrokenSince.Equal(want) {
+				t.Errorf("Broken since mismatch for %s, got: %s, want: %s.", key, info.BrokenSince, want)
+			}
+		}
+		if keys := latest.LongBroken.Keys(); !reflect.DeepEqual(keys, []string{"http://testing.com/old"}) || latest.Severity() != SeverityCritical {
+			t.Errorf("Escalation mismatch, got: %v (%s), want: old (critical).", keys, latest.Severity())
+		}
+	})
+}
+
+func TestResume(t *testing.T) {
+	t.Run("Continue an aborted crawl", func(t *testing.T) {
+		t.Parallel()
+		requested := NewLinkSet()
+		server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			requested.Add(NewLink(req.URL.Path))
+			switch req.URL.Path {
+			case "/":
+				fmt.Fprint(rw, `<a href="/a">A</a><a href="/b">B</a>`)
+			case "/b":
+				fmt.Fprint(rw, `<a href="/c">C</a>`)
+			default:
+				fmt.Fprint(rw, `<html><body></body></html>`)
+			}
+		}))
+		defer server.Close()
+
+		c := NewCrawler(server.URL)
+		previous := NewCrawlResult()
+		previous.Aborted = true
+		previous.Visited.Add(c.Seed(server.URL + "/"))
+		previous.Visited.Add(NewLink(server.URL + "/a"))
+		for _, path := range []string{"/a", "/b"} {
+			link := NewLink(server.URL + path)
+			link.Depth = 1
+			previous.record(link, func(info *PageInfo) {
+				info.AddReferrer(server.URL + "/")
+			})
+		}
+
+		result := c.Resume(previous)
+		expected := []string{server.URL + "/", server.URL + "/a", server.URL + "/b", server.URL + "/c"}
+		if found := result.Visited.Keys(); !reflect.DeepEqual(found, expected) {
+			t.Errorf("Visited mismatch, got: %v, want: %v.", found, expected)
+		}
+		if expected := []string{"/b", "/c"}; !reflect.DeepEqual(requested.Keys(), expected) {
+			t.Errorf("Requested mismatch, got: %v, want: %v.", requested.Keys(), expected)
+		}
+		if result.Aborted {
+			t.Errorf("Resumed crawl should not be aborted.")
+		}
+	})
+}
@@ -0,0 +1,54 @@
+package wikicrawl
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestTracing(t *testing.T) {
+	t.Run("OpenTelemetry crawl spans", func(t *testing.T) {
+		t.Parallel()
+		server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			if req.URL.Path == "/" {
+				fmt.Fprintf(rw, `<html><body><a href="/a" /></body></html>`)
+			}
+		}))
+		defer server.Close()
+
+		recorder := tracetest.NewSpanRecorder()
+		provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+		NewCrawler(server.URL, WithTracerProvider(provider)).Crawl(server.URL + "/")
+
+		spans := make(map[string][]sdktrace.ReadOnlySpan)
+		for _, span := range recorder.Ended() {
+			spans[span.Name()] = append(spans[span.Name()], span)
+		}
+
+		if len(spans["crawl"]) != 1 || len(spans["page"]) != 2 || len(spans["HTTP GET"]) != 2 {
+			t.Fatalf("Span count mismatch, got: %d crawl, %d page, %d HTTP spans.",
+				len(spans["crawl"]), len(spans["page"]), len(spans["HTTP GET"]))
+		}
+
+		crawl := spans["crawl"][0].SpanContext().SpanID()
+		for _, page := range spans["page"] {
+			if page.Parent().SpanID() != crawl {
+				t.Errorf("Page span parent mismatch, got: %s, want: %s.", page.Parent().SpanID(), crawl)
+			}
+		}
+
+		pages := make(map[string]bool)
+		for _, page := range spans["page"] {
+			pages[page.SpanContext().SpanID().String()] = true
+		}
+		for _, request := range spans["HTTP GET"] {
+			if !pages[request.Parent().SpanID().String()] {
+				t.Errorf("HTTP span should be a child of a page span, got parent: %s.", request.Parent().SpanID())
+			}
+		}
+	})
+}
@@ -0,0 +1,66 @@
+package wikicrawl
+
+import (
+	"net"
+	"net/url"
+	"strings"
+	"unicode"
+
+	"golang.org/x/net/idna"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Host in its lower case ASCII (punycode) form, keeping the port, so
+// internationalized domain names match however they are written:
+// bücher.example, BÜCHER.example and xn--bcher-kva.example are one host.
+// Hosts IDNA rejects (IP literals, underscores) are only lower cased.
+func asciiHost(host string) string {
+	hostname, port, err := net.SplitHostPort(host)
+	if err != nil {
+		hostname, port = host, ""
+	}
+
+	ascii, err := idna.Lookup.ToASCII(hostname)
+	if err != nil {
+		return strings.ToLower(host)
+	}
+	if len(port) > 0 {
+		return net.JoinHostPort(ascii, port)
+	}
+
+	return ascii
+}
+
+// Brings the path and title of a url to one form, so a page is not split
+// into several urls by how its Unicode characters were written:
+//
+//  1. The path is NFC normalized and percent encoded the standard way,
+//     upper case hex and only the bytes that need it. Paths encoding a
+//     slash (%2F) keep their encoding.
+//  2. The title parameter is normalized as by wikiTitle.
+func normalizeUnicode(link *url.URL) {
+	link.Path = norm.NFC.String(link.Path)
+	if !strings.Contains(strings.ToUpper(link.RawPath), "%2F") {
+		link.RawPath = ""
+	}
+
+	if !strings.Contains(link.RawQuery, "title=") {
+		return
+	}
+	query, err := url.ParseQuery(link.RawQuery)
+	if err != nil || len(query.Get("title")) == 0 {
+		return
+	}
+	query.Set("title", wikiTitle(query.Get("title")))
+	link.RawQuery = query.Encode()
+}
+
+// Title as MediaWiki stores it: NFC normalized, with single underscores
+// for runs of spaces, Unicode ones such as the ideographic space (U+3000)
+// and non-breaking space included, and none at either end. Full-width
+// letters and digits are distinct characters in titles and kept.
+func wikiTitle(title string) string {
+	return strings.Join(strings.FieldsFunc(norm.NFC.String(title), func(r rune) bool {
+		return r == '_' || unicode.IsSpace(r) || unicode.Is(unicode.Zs, r)
+	}), "_")
+}
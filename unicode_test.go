@@ -0,0 +1,123 @@
+package wikicrawl
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestNormalizeUnicode(t *testing.T) {
+	base, _ := url.Parse("http://wiki.example.jp")
+	normalized := func(raw string) string {
+		link, err := url.Parse(raw)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return NormalizeUrl(link, base).String()
+	}
+
+	t.Run("Same page however it is written", func(t *testing.T) {
+		t.Parallel()
+		same := map[string][]string{
+			"percent encoded title": {
+				"/index.php?title=日本語",
+				"/index.php?title=%E6%97%A5%E6%9C%AC%E8%AA%9E",
+				"/index.php?title=%e6%97%a5%e6%9c%ac%e8%aa%9e",
+			},
+			"percent encoded path": {
+				"/wiki/日本語",
+				"/wiki/%E6%97%A5%E6%9C%AC%E8%AA%9E",
+				"/wiki/%e6%97%a5%e6%9c%ac%e8%aa%9e",
+			},
+			"decomposed characters": {
+				"/index.php?title=%E3%81%8C",
+				"/index.php?title=%E3%81%8B%E3%82%99",
+			},
+			"ideographic space": {
+				"/index.php?title=全角_スペース",
+				"/index.php?title=全角　スペース",
+				"/index.php?title=全角+スペース",
+			},
+			"internationalized domain name": {
+				"http://日本.example.jp/wiki/Main",
+				"http://xn--wgv71a.example.jp/wiki/Main",
+				"http://ＸＮ--ＷＧＶ７１Ａ.example.jp/wiki/Main",
+			},
+		}
+		for name, links := range same {
+			want := normalized(links[0])
+			for _, link := range links[1:] {
+				if got := normalized(link); got != want {
+					t.Errorf("Normalized %s mismatch, got: %s, want: %s.", name, got, want)
+				}
+			}
+		}
+	})
+
+	t.Run("Full-width letters are distinct titles", func(t *testing.T) {
+		t.Parallel()
+		if got, other := normalized("/index.php?title=Ｗｉｋｉ"), normalized("/index.php?title=Wiki"); got == other {
+			t.Errorf("Normalized mismatch, got: %s for both, want distinct urls.", got)
+		}
+	})
+
+	t.Run("Encoded slashes are kept", func(t *testing.T) {
+		t.Parallel()
+		if got, want := normalized("/wiki/AC%2FDC"), "http://wiki.example.jp/wiki/AC%2FDC"; got != want {
+			t.Errorf("Normalized mismatch, got: %s, want: %s.", got, want)
+		}
+	})
+
+	t.Run("Hosts IDNA rejects", func(t *testing.T) {
+		t.Parallel()
+		for host, want := range map[string]string{"[::1]:8080": "[::1]:8080", "Under_Score.example": "under_score.example", "127.0.0.1:80": "127.0.0.1:80"} {
+			if got := asciiHost(host); got != want {
+				t.Errorf("Host mismatch, got: %s, want: %s.", got, want)
+			}
+		}
+	})
+}
+
+func TestUnicodeCrawl(t *testing.T) {
+	t.Run("Pages are not split by encoding", func(t *testing.T) {
+		t.Parallel()
+		server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			switch req.URL.Query().Get("title") {
+			case "メインページ":
+				io.WriteString(rw, `<a href="/index.php?title=日本語_記事">a</a>
+					<a href="/index.php?title=%E6%97%A5%E6%9C%AC%E8%AA%9E%E3%80%80%E8%A8%98%E4%BA%8B">b</a>
+					<a href="/index.php?title=%e6%97%a5%e6%9c%ac%e8%aa%9e+%e8%a8%98%e4%ba%8b">c</a>`)
+			default:
+				fmt.Fprint(rw, `<html><body></body></html>`)
+			}
+		}))
+		defer server.Close()
+
+		result := NewCrawler(server.URL + "/index.php").Crawl(server.URL + "/index.php?title=メインページ")
+		if result.Visited.Len() != 2 {
+			t.Errorf("Visited mismatch, got: %v, want: 2 pages.", result.Visited.Keys())
+		}
+	})
+
+	t.Run("Internationalized base host", func(t *testing.T) {
+		t.Parallel()
+		c := NewCrawler("http://日本.example.jp/wiki/")
+		if link, _ := url.Parse("http://xn--wgv71a.example.jp/wiki/Main"); !c.ValidateLink(link) {
+			t.Errorf("Url incorrectly marked as invalid: %s.", link)
+		}
+		if seed := c.Seed("http://日本.example.jp/wiki/Main"); seed.Class != InternalLink {
+			t.Errorf("Class mismatch, got: %s, want: internal.", seed.Class)
+		}
+	})
+
+	t.Run("Decomposed path prefix", func(t *testing.T) {
+		t.Parallel()
+		c := NewCrawler("http://wiki.example.jp", WithScope(true, "/wiki/Cafe\u0301/"))
+		if link, _ := url.Parse("http://wiki.example.jp/wiki/Caf%C3%A9/Menu"); !c.ValidateLink(link) {
+			t.Errorf("Url incorrectly marked as invalid: %s.", link)
+		}
+	})
+}
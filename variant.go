@@ -0,0 +1,144 @@
+package wikicrawl
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// Middleware requesting another variant of the wiki, keeping crawled urls
+// unchanged so results of both variants can be compared. Requests to the
+// host of base are sent to host when set (e.g. m.wiki-url) and ask for skin
+// when set (e.g. minerva, the mobile skin).
+func Variant(base, host, skin string) Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			if !strings.EqualFold(req.URL.Host, base) {
+				return next.RoundTrip(req)
+			}
+
+			req = req.Clone(req.Context())
+			if len(host) > 0 {
+				req.URL.Host = host
+				req.Host = host
+			}
+			if len(skin) > 0 {
+				query := req.URL.Query()
+				query.Set("useskin", skin)
+				req.URL.RawQuery = query.Encode()
+			}
+
+			return next.RoundTrip(req)
+		})
+	}
+}
+
+// Variant requesting the mobile pages of the wiki at base, from the m.
+// host when given or with the minerva skin otherwise.
+func MobileVariant(base, host string) Middleware {
+	if len(host) > 0 {
+		return Variant(base, host, "")
+	}
+
+	return Variant(base, "", "minerva")
+}
+
+// Crawls the wiki from source under two skins, requested with useskin=,
+// and compares the links of their pages, catching navigation one skin
+// renders and the other omits. The second crawl only expands the pages the
+// first one parsed so both cover the same page set, pages linked under the
+// second skin only are verified without being crawled.
+func (c *Crawler) CompareSkins(source, first, second string) VariantDiff {
+	crawl := *c
+	crawl.Use(Variant(c.base.Host, "", first))
+	firstResult := crawl.Crawl(source)
+
+	pages := make(map[string]bool)
+	for _, info := range firstResult.Pages.Values() {
+		if info.Parsed {
+			pages[info.Link.String()] = true
+		}
+	}
+
+	crawl = *c
+	crawl.Use(Variant(c.base.Host, "", second))
+	crawl.pageSet = pages
+	return CompareResults(firstResult, crawl.Crawl(source))
+}
+
+// Differences between crawls of two variants of the wiki, only pages
+// parsed in both crawls are compared.
+//
+//  1. Missing: Links found on a page in the first crawl only, keyed by page.
+//  2. Extra: Links found on a page in the second crawl only, keyed by page.
+//  3. Broken: Links broken in the second crawl only.
+type VariantDiff struct {
+	Missing map[string][]string `json:"missing"`
+	Extra   map[string][]string `json:"extra"`
+	Broken  []string            `json:"broken"`
+}
+
+// Compares the link sets of the pages of two crawls, e.g. desktop and mobile.
+func CompareResults(first, second *CrawlResult) VariantDiff {
+	diff := VariantDiff{
+		Missing: make(map[string][]string),
+		Extra:   make(map[string][]string),
+	}
+
+	firstLinks := pageLinks(first)
+	secondLinks := pageLinks(second)
+	for page, links := range firstLinks {
+		other, found := secondLinks[page]
+		if !found {
+			continue
+		}
+
+		if missing := difference(links, other); len(missing) > 0 {
+			diff.Missing[page] = missing
+		}
+		if extra := difference(other, links); len(extra) > 0 {
+			diff.Extra[page] = extra
+		}
+	}
+
+	for _, key := range second.Broken.Keys() {
+		if !first.Broken.Contains(key) {
+			diff.Broken = append(diff.Broken, key)
+		}
+	}
+
+	return diff
+}
+
+// Links found on every parsed page of a result, from the referrers.
+func pageLinks(result *CrawlResult) map[string]map[string]bool {
+	pages := make(map[string]map[string]bool)
+	infos := result.Pages.Values()
+	for _, info := range infos {
+		if info.Parsed {
+			pages[info.Link.String()] = make(map[string]bool)
+		}
+	}
+
+	for _, info := range infos {
+		for _, referrer := range info.Referrers {
+			if links, found := pages[referrer]; found {
+				links[info.Link.String()] = true
+			}
+		}
+	}
+
+	return pages
+}
+
+func difference(links, other map[string]bool) []string {
+	var missing []string
+	for link := range links {
+		if !other[link] {
+			missing = append(missing, link)
+		}
+	}
+	sort.Strings(missing)
+
+	return missing
+}
@@ -0,0 +1,106 @@
+package wikicrawl
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestVariant(t *testing.T) {
+	t.Run("Request headers and skin", func(t *testing.T) {
+		t.Parallel()
+		var mu sync.Mutex
+		var requests []string
+		server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			mu.Lock()
+			defer mu.Unlock()
+			requests = append(requests, req.Header.Get("Accept-Language")+" "+req.URL.Query().Get("useskin"))
+		}))
+		defer server.Close()
+
+		NewCrawler(server.URL, WithAcceptLanguage("de"), WithMobile("")).Crawl(server.URL + "/?title=Main")
+		expected := []string{"de minerva"}
+		if !reflect.DeepEqual(requests, expected) {
+			t.Errorf("Requests mismatch, got: %v, want: %v.", requests, expected)
+		}
+	})
+
+	t.Run("Compare desktop and mobile links", func(t *testing.T) {
+		t.Parallel()
+		server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			mobile := req.URL.Query().Get("useskin") == "minerva"
+			switch req.URL.Path {
+			case "/Main":
+				if mobile {
+					fmt.Fprint(rw, `<html><body><a href="/Shared">S</a><a href="/Menu">M</a></body></html>`)
+					return
+				}
+				fmt.Fprint(rw, `<html><body><a href="/Shared">S</a><a href="/Sidebar">B</a></body></html>`)
+			case "/Menu":
+				rw.WriteHeader(http.StatusNotFound)
+			default:
+				fmt.Fprint(rw, `<html><body></body></html>`)
+			}
+		}))
+		defer server.Close()
+
+		desktop := NewCrawler(server.URL, WithDeterministic()).Crawl(server.URL + "/Main")
+		mobile := NewCrawler(server.URL, WithDeterministic(), WithMobile("")).Crawl(server.URL + "/Main")
+		diff := CompareResults(desktop, mobile)
+
+		expected := VariantDiff{
+			Missing: map[string][]string{server.URL + "/Main": {server.URL + "/Sidebar"}},
+			Extra:   map[string][]string{server.URL + "/Main": {server.URL + "/Menu"}},
+			Broken:  []string{server.URL + "/Menu"},
+		}
+		if !reflect.DeepEqual(diff, expected) {
+			t.Errorf("Variant diff mismatch, got: %v, want: %v.", diff, expected)
+		}
+	})
+}
+
+func TestCompareSkins(t *testing.T) {
+	t.Run("Compare the same pages under two skins", func(t *testing.T) {
+		t.Parallel()
+		var mu sync.Mutex
+		requests := make(map[string]int)
+		server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			skin := req.URL.Query().Get("useskin")
+			mu.Lock()
+			requests[req.URL.Path+" "+skin]++
+			mu.Unlock()
+
+			switch req.URL.Path {
+			case "/Main":
+				if skin == "timeless" {
+					fmt.Fprint(rw, `<html><body><a href="/Shared">S</a><a href="/Tools">T</a></body></html>`)
+					return
+				}
+				fmt.Fprint(rw, `<html><body><a href="/Shared">S</a><a href="/Sidebar">B</a></body></html>`)
+			case "/Tools":
+				fmt.Fprint(rw, `<html><body><a href="/Deeper">D</a></body></html>`)
+			default:
+				fmt.Fprint(rw, `<html><body></body></html>`)
+			}
+		}))
+		defer server.Close()
+
+		diff := NewCrawler(server.URL).CompareSkins(server.URL+"/Main", "vector", "timeless")
+		expected := VariantDiff{
+			Missing: map[string][]string{server.URL + "/Main": {server.URL + "/Sidebar"}},
+			Extra:   map[string][]string{server.URL + "/Main": {server.URL + "/Tools"}},
+		}
+		if !reflect.DeepEqual(diff, expected) {
+			t.Errorf("Skin diff mismatch, got: %v, want: %v.", diff, expected)
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		if requests["/Tools timeless"] != 1 || requests["/Deeper timeless"] != 0 {
+			t.Errorf("Pages outside the page set should be verified only, got: %v.", requests)
+		}
+	})
+}
@@ -0,0 +1,88 @@
+package wikicrawl
+
+import (
+	"runtime"
+	"sync/atomic"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// Diagnostics of a crawl aborted by the watchdog.
+//
+//  1. LastProgress: When a link was last done.
+//  2. InFlight: Links workers were stuck on.
+//  3. Pending: Number of links waiting for a worker.
+//  4. Stacks: Stack dump of every goroutine.
+type StallReport struct {
+	LastProgress time.Time `json:"lastProgress"`
+	InFlight     []string  `json:"inFlight"`
+	Pending      int       `json:"pending"`
+	Stacks       string    `json:"stacks"`
+}
+
+// Progress of the workers watched for stalls.
+type watchdog struct {
+	progress atomic.Int64
+	inFlight LinkSet
+	stalled  chan struct{}
+}
+
+func newWatchdog() *watchdog {
+	dog := &watchdog{inFlight: NewLinkSet(), stalled: make(chan struct{})}
+	dog.touch()
+	return dog
+}
+
+// Records progress of the crawl.
+func (w *watchdog) touch() {
+	w.progress.Store(time.Now().UnixNano())
+}
+
+func (w *watchdog) lastProgress() time.Time {
+	return time.Unix(0, w.progress.Load())
+}
+
+// Aborts the crawl with diagnostics when no link is done for timeout while
+// work remains, instead of hanging forever. Wait returns without waiting
+// for the stuck workers.
+func (wq *WorkQueue) Watch(timeout time.Duration) {
+	go func() {
+		ticker := time.NewTicker(timeout / 4)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				outstanding := wq.watchdog.inFlight.Len() + wq.Pending()
+				if outstanding > 0 && time.Since(wq.watchdog.lastProgress()) >= timeout {
+					wq.stall()
+					return
+				}
+			case <-wq.metrics.done:
+				return
+			}
+		}
+	}()
+}
+
+func (wq *WorkQueue) stall() {
+	stacks := make([]byte, 1<<20)
+	stacks = stacks[:runtime.Stack(stacks, true)]
+
+	report := &StallReport{
+		LastProgress: wq.watchdog.lastProgress(),
+		InFlight:     wq.watchdog.inFlight.Keys(),
+		Pending:      wq.Pending(),
+		Stacks:       string(stacks),
+	}
+	wq.crawler.logger().WithFields(log.Fields{
+		"lastProgress": report.LastProgress,
+		"inFlight":     report.InFlight,
+		"pending":      report.Pending,
+	}).Error("Crawl stalled, aborting")
+	wq.crawler.logger().Debug(report.Stacks)
+
+	wq.Result.Stall = report
+	wq.Abort()
+	close(wq.watchdog.stalled)
+}
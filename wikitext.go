@@ -0,0 +1,186 @@
+package wikicrawl
+
+import (
+	"context"
+	"net/url"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+var (
+	wikiLinkPattern     = regexp.MustCompile(`\[\[([^\[\]|#]*)(#[^\[\]|]*)?(\|[^\[\]]*)?\]\]`)
+	externalLinkPattern = regexp.MustCompile(`https?://[^\s\[\]<>"{}|]+`)
+	relativeLinkPattern = regexp.MustCompile(`\[//[^\s\[\]<>"{}|]+`)
+	headingPattern      = regexp.MustCompile(`(?m)^(={1,6})\s*(.+?)\s*={1,6}\s*$`)
+	templatePattern     = regexp.MustCompile(`\{\{\s*([^|{}]+?)\s*\|([^{}]*)\}\}`)
+)
+
+// Url cited in a parameter of a citation template, e.g. {{cite web|url=...}}.
+//
+//  1. URL: The cited url, as written.
+//  2. Page: Url of the citing page.
+//  3. Template: Name of the citation template.
+//  4. Parameter: Template parameter holding the url.
+//  5. Section: Heading of the page section, empty for the lead section.
+type Citation struct {
+	URL       string `json:"url"`
+	Page      string `json:"page,omitempty"`
+	Template  string `json:"template"`
+	Parameter string `json:"parameter"`
+	Section   string `json:"section,omitempty"`
+}
+
+// Targets of the [[internal]] links of wikitext, as written.
+func WikiLinks(text string) []string {
+	var links []string
+	for _, match := range wikiLinkPattern.FindAllStringSubmatch(text, -1) {
+		if target := strings.TrimSpace(match[1]); len(target) > 0 {
+			links = append(links, target)
+		}
+	}
+
+	return links
+}
+
+// Urls of the [external] and bare links of wikitext, including urls in
+// template parameters. Protocol relative [//host/path] links use https.
+func ExternalLinks(text string) []string {
+	var links []string
+	for _, match := range externalLinkPattern.FindAllString(text, -1) {
+		links = append(links, strings.TrimRight(match, ".,;:!?'\")"))
+	}
+	for _, match := range relativeLinkPattern.FindAllString(text, -1) {
+		links = append(links, "https:"+match[1:])
+	}
+
+	return links
+}
+
+// Urls in the parameters of citation templates (cite web, citation...) of
+// wikitext. Nested templates are only matched innermost.
+func Citations(text string) []Citation {
+	headings := headingPattern.FindAllStringSubmatchIndex(text, -1)
+
+	var citations []Citation
+	for _, match := range templatePattern.FindAllStringSubmatchIndex(text, -1) {
+		name := text[match[2]:match[3]]
+		lower := strings.ToLower(name)
+		if !strings.HasPrefix(lower, "cite") && !strings.HasPrefix(lower, "citation") {
+			continue
+		}
+
+		section := ""
+		for _, heading := range headings {
+			if heading[0] > match[0] {
+				break
+			}
+			section = text[heading[4]:heading[5]]
+		}
+
+		for _, param := range strings.Split(text[match[4]:match[5]], "|") {
+			split := strings.Index(param, "=")
+			if split < 0 {
+				continue
+			}
+
+			value := strings.TrimSpace(param[split+1:])
+			if !externalLinkPattern.MatchString(value) || externalLinkPattern.FindStringIndex(value)[0] != 0 {
+				continue
+			}
+
+			citations = append(citations, Citation{
+				URL:       externalLinkPattern.FindString(value),
+				Template:  name,
+				Parameter: strings.TrimSpace(param[:split]),
+				Section:   section,
+			})
+		}
+	}
+
+	return citations
+}
+
+// Wikitext of a page (action=raw), empty when it cannot be fetched.
+func (c *Crawler) pageWikitext(ctx context.Context, title string) string {
+	raw := c.TitleURL(title)
+	query := raw.Query()
+	query.Set("action", "raw")
+	raw.RawQuery = query.Encode()
+
+	page, err := (&HTTPFetcher{Client: c.Client}).FetchContext(ctx, linkFromURL(raw))
+	if err != nil || page.StatusCode != 200 {
+		c.logger().WithFields(log.Fields{
+			"title": title,
+			"err":   err,
+		}).Warn("Fetching wikitext failed")
+		return ""
+	}
+
+	return string(page.Body)
+}
+
+// Links found in wikitext, catching links that rendered HTML omits such as
+// collapsed sections or conditional templates.
+func (c *Crawler) wikitextLinks(text string) []string {
+	links := ExternalLinks(text)
+	for _, target := range WikiLinks(text) {
+		if !isInterwiki(target) {
+			links = append(links, c.TitleURL(target).String())
+		}
+	}
+
+	return links
+}
+
+// Records the citations of wikitext on the PageInfo of the cited urls.
+func (c *Crawler) recordCitations(result *CrawlResult, source Link, text string) {
+	for _, citation := range Citations(text) {
+		cited, err := url.Parse(citation.URL)
+		if err != nil {
+			continue
+		}
+		cited.Fragment = ""
+
+		citation.Page = source.String()
+		result.record(c.child(cited, source), func(info *PageInfo) {
+			info.AddCitation(citation)
+		})
+	}
+}
+
+// Canonical form of a page title: underscores instead of spaces, upper
+// case first letter and no leading colon.
+func NormalizeTitle(title string) string {
+	title = strings.TrimPrefix(strings.TrimSpace(title), ":")
+	title = strings.Join(strings.FieldsFunc(title, func(r rune) bool {
+		return r == ' ' || r == '_'
+	}), "_")
+
+	first, size := utf8.DecodeRuneInString(title)
+	if size == 0 {
+		return ""
+	}
+
+	return string(unicode.ToUpper(first)) + title[size:]
+}
+
+// Reports if a link target points to another wiki (e.g. [[wikipedia:Page]]
+// or [[de:Seite]]), interwiki prefixes being lower case.
+func isInterwiki(target string) bool {
+	split := strings.Index(target, ":")
+	if split <= 0 {
+		return false
+	}
+
+	for _, r := range target[:split] {
+		if !unicode.IsLower(r) && r != '-' {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,116 @@
+package wikicrawl
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestWikiLinks(t *testing.T) {
+	t.Run("Extract wikitext links", func(t *testing.T) {
+		t.Run("Internal links", func(t *testing.T) {
+			t.Parallel()
+			text := "See [[Main Page]], [[Help:Contents|help]] and [[Install#Linux]]. [[ ]]"
+			found := WikiLinks(text)
+			expected := []string{"Main Page", "Help:Contents", "Install"}
+			if !reflect.DeepEqual(found, expected) {
+				t.Errorf("Wiki links mismatch, got: %v, want: %v.", found, expected)
+			}
+		})
+
+		t.Run("External links", func(t *testing.T) {
+			t.Parallel()
+			text := "Visit [http://a.com/docs the docs], https://b.com/page. {{cite web|url=http://c.com/x|title=C}} [//d.com/y]"
+			found := ExternalLinks(text)
+			expected := []string{"http://a.com/docs", "https://b.com/page", "http://c.com/x", "https://d.com/y"}
+			if !reflect.DeepEqual(found, expected) {
+				t.Errorf("External links mismatch, got: %v, want: %v.", found, expected)
+			}
+		})
+	})
+}
+
+func TestCrawlWikitext(t *testing.T) {
+	t.Run("Follow links only present in wikitext", func(t *testing.T) {
+		t.Parallel()
+		server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			if req.URL.Query().Get("action") == "raw" && req.URL.Query().Get("title") == "Main" {
+				fmt.Fprint(rw, "{{#if: x | [[Hidden page]] }}")
+				return
+			}
+			fmt.Fprint(rw, `<html><body>No links</body></html>`)
+		}))
+		defer server.Close()
+
+		c := NewCrawler(server.URL+"/index.php", WithWikitext())
+		result := c.Crawl(server.URL + "/index.php?title=Main")
+		if !result.Visited.Contains(c.TitleURL("Hidden_page").String()) {
+			t.Errorf("Wikitext link not followed, got: %v.", result.Visited.Keys())
+		}
+	})
+}
+
+func TestCitations(t *testing.T) {
+	t.Run("Citation template urls with sections", func(t *testing.T) {
+		t.Parallel()
+		text := `Lead {{Cite web|url=http://a.com/lead|title=A}}
+== Install ==
+See {{cite web | title = B | url = https://b.com/x | archive-url = http://archive.org/b }}
+{{Infobox|website=http://c.com}}`
+		found := Citations(text)
+		expected := []Citation{
+			{URL: "http://a.com/lead", Template: "Cite web", Parameter: "url"},
+			{URL: "https://b.com/x", Template: "cite web", Parameter: "url", Section: "Install"},
+			{URL: "http://archive.org/b", Template: "cite web", Parameter: "archive-url", Section: "Install"},
+		}
+		if !reflect.DeepEqual(found, expected) {
+			t.Errorf("Citations mismatch, got: %v, want: %v.", found, expected)
+		}
+	})
+
+	t.Run("Report dead citations of crawled wikitext", func(t *testing.T) {
+		t.Parallel()
+		external := httptest.NewServer(http.NotFoundHandler())
+		defer external.Close()
+		server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			if req.URL.Query().Get("action") == "raw" {
+				fmt.Fprintf(rw, "== Sources ==\n{{cite web|url=%s/dead}}", external.URL)
+				return
+			}
+			fmt.Fprint(rw, `<html><body>No links</body></html>`)
+		}))
+		defer server.Close()
+
+		c := NewCrawler(server.URL+"/index.php", WithWikitext(), WithExternalLinks())
+		result := c.Crawl(server.URL + "/index.php?title=Main")
+		found := result.DeadCitations()
+		expected := []Citation{{
+			URL:       external.URL + "/dead",
+			Page:      server.URL + "/index.php?title=Main",
+			Template:  "cite web",
+			Parameter: "url",
+			Section:   "Sources",
+		}}
+		if !reflect.DeepEqual(found, expected) {
+			t.Errorf("Dead citations mismatch, got: %v, want: %v.", found, expected)
+		}
+	})
+}
+
+func TestNormalizeTitle(t *testing.T) {
+	t.Run("Canonical page titles", func(t *testing.T) {
+		t.Parallel()
+		for title, expected := range map[string]string{
+			"main page":        "Main_page",
+			" :Category:Tools": "Category:Tools",
+			"Help__Contents":   "Help_Contents",
+			"":                 "",
+		} {
+			if found := NormalizeTitle(title); found != expected {
+				t.Errorf("Title mismatch, got: %s, want: %s.", found, expected)
+			}
+		}
+	})
+}
@@ -1,52 +1,315 @@
 package wikicrawl
 
 import (
+	"context"
 	"sync"
+	"sync/atomic"
 	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type WorkQueue struct {
-	crawler Crawler
-	wait    sync.WaitGroup
-	todo    chan Link
-	Result  *CrawlResult
+	crawler  Crawler
+	wait     sync.WaitGroup
+	todo     chan Link
+	stop     chan struct{}
+	abort    sync.Once
+	forward  func(Link)
+	ctx      context.Context
+	span     trace.Span
+	metrics  *queueMetrics
+	watchdog *watchdog
+	capped   atomic.Bool
+	finished atomic.Bool
+	reserved LinkSet
+	overflow overflow
+	Result   *CrawlResult
+
+	// Interval between samples of the queue depth.
+	SampleInterval time.Duration
+}
+
+// Links waiting for room in a full queue, in the order they were added.
+// Workers queue the links they find, blocking them on a full queue would
+// have them wait on each other forever.
+type overflow struct {
+	sync.Mutex
+	links []overflowLink
+}
+
+type overflowLink struct {
+	link Link
+	at   time.Time
+}
+
+// Panic of the bookkeeping of a queue, never recovered as the failure of
+// a single link since the queue can no longer be trusted.
+type queueFailure string
+
+// Queue usage of a crawl, evidence for tuning workers and queue size.
+//
+//  1. Capacity: Links the queue holds before producers stall.
+//  2. PeakDepth: Most links waiting for a worker at once.
+//  3. ProducerStall: Total time links waited for room on a full queue.
+//  4. WorkerIdle: Total time workers waited for links, summed over workers.
+//  5. Depth: Links waiting for a worker, sampled over time.
+//  6. Dropped: Links not queued because of the memory limit.
+type QueueStats struct {
+	Capacity      int           `json:"capacity"`
+	PeakDepth     int           `json:"peakDepth"`
+	ProducerStall time.Duration `json:"producerStall"`
+	WorkerIdle    time.Duration `json:"workerIdle"`
+	Depth         []QueueSample `json:"depth,omitempty"`
+	Dropped       int           `json:"dropped,omitempty"`
+}
+
+// Queue depth at some time after the crawl started.
+type QueueSample struct {
+	Elapsed time.Duration `json:"elapsed"`
+	Depth   int           `json:"depth"`
+}
+
+// Stats of two queues working side by side, every measure adding up.
+// Depth samples of separate queues do not line up and are dropped.
+func (qs QueueStats) add(other QueueStats) QueueStats {
+	qs.Capacity += other.Capacity
+	qs.PeakDepth += other.PeakDepth
+	qs.ProducerStall += other.ProducerStall
+	qs.WorkerIdle += other.WorkerIdle
+	qs.Dropped += other.Dropped
+	qs.Depth = nil
+
+	return qs
+}
+
+type queueMetrics struct {
+	sync.Mutex
+	started  time.Time
+	stats    QueueStats
+	done     chan struct{}
+	followed int
+	progress []progressSample
+}
+
+func (qm *queueMetrics) record(update func(stats *QueueStats)) {
+	qm.Lock()
+	defer qm.Unlock()
+	update(&qm.stats)
 }
 
 func (wq *WorkQueue) AddWork(href Link) {
+	if wq.Aborted() {
+		return
+	}
+	if wq.finished.Load() {
+		panic(queueFailure("work added to a finished queue: " + href.String()))
+	}
+
+	if wq.capped.Load() {
+		wq.metrics.record(func(stats *QueueStats) {
+			stats.Dropped++
+		})
+		return
+	}
+
+	// Distributed workers hand discovered links to the coordinator.
+	if wq.forward != nil {
+		wq.forward(href)
+		return
+	}
+
+	// Every page enters the queue at most once, as identified by its key.
+	if ok := wq.reserved.Add(href); !ok {
+		return
+	}
+
 	wq.wait.Add(1)
-	for {
+	wq.overflow.Lock()
+	defer wq.overflow.Unlock()
+	if len(wq.overflow.links) == 0 {
 		select {
 		case wq.todo <- href:
+			wq.observe()
+			return
+		default:
+		}
+	}
+
+	wq.overflow.links = append(wq.overflow.links, overflowLink{link: href, at: time.Now()})
+	if len(wq.overflow.links) == 1 {
+		go wq.feed()
+	}
+}
+
+// Moves overflowing links into the queue as workers make room, until none
+// are left. A link leaves the overflow once queued so later links keep
+// waiting behind it.
+func (wq *WorkQueue) feed() {
+	for {
+		wq.overflow.Lock()
+		next := wq.overflow.links[0]
+		wq.overflow.Unlock()
+
+		wq.todo <- next.link
+		wq.observe()
+		wq.metrics.record(func(stats *QueueStats) {
+			stats.ProducerStall += time.Since(next.at)
+		})
+
+		wq.overflow.Lock()
+		wq.overflow.links = wq.overflow.links[1:]
+		empty := len(wq.overflow.links) == 0
+		wq.overflow.Unlock()
+		if empty {
 			return
-		case <-time.After(5 * time.Second):
-			panic("Queue full")
 		}
 	}
 }
 
+// Tracks the peak depth of the queue.
+func (wq *WorkQueue) observe() {
+	depth := len(wq.todo)
+	wq.metrics.record(func(stats *QueueStats) {
+		if depth > stats.PeakDepth {
+			stats.PeakDepth = depth
+		}
+	})
+}
+
+// Samples the queue depth until the queue is done.
+func (wq *WorkQueue) sample() {
+	ticker := time.NewTicker(wq.SampleInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			wq.addSample()
+		case <-wq.metrics.done:
+			return
+		}
+	}
+}
+
+func (wq *WorkQueue) addSample() {
+	sample := QueueSample{Elapsed: time.Since(wq.metrics.started), Depth: len(wq.todo)}
+	wq.metrics.record(func(stats *QueueStats) {
+		stats.Depth = append(stats.Depth, sample)
+	})
+}
+
+// Queue usage so far.
+func (wq *WorkQueue) Stats() QueueStats {
+	wq.metrics.Lock()
+	defer wq.metrics.Unlock()
+
+	stats := wq.metrics.stats
+	stats.Capacity = cap(wq.todo)
+	stats.Depth = append([]QueueSample(nil), stats.Depth...)
+	return stats
+}
+
 func (wq *WorkQueue) Start(pool int) {
+	wq.metrics.started = time.Now()
+	if wq.SampleInterval > 0 {
+		go wq.sample()
+	}
+
 	for i := 0; i < pool; i++ {
 		go func() {
+			idle := time.Now()
 			for work := range wq.todo {
+				waited := time.Since(idle)
+				wq.metrics.record(func(stats *QueueStats) {
+					stats.WorkerIdle += waited
+				})
+
 				func() {
 					defer wq.wait.Done()
-					wq.crawler.FollowLink(work, wq)
+					defer wq.watchdog.touch()
+					if !wq.Aborted() {
+						wq.watchdog.inFlight.Add(work)
+						defer wq.watchdog.inFlight.Remove(work.String())
+						wq.crawler.followIsolated(work, wq)
+					}
+					wq.metrics.Lock()
+					wq.metrics.followed++
+					wq.metrics.Unlock()
 				}()
+				idle = time.Now()
 			}
 		}()
 	}
 }
 
+// Stops the crawl, remaining queued work is drained without being followed.
+func (wq *WorkQueue) Abort() {
+	wq.abort.Do(func() {
+		wq.Result.Aborted = true
+		close(wq.stop)
+	})
+}
+
+func (wq *WorkQueue) Aborted() bool {
+	select {
+	case <-wq.stop:
+		return true
+	default:
+		return false
+	}
+}
+
+// Number of links waiting for a worker.
+func (wq *WorkQueue) Pending() int {
+	wq.overflow.Lock()
+	defer wq.overflow.Unlock()
+	return len(wq.todo) + len(wq.overflow.links)
+}
+
 func (wq *WorkQueue) Wait() {
-	wq.wait.Wait()
-	close(wq.todo)
+	done := make(chan struct{})
+	go func() {
+		wq.wait.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		wq.finished.Store(true)
+		close(wq.todo)
+	case <-wq.watchdog.stalled:
+		// Stuck workers may still hold work, leave the queue open.
+	}
+	close(wq.metrics.done)
+	wq.addSample()
+	wq.Result.Metadata.Finished = time.Now()
+	wq.Result.Queue = wq.Stats()
+	if wq.Result.Queue.Dropped > 0 {
+		wq.Result.Truncated = true
+	}
+
+	if wq.span != nil {
+		wq.span.SetAttributes(
+			attribute.Int("wikicrawl.visited", wq.Result.Visited.Len()),
+			attribute.Int("wikicrawl.broken", wq.Result.Broken.Len()),
+			attribute.Bool("wikicrawl.aborted", wq.Aborted()),
+		)
+		wq.span.End()
+	}
 }
 
 func NewWorkQueue(crawler Crawler, limit int) *WorkQueue {
 	queue := new(WorkQueue)
 	queue.crawler = crawler
 	queue.todo = make(chan Link, limit)
-	queue.Result = &CrawlResult{Visited: NewLinkSet(), Broken: NewLinkSet()}
+	queue.stop = make(chan struct{})
+	queue.ctx = context.Background()
+	queue.metrics = &queueMetrics{started: time.Now(), done: make(chan struct{})}
+	queue.SampleInterval = time.Second
+	queue.watchdog = newWatchdog()
+	queue.reserved = NewSet(crawler.pageKey())
+	queue.Result = NewCrawlResult()
 
 	return queue
 }
@@ -1 +1,164 @@
 package wikicrawl
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWorkQueue(t *testing.T) {
+	t.Run("Abort work queue", func(t *testing.T) {
+		t.Run("Drop queued and new work", func(t *testing.T) {
+			t.Parallel()
+			queue := NewWorkQueue(*NewCrawler("http://testing.com"), 10)
+			queue.AddWork(NewLink("http://testing.com/queued"))
+			queue.Abort()
+			queue.Abort()
+			queue.AddWork(NewLink("http://testing.com/late"))
+			queue.Start(1)
+			queue.Wait()
+
+			if !queue.Result.Aborted {
+				t.Errorf("Aborted queue should be reported in result.")
+			}
+
+			if len(queue.Result.Visited.Set) != 0 {
+				t.Errorf("Aborted queue should not follow links, got: %v.", queue.Result.Visited.Set)
+			}
+		})
+	})
+
+	t.Run("Queue links once", func(t *testing.T) {
+		t.Parallel()
+		queue := NewWorkQueue(*NewCrawler("http://testing.com"), 10)
+		queue.AddWork(NewLink("http://testing.com/page"))
+		queue.AddWork(NewLink("http://testing.com/page"))
+		queue.AddWork(NewLink("http://testing.com/other"))
+
+		if queue.Pending() != 2 {
+			t.Errorf("Pending links mismatch, got: %v, want: %v.", queue.Pending(), 2)
+		}
+		queue.Abort()
+		queue.Start(1)
+		queue.Wait()
+	})
+
+	t.Run("Queue stats", func(t *testing.T) {
+		t.Parallel()
+		server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			fmt.Fprint(rw, `<html><body>No links</body></html>`)
+		}))
+		defer server.Close()
+
+		queue := NewWorkQueue(*NewCrawler(server.URL), 2)
+		queue.AddWork(NewLink(server.URL + "/a"))
+		queue.AddWork(NewLink(server.URL + "/b"))
+
+		// The third link waits until a worker takes one.
+		go func() {
+			time.Sleep(20 * time.Millisecond)
+			queue.Start(1)
+		}()
+		queue.AddWork(NewLink(server.URL + "/c"))
+		queue.Wait()
+
+		stats := queue.Result.Queue
+		if stats.Capacity != 2 {
+			t.Errorf("Capacity mismatch, got: %v, want: %v.", stats.Capacity, 2)
+		}
+		if stats.PeakDepth != 2 {
+			t.Errorf("Peak depth mismatch, got: %v, want: %v.", stats.PeakDepth, 2)
+		}
+		if stats.ProducerStall < 20*time.Millisecond {
+			t.Errorf("Producer stall too short, got: %v.", stats.ProducerStall)
+		}
+		if len(stats.Depth) == 0 || stats.Depth[len(stats.Depth)-1].Depth != 0 {
+			t.Errorf("Final depth sample should be empty, got: %v.", stats.Depth)
+		}
+	})
+
+	t.Run("Queue links overflowing a full queue", func(t *testing.T) {
+		t.Parallel()
+		server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			if req.URL.Path == "/" {
+				fmt.Fprint(rw, `<a href="/1">1</a><a href="/2">2</a><a href="/3">3</a><a href="/4">4</a><a href="/5">5</a>`)
+				return
+			}
+			fmt.Fprint(rw, `<html><body>No links</body></html>`)
+		}))
+		defer server.Close()
+
+		// The only worker queues the links of the root page itself.
+		queue := NewWorkQueue(*NewCrawler(server.URL, WithConcurrency(1), WithQueueSize(1)), 1)
+		queue.AddWork(NewLink(server.URL + "/"))
+		queue.Start(1)
+
+		done := make(chan struct{})
+		go func() {
+			queue.Wait()
+			close(done)
+		}()
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatal("Crawl with a full queue hung.")
+		}
+
+		if visited := queue.Result.Visited.Len(); visited != 6 || queue.Result.Queue.Dropped != 0 {
+			t.Errorf("Visited mismatch, got: %d visited and %d dropped, want: 6 visited.", visited, queue.Result.Queue.Dropped)
+		}
+	})
+
+	t.Run("Abort stalled crawl", func(t *testing.T) {
+		t.Parallel()
+		release := make(chan struct{})
+		server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			if req.URL.Path == "/stuck" {
+				<-release
+			}
+			fmt.Fprint(rw, `<html><body><a href="/stuck">Stuck</a></body></html>`)
+		}))
+		defer server.Close()
+		defer close(release)
+
+		c := NewCrawler(server.URL, WithStallTimeout(50*time.Millisecond))
+		c.Client.Timeout = 0
+		result := c.Crawl(server.URL + "/")
+
+		if !result.Aborted || result.Stall == nil {
+			t.Fatalf("Stalled crawl should be aborted with diagnostics.")
+		}
+		if expected := []string{server.URL + "/stuck"}; !reflect.DeepEqual(result.Stall.InFlight, expected) {
+			t.Errorf("In flight mismatch, got: %v, want: %v.", result.Stall.InFlight, expected)
+		}
+		if !strings.Contains(result.Stall.Stacks, "goroutine") {
+			t.Errorf("Stack dump missing, got: %q.", result.Stall.Stacks)
+		}
+	})
+
+	t.Run("Stop queueing above memory limit", func(t *testing.T) {
+		t.Parallel()
+		queue := NewWorkQueue(*NewCrawler("http://testing.com"), 10)
+		if !queue.checkMemory(1) {
+			t.Fatalf("Heap should be above a one byte limit.")
+		}
+
+		queue.AddWork(NewLink("http://testing.com/dropped"))
+		if queue.Pending() != 0 || queue.Stats().Dropped != 1 {
+			t.Errorf("Link should be dropped, got pending: %d, dropped: %d.", queue.Pending(), queue.Stats().Dropped)
+		}
+		queue.Start(1)
+		queue.Wait()
+		if !queue.Result.Truncated || queue.Result.Severity() != SeverityCritical {
+			t.Errorf("Result should be truncated, got: %t, severity %s.", queue.Result.Truncated, queue.Result.Severity())
+		}
+
+		if NewWorkQueue(*NewCrawler("http://testing.com"), 10).checkMemory(1 << 62) {
+			t.Errorf("Heap should be below the limit.")
+		}
+	})
+}